@@ -12,6 +12,11 @@ import (
 // NewAccessTokenConnector creates a new connector from a DSN and a token provider.
 // The token provider func will be called when a new connection is requested and should return a valid access token.
 // The returned connector may be used with sql.OpenDB.
+//
+// The login sends the token instead of a user name and password, so the DSN
+// must not set user id or password. It should set the database, which Azure
+// SQL Database opens at login: a token for a contained database user cannot
+// log in to master.
 func NewAccessTokenConnector(dsn string, tokenProvider func() (string, error)) (driver.Connector, error) {
 	if tokenProvider == nil {
 		return nil, errors.New("mssql: tokenProvider cannot be nil")
@@ -21,6 +26,9 @@ func NewAccessTokenConnector(dsn string, tokenProvider func() (string, error)) (
 	if err != nil {
 		return nil, err
 	}
+	if err = checkTokenConfig(conn.params); err != nil {
+		return nil, err
+	}
 
 	conn.fedAuthRequired = true
 	conn.fedAuthLibrary = FedAuthLibrarySecurityToken
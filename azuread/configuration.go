@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
@@ -73,6 +74,27 @@ type azureFedAuthConfig struct {
 	disableInstanceDiscovery   bool     // For most credential types
 	tokenFilePath              string   // For WorkloadIdentity
 	sendCertificateChain       bool     // For ClientCertificate
+
+	// For DeviceCode
+	deviceCodePrompt func(context.Context, DeviceCodeMessage) error
+
+	// credMu guards credentials, which all connections share so tokens
+	// are cached and refreshed rather than requested for each connection,
+	// and interactive users sign in once. They are keyed by STS URL.
+	credMu      sync.Mutex
+	credentials map[string]azcore.TokenCredential
+
+	// transport replaces the HTTP client of the credentials in tests
+	transport policy.Transporter
+}
+
+// DeviceCodeMessage holds the instructions given to the user during
+// ActiveDirectoryDeviceCode authentication: open VerificationURL in a
+// browser and enter UserCode. Message combines both in a sentence.
+type DeviceCodeMessage struct {
+	UserCode        string
+	VerificationURL string
+	Message         string
 }
 
 // parse returns a config based on an msdsn-style connection string
@@ -313,18 +335,50 @@ func splitAuthorityAndTenant(authorityURL string) (string, string) {
 }
 
 func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, serverSPN, stsURL string) (string, error) {
-	var cred azcore.TokenCredential
-	var err error
+	if p.fedAuthWorkflow == ActiveDirectoryServicePrincipalAccessToken {
+		return p.password, nil
+	}
+	scope := serverSPN
+	if !strings.HasSuffix(serverSPN, scopeDefaultSuffix) {
+		scope = serverSPN + scopeDefaultSuffix
+	}
+	cred, err := p.credential(stsURL)
+	if err != nil {
+		return "", err
+	}
+	opts := policy.TokenRequestOptions{Scopes: []string{scope}}
+	tk, err := cred.GetToken(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	return tk.Token, err
+}
+
+// credential returns the credential for the STS URL sent by the server,
+// creating it on first use.
+func (p *azureFedAuthConfig) credential(stsURL string) (azcore.TokenCredential, error) {
+	p.credMu.Lock()
+	defer p.credMu.Unlock()
+	if cred, ok := p.credentials[stsURL]; ok {
+		return cred, nil
+	}
 	authority, tenant := splitAuthorityAndTenant(stsURL)
 	// client secret connection strings may override the server tenant
 	if p.tenantID != "" {
 		tenant = p.tenantID
 	}
-	scope := serverSPN
-	if !strings.HasSuffix(serverSPN, scopeDefaultSuffix) {
-		scope = serverSPN + scopeDefaultSuffix
+	cred, err := p.newCredential(authority, tenant)
+	if err != nil {
+		return nil, err
 	}
+	if p.credentials == nil {
+		p.credentials = make(map[string]azcore.TokenCredential)
+	}
+	p.credentials[stsURL] = cred
+	return cred, nil
+}
 
+func (p *azureFedAuthConfig) newCredential(authority, tenant string) (cred azcore.TokenCredential, err error) {
 	switch p.fedAuthWorkflow {
 	case ActiveDirectoryServicePrincipal, ActiveDirectoryApplication:
 		switch {
@@ -337,6 +391,7 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 				certs, key, err = azidentity.ParseCertificates(certData, []byte(p.clientSecret))
 				if err == nil {
 					options := &azidentity.ClientCertificateCredentialOptions{
+						ClientOptions:              azcore.ClientOptions{Transport: p.transport},
 						AdditionallyAllowedTenants: p.additionallyAllowedTenants,
 						DisableInstanceDiscovery:   p.disableInstanceDiscovery,
 						SendCertificateChain:       p.sendCertificateChain,
@@ -346,13 +401,12 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 			}
 		default:
 			options := &azidentity.ClientSecretCredentialOptions{
+				ClientOptions:              azcore.ClientOptions{Transport: p.transport},
 				AdditionallyAllowedTenants: p.additionallyAllowedTenants,
 				DisableInstanceDiscovery:   p.disableInstanceDiscovery,
 			}
 			cred, err = azidentity.NewClientSecretCredential(tenant, p.clientID, p.clientSecret, options)
 		}
-	case ActiveDirectoryServicePrincipalAccessToken:
-		return p.password, nil
 	case ActiveDirectoryPassword:
 		options := &azidentity.UsernamePasswordCredentialOptions{
 			AdditionallyAllowedTenants: p.additionallyAllowedTenants,
@@ -380,10 +434,24 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 
 	case ActiveDirectoryDeviceCode:
 		options := &azidentity.DeviceCodeCredentialOptions{
+			ClientOptions: azcore.ClientOptions{
+				Cloud:     cloud.Configuration{ActiveDirectoryAuthorityHost: authority},
+				Transport: p.transport,
+			},
 			ClientID:                   p.applicationClientID,
+			TenantID:                   tenant,
 			AdditionallyAllowedTenants: p.additionallyAllowedTenants,
 			DisableInstanceDiscovery:   p.disableInstanceDiscovery,
 		}
+		if p.deviceCodePrompt != nil {
+			options.UserPrompt = func(ctx context.Context, m azidentity.DeviceCodeMessage) error {
+				return p.deviceCodePrompt(ctx, DeviceCodeMessage{
+					UserCode:        m.UserCode,
+					VerificationURL: m.VerificationURL,
+					Message:         m.Message,
+				})
+			}
+		}
 		cred, err = azidentity.NewDeviceCodeCredential(options)
 	case ActiveDirectoryAzCli:
 		options := &azidentity.AzureCLICredentialOptions{
@@ -470,14 +538,5 @@ func (p *azureFedAuthConfig) provideActiveDirectoryToken(ctx context.Context, se
 		}
 		cred, err = azidentity.NewDefaultAzureCredential(options)
 	}
-
-	if err != nil {
-		return "", err
-	}
-	opts := policy.TokenRequestOptions{Scopes: []string{scope}}
-	tk, err := cred.GetToken(ctx, opts)
-	if err != nil {
-		return "", err
-	}
-	return tk.Token, err
+	return cred, err
 }
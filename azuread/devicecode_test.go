@@ -0,0 +1,117 @@
+//go:build go1.18
+// +build go1.18
+
+package azuread
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthority stubs the endpoints of the device code flow of a tenant.
+type fakeAuthority struct {
+	mu           sync.Mutex
+	deviceCodes  int
+	tokenQueries int
+	pending      int // authorization_pending answers before the token
+}
+
+func (a *fakeAuthority) Do(req *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	const base = "https://login.example.com/tenant"
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/.well-known/openid-configuration"):
+		return jsonResponse(req, http.StatusOK, `{
+			"authorization_endpoint": "`+base+`/oauth2/v2.0/authorize",
+			"token_endpoint": "`+base+`/oauth2/v2.0/token",
+			"device_authorization_endpoint": "`+base+`/oauth2/v2.0/devicecode",
+			"issuer": "`+base+`/v2.0"}`), nil
+	case strings.HasSuffix(req.URL.Path, "/devicecode"):
+		a.deviceCodes++
+		return jsonResponse(req, http.StatusOK, `{
+			"user_code": "ABCD-1234",
+			"device_code": "device-code",
+			"verification_uri": "https://login.example.com/device",
+			"expires_in": 900,
+			"interval": 0,
+			"message": "To sign in, enter ABCD-1234 at https://login.example.com/device"}`), nil
+	case strings.HasSuffix(req.URL.Path, "/token"):
+		a.tokenQueries++
+		body, _ := io.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		if form.Get("device_code") != "device-code" {
+			return jsonResponse(req, http.StatusBadRequest, `{"error": "invalid_grant"}`), nil
+		}
+		if a.pending > 0 {
+			a.pending--
+			return jsonResponse(req, http.StatusBadRequest, `{"error": "authorization_pending"}`), nil
+		}
+		clientInfo := base64.RawURLEncoding.EncodeToString([]byte(`{"uid":"user","utid":"tenant"}`))
+		idToken := "e30." + base64.RawURLEncoding.EncodeToString([]byte(
+			`{"aud":"client","iss":"`+base+`/v2.0","oid":"user","sub":"user","tid":"tenant","preferred_username":"user@example.com","exp":9999999999}`)) + ".sig"
+		return jsonResponse(req, http.StatusOK, `{
+			"token_type": "Bearer",
+			"scope": "https://database.windows.net//.default",
+			"expires_in": 3600,
+			"access_token": "device-token",
+			"refresh_token": "refresh-token",
+			"id_token": "`+idToken+`",
+			"client_info": "`+clientInfo+`"}`), nil
+	}
+	return jsonResponse(req, http.StatusNotFound, `{}`), nil
+}
+
+func jsonResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestDeviceCodeTokenIsCached(t *testing.T) {
+	dsn := "sqlserver://someserver.database.windows.net?fedauth=ActiveDirectoryDeviceCode&applicationclientid=client&disableinstancediscovery=true"
+	config, err := parse(dsn)
+	require.NoError(t, err)
+	authority := &fakeAuthority{pending: 1}
+	config.transport = authority
+	var prompts []DeviceCodeMessage
+	config.deviceCodePrompt = func(ctx context.Context, m DeviceCodeMessage) error {
+		prompts = append(prompts, m)
+		return nil
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		token, err := config.provideActiveDirectoryToken(ctx, "https://database.windows.net/", "https://login.example.com/tenant")
+		require.NoError(t, err, "connection %d", i+1)
+		assert.Equal(t, "device-token", token)
+	}
+
+	require.Len(t, prompts, 1, "the user signs in once")
+	assert.Equal(t, DeviceCodeMessage{
+		UserCode:        "ABCD-1234",
+		VerificationURL: "https://login.example.com/device",
+		Message:         "To sign in, enter ABCD-1234 at https://login.example.com/device",
+	}, prompts[0])
+	assert.Equal(t, 1, authority.deviceCodes)
+	assert.Equal(t, 2, authority.tokenQueries, "one pending poll, then the token")
+}
+
+func TestNewConnectorWithDeviceCodePrompt(t *testing.T) {
+	_, err := NewConnectorWithDeviceCodePrompt("sqlserver://someserver?fedauth=ActiveDirectoryDeviceCode", func(context.Context, DeviceCodeMessage) error { return nil })
+	require.NoError(t, err)
+	_, err = NewConnectorWithDeviceCodePrompt("sqlserver://someserver?fedauth=Unknown", nil)
+	assert.Error(t, err)
+}
@@ -43,6 +43,20 @@ func NewConnector(dsn string) (*mssql.Connector, error) {
 	return newConnectorConfig(config)
 }
 
+// NewConnectorWithDeviceCodePrompt creates a new connector from a DSN like
+// NewConnector. With fedauth=ActiveDirectoryDeviceCode, prompt is called
+// with the code the user must enter instead of printing it to stdout.
+// The user signs in once per connector: later connections use the cached
+// token, which is refreshed when it expires.
+func NewConnectorWithDeviceCodePrompt(dsn string, prompt func(context.Context, DeviceCodeMessage) error) (*mssql.Connector, error) {
+	config, err := parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.deviceCodePrompt = prompt
+	return newConnectorConfig(config)
+}
+
 // newConnectorConfig creates a Connector from config.
 func newConnectorConfig(config *azureFedAuthConfig) (*mssql.Connector, error) {
 	switch config.fedAuthLibrary {
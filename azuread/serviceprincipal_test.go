@@ -0,0 +1,108 @@
+//go:build go1.18
+// +build go1.18
+
+package azuread
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clientCredentialsAuthority stubs the public cloud token endpoint of the OAuth2
+// client credentials flow and records the token requests.
+type clientCredentialsAuthority struct {
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func (a *clientCredentialsAuthority) Do(req *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	const base = "https://login.microsoftonline.com/my-tenant-id"
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/.well-known/openid-configuration"):
+		return jsonResponse(req, http.StatusOK, `{
+			"authorization_endpoint": "`+base+`/oauth2/v2.0/authorize",
+			"token_endpoint": "`+base+`/oauth2/v2.0/token",
+			"issuer": "`+base+`/v2.0"}`), nil
+	case strings.HasSuffix(req.URL.Path, "/token"):
+		body, _ := io.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		a.requests = append(a.requests, form)
+		if form.Get("grant_type") != "client_credentials" || form.Get("client_id") != "my-app-id" {
+			return jsonResponse(req, http.StatusBadRequest, `{"error": "invalid_client"}`), nil
+		}
+		return jsonResponse(req, http.StatusOK, `{
+			"token_type": "Bearer",
+			"expires_in": 3600,
+			"access_token": "app-token"}`), nil
+	}
+	return jsonResponse(req, http.StatusNotFound, `{}`), nil
+}
+
+// writeTestCertificate writes a self-signed certificate and its key as PEM.
+func writeTestCertificate(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "my-app"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	path := filepath.Join(t.TempDir(), "app.pem")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestServicePrincipalTokenIsCached(t *testing.T) {
+	certPath := writeTestCertificate(t)
+	tests := []struct {
+		name       string
+		params     string
+		credential string
+	}{
+		{"secret", "password=my-secret", "client_secret"},
+		{"certificate", "clientcertpath=" + url.QueryEscape(certPath), "client_assertion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := "sqlserver://someserver.database.windows.net?fedauth=ActiveDirectoryServicePrincipal&disableinstancediscovery=true&" +
+				"user+id=" + url.QueryEscape("my-app-id@my-tenant-id") + "&" + tt.params
+			config, err := parse(dsn)
+			require.NoError(t, err)
+			authority := &clientCredentialsAuthority{}
+			config.transport = authority
+
+			for i := 0; i < 2; i++ {
+				token, err := config.provideActiveDirectoryToken(context.Background(), "https://database.windows.net/", "https://login.microsoftonline.com/my-tenant-id")
+				require.NoError(t, err, "connection %d", i+1)
+				assert.Equal(t, "app-token", token)
+			}
+			require.Len(t, authority.requests, 1, "later connections use the cached token")
+			assert.NotEmpty(t, authority.requests[0].Get(tt.credential))
+			assert.Contains(t, authority.requests[0].Get("scope"), "https://database.windows.net//.default")
+		})
+	}
+}
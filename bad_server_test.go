@@ -3,9 +3,11 @@ package mssql
 import (
 	"database/sql"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"testing"
+	"time"
 )
 
 // tests simulating bad server
@@ -30,6 +32,12 @@ func testConnectionBad(t *testing.T, connStr string) (err error) {
 }
 
 func testBadServer(t *testing.T, handler func(net.Conn)) {
+	testBadServerWithParams(t, "", handler)
+}
+
+// testBadServerWithParams connects to a server running handler and returns
+// the error of the failed query.
+func testBadServerWithParams(t *testing.T, params string, handler func(net.Conn)) error {
 	addr := &net.TCPAddr{IP: net.IP{127, 0, 0, 1}}
 	listener, err := net.ListenTCP("tcp", addr)
 	if err != nil {
@@ -48,7 +56,7 @@ func testBadServer(t *testing.T, handler func(net.Conn)) {
 	tl := testLogger{t: t}
 	defer tl.StopLogging()
 	SetLogger(&tl)
-	_ = testConnectionBad(t, fmt.Sprintf("host=%s;port=%d;log=255;protocol=tcp", addr.IP.String(), addr.Port))
+	return testConnectionBad(t, fmt.Sprintf("host=%s;port=%d;log=255;protocol=tcp;%s", addr.IP.String(), addr.Port, params))
 }
 
 func TestBadServerCloseConnection(t *testing.T) {
@@ -195,6 +203,23 @@ func TestBadServerNoLoginResponse(t *testing.T) {
 	})
 }
 
+func TestBadServerStalledReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	testBadServerWithParams(t, "read timeout=1", func(conn net.Conn) {
+		buf := newTdsBuffer(defaultPacketSize, conn)
+
+		goodPreloginSequence(t, buf)
+
+		// stop responding without closing the connection
+		<-release
+	})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("read timeout did not interrupt the stalled read, took %v", elapsed)
+	}
+}
+
 func TestBadServerIncorrectLoginResponseType(t *testing.T) {
 	testBadServer(t, func(conn net.Conn) {
 		buf := newTdsBuffer(defaultPacketSize, conn)
@@ -229,3 +254,76 @@ func TestBadServerInvalidTokenId(t *testing.T) {
 		}
 	})
 }
+
+// writeErrorToken writes an ERROR token to buf.
+func writeErrorToken(t *testing.T, buf *tdsBuffer, number int32, state uint8, message string) {
+	if _, err := buf.Write(errorToken(number, state, message)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errorToken returns an ERROR token of severity 14 from server srv.
+func errorToken(number int32, state uint8, message string) []byte {
+	msg := str2ucs2(message)
+	server := str2ucs2("srv")
+	length := 4 + 1 + 1 + 2 + len(msg) + 1 + len(server) + 1 + 4
+	b := []byte{byte(tokenError), byte(length), byte(length >> 8)}
+	b = binary.LittleEndian.AppendUint32(b, uint32(number))
+	b = append(b, state, 14)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(msg)/2))
+	b = append(b, msg...)
+	b = append(b, byte(len(server)/2))
+	b = append(b, server...)
+	b = append(b, 0)
+	return binary.LittleEndian.AppendUint32(b, 1)
+}
+
+func TestBadServerLoginFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		number int32
+		state  uint8
+		cause  LoginFailure
+		params string
+	}{
+		{"bad password", 18456, 8, LoginFailureCredentials, ""},
+		{"database unavailable", 4060, 1, LoginFailureDatabaseUnavailable, ""},
+		{"contained user password", 18456, 65, LoginFailureContainedUser, "database=sales"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testBadServerWithParams(t, tt.params, func(conn net.Conn) {
+				buf := newTdsBuffer(defaultPacketSize, conn)
+				goodPreloginSequence(t, buf)
+
+				buf.BeginPacket(packReply, false)
+				writeErrorToken(t, buf, tt.number, tt.state, "Login failed")
+				done := make([]byte, 1+2+2+8)
+				done[0] = byte(tokenDone)
+				binary.LittleEndian.PutUint16(done[1:], doneError)
+				if _, err := buf.Write(done); err != nil {
+					t.Fatal(err)
+				}
+				if err := buf.FinishPacket(); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if _, ok := err.(Error); !ok {
+				t.Fatalf("expected an Error, got %T: %v", err, err)
+			}
+			var loginErr LoginError
+			if !errors.As(err, &loginErr) {
+				t.Fatalf("expected a LoginError, got %T: %v", err, err)
+			}
+			if loginErr.Err.Number != tt.number || loginErr.Err.State != tt.state {
+				t.Errorf("got error %d state %d, expected %d state %d", loginErr.Err.Number, loginErr.Err.State, tt.number, tt.state)
+			}
+			if loginErr.Cause() != tt.cause {
+				t.Errorf("got cause %v, expected %v", loginErr.Cause(), tt.cause)
+			}
+			if tt.params != "" && loginErr.Database != "sales" {
+				t.Errorf("got database %q, expected sales", loginErr.Database)
+			}
+		})
+	}
+}
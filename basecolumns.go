@@ -0,0 +1,73 @@
+package mssql
+
+// BaseColumn describes the source of a column of a browse-mode result set.
+type BaseColumn struct {
+	// Name is the name of the result column, as returned by Rows.Columns.
+	Name string
+	// Server, Database, Schema and Table name the base table. Parts the
+	// server did not send are empty, as are all parts for expressions.
+	Server   string
+	Database string
+	Schema   string
+	Table    string
+	// Column is the name of the column in the base table. It is empty for
+	// expressions and computed columns.
+	Column string
+	// Expression is set for columns computed from an expression, which have
+	// no base table or column.
+	Expression bool
+	// Key is set for columns that are part of the key of the base table.
+	Key bool
+	// Hidden is set for key columns the server added to the result set
+	// because the query did not select them.
+	Hidden bool
+}
+
+// BaseColumns may be passed as a query argument to receive the base table
+// and column of each result column. The server only sends this information
+// for browse-mode queries, such as those ending in FOR BROWSE. It is filled
+// in no later than the first call to Rows.Next.
+//
+//	var bc mssql.BaseColumns
+//	rows, err := db.Query("select id, name as n from dbo.t for browse", &bc)
+//	...
+//	for rows.Next() {
+//		...
+//	}
+//	log.Printf("%s comes from %s.%s", bc[1].Name, bc[1].Table, bc[1].Column)
+type BaseColumns []BaseColumn
+
+// newBaseColumns combines the COLMETADATA, TABNAME and COLINFO tokens of a
+// result set.
+func newBaseColumns(columns []columnStruct, tables [][]string, info []colInfoStruct) BaseColumns {
+	res := make(BaseColumns, len(columns))
+	for i := range columns {
+		res[i].Name = columns[i].ColName
+	}
+	for _, ci := range info {
+		if ci.ColNum == 0 || int(ci.ColNum) > len(res) {
+			continue
+		}
+		bc := &res[ci.ColNum-1]
+		bc.Expression = ci.Status&colInfoExpression != 0
+		bc.Key = ci.Status&colInfoKey != 0
+		bc.Hidden = ci.Status&colInfoHidden != 0
+		if bc.Expression || ci.TableNum == 0 || int(ci.TableNum) > len(tables) {
+			continue
+		}
+		// name parts are sent most significant first, so align them on the table
+		parts := tables[ci.TableNum-1]
+		names := []*string{&bc.Server, &bc.Database, &bc.Schema, &bc.Table}
+		if len(parts) <= len(names) {
+			for j, part := range parts {
+				*names[len(names)-len(parts)+j] = part
+			}
+		}
+		if ci.Status&colInfoDifferentName != 0 {
+			bc.Column = ci.ColName
+		} else {
+			bc.Column = bc.Name
+		}
+	}
+	return res
+}
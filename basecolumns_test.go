@@ -0,0 +1,180 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browseResultStream returns the tokens of a browse-mode result set of
+//
+//	select id, name as n, id * 2 as twice from db.dbo.t for browse
+//
+// in which the server added the hidden key column k.
+func browseResultStream() []byte {
+	usVarChar := func(s string) []byte {
+		return append([]byte{byte(len(s)), 0}, str2ucs2(s)...)
+	}
+	bVarChar := func(s string) []byte {
+		return append([]byte{byte(len(s))}, str2ucs2(s)...)
+	}
+	var stream []byte
+	// COLMETADATA: four int columns
+	stream = append(stream, byte(tokenColMetadata), 0x04, 0x00)
+	for _, name := range []string{"id", "n", "twice", "k"} {
+		stream = append(stream, 0, 0, 0, 0, 0, 0, typeInt4)
+		stream = append(stream, bVarChar(name)...)
+	}
+	// TABNAME: db.dbo.t
+	tabName := []byte{3}
+	for _, part := range []string{"db", "dbo", "t"} {
+		tabName = append(tabName, usVarChar(part)...)
+	}
+	stream = append(stream, byte(tokenTabName), byte(len(tabName)), 0x00)
+	stream = append(stream, tabName...)
+	// COLINFO
+	colInfo := []byte{
+		1, 1, colInfoKey,
+		2, 1, colInfoDifferentName,
+	}
+	colInfo = append(colInfo, bVarChar("name")...)
+	colInfo = append(colInfo,
+		3, 0, colInfoExpression,
+		4, 1, colInfoKey|colInfoHidden,
+	)
+	stream = append(stream, byte(tokenColInfo), byte(len(colInfo)), 0x00)
+	stream = append(stream, colInfo...)
+	// ROW
+	stream = append(stream, byte(tokenRow))
+	for _, v := range []uint32{1, 2, 2, 9} {
+		stream = binary.LittleEndian.AppendUint32(stream, v)
+	}
+	// DONE
+	done := make([]byte, 1+2+2+8)
+	done[0] = byte(tokenDone)
+	binary.LittleEndian.PutUint16(done[1:], doneCount)
+	binary.LittleEndian.PutUint64(done[5:], 1)
+	return append(stream, done...)
+}
+
+func TestBaseColumnsFromBrowseTokens(t *testing.T) {
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(browseResultStream()))}),
+		logger: optionalLogger{},
+	}
+	var bc BaseColumns
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(context.Background(), sess, ch, outputs{baseColumns: &bc})
+	var got BaseColumns
+	rows := 0
+	for tok := range ch {
+		switch tok := tok.(type) {
+		case error:
+			t.Fatal(tok)
+		case BaseColumns:
+			got = tok
+		case []interface{}:
+			rows++
+			assert.Equal(t, []interface{}{int64(1), int64(2), int64(2), int64(9)}, tok)
+		}
+	}
+	assert.Equal(t, 1, rows, "the row following the browse tokens")
+
+	expected := BaseColumns{
+		{Name: "id", Database: "db", Schema: "dbo", Table: "t", Column: "id", Key: true},
+		{Name: "n", Database: "db", Schema: "dbo", Table: "t", Column: "name"},
+		{Name: "twice", Expression: true},
+		{Name: "k", Database: "db", Schema: "dbo", Table: "t", Column: "k", Key: true, Hidden: true},
+	}
+	assert.Equal(t, expected, got)
+}
+
+func TestBaseColumnsNotRequested(t *testing.T) {
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(browseResultStream()))}),
+		logger: optionalLogger{},
+	}
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(context.Background(), sess, ch, outputs{})
+	for tok := range ch {
+		_, ok := tok.(BaseColumns)
+		assert.False(t, ok, "BaseColumns sent without being requested")
+	}
+}
+
+func TestNewBaseColumnsOnePartTableName(t *testing.T) {
+	columns := []columnStruct{{ColName: "a"}, {ColName: "b"}}
+	info := []colInfoStruct{{ColNum: 1, TableNum: 1}, {ColNum: 5, TableNum: 1}}
+	got := newBaseColumns(columns, [][]string{{"t"}}, info)
+	assert.Equal(t, BaseColumns{{Name: "a", Table: "t", Column: "a"}, {Name: "b"}}, got)
+}
+
+func TestBaseColumnsForBrowse(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec(`if object_id('dbo.go_mssql_browse_test') is not null drop table dbo.go_mssql_browse_test;
+create table dbo.go_mssql_browse_test (id int primary key, name nvarchar(20), ts rowversion)`)
+	require.NoError(t, err)
+	defer conn.Exec("drop table dbo.go_mssql_browse_test")
+	_, err = conn.Exec("insert into dbo.go_mssql_browse_test (id, name) values (1, N'one')")
+	require.NoError(t, err)
+
+	var bc BaseColumns
+	rows, err := conn.Query("select name as n, id * 2 as twice from dbo.go_mssql_browse_test for browse", &bc)
+	require.NoError(t, err)
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	for rows.Next() {
+		dest := make([]interface{}, len(cols))
+		for i := range dest {
+			dest[i] = new(interface{})
+		}
+		require.NoError(t, rows.Scan(dest...))
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	require.Len(t, bc, len(cols))
+	assert.Equal(t, "n", bc[0].Name)
+	assert.Equal(t, "go_mssql_browse_test", bc[0].Table)
+	assert.Equal(t, "name", bc[0].Column)
+	assert.False(t, bc[0].Expression)
+	assert.True(t, bc[1].Expression, "computed column")
+	assert.Empty(t, bc[1].Column)
+	var hiddenKey bool
+	for _, c := range bc[2:] {
+		if c.Hidden && c.Key && c.Column == "id" {
+			hiddenKey = true
+		}
+	}
+	assert.True(t, hiddenKey, "the server adds the key column id: %+v", bc)
+}
+
+func TestRowsNextFillsBaseColumns(t *testing.T) {
+	conn := &Conn{sess: &tdsSession{}, connectionGood: true}
+	var bc BaseColumns
+	nv := &driver.NamedValue{Ordinal: 1, Value: &bc}
+	assert.Equal(t, driver.ErrRemoveArgument, conn.CheckNamedValue(nv))
+	require.Equal(t, &bc, conn.outs.baseColumns)
+
+	tokChan := make(chan tokenStruct, 5)
+	rows := &Rows{
+		stmt:   &Stmt{c: conn},
+		reader: &tokenProcessor{tokChan: tokChan, ctx: context.Background(), sess: conn.sess, outs: conn.outs},
+		cancel: func() {},
+	}
+	tokChan <- BaseColumns{{Name: "a", Table: "t", Column: "a"}}
+	tokChan <- doneStruct{}
+	close(tokChan)
+
+	assert.Equal(t, io.EOF, rows.Next(nil))
+	assert.Equal(t, BaseColumns{{Name: "a", Table: "t", Column: "a"}}, bc)
+}
@@ -0,0 +1,164 @@
+package mssql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnparameterizableStatement is returned when a parameter of a query is used
+// in a statement that takes no variables, such as USE or a DDL statement.
+// Queries with arguments are sent with sp_executesql, which declares the
+// parameters as variables, and such statements only accept literal names
+// and values: the server rejects them with a syntax error pointing at the
+// parameter. Write the name or value into the query text instead, quoted
+// with QuoteIdentifier or QuoteLiteral, and run the query without
+// arguments, which sends it as a batch.
+type UnparameterizableStatement struct {
+	// Statement is the keyword starting the statement, such as USE or
+	// CREATE.
+	Statement string
+	// Parameter is the parameter used in it, such as @p1.
+	Parameter string
+}
+
+func (e UnparameterizableStatement) Error() string {
+	return fmt.Sprintf("mssql: parameter %s is used in a %s statement, which takes no variables, so it cannot be passed as an argument: "+
+		"write its value into the query text, quoted with QuoteIdentifier or QuoteLiteral, and run the query without arguments", e.Parameter, e.Statement)
+}
+
+// moduleKinds are the objects whose CREATE or ALTER statement takes the
+// rest of the query as the body of the object.
+var moduleKinds = map[string]bool{
+	"FUNCTION": true, "PROC": true, "PROCEDURE": true, "TRIGGER": true, "VIEW": true,
+}
+
+// statementStarts are the keywords ending a DDL statement not followed
+// by a semicolon. WITH is not among them, as it is also part of
+// statements such as ALTER TABLE ... WITH CHECK, and some of them are only
+// when they do not continue the statement, see endsStatement.
+var statementStarts = map[string]bool{
+	"BEGIN": true, "COMMIT": true, "DECLARE": true, "DELETE": true, "EXEC": true,
+	"EXECUTE": true, "IF": true, "INSERT": true, "MERGE": true, "PRINT": true,
+	"RAISERROR": true, "RETURN": true, "ROLLBACK": true, "SELECT": true, "SET": true,
+	"THROW": true, "TRUNCATE": true, "UPDATE": true, "WAITFOR": true, "WHILE": true,
+}
+
+// endsStatement reports whether word, read after the words before and
+// beforeThat of the statement started by statement, starts a new one. IF
+// continues DROP ... IF EXISTS, SET continues ALTER statements such as
+// ALTER DATABASE ... SET, and ROLLBACK continues WITH ROLLBACK IMMEDIATE.
+func endsStatement(statement, beforeThat, before, word string) bool {
+	switch word {
+	case "IF":
+		return beforeThat != "DROP"
+	case "SET":
+		return statement != "ALTER"
+	case "ROLLBACK":
+		return before != "WITH"
+	}
+	return statementStarts[word]
+}
+
+// checkParameterizable returns an UnparameterizableStatement when query
+// uses one of the parameters of args in a USE, CREATE, ALTER or DROP
+// statement. See unparameterizableUses.
+func checkParameterizable(query string, args []namedValue) error {
+	return checkParameterUses(unparameterizableUses(query), args)
+}
+
+// checkParameterizable is checkParameterizable for the query of s, which
+// is scanned once for all its executions.
+func (s *Stmt) checkParameterizable(args []namedValue) error {
+	if s.unparameterizable == nil {
+		s.unparameterizable = unparameterizableUses(s.query)
+	}
+	return checkParameterUses(s.unparameterizable, args)
+}
+
+// checkParameterUses returns the first of uses whose parameter is one of
+// args.
+func checkParameterUses(uses []UnparameterizableStatement, args []namedValue) error {
+	if len(uses) == 0 {
+		return nil
+	}
+	params := make(map[string]bool, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			params[strings.ToLower(arg.Name)] = true
+		} else {
+			params["p"+strconv.Itoa(i+1)] = true
+		}
+	}
+	for _, use := range uses {
+		if params[strings.ToLower(use.Parameter[1:])] {
+			return use
+		}
+	}
+	return nil
+}
+
+// unparameterizableUses returns the variables query uses in a USE, CREATE,
+// ALTER or DROP statement, in their order, ignoring string literals,
+// quoted identifiers and comments, and an empty slice when there are none.
+// The body of a procedure, function, trigger or view created or altered
+// by query is part of the statement, as it cannot reference the variables
+// of sp_executesql either.
+func unparameterizableUses(query string) []UnparameterizableStatement {
+	uses := []UnparameterizableStatement{}
+	// statement is the keyword starting the USE or DDL statement read,
+	// if any, before and beforeThat the words of the statement read last,
+	// and module is set for the rest of the query once it creates or
+	// alters a module.
+	var statement, before, beforeThat string
+	var module, kindNext bool
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			i = skipUntil(query, i+1, string(c))
+		case c == '[':
+			i = skipUntil(query, i+1, "]")
+		case strings.HasPrefix(query[i:], "--"):
+			i = skipUntil(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			i = skipComment(query, i+2)
+		case c == ';':
+			if !module {
+				statement = ""
+			}
+		case c == '@' && i+1 < len(query) && query[i+1] == '@':
+			i = skipName(query, i+2) - 1
+		case c == '@':
+			end := skipName(query, i+1)
+			if statement != "" && end > i+1 {
+				uses = append(uses, UnparameterizableStatement{Statement: statement, Parameter: query[i:end]})
+			}
+			i = end - 1
+		case isNameChar(c):
+			end := skipName(query, i)
+			word := strings.ToUpper(query[i:end])
+			switch {
+			case module:
+			case kindNext:
+				// CREATE OR ALTER is followed by the kind
+				if word != "OR" && word != "ALTER" {
+					module = moduleKinds[word]
+					kindNext = false
+				}
+			case statement != "":
+				// ALTER TABLE ... DROP is one statement
+				if endsStatement(statement, beforeThat, before, word) {
+					statement = ""
+				}
+			case word == "USE" || word == "DROP":
+				statement = word
+			case word == "CREATE" || word == "ALTER":
+				statement = word
+				kindNext = true
+			}
+			beforeThat, before = before, word
+			i = end - 1
+		}
+	}
+	return uses
+}
@@ -0,0 +1,92 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckParameterizable(t *testing.T) {
+	ordinal := []namedValue{{}, {}}
+	named := []namedValue{{Name: "db"}}
+	tests := []struct {
+		query string
+		args  []namedValue
+		want  error
+	}{
+		{"select @p1, @p2", ordinal, nil},
+		{"use @p1", ordinal, UnparameterizableStatement{"USE", "@p1"}},
+		{"USE @DB", named, UnparameterizableStatement{"USE", "@DB"}},
+		{"use mydb; select @p1", ordinal, nil},
+		{"use mydb select @p1", ordinal, nil},
+		{"create table #t (c int default @p2)", ordinal, UnparameterizableStatement{"CREATE", "@p2"}},
+		{"create table #t (c int) insert #t values (@p1)", ordinal, nil},
+		{"alter table t add constraint df default @p1 for c", ordinal, UnparameterizableStatement{"ALTER", "@p1"}},
+		{"alter database current set recovery simple; select @p1", ordinal, nil},
+		{"drop table if exists @p1", ordinal, UnparameterizableStatement{"DROP", "@p1"}},
+		{"create index ix on t (c) where c > @p1", ordinal, UnparameterizableStatement{"CREATE", "@p1"}},
+		{"create view v as select * from t where id = @p1", ordinal, UnparameterizableStatement{"CREATE", "@p1"}},
+		{"create or alter procedure p @id int as select @id, @p2", ordinal, UnparameterizableStatement{"CREATE", "@p2"}},
+		{"create procedure p @id int as select @id", ordinal, nil},
+		{"select 'use @p1', [create @p1] -- use @p1\n, @p1 /* drop @p1 */", ordinal, nil},
+		{"use @p3", ordinal, nil},
+		{"declare @x int = @p1; use mydb", ordinal, nil},
+		{"create table #t (c int) if @p1 = 1 select 1", ordinal, nil},
+		{"create table #t (c int) set @p2 = @p1", ordinal, nil},
+		{"drop table #t waitfor delay @p1", ordinal, nil},
+		{"drop table #t raiserror(@p1, 16, 1)", ordinal, nil},
+		{"drop table #t throw 50000, @p1, 1", ordinal, nil},
+		{"create table #t (c int) commit rollback select @p1", ordinal, nil},
+		{"drop table if exists #t if @p1 = 1 print 1", ordinal, nil},
+		{"alter database current set compatibility_level = @p1", ordinal, UnparameterizableStatement{"ALTER", "@p1"}},
+		{"alter database current set single_user with rollback immediate select @p1", ordinal, nil},
+		{"alter database current set single_user with rollback after @p1", ordinal, UnparameterizableStatement{"ALTER", "@p1"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, checkParameterizable(tt.query, tt.args), tt.query)
+	}
+}
+
+func TestStmtCheckParameterizableScansOnce(t *testing.T) {
+	s := &Stmt{query: "create table #t (c int default @p1)"}
+	args := []namedValue{{Ordinal: 1}}
+	want := UnparameterizableStatement{"CREATE", "@p1"}
+	assert.Equal(t, want, s.checkParameterizable(args))
+	assert.Equal(t, []UnparameterizableStatement{want}, s.unparameterizable)
+	s.query = "select @p1"
+	assert.Equal(t, want, s.checkParameterizable(args), "the uses of the query are cached")
+
+	s = &Stmt{query: "select @p1"}
+	assert.NoError(t, s.checkParameterizable(args))
+	assert.NotNil(t, s.unparameterizable, "a query without uses is scanned once too")
+}
+
+func TestUnparameterizableStatementIsNotSent(t *testing.T) {
+	server := newRowServer(t)
+	db, err := sql.Open("sqlserver", server.connString())
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, query := range []string{"USE @p1", "CREATE TABLE #t (c int DEFAULT @p1)"} {
+		before := server.requestsPerConn()[server.accepted()-1]
+		_, err = conn.ExecContext(ctx, query, "master")
+		var unparameterizable UnparameterizableStatement
+		require.True(t, errors.As(err, &unparameterizable), "%T: %v", err, err)
+		assert.Equal(t, "@p1", unparameterizable.Parameter)
+		assert.Contains(t, err.Error(), "run the query without arguments")
+		assert.Equal(t, before, server.requestsPerConn()[server.accepted()-1], "the query is not sent")
+	}
+
+	_, err = conn.ExecContext(ctx, "USE [master]")
+	assert.NoError(t, err, "the name written into the text is sent as a batch")
+}
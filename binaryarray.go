@@ -0,0 +1,52 @@
+package mssql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BinaryArray returns a sql.Scanner storing a binary or varbinary column in
+// the byte array dest points to, such as a *[16]byte or a *[32]byte, which
+// database/sql cannot scan into. It fails when the value has another length
+// than the array, or is NULL:
+//
+//	var hash [32]byte
+//	err := db.QueryRowContext(ctx, "SELECT hash FROM files WHERE id = @p1", id).Scan(mssql.BinaryArray(&hash))
+//
+// Byte arrays are sent as varbinary when used as parameters, so no wrapper
+// is needed to pass hash back.
+func BinaryArray(dest interface{}) *BinaryArrayScanner {
+	return &BinaryArrayScanner{dest: dest}
+}
+
+// BinaryArrayScanner is the sql.Scanner returned by BinaryArray.
+type BinaryArrayScanner struct {
+	dest interface{}
+}
+
+// Scan copies a []byte of the length of the array into the array.
+func (s *BinaryArrayScanner) Scan(src interface{}) error {
+	rv := reflect.ValueOf(s.dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Array || rv.Elem().Type().Elem().Kind() != reflect.Uint8 {
+		return fmt.Errorf("mssql: BinaryArray requires a pointer to a byte array, not %T", s.dest)
+	}
+	arr := rv.Elem()
+	switch b := src.(type) {
+	case nil:
+		return fmt.Errorf("mssql: cannot scan NULL into %T", s.dest)
+	case []byte:
+		if len(b) != arr.Len() {
+			return fmt.Errorf("mssql: cannot scan %d bytes into %T", len(b), s.dest)
+		}
+		if arr.Type().Elem() == reflect.TypeOf(byte(0)) {
+			reflect.Copy(arr, reflect.ValueOf(b))
+			return nil
+		}
+		// an array of a defined byte type
+		for i, c := range b {
+			arr.Index(i).SetUint(uint64(c))
+		}
+		return nil
+	}
+	return fmt.Errorf("mssql: cannot scan %T into %T", src, s.dest)
+}
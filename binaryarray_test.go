@@ -0,0 +1,52 @@
+package mssql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryArrayScan(t *testing.T) {
+	var hash [4]byte
+	require.NoError(t, BinaryArray(&hash).Scan([]byte{1, 2, 3, 4}))
+	assert.Equal(t, [4]byte{1, 2, 3, 4}, hash)
+
+	type octet byte
+	var octets [2]octet
+	require.NoError(t, BinaryArray(&octets).Scan([]byte{5, 6}))
+	assert.Equal(t, [2]octet{5, 6}, octets)
+
+	err := BinaryArray(&hash).Scan([]byte{1, 2, 3})
+	assert.EqualError(t, err, "mssql: cannot scan 3 bytes into *[4]uint8")
+	assert.Equal(t, [4]byte{1, 2, 3, 4}, hash, "the array is unchanged")
+	err = BinaryArray(&hash).Scan(nil)
+	assert.EqualError(t, err, "mssql: cannot scan NULL into *[4]uint8")
+	err = BinaryArray(&hash).Scan("abcd")
+	assert.EqualError(t, err, "mssql: cannot scan string into *[4]uint8")
+
+	var slice []byte
+	err = BinaryArray(&slice).Scan([]byte{1})
+	assert.EqualError(t, err, "mssql: BinaryArray requires a pointer to a byte array, not *[]uint8")
+	err = BinaryArray(hash).Scan([]byte{1, 2, 3, 4})
+	assert.EqualError(t, err, "mssql: BinaryArray requires a pointer to a byte array, not [4]uint8")
+}
+
+func TestBinaryArrayIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	in := [16]byte{0xde, 0xad, 0xbe, 0xef, 15: 0xff}
+	var out [16]byte
+	var equal bool
+	err := conn.QueryRow("SELECT CAST(@p1 AS binary(16)), CASE WHEN @p1 = 0xDEADBEEF0000000000000000000000FF THEN 1 ELSE 0 END", in).
+		Scan(BinaryArray(&out), &equal)
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+	assert.True(t, equal, "the array is sent as its bytes")
+
+	var short [8]byte
+	err = conn.QueryRow("SELECT CAST(0x01 AS binary(16))").Scan(BinaryArray(&short))
+	assert.Error(t, err, "a binary(16) does not fit in a [8]byte")
+}
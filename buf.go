@@ -52,11 +52,16 @@ type tdsBuffer struct {
 	rsize       int
 	final       bool
 	rPacketType packetType
+	// rSpid is the server process id of the last packet read.
+	rSpid uint16
 
 	// afterFirst is assigned to right after tdsBuffer is created and
 	// before the first use. It is executed after the first packet is
 	// written and then removed.
 	afterFirst func()
+
+	// capture records the packets, when set by Connector.PacketCapture.
+	capture *connCapture
 }
 
 func newTdsBuffer(bufsize uint16, transport io.ReadWriteCloser) *tdsBuffer {
@@ -89,9 +94,11 @@ func (w *tdsBuffer) flush() (err error) {
 	w.wbuf[6] = w.wPacketSeq
 
 	// Write packet into underlying transport.
+	w.capture.writing(w.wPacketType)
 	if _, err = w.transport.Write(w.wbuf[:w.wpos]); err != nil {
 		return err
 	}
+	w.capture.packet(true, w.wbuf[:w.wpos])
 	// It is possible to create a whole new buffer after a flush.
 	// Useful for debugging. Normally reuse the buffer.
 	// w.wbuf = make([]byte, 1<<16)
@@ -181,10 +188,12 @@ func (r *tdsBuffer) readNextPacket() error {
 	if err != nil {
 		return err
 	}
+	r.capture.packet(false, r.rbuf[:h.Size])
 	r.rpos = headerSize
 	r.rsize = int(h.Size)
 	r.final = h.Status != 0
 	r.rPacketType = h.PacketType
+	r.rSpid = h.Spid
 	return nil
 }
 
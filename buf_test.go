@@ -120,6 +120,16 @@ func TestBeginReadSucceeds(t *testing.T) {
 	}
 }
 
+func TestBeginReadRecordsSpid(t *testing.T) {
+	buffer := makeBuf(9, []byte{0x04 /*id*/, 0x01 /*status*/, 0x0, 0x9 /*size*/, 0x00, 0x35 /*spid*/, 0x01, 0x00, 0x02})
+	if _, err := buffer.BeginRead(); err != nil {
+		t.Fatal("BeginRead failed:", err.Error())
+	}
+	if buffer.rSpid != 53 {
+		t.Fatalf("Expected spid to be 53 but it is %d", buffer.rSpid)
+	}
+}
+
 func makeLargeDataBuffer() []byte {
 	data := make([]byte, 1<<15)
 
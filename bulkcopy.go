@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +33,12 @@ type Bulk struct {
 	tablename   string
 	numRows     int
 
+	// batchRows and batchBytes are the rows and bytes of the current batch,
+	// rowsCopied the rows of the batches already done.
+	batchRows  int
+	batchBytes int
+	rowsCopied int64
+
 	headerSent bool
 	Options    BulkOptions
 	Debug      bool
@@ -43,8 +51,39 @@ type BulkOptions struct {
 	RowsPerBatch      int
 	Order             []string
 	Tablock           bool
+
+	// BatchSize and BatchByteSize, when positive, end the bulk copy once it
+	// holds that many rows or bytes of row data and start a new one with the
+	// next row. Unlike RowsPerBatch and KilobytesPerBatch, which are hints
+	// to the server, they split the copy into several INSERT BULK statements.
+	//
+	// Outside a transaction each batch commits on its own: when a batch
+	// fails, the rows of the earlier batches stay in the table. Run the copy
+	// in a transaction to keep it all or nothing.
+	BatchSize     int
+	BatchByteSize int
+
+	// Rounding is how float values are rounded to the scale of decimal and
+	// numeric columns. The default is RoundHalfUp.
+	Rounding RoundingMode
 }
 
+// RoundingMode is how the bulk copy rounds a float copied into a decimal or
+// numeric column with fewer decimals. The float is taken as its shortest
+// decimal representation, so 2.345 is rounded as 2.345 and not as the
+// binary value just below it.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halfway values away from zero, as SQL Server does
+	// when it converts a value to a decimal of smaller scale: 2.345 is 2.35
+	// and -2.5 is -3 at scales 2 and 0.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halfway values to the even digit, as banker's
+	// rounding does: 2.345 is 2.34 and 2.5 is 2 at scales 2 and 0.
+	RoundHalfEven
+)
+
 type DataValue interface{}
 
 const (
@@ -66,39 +105,11 @@ func (c *Conn) CreateBulkContext(ctx context.Context, table string, columns []st
 }
 
 func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
-	//get table columns info
-	err = b.getMetadata(ctx)
-	if err != nil {
-		return err
-	}
-
-	//match the columns
-	for _, colname := range b.columnsName {
-		var bulkCol *columnStruct
-
-		for _, m := range b.metadata {
-			if m.ColName == colname {
-				bulkCol = &m
-				break
-			}
-		}
-		if bulkCol != nil {
-			// Note that for INSERT BULK operations, XMLTYPE is to be sent as NVARCHAR(N) or NVARCHAR(MAX) data type.
-			// An error is produced if XMLTYPE is specified.
-			//
-			// https://learn.microsoft.com/openspecs/windows_protocols/ms-tds/ab4a7d62-cd1f-4db1-b67d-ecae58f493e3
-			if bulkCol.ti.TypeId == typeXml {
-				bulkCol.ti.TypeId = typeNVarChar
-			}
-
-			if bulkCol.ti.TypeId == typeUdt {
-				//send udt as binary
-				bulkCol.ti.TypeId = typeBigVarBin
-			}
-			b.bulkColumns = append(b.bulkColumns, *bulkCol)
-			b.dlogf(ctx, "Adding column %s %s %#x", colname, bulkCol.ColName, bulkCol.ti.TypeId)
-		} else {
-			return fmt.Errorf("column %s does not exist in destination table %s", colname, b.tablename)
+	// the columns are known from the first batch
+	if b.bulkColumns == nil {
+		err = b.matchColumns(ctx)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -147,7 +158,7 @@ func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
 
 	stmt, err := b.cn.PrepareContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("Prepare failed: %s", err.Error())
+		return fmt.Errorf("Prepare failed: %w", err)
 	}
 	b.dlogf(ctx, "%s", query)
 
@@ -168,6 +179,49 @@ func (b *Bulk) sendBulkCommand(ctx context.Context) (err error) {
 	return
 }
 
+// matchColumns reads the columns of the destination table and sets the
+// columns of the copy.
+func (b *Bulk) matchColumns(ctx context.Context) (err error) {
+	//get table columns info
+	if b.metadata == nil {
+		err = b.getMetadata(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	//match the columns
+	for _, colname := range b.columnsName {
+		var bulkCol *columnStruct
+
+		for _, m := range b.metadata {
+			if m.ColName == colname {
+				bulkCol = &m
+				break
+			}
+		}
+		if bulkCol != nil {
+			// Note that for INSERT BULK operations, XMLTYPE is to be sent as NVARCHAR(N) or NVARCHAR(MAX) data type.
+			// An error is produced if XMLTYPE is specified.
+			//
+			// https://learn.microsoft.com/openspecs/windows_protocols/ms-tds/ab4a7d62-cd1f-4db1-b67d-ecae58f493e3
+			if bulkCol.ti.TypeId == typeXml {
+				bulkCol.ti.TypeId = typeNVarChar
+			}
+
+			if bulkCol.ti.TypeId == typeUdt {
+				//send udt as binary
+				bulkCol.ti.TypeId = typeBigVarBin
+			}
+			b.bulkColumns = append(b.bulkColumns, *bulkCol)
+			b.dlogf(ctx, "Adding column %s %s %#x", colname, bulkCol.ColName, bulkCol.ti.TypeId)
+		} else {
+			return fmt.Errorf("column %s does not exist in destination table %s", colname, b.tablename)
+		}
+	}
+	return nil
+}
+
 // AddRow immediately writes the row to the destination table.
 // The arguments are the row values in the order they were specified.
 func (b *Bulk) AddRow(row []interface{}) (err error) {
@@ -187,13 +241,24 @@ func (b *Bulk) AddRow(row []interface{}) (err error) {
 	if err != nil {
 		return
 	}
+	return b.writeRow(bytes)
+}
 
-	_, err = b.cn.sess.buf.Write(bytes)
+// writeRow writes the data of a row made by makeRowData, once the INSERT
+// BULK statement is sent.
+func (b *Bulk) writeRow(data []byte) (err error) {
+	_, err = b.cn.sess.buf.Write(data)
 	if err != nil {
 		return
 	}
 
 	b.numRows = b.numRows + 1
+	b.batchRows++
+	b.batchBytes += len(data)
+	if (b.Options.BatchSize > 0 && b.batchRows >= b.Options.BatchSize) ||
+		(b.Options.BatchByteSize > 0 && b.batchBytes >= b.Options.BatchByteSize) {
+		err = b.finishBatch()
+	}
 	return
 }
 
@@ -209,7 +274,7 @@ func (b *Bulk) makeRowData(row []interface{}) ([]byte, error) {
 		}
 		param, err := b.makeParam(row[i], col)
 		if err != nil {
-			return nil, fmt.Errorf("bulkcopy: %s", err.Error())
+			return nil, fmt.Errorf("bulkcopy: %w", err)
 		}
 
 		if col.ti.Writer == nil {
@@ -218,7 +283,7 @@ func (b *Bulk) makeRowData(row []interface{}) ([]byte, error) {
 		}
 		err = col.ti.Writer(buf, param.ti, param.buffer, b.cn.sess.encoding)
 		if err != nil {
-			return nil, fmt.Errorf("bulkcopy: %s", err.Error())
+			return nil, fmt.Errorf("bulkcopy: %w", err)
 		}
 	}
 
@@ -227,11 +292,20 @@ func (b *Bulk) makeRowData(row []interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Done sends the last batch and returns the number of rows copied. When it
+// fails, rowcount is the number of rows of the batches already done.
 func (b *Bulk) Done() (rowcount int64, err error) {
 	if !b.headerSent {
 		//no rows had been sent
-		return 0, nil
+		return b.rowsCopied, nil
 	}
+	err = b.finishBatch()
+	return b.rowsCopied, err
+}
+
+// finishBatch ends the INSERT BULK statement of the current batch. The
+// next row starts a new one.
+func (b *Bulk) finishBatch() error {
 	var buf = b.cn.sess.buf
 	buf.WriteByte(byte(tokenDone))
 
@@ -246,13 +320,93 @@ func (b *Bulk) Done() (rowcount int64, err error) {
 
 	buf.FinishPacket()
 
+	batchStart := b.numRows - b.batchRows
+	b.headerSent = false
+	b.batchRows, b.batchBytes = 0, 0
+
 	reader := startReading(b.cn.sess, b.ctx, outputs{})
-	err = reader.iterateResponse()
+	err := reader.iterateResponse()
 	if err != nil {
-		return 0, b.cn.checkBadConn(b.ctx, err, false)
+		err = b.cn.checkBadConn(b.ctx, err, false)
+		var serverErr Error
+		if errors.As(err, &serverErr) {
+			bulkErr := b.newBulkError(serverErr, batchStart)
+			serverErr.bulk = &bulkErr
+			err = serverErr
+		}
+		return err
+	}
+
+	b.rowsCopied += reader.rowCount
+	return nil
+}
+
+// BulkError describes a bulk copy whose rows the server rejected. Bulk.Done
+// returns the errors sent by the server as an Error, as for any other
+// request, and errors.As with a BulkError finds the BulkError of that
+// Error. The server checks the rows once it received all of them, so the
+// error is reported when the copy is done rather than by AddRow, or by the
+// AddRow that ends a batch when BulkOptions.BatchSize or BatchByteSize is
+// set.
+type BulkError struct {
+	// Err is the error returned by the server.
+	Err Error
+	// Row and Column are the 1-based ordinals of the row and column that
+	// failed, and ColumnName the name of the column, when the server
+	// reports them, as it does for conversion errors. They are 0 and empty
+	// otherwise, such as for constraint violations.
+	Row        int
+	Column     int
+	ColumnName string
+	// RowsSent is the number of rows added to the copy, including those
+	// of earlier batches.
+	RowsSent int
+}
+
+func (e BulkError) Error() string {
+	var loc string
+	switch {
+	case e.Row > 0 && e.ColumnName != "":
+		loc = fmt.Sprintf(" at row %d, column %d (%s)", e.Row, e.Column, e.ColumnName)
+	case e.Column > 0 && e.ColumnName != "":
+		loc = fmt.Sprintf(" at column %d (%s)", e.Column, e.ColumnName)
 	}
+	return fmt.Sprintf("mssql: bulk copy of %d rows failed%s: %s", e.RowsSent, loc, e.Err.Error())
+}
+
+func (e BulkError) Unwrap() error {
+	return e.Err
+}
 
-	return reader.rowCount, nil
+var (
+	// bulkRowColumnPattern matches the location in messages such as
+	// "Bulk load data conversion error (truncation) for row 2, column 3 (name)."
+	bulkRowColumnPattern = regexp.MustCompile(`\brow (\d+), column (\d+) \(([^)]*)\)`)
+	// bulkColidPattern matches "... from the bcp client for colid 2."
+	bulkColidPattern = regexp.MustCompile(`\bcolid (\d+)\b`)
+)
+
+// newBulkError makes the BulkError of the batch following the first
+// batchStart rows, whose row ordinals the server counts from 1.
+func (b *Bulk) newBulkError(err Error, batchStart int) BulkError {
+	bulkErr := BulkError{Err: err, RowsSent: b.numRows}
+	for _, e := range append([]Error{err}, err.All...) {
+		if m := bulkRowColumnPattern.FindStringSubmatch(e.Message); m != nil {
+			bulkErr.Row, _ = strconv.Atoi(m[1])
+			bulkErr.Row += batchStart
+			bulkErr.Column, _ = strconv.Atoi(m[2])
+			bulkErr.ColumnName = m[3]
+			break
+		}
+		if m := bulkColidPattern.FindStringSubmatch(e.Message); m != nil {
+			bulkErr.Column, _ = strconv.Atoi(m[1])
+			if bulkErr.Column > 0 && bulkErr.Column <= len(b.bulkColumns) {
+				bulkErr.ColumnName = b.bulkColumns[bulkErr.Column-1].ColName
+			}
+			break
+		}
+	}
+	return bulkErr
 }
 
 func (b *Bulk) createColMetadata() []byte {
@@ -333,7 +487,7 @@ func (b *Bulk) getMetadata(ctx context.Context) (err error) {
 	}
 	rows, err := stmt.QueryContext(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("get columns info failed: %v", err)
+		return fmt.Errorf("get columns info failed: %w", err)
 	}
 	resetFmtonly = false
 	b.metadata = rows.(*Rows).cols
@@ -349,10 +503,25 @@ func (b *Bulk) getMetadata(ctx context.Context) (err error) {
 	return rows.Close()
 }
 
+// intFitsSize reports whether v fits an int column of size bytes, tinyint
+// being unsigned.
+func intFitsSize(v int64, size int) bool {
+	switch size {
+	case 1:
+		return v >= 0 && v <= math.MaxUint8
+	case 2:
+		return v >= math.MinInt16 && v <= math.MaxInt16
+	case 4:
+		return v >= math.MinInt32 && v <= math.MaxInt32
+	}
+	return true
+}
+
 func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error) {
 	res.ti.Size = col.ti.Size
 	res.ti.TypeId = col.ti.TypeId
 	loc := getTimezone(b.cn)
+	zonelessLoc := getZonelessLocation(b.cn)
 
 	switch valuer := val.(type) {
 	case Money[shopspring.Decimal]:
@@ -384,18 +553,47 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		switch val := val.(type) {
 		case int:
 			intvalue = int64(val)
+		case int8:
+			intvalue = int64(val)
+		case int16:
+			intvalue = int64(val)
 		case int32:
 			intvalue = int64(val)
 		case int64:
 			intvalue = val
+		case uint8:
+			intvalue = int64(val)
+		case uint16:
+			intvalue = int64(val)
+		case uint32:
+			intvalue = int64(val)
+		case uint:
+			if uint64(val) > math.MaxInt64 {
+				return res, fmt.Errorf("mssql: value %d is out of range of an int column", val)
+			}
+			intvalue = int64(val)
+		case uint64:
+			if val > math.MaxInt64 {
+				return res, fmt.Errorf("mssql: value %d is out of range of an int column", val)
+			}
+			intvalue = int64(val)
 		case float32:
+			if !(float64(val) >= math.MinInt64 && float64(val) < math.MaxInt64) {
+				return res, fmt.Errorf("mssql: value %v is out of range of an int column", val)
+			}
 			intvalue = int64(val)
 		case float64:
+			if !(val >= math.MinInt64 && val < math.MaxInt64) {
+				return res, fmt.Errorf("mssql: value %v is out of range of an int column", val)
+			}
 			intvalue = int64(val)
 		default:
 			err = fmt.Errorf("mssql: invalid type for int column: %T", val)
 			return
 		}
+		if !intFitsSize(intvalue, col.ti.Size) {
+			return res, fmt.Errorf("mssql: value %d is out of range of a %d byte int column", intvalue, col.ti.Size)
+		}
 
 		res.buffer = make([]byte, res.ti.Size)
 		if col.ti.Size == 1 {
@@ -490,7 +688,7 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 	case typeDateTime2N:
 		switch val := val.(type) {
 		case time.Time:
-			res.buffer = encodeDateTime2(val, int(col.ti.Scale))
+			res.buffer = encodeDateTime2(zoneless(val, zonelessLoc), int(col.ti.Scale))
 			res.ti.Size = len(res.buffer)
 		case string:
 			var t time.Time
@@ -522,7 +720,7 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 	case typeDateN:
 		switch val := val.(type) {
 		case time.Time:
-			res.buffer = encodeDate(val)
+			res.buffer = encodeDate(zoneless(val, zonelessLoc))
 			res.ti.Size = len(res.buffer)
 		case string:
 			var t time.Time
@@ -539,7 +737,7 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		var t time.Time
 		switch val := val.(type) {
 		case time.Time:
-			t = val
+			t = zoneless(val, zonelessLoc)
 		case string:
 			if t, err = time.Parse(sqlDateTimeFormat, val); err != nil {
 				return res, fmt.Errorf("bulk: unable to convert string to date: %v", err)
@@ -550,7 +748,7 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		}
 
 		if col.ti.Size == 4 {
-			res.buffer = encodeDateTim4(t, loc)
+			res.buffer = encodeDateTim4(t)
 			res.ti.Size = len(res.buffer)
 		} else if col.ti.Size == 8 {
 			res.buffer = encodeDateTime(t)
@@ -562,6 +760,7 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		var t time.Time
 		switch val := val.(type) {
 		case time.Time:
+			val = zoneless(val, zonelessLoc)
 			res.buffer = encodeTime(val.Hour(), val.Minute(), val.Second(), val.Nanosecond(), int(col.ti.Scale))
 			res.ti.Size = len(res.buffer)
 		case string:
@@ -621,9 +820,9 @@ func (b *Bulk) makeParam(val DataValue, col columnStruct) (res param, err error)
 		case int64:
 			dec = decimal.Int64ToDecimalScale(int64(v), 0)
 		case float32:
-			dec, err = decimal.Float64ToDecimalScale(float64(v), scale)
+			dec, err = decimal.FloatToDecimalRound(float64(v), 32, scale, b.Options.Rounding == RoundHalfEven)
 		case float64:
-			dec, err = decimal.Float64ToDecimalScale(float64(v), scale)
+			dec, err = decimal.FloatToDecimalRound(v, 64, scale, b.Options.Rounding == RoundHalfEven)
 		case string:
 			dec, err = decimal.StringToDecimalScale(v, scale)
 		default:
@@ -4,9 +4,14 @@
 package mssql
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"strings"
@@ -15,6 +20,7 @@ import (
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBulkcopyWithInvalidNullableType(t *testing.T) {
@@ -551,3 +557,261 @@ func TestBulkcopyFailure(t *testing.T) {
 	_, err = stmt.ExecContext(ctx)
 	assert.NoError(t, err)
 }
+
+func TestNewBulkError(t *testing.T) {
+	b := &Bulk{bulkColumns: []columnStruct{{ColName: "id"}, {ColName: "name"}}, numRows: 3}
+	tests := []struct {
+		message string
+		row     int
+		column  int
+		name    string
+	}{
+		{"Bulk load data conversion error (truncation) for row 2, column 2 (name).", 2, 2, "name"},
+		{"Received an invalid column length from the bcp client for colid 2.", 0, 2, "name"},
+		{"Received an invalid column length from the bcp client for colid 9.", 0, 9, ""},
+		{`The INSERT statement conflicted with the CHECK constraint "ck". The conflict occurred in database "db", table "dbo.t", column 'id'.`, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		got := b.newBulkError(Error{Number: 4863, Message: tt.message}, 0)
+		assert.Equal(t, BulkError{Err: Error{Number: 4863, Message: tt.message}, Row: tt.row, Column: tt.column, ColumnName: tt.name, RowsSent: 3}, got, tt.message)
+	}
+	assert.Equal(t, "mssql: bulk copy of 3 rows failed at row 2, column 2 (name): mssql: Bulk load data conversion error (truncation) for row 2, column 2 (name). (4863)",
+		b.newBulkError(Error{Number: 4863, Message: tests[0].message}, 0).Error())
+}
+
+func TestBulkDecimalRounding(t *testing.T) {
+	col := columnStruct{ti: typeInfo{TypeId: typeDecimalN, Prec: 9, Scale: 2, Size: 5}}
+	tests := []struct {
+		val      interface{}
+		rounding RoundingMode
+		want     []byte
+	}{
+		{2.345, RoundHalfUp, []byte{1, 235, 0, 0, 0}},
+		{2.345, RoundHalfEven, []byte{1, 234, 0, 0, 0}},
+		{-0.125, RoundHalfUp, []byte{0, 13, 0, 0, 0}},
+		{-0.125, RoundHalfEven, []byte{0, 12, 0, 0, 0}},
+		{float32(0.125), RoundHalfUp, []byte{1, 13, 0, 0, 0}},
+		{float32(0.125), RoundHalfEven, []byte{1, 12, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		b := &Bulk{Options: BulkOptions{Rounding: tt.rounding}}
+		p, err := b.makeParam(tt.val, col)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, p.buffer, "%v rounded with %d", tt.val, tt.rounding)
+	}
+}
+
+func TestBulkIntRange(t *testing.T) {
+	b := &Bulk{}
+	bigint := columnStruct{ti: typeInfo{TypeId: typeIntN, Size: 8}}
+	for _, v := range []interface{}{int64(math.MaxInt64), int64(math.MinInt64), int64(-1), uint64(math.MaxInt64), int8(-5), uint32(math.MaxUint32)} {
+		p, err := b.makeParam(v, bigint)
+		require.NoError(t, err, "%T %v", v, v)
+		assert.Equal(t, fmt.Sprint(v), fmt.Sprint(int64(binary.LittleEndian.Uint64(p.buffer))), "%T %v", v, v)
+	}
+	_, err := b.makeParam(uint64(math.MaxInt64)+1, bigint)
+	assert.Error(t, err, "uint64 beyond bigint")
+	_, err = b.makeParam(float64(math.MaxInt64), bigint)
+	assert.Error(t, err, "float beyond bigint")
+
+	for _, tt := range []struct {
+		size   int
+		fits   []int64
+		beyond []int64
+	}{
+		{1, []int64{0, 255}, []int64{-1, 256}},
+		{2, []int64{math.MinInt16, math.MaxInt16}, []int64{math.MinInt16 - 1, math.MaxInt16 + 1}},
+		{4, []int64{math.MinInt32, math.MaxInt32}, []int64{math.MinInt32 - 1, math.MaxInt32 + 1}},
+	} {
+		col := columnStruct{ti: typeInfo{TypeId: typeIntN, Size: tt.size}}
+		for _, v := range tt.fits {
+			_, err := b.makeParam(v, col)
+			assert.NoError(t, err, "%d in %d bytes", v, tt.size)
+		}
+		for _, v := range tt.beyond {
+			_, err := b.makeParam(v, col)
+			assert.Error(t, err, "%d in %d bytes is not truncated", v, tt.size)
+		}
+	}
+}
+
+// splitTransport reads the replies of a fake server and discards the requests.
+type splitTransport struct {
+	io.Reader
+}
+
+func (splitTransport) Write(p []byte) (int, error) { return len(p), nil }
+func (splitTransport) Close() error                { return nil }
+
+func TestBulkDoneReturnsBulkError(t *testing.T) {
+	const message = "Bulk load data conversion error (type mismatch or invalid character for the specified codepage) for row 2, column 1 (id)."
+	reply := errorToken(4864, 1, message)
+	reply = append(reply, doneToken(doneError|doneFinal, 0)...)
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, splitTransport{bytes.NewReader(makeReplyPacket(reply))}),
+		logger: optionalLogger{},
+	}
+	sess.loginAck.TDSVersion = verTDS74
+	b := &Bulk{
+		ctx:         context.Background(),
+		cn:          &Conn{sess: sess, connectionGood: true, connector: &Connector{}},
+		bulkColumns: []columnStruct{{ColName: "id"}},
+		headerSent:  true,
+		numRows:     3,
+		batchRows:   3,
+	}
+	_, err := b.Done()
+	_, ok := err.(Error)
+	require.True(t, ok, "the server error is returned as before, %T: %v", err, err)
+	assert.Equal(t, "mssql: bulk copy of 3 rows failed at row 2, column 1 (id): mssql: "+message+" (4864)", err.Error())
+	var bulkErr BulkError
+	require.True(t, errors.As(err, &bulkErr), "%T: %v", err, err)
+	assert.Equal(t, 2, bulkErr.Row)
+	assert.Equal(t, "id", bulkErr.ColumnName)
+	assert.Equal(t, 3, bulkErr.RowsSent)
+	var serverErr Error
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, int32(4864), serverErr.Number)
+	assert.True(t, b.cn.connectionGood, "a rejected copy keeps the connection")
+}
+
+// batchServer is the transport of a fake server that answers each bulk load
+// with a DONE token counting rowsPerBatch rows, and counts the INSERT BULK
+// statements it receives.
+type batchServer struct {
+	rowsPerBatch uint64
+	replies      bytes.Buffer
+	inserts      int
+}
+
+func (s *batchServer) Read(p []byte) (int, error) { return s.replies.Read(p) }
+func (s *batchServer) Close() error               { return nil }
+
+func (s *batchServer) Write(p []byte) (int, error) {
+	if packetType(p[0]) == packSQLBatch && bytes.Contains(p, str2ucs2("INSERT BULK")) {
+		s.inserts++
+	}
+	if p[1]&1 != 0 {
+		reply := doneToken(0, 0)
+		if packetType(p[0]) == packBulkLoadBCP {
+			reply = doneToken(doneCount, s.rowsPerBatch)
+		}
+		s.replies.Write(makeReplyPacket(reply))
+	}
+	return len(p), nil
+}
+
+func TestBulkBatchSize(t *testing.T) {
+	server := &batchServer{rowsPerBatch: 1000}
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, server),
+		logger: optionalLogger{},
+	}
+	sess.loginAck.TDSVersion = verTDS74
+	cn := &Conn{sess: sess, connectionGood: true, connector: &Connector{}}
+	b := cn.CreateBulk("dbo.batched", []string{"id"})
+	b.Options.BatchSize = 1000
+	b.bulkColumns = []columnStruct{{ColName: "id", ti: typeInfo{TypeId: typeInt4, Size: 4, Writer: writeFixedType}}}
+
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, b.AddRow([]interface{}{int32(i)}))
+	}
+	assert.Equal(t, 10, server.inserts, "a statement per batch")
+	rowcount, err := b.Done()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), rowcount)
+	assert.Equal(t, 10, server.inserts, "the last batch was full")
+
+	server.inserts = 0
+	b = cn.CreateBulk("dbo.batched", []string{"id"})
+	b.Options.BatchByteSize = 50
+	b.bulkColumns = []columnStruct{{ColName: "id", ti: typeInfo{TypeId: typeInt4, Size: 4, Writer: writeFixedType}}}
+	for i := 0; i < 25; i++ {
+		require.NoError(t, b.AddRow([]interface{}{int32(i)}))
+	}
+	_, err = b.Done()
+	require.NoError(t, err)
+	assert.Equal(t, 3, server.inserts, "rows of 5 bytes, batches of 10 rows")
+}
+
+func TestBulkcopyBatchesCommitIndependently(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := pool.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #batched (id int NOT NULL CHECK (id <> 5500))")
+	require.NoError(t, err)
+	count := func() int {
+		var n int
+		require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM #batched").Scan(&n))
+		return n
+	}
+
+	copyRows := func(skip int) error {
+		stmt, err := conn.PrepareContext(ctx, CopyIn("#batched", BulkOptions{CheckConstraints: true, BatchSize: 1000}, "id"))
+		require.NoError(t, err)
+		defer stmt.Close()
+		for id := 0; id < 10000; id++ {
+			if id == skip {
+				continue
+			}
+			if _, err = stmt.ExecContext(ctx, id); err != nil {
+				return err
+			}
+		}
+		res, err := stmt.ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(9999), n)
+		return nil
+	}
+
+	require.NoError(t, copyRows(5500))
+	assert.Equal(t, 9999, count())
+
+	_, err = conn.ExecContext(ctx, "TRUNCATE TABLE #batched")
+	require.NoError(t, err)
+	err = copyRows(-1)
+	var bulkErr BulkError
+	require.True(t, errors.As(err, &bulkErr), "%T: %v", err, err)
+	assert.Equal(t, int32(547), bulkErr.Err.Number)
+	assert.Equal(t, 6000, bulkErr.RowsSent, "the batch holding id 5500 failed")
+	assert.Equal(t, 5000, count(), "the earlier batches are committed")
+}
+
+func TestBulkcopyRowError(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := pool.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #checked (id int NOT NULL CHECK (id > 0), name varchar(10))")
+	require.NoError(t, err)
+
+	stmt, err := conn.PrepareContext(ctx, CopyIn("#checked", BulkOptions{CheckConstraints: true}, "id", "name"))
+	require.NoError(t, err)
+	defer stmt.Close()
+	for _, id := range []int{1, -1, 2} {
+		_, err = stmt.ExecContext(ctx, id, "x")
+		require.NoError(t, err, "rows are checked when the copy is done")
+	}
+	_, err = stmt.ExecContext(ctx)
+	var bulkErr BulkError
+	require.True(t, errors.As(err, &bulkErr), "%T: %v", err, err)
+	assert.Equal(t, int32(547), bulkErr.Err.Number, "CHECK constraint violation")
+	assert.Equal(t, 3, bulkErr.RowsSent)
+
+	var count int
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM #checked").Scan(&count))
+	assert.Zero(t, count, "the copy is rolled back")
+}
@@ -0,0 +1,216 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxUncoalesced bounds the statements a connection remembers it cannot
+// coalesce.
+const maxUncoalesced = 256
+
+// coalescedInsert is the bulk copy the rows of an INSERT statement are
+// added to with Connector.CoalesceInserts.
+type coalescedInsert struct {
+	query string
+	bulk  *Bulk
+	// params are the ordinals of the parameters of the columns of the bulk
+	// copy.
+	params []int
+}
+
+// insertShape is an INSERT of one row whose values are all parameters.
+type insertShape struct {
+	table   string
+	columns []string
+	params  []int
+}
+
+const insertIdent = `(?:\[(?:[^\]]|\]\])+\]|"(?:[^"]|"")+"|[\p{L}_#][\p{L}\p{N}_@#$]*)`
+
+var (
+	insertRe      = regexp.MustCompile(`(?is)^\s*INSERT\s+(?:INTO\s+)?(` + insertIdent + `(?:\s*\.\s*` + insertIdent + `){0,3})\s*\(([^()]*)\)\s*VALUES\s*\(([^()]*)\)\s*;?\s*$`)
+	insertIdentRe = regexp.MustCompile(`^\s*` + insertIdent + `\s*`)
+	insertParamRe = regexp.MustCompile(`^\s*@[pP]([1-9][0-9]*)\s*$`)
+)
+
+// parseInsert returns the shape of query, or nil when it is not an INSERT
+// of one row of parameters, such as INSERT INTO t (a, b) VALUES (@p1, @p2).
+func parseInsert(query string) *insertShape {
+	m := insertRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil
+	}
+	shape := &insertShape{table: m[1]}
+	for list := m[2]; ; {
+		ident := insertIdentRe.FindString(list)
+		if ident == "" {
+			return nil
+		}
+		shape.columns = append(shape.columns, unquoteIdent(strings.TrimSpace(ident)))
+		list = list[len(ident):]
+		if list == "" {
+			break
+		}
+		if list[0] != ',' {
+			return nil
+		}
+		list = list[1:]
+	}
+	values := strings.Split(m[3], ",")
+	if len(values) != len(shape.columns) {
+		return nil
+	}
+	for _, v := range values {
+		p := insertParamRe.FindStringSubmatch(v)
+		if p == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(p[1])
+		if err != nil {
+			return nil
+		}
+		shape.params = append(shape.params, n)
+	}
+	return shape
+}
+
+// unquoteIdent returns the name of the identifier ident, quoted with
+// brackets or double quotes or not.
+func unquoteIdent(ident string) string {
+	switch ident[0] {
+	case '[':
+		return strings.ReplaceAll(ident[1:len(ident)-1], "]]", "]")
+	case '"':
+		return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+	}
+	return ident
+}
+
+// coalesceInsert adds the row inserted by s to the bulk copy of the
+// coalesced inserts of the transaction. It returns false when the insert is
+// to be run as usual, after ending the bulk copy of another statement.
+func (s *Stmt) coalesceInsert(ctx context.Context, args []namedValue) (driver.Result, bool, error) {
+	c := s.c
+	if c.connector == nil || !c.connector.CoalesceInserts || !c.inTransaction ||
+		s.notifSub != nil || s.doEncryption() || c.outs.hasOutputs() || c.outs.msgq != nil ||
+		c.uncoalesced[s.query] {
+		return nil, false, nil
+	}
+	ins := c.inserts
+	if ins == nil || ins.query != s.query {
+		if err := c.flushInserts(); err != nil {
+			return nil, true, err
+		}
+		if ins = c.startInsert(ctx, s.query); ins == nil {
+			c.noCoalesce(s.query)
+			return nil, false, nil
+		}
+		c.inserts = ins
+	}
+
+	row, ok := ins.row(args)
+	if ok && len(args) == s.paramCount {
+		data, err := ins.bulk.makeRowData(row)
+		if err == nil {
+			return c.addInsertRow(ctx, ins, data)
+		}
+	}
+	// arguments that do not match the parameters, or a value the bulk copy
+	// does not convert, which the server may: the statement runs as usual
+	c.noCoalesce(s.query)
+	if err := c.flushInserts(); err != nil {
+		return nil, true, err
+	}
+	return nil, false, nil
+}
+
+// noCoalesce records that the inserts of query run as usual.
+func (c *Conn) noCoalesce(query string) {
+	if c.uncoalesced == nil || len(c.uncoalesced) >= maxUncoalesced {
+		c.uncoalesced = map[string]bool{}
+	}
+	c.uncoalesced[query] = true
+}
+
+// addInsertRow writes the data of a row to the bulk copy of ins, sending
+// the INSERT BULK statement first.
+func (c *Conn) addInsertRow(ctx context.Context, ins *coalescedInsert, data []byte) (driver.Result, bool, error) {
+	if !ins.bulk.headerSent {
+		// the INSERT BULK statement is sent as a query, which must not end
+		// the copy
+		c.inserts = nil
+		if err := ins.bulk.sendBulkCommand(ctx); err != nil {
+			return nil, true, err
+		}
+		c.inserts = ins
+	}
+	if err := ins.bulk.writeRow(data); err != nil {
+		c.inserts = nil
+		return nil, true, err
+	}
+	return &Result{c, 1}, true, nil
+}
+
+// startInsert starts coalescing the inserts of query, or returns nil when
+// it cannot.
+func (c *Conn) startInsert(ctx context.Context, query string) *coalescedInsert {
+	shape := parseInsert(query)
+	if shape == nil {
+		return nil
+	}
+	// the rows are sent until the next statement, after this context ended
+	b := c.CreateBulkContext(c.transactionCtx, shape.table, nil)
+	// the checks and triggers of an INSERT, and NULL kept over defaults
+	b.Options = BulkOptions{CheckConstraints: true, FireTriggers: true, KeepNulls: true}
+	if err := b.getMetadata(ctx); err != nil {
+		return nil
+	}
+	for _, name := range shape.columns {
+		col := -1
+		for i, m := range b.metadata {
+			if strings.EqualFold(m.ColName, name) {
+				col = i
+				break
+			}
+		}
+		// the bulk copy would generate identity values instead of failing
+		// or inserting those given
+		if col < 0 || b.metadata[col].Flags&colFlagIdentity != 0 {
+			return nil
+		}
+		b.columnsName = append(b.columnsName, b.metadata[col].ColName)
+	}
+	if err := b.matchColumns(ctx); err != nil {
+		return nil
+	}
+	return &coalescedInsert{query: query, bulk: b, params: shape.params}
+}
+
+// row returns the values of the columns of the bulk copy from args, or
+// false when they do not match the parameters of the statement.
+func (ins *coalescedInsert) row(args []namedValue) ([]interface{}, bool) {
+	row := make([]interface{}, len(ins.params))
+	for i, p := range ins.params {
+		if p > len(args) || args[p-1].Name != "" {
+			return nil, false
+		}
+		row[i] = args[p-1].Value
+	}
+	return row, true
+}
+
+// flushInserts ends the bulk copy of the coalesced inserts, if any. It is
+// called before another request is sent on c.
+func (c *Conn) flushInserts() error {
+	ins := c.inserts
+	if ins == nil {
+		return nil
+	}
+	c.inserts = nil
+	_, err := ins.bulk.Done()
+	return err
+}
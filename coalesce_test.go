@@ -0,0 +1,153 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInsert(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *insertShape
+	}{
+		{"INSERT INTO t (a, b) VALUES (@p1, @p2)", &insertShape{"t", []string{"a", "b"}, []int{1, 2}}},
+		{"insert dbo.t(a,b) values(@P2,@p1);", &insertShape{"dbo.t", []string{"a", "b"}, []int{2, 1}}},
+		{"INSERT INTO [my db].[dbo].[t x] ([a,b], \"c\"\"d\") VALUES (@p1, @p2)",
+			&insertShape{"[my db].[dbo].[t x]", []string{"a,b", `c"d`}, []int{1, 2}}},
+		{"\n\tINSERT INTO #tmp (id)\n\tVALUES (@p1)\n", &insertShape{"#tmp", []string{"id"}, []int{1}}},
+		{"INSERT INTO t (a, b) VALUES (@p1, 2)", nil},
+		{"INSERT INTO t (a, b) VALUES (@p1, @name)", nil},
+		{"INSERT INTO t (a) VALUES (@p1), (@p2)", nil},
+		{"INSERT INTO t (a) VALUES (@p1 + 1)", nil},
+		{"INSERT INTO t (a) VALUES (getdate())", nil},
+		{"INSERT INTO t (a) OUTPUT inserted.id VALUES (@p1)", nil},
+		{"INSERT INTO t (a) SELECT @p1", nil},
+		{"INSERT INTO t VALUES (@p1)", nil},
+		{"INSERT INTO t (a, b) VALUES (@p1)", nil},
+		{"INSERT INTO t (a) VALUES (@p1); SELECT 1", nil},
+		{"INSERT BULK t (a int)", nil},
+		{"UPDATE t SET a = @p1", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseInsert(tt.query), tt.query)
+	}
+}
+
+func openCoalescing(t *testing.T) (*sql.DB, context.Context) {
+	t.Helper()
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	connector.CoalesceInserts = true
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return db, ctx
+}
+
+func TestCoalesceInserts(t *testing.T) {
+	db, ctx := openCoalescing(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `CREATE TABLE #coalesce (
+	id int NOT NULL PRIMARY KEY,
+	name nvarchar(20) NULL,
+	amount decimal(10, 2) NOT NULL CHECK (amount >= 0),
+	created datetime2 NOT NULL DEFAULT (sysutcdatetime())
+)`)
+	require.NoError(t, err)
+	count := func(q interface {
+		QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+	}) int {
+		var n int
+		require.NoError(t, q.QueryRowContext(ctx, "SELECT COUNT(*) FROM #coalesce").Scan(&n))
+		return n
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO #coalesce (id, Name, amount) VALUES (@p1, @p2, @p3)")
+	require.NoError(t, err)
+	for i := 1; i <= 100; i++ {
+		var name interface{} = "row"
+		if i%10 == 0 {
+			name = nil
+		}
+		res, err := stmt.ExecContext(ctx, i, name, float64(i)/4)
+		require.NoError(t, err)
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	}
+	require.NoError(t, stmt.Close())
+	assert.Equal(t, 100, count(tx), "the transaction reads its coalesced inserts")
+	// the unprepared form of the same statement is coalesced too
+	for i := 101; i <= 110; i++ {
+		_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce (id, Name, amount) VALUES (@p1, @p2, @p3)", i, "more", 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, 110, count(conn))
+	var nulls, defaults int
+	require.NoError(t, conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) - COUNT(name), COUNT(created) FROM #coalesce").Scan(&nulls, &defaults))
+	assert.Equal(t, 10, nulls, "NULL values are kept")
+	assert.Equal(t, 110, defaults, "omitted columns get their default")
+
+	// a row failing the check constraint fails the commit, not its Exec
+	tx, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	for i, amount := range []float64{1, -1, 2} {
+		_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce (id, amount) VALUES (@p1, @p2)", 200+i, amount)
+		require.NoError(t, err)
+	}
+	err = tx.Commit()
+	var bulkErr BulkError
+	require.True(t, errors.As(err, &bulkErr), "%T: %v", err, err)
+	assert.Equal(t, 110, count(conn), "the transaction is rolled back")
+
+	// rolling back discards the coalesced inserts
+	tx, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce (id, amount) VALUES (@p1, @p2)", 300, 1)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, 110, count(conn))
+
+	// values the bulk copy does not convert are inserted as usual
+	tx, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce (id, amount) VALUES (@p1, @p2)", 301, 1)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce (id, amount) VALUES (@p1, @p2)", "302", "1.5")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, 112, count(conn))
+}
+
+func TestCoalesceInsertsIdentity(t *testing.T) {
+	db, ctx := openCoalescing(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #coalesce_identity (id int IDENTITY(1,1) PRIMARY KEY, v int)")
+	require.NoError(t, err)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "SET IDENTITY_INSERT #coalesce_identity ON")
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO #coalesce_identity (id, v) VALUES (@p1, @p2)", 42, 1)
+	require.NoError(t, err)
+	var id int
+	require.NoError(t, tx.QueryRowContext(ctx, "SELECT id FROM #coalesce_identity").Scan(&id))
+	assert.Equal(t, 42, id, "inserts into identity columns are not coalesced")
+}
@@ -0,0 +1,71 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// contextInfoParam is the parameter carrying the CONTEXT_INFO set by
+// Connector.TraceContextInfo, declared by contextInfoDecl.
+const (
+	contextInfoParam = "@go_mssqldb_context_info"
+	contextInfoDecl  = contextInfoParam + " varbinary(128)"
+	contextInfoSQL   = "SET CONTEXT_INFO " + contextInfoParam
+	// maxContextInfo is the size of CONTEXT_INFO.
+	maxContextInfo = 128
+)
+
+// contextInfo returns the CONTEXT_INFO the query sent with ctx on c runs
+// with and whether it differs from the one the session holds: the trace id
+// of ctx, or nothing to clear the id set for an earlier query. Stored
+// procedure calls and SQL batches set none.
+func (c *Conn) contextInfo(ctx context.Context, isProc, batch bool) ([]byte, bool) {
+	if c.connector == nil || !c.connector.TraceContextInfo || isProc || batch {
+		return nil, false
+	}
+	var info []byte
+	if id, ok := TraceIDFromContext(ctx); ok {
+		if len(id) > maxContextInfo {
+			id = id[:maxContextInfo]
+		}
+		info = []byte(id)
+	}
+	return info, !bytes.Equal(info, c.sessionContextInfo)
+}
+
+// setContextInfo sets the CONTEXT_INFO of the session to info with a
+// request of its own, sent before the query: prepended to the query, the
+// SET statement would make statements that must start a batch, such as
+// CREATE PROCEDURE, fail, and shift the line numbers of the errors of the
+// query. reset is the session reset flag of the request.
+func (c *Conn) setContextInfo(ctx context.Context, headers []headerStruct, info []byte, reset bool) error {
+	params := []param{makeStrParam(contextInfoSQL), makeStrParam(contextInfoDecl), makeContextInfoParam(info)}
+	if err := sendRpc(c.sess.buf, headers, sp_ExecuteSql, 0, params, reset, c.sess.encoding); err != nil {
+		c.sess.LogF(ctx, msdsn.LogErrors, "Failed to send Rpc with %v", err)
+		c.connectionGood = false
+		return fmt.Errorf("failed to send RPC: %w", err)
+	}
+	// keep the outputs for the query
+	outs := c.outs
+	c.outs = outputs{}
+	err := c.simpleProcessResp(ctx, false)
+	c.outs = outs
+	if err != nil {
+		return err
+	}
+	c.sessionContextInfo = info
+	return nil
+}
+
+// makeContextInfoParam returns the parameter of contextInfoSQL.
+func makeContextInfoParam(info []byte) param {
+	var p param
+	p.Name = contextInfoParam
+	p.ti.TypeId = typeBigVarBin
+	p.ti.Size = maxContextInfo
+	p.buffer = info
+	return p
+}
@@ -0,0 +1,169 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contextInfoServer answers every request of a rowServer with a done
+// token, and returns the requests it received.
+func contextInfoServer(server *rowServer) func() [][]byte {
+	var mu sync.Mutex
+	var requests [][]byte
+	server.answer = func(_ int, request []byte) ([]byte, bool) {
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+		return doneToken(0, 0), true
+	}
+	return func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		got := requests
+		requests = nil
+		return got
+	}
+}
+
+// isSQLBatch reports whether the data of request is the SQL batch query.
+func isSQLBatch(request []byte, query string) bool {
+	if len(request) < 4 {
+		return false
+	}
+	headersLen := binary.LittleEndian.Uint32(request)
+	if int(headersLen) > len(request) {
+		return false
+	}
+	text, err := ucs22str(request[headersLen:])
+	return err == nil && text == query
+}
+
+func TestSendQueryWithTraceContextInfo(t *testing.T) {
+	server := newRowServer(t)
+	requests := contextInfoServer(server)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	connector.TraceContextInfo = true
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	traced := WithTraceID(ctx, "0af7651916cd43dd")
+	setContextInfo := str2ucs2(contextInfoSQL)
+
+	// the id is set by a request of its own, and the query is sent
+	// unchanged, so it may be one that must start a batch
+	create := "create procedure dbo.traced as select 1"
+	_, err = conn.ExecContext(traced, create)
+	require.NoError(t, err)
+	got := requests()
+	require.Len(t, got, 2)
+	assert.True(t, bytes.Contains(got[0], setContextInfo))
+	assert.True(t, bytes.Contains(got[0], []byte("0af7651916cd43dd")))
+	assert.True(t, isSQLBatch(got[1], create), "the query is a SQL batch")
+
+	// the session holds the id already
+	_, err = conn.ExecContext(traced, "select 1")
+	require.NoError(t, err)
+	got = requests()
+	require.Len(t, got, 1)
+	assert.True(t, isSQLBatch(got[0], "select 1"))
+
+	// the next query without an id clears it
+	_, err = conn.ExecContext(ctx, "select @p1", 1)
+	require.NoError(t, err)
+	got = requests()
+	require.Len(t, got, 2)
+	assert.True(t, bytes.Contains(got[0], setContextInfo))
+	assert.False(t, bytes.Contains(got[1], setContextInfo))
+
+	// once cleared, queries are sent alone
+	_, err = conn.ExecContext(ctx, "select 2")
+	require.NoError(t, err)
+	got = requests()
+	require.Len(t, got, 1)
+	assert.True(t, isSQLBatch(got[0], "select 2"))
+
+	for _, tt := range []struct {
+		query string
+		ctx   context.Context
+	}{
+		{"sp_who", traced},
+		{"select 1", WithSQLBatch(traced)},
+	} {
+		_, err = conn.ExecContext(tt.ctx, tt.query)
+		require.NoError(t, err, tt.query)
+		got = requests()
+		require.Len(t, got, 1, tt.query)
+		assert.False(t, bytes.Contains(got[0], str2ucs2("CONTEXT_INFO")), tt.query)
+	}
+
+	// a session reset clears CONTEXT_INFO, so the id is set again
+	_, err = conn.ExecContext(traced, "select 1")
+	require.NoError(t, err)
+	require.Len(t, requests(), 2)
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*Conn).resetSession = true
+		return nil
+	}))
+	_, err = conn.ExecContext(traced, "select 1")
+	require.NoError(t, err)
+	got = requests()
+	require.Len(t, got, 2, "after a reset")
+	assert.True(t, bytes.Contains(got[0], setContextInfo))
+
+	connector.TraceContextInfo = false
+	_, err = conn.ExecContext(ctx, "select 1")
+	require.NoError(t, err)
+	got = requests()
+	require.Len(t, got, 1, "only with the option")
+	assert.True(t, isSQLBatch(got[0], "select 1"))
+}
+
+func TestContextInfoIsTruncated(t *testing.T) {
+	conn := &Conn{connector: &Connector{TraceContextInfo: true}}
+	info, ok := conn.contextInfo(WithTraceID(context.Background(), strings.Repeat("x", 200)), false, false)
+	require.True(t, ok)
+	assert.Len(t, info, maxContextInfo)
+}
+
+func TestTraceContextInfoIsSetBeforeTheQuery(t *testing.T) {
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	connector.TraceContextInfo = true
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var info []byte
+	err = conn.QueryRowContext(WithTraceID(ctx, "0af7651916cd43dd"), "select context_info()").Scan(&info)
+	require.NoError(t, err)
+	assert.Equal(t, "0af7651916cd43dd", string(bytes.TrimRight(info, "\x00")))
+
+	err = conn.QueryRowContext(ctx, "select context_info()").Scan(&info)
+	require.NoError(t, err)
+	assert.Empty(t, bytes.TrimRight(info, "\x00"), "a query without a trace id clears it")
+
+	// a statement that must start a batch runs with the id set
+	traced := WithTraceID(ctx, "0af7651916cd43dd")
+	_, err = conn.ExecContext(traced, "create procedure #traced as select context_info()")
+	require.NoError(t, err)
+	err = conn.QueryRowContext(traced, "exec #traced").Scan(&info)
+	require.NoError(t, err)
+	assert.Equal(t, "0af7651916cd43dd", string(bytes.TrimRight(info, "\x00")))
+}
@@ -18,6 +18,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"time"
@@ -100,6 +101,27 @@ func convertAssign(dest, src interface{}) error {
 			*d = s.AppendFormat((*d)[:0], time.RFC3339Nano)
 			return nil
 		}
+	case float64:
+		// The driver returns FLOAT and REAL values as float64. Narrowing to
+		// float32 here avoids the string round trip of the reflection path.
+		switch d := dest.(type) {
+		case *float64:
+			if d == nil {
+				return errNilPtr
+			}
+			*d = s
+			return nil
+		case *float32:
+			if d == nil {
+				return errNilPtr
+			}
+			f32 := float32(s)
+			if math.IsInf(float64(f32), 0) && !math.IsInf(s, 0) {
+				return fmt.Errorf("converting driver.Value type %T (%q) to a float32: %w", src, asString(src), strconv.ErrRange)
+			}
+			*d = f32
+			return nil
+		}
 	case nil:
 		switch d := dest.(type) {
 		case *interface{}:
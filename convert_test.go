@@ -2,7 +2,9 @@ package mssql
 
 import (
 	"database/sql"
+	"math"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -697,6 +699,30 @@ func TestConvertAssign_StringToFloat(t *testing.T) {
 	}
 }
 
+func TestConvertAssign_Float64ToFloat32(t *testing.T) {
+	// REAL values arrive widened to float64 and must narrow back exactly.
+	var f32 float32
+	assert.NoError(t, convertAssign(&f32, float64(float32(3.14))))
+	assert.Equal(t, float32(3.14), f32)
+
+	// FLOAT values are rounded to the nearest float32.
+	assert.NoError(t, convertAssign(&f32, 0.1))
+	assert.Equal(t, float32(0.1), f32)
+
+	assert.NoError(t, convertAssign(&f32, math.Inf(-1)))
+	assert.True(t, math.IsInf(float64(f32), -1))
+
+	err := convertAssign(&f32, 1e300)
+	assert.ErrorIs(t, err, strconv.ErrRange, "values outside the float32 range must fail")
+
+	var f64 float64
+	assert.NoError(t, convertAssign(&f64, 0.1))
+	assert.Equal(t, 0.1, f64)
+
+	var nilF32 *float32
+	assert.ErrorIs(t, convertAssign(nilF32, 0.1), errNilPtr)
+}
+
 func TestConvertAssign_StringDest(t *testing.T) {
 	tests := []struct {
 		name string
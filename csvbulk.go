@@ -0,0 +1,181 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVBulkOptions configures BulkLoadCSV.
+type CSVBulkOptions struct {
+	// Comma is the field delimiter, ',' when zero. Use '\t' for TSV.
+	Comma rune
+	// Comment, when set, starts lines that are ignored.
+	Comment rune
+	// LazyQuotes allows quotes in unquoted fields, as found in TSV files
+	// that do not quote.
+	LazyQuotes bool
+	// Header means the first record holds the names of the destination
+	// columns of the fields.
+	Header bool
+	// Columns names the destination columns of the fields, and overrides
+	// the header. Without a header or Columns the fields are the columns of
+	// the table in order.
+	Columns []string
+	// Fields equal to Null are loaded as NULL. When Null is empty, empty
+	// fields are NULL.
+	Null string
+	// Bulk are the options of the bulk copy.
+	Bulk BulkOptions
+}
+
+// csvTimeLayouts are the layouts of date and time fields, tried in order.
+var csvTimeLayouts = []string{
+	sqlDateTimeFormat,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	sqlDateFormat,
+}
+
+// BulkLoadCSV bulk copies the CSV records read from r into table and
+// returns the number of rows copied. Quoted fields may hold delimiters,
+// quotes written twice and newlines. The fields are converted to the types
+// of the destination columns: numbers, bits as true, false, 1 or 0, dates
+// and times as ISO 8601, binary as hex with an optional 0x prefix, and
+// uniqueidentifier in its string form.
+//
+// The records are read as they are copied, so large files are streamed.
+// A record that cannot be read or converted ends the copy with an error
+// reporting its line, and the rows before it are copied. Load in a
+// transaction to keep none of them.
+func BulkLoadCSV(ctx context.Context, conn *sql.Conn, table string, r io.Reader, opts CSVBulkOptions) (int64, error) {
+	var rowcount int64
+	err := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return errors.New("mssql: BulkLoadCSV requires a connection of this driver")
+		}
+		var err error
+		rowcount, err = c.bulkLoadCSV(ctx, table, r, opts)
+		return err
+	})
+	return rowcount, err
+}
+
+func (c *Conn) bulkLoadCSV(ctx context.Context, table string, r io.Reader, opts CSVBulkOptions) (int64, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.Comment = opts.Comment
+	reader.LazyQuotes = opts.LazyQuotes
+	reader.ReuseRecord = true
+	// the number of fields is checked against the columns
+	reader.FieldsPerRecord = -1
+
+	columns := opts.Columns
+	if opts.Header {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("mssql: reading the CSV header: %w", err)
+		}
+		if columns == nil {
+			columns = append([]string(nil), header...)
+		}
+	}
+
+	b := c.CreateBulkContext(ctx, table, columns)
+	b.Options = opts.Bulk
+	if columns == nil {
+		if err := b.getMetadata(ctx); err != nil {
+			return 0, err
+		}
+		for _, col := range b.metadata {
+			b.columnsName = append(b.columnsName, col.ColName)
+		}
+	}
+	if err := b.matchColumns(ctx); err != nil {
+		return 0, err
+	}
+
+	loc := getTimezone(c)
+	row := make([]interface{}, len(b.bulkColumns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.Done()
+			return b.rowsCopied, fmt.Errorf("mssql: reading CSV: %w", err)
+		}
+		if len(record) != len(row) {
+			line, _ := reader.FieldPos(0)
+			b.Done()
+			return b.rowsCopied, fmt.Errorf("mssql: CSV line %d has %d fields, the copy has %d columns", line, len(record), len(row))
+		}
+		for i, field := range record {
+			if row[i], err = csvValue(field, opts.Null, b.bulkColumns[i], loc); err != nil {
+				line, _ := reader.FieldPos(i)
+				b.Done()
+				return b.rowsCopied, fmt.Errorf("mssql: CSV line %d, column %s: %w", line, b.bulkColumns[i].ColName, err)
+			}
+		}
+		if err = b.AddRow(row); err != nil {
+			line, _ := reader.FieldPos(0)
+			b.Done()
+			return b.rowsCopied, fmt.Errorf("mssql: CSV line %d: %w", line, err)
+		}
+	}
+	return b.Done()
+}
+
+// csvValue converts field to a value of the type of col.
+func csvValue(field, null string, col columnStruct, loc *time.Location) (interface{}, error) {
+	if field == null {
+		return nil, nil
+	}
+	switch col.ti.TypeId {
+	case typeInt1, typeInt2, typeInt4, typeInt8, typeIntN:
+		return strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+	case typeFlt4, typeFlt8, typeFltN:
+		return strconv.ParseFloat(strings.TrimSpace(field), 64)
+	case typeBit, typeBitN:
+		return strconv.ParseBool(strings.TrimSpace(field))
+	case typeDateTime, typeDateTimeN, typeDateTim4, typeDateTime2N, typeDateTimeOffsetN:
+		field = strings.TrimSpace(field)
+		for _, layout := range csvTimeLayouts {
+			if t, err := time.ParseInLocation(layout, field, loc); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot parse %q as a date and time", field)
+	case typeBigVarBin, typeBigBinary, typeImage:
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "0x") || strings.HasPrefix(field, "0X") {
+			field = field[2:]
+		}
+		return hex.DecodeString(field)
+	case typeGuid:
+		var u UniqueIdentifier
+		if err := u.Scan(strings.TrimSpace(field)); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	// text, date, time, decimal and money columns convert strings
+	return field, nil
+}
@@ -0,0 +1,125 @@
+package mssql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVValue(t *testing.T) {
+	col := func(typeID uint8, size int) columnStruct {
+		return columnStruct{ti: typeInfo{TypeId: typeID, Size: size}}
+	}
+	loc := time.FixedZone("test", 3600)
+	tests := []struct {
+		field string
+		col   columnStruct
+		want  interface{}
+	}{
+		{"42", col(typeIntN, 4), int64(42)},
+		{" -7 ", col(typeInt8, 8), int64(-7)},
+		{"1.5", col(typeFltN, 8), 1.5},
+		{"1", col(typeBitN, 1), true},
+		{"false", col(typeBitN, 1), false},
+		{"2024-02-29 13:45:00", col(typeDateTime2N, 8), time.Date(2024, 2, 29, 13, 45, 0, 0, loc)},
+		{"2024-02-29T13:45:00.5Z", col(typeDateTimeOffsetN, 10), time.Date(2024, 2, 29, 13, 45, 0, 500000000, time.UTC)},
+		{"2024-02-29", col(typeDateTimeN, 8), time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+		{"0x0aFF", col(typeBigVarBin, 10), []byte{0x0a, 0xff}},
+		{"6F9619FF-8B86-D011-B42D-00C04FC964FF", col(typeGuid, 16),
+			UniqueIdentifier{0x6F, 0x96, 0x19, 0xFF, 0x8B, 0x86, 0xD0, 0x11, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}},
+		{"12.50", col(typeDecimalN, 5), "12.50"},
+		{" text, kept ", col(typeNVarChar, 40), " text, kept "},
+	}
+	for _, tt := range tests {
+		got, err := csvValue(tt.field, "", tt.col, loc)
+		require.NoError(t, err, tt.field)
+		if want, ok := tt.want.(time.Time); ok {
+			assert.True(t, want.Equal(got.(time.Time)), "%s: got %v", tt.field, got)
+			continue
+		}
+		assert.Equal(t, tt.want, got, tt.field)
+	}
+
+	for _, null := range []string{"", `\N`} {
+		got, err := csvValue(null, null, col(typeIntN, 4), loc)
+		assert.NoError(t, err)
+		assert.Nil(t, got, "%q is NULL", null)
+	}
+	got, err := csvValue("", `\N`, col(typeNVarChar, 40), loc)
+	assert.NoError(t, err)
+	assert.Equal(t, "", got, "empty is not NULL with another sentinel")
+
+	for _, bad := range []struct {
+		field string
+		col   columnStruct
+	}{
+		{"4x", col(typeIntN, 4)},
+		{"yes", col(typeBitN, 1)},
+		{"29/02/2024", col(typeDateTime2N, 8)},
+		{"0xZZ", col(typeBigVarBin, 10)},
+	} {
+		_, err := csvValue(bad.field, "", bad.col, loc)
+		assert.Error(t, err, bad.field)
+	}
+}
+
+func TestBulkLoadCSV(t *testing.T) {
+	pool, logger := open(t)
+	defer pool.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := pool.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #csv (id int NOT NULL, name nvarchar(50), note nvarchar(100), price decimal(10,2), active bit, created datetime2(0))")
+	require.NoError(t, err)
+
+	const data = "id,name,note,price,active,created\n" +
+		"1,\"Smith, John\",\"says \"\"hi\"\"\",12.50,true,2024-02-29 13:45:00\n" +
+		"2,Jane,\"two\nlines\",\\N,0,\\N\n" +
+		"3,\\N,,7,1,2024-03-01T08:00:00\n"
+	n, err := BulkLoadCSV(ctx, conn, "#csv", strings.NewReader(data), CSVBulkOptions{Header: true, Null: `\N`})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	rows, err := conn.QueryContext(ctx, "SELECT id, name, note, price, active, created FROM #csv ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+	type record struct {
+		id      int
+		name    *string
+		note    *string
+		price   *string
+		active  bool
+		created *time.Time
+	}
+	var got []record
+	for rows.Next() {
+		var r record
+		require.NoError(t, rows.Scan(&r.id, &r.name, &r.note, &r.price, &r.active, &r.created))
+		got = append(got, r)
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, got, 3)
+	assert.Equal(t, "Smith, John", *got[0].name)
+	assert.Equal(t, `says "hi"`, *got[0].note)
+	assert.Equal(t, "12.50", *got[0].price)
+	assert.True(t, got[0].active)
+	assert.Equal(t, time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC), got[0].created.UTC())
+	assert.Equal(t, "two\nlines", *got[1].note, "embedded newline")
+	assert.Nil(t, got[1].price, "NULL sentinel")
+	assert.Nil(t, got[1].created)
+	assert.False(t, got[1].active)
+	assert.Nil(t, got[2].name)
+	assert.Equal(t, "", *got[2].note, "an empty field is not the NULL sentinel")
+
+	tsv := "4\tTab\tseparated\t1\t1\t2024-01-01\n5\tbad\trow\tx\t1\t2024-01-01\n"
+	n, err = BulkLoadCSV(ctx, conn, "#csv", strings.NewReader(tsv), CSVBulkOptions{Comma: '\t'})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+	assert.Equal(t, int64(1), n, "the rows before the bad record are copied")
+}
@@ -0,0 +1,44 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// sessionInitSQL returns the batch run on each new or reset session: the
+// SET statements of the dateformat and datefirst connection parameters,
+// then SessionInitSQL. The session reset restores the defaults of the
+// language of the login, so the settings are applied again after each.
+func (c *Connector) sessionInitSQL() string {
+	var sets []string
+	if c.params.DateFormat != "" {
+		// validated by msdsn.Parse, which only accepts the six orders
+		sets = append(sets, "SET DATEFORMAT "+c.params.DateFormat+";")
+	}
+	if c.params.DateFirst != 0 {
+		sets = append(sets, fmt.Sprintf("SET DATEFIRST %d;", c.params.DateFirst))
+	}
+	if len(sets) == 0 {
+		return c.SessionInitSQL
+	}
+	if c.SessionInitSQL != "" {
+		sets = append(sets, c.SessionInitSQL)
+	}
+	return strings.Join(sets, "\n")
+}
+
+// DateSettings returns the DATEFORMAT and DATEFIRST settings of the session
+// of conn, such as "dmy" and 1. They decide how date strings converted by
+// the server, such as '02/01/2024', are read, and which weekday DATEPART
+// returns 1 for. They default to those of the language of the login, and
+// are set with the dateformat and datefirst connection parameters.
+func DateSettings(ctx context.Context, conn *sql.Conn) (format string, first int, err error) {
+	err = conn.QueryRowContext(ctx,
+		"SELECT date_format, @@DATEFIRST FROM sys.dm_exec_sessions WHERE session_id = @@SPID").Scan(&format, &first)
+	if err != nil {
+		return "", 0, err
+	}
+	return format, first, nil
+}
@@ -0,0 +1,54 @@
+package mssql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectorSessionInitSQLDateSettings(t *testing.T) {
+	c := &Connector{}
+	assert.Equal(t, "", c.sessionInitSQL())
+	c.SessionInitSQL = "SET XACT_ABORT ON"
+	assert.Equal(t, "SET XACT_ABORT ON", c.sessionInitSQL())
+
+	c.params = msdsn.Config{DateFormat: "dmy", DateFirst: 1}
+	assert.Equal(t, "SET DATEFORMAT dmy;\nSET DATEFIRST 1;\nSET XACT_ABORT ON", c.sessionInitSQL())
+	c.SessionInitSQL = ""
+	c.params.DateFormat = ""
+	assert.Equal(t, "SET DATEFIRST 1;", c.sessionInitSQL())
+}
+
+func TestDateSettings(t *testing.T) {
+	config := testConnParams(t)
+	config.DateFormat = "dmy"
+	config.DateFirst = 1
+	db, err := sql.Open("sqlserver", config.URL().String())
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := testContext(t)
+
+	// the second round runs on the same connection after its session reset
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		format, first, err := DateSettings(ctx, conn)
+		require.NoError(t, err)
+		assert.Equal(t, "dmy", format)
+		assert.Equal(t, 1, first)
+
+		var month, weekday int
+		// 2024-01-01 is a Monday, the first day of the week with DATEFIRST 1
+		err = conn.QueryRowContext(ctx,
+			"SELECT DATEPART(month, CAST('02/01/2024' AS datetime)), DATEPART(weekday, CAST('01/01/2024' AS datetime))").
+			Scan(&month, &weekday)
+		require.NoError(t, err)
+		assert.Equal(t, 1, month, "'02/01/2024' is read as day/month/year")
+		assert.Equal(t, 1, weekday)
+		require.NoError(t, conn.Close())
+	}
+}
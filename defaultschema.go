@@ -0,0 +1,36 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// checkDefaultSchema verifies that unqualified names of the session resolve
+// to schema, for the default schema connection parameter. SQL Server cannot
+// change the default schema of a session, so a mismatch fails the login.
+func (c *Conn) checkDefaultSchema(ctx context.Context, schema string) error {
+	stmt, err := c.prepareContext(ctx, "SELECT SCHEMA_NAME()")
+	if err != nil {
+		return err
+	}
+	rows, err := stmt.QueryContext(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("mssql: SCHEMA_NAME() returned no rows")
+		}
+		return err
+	}
+	actual, _ := dest[0].(string)
+	if !strings.EqualFold(actual, schema) {
+		return fmt.Errorf("mssql: the default schema is %q, not %q: it is a property of the database user and cannot be set by the connection, change it with ALTER USER ... WITH DEFAULT_SCHEMA = %s or qualify object names", actual, schema, TSQLQuoter{}.ID(schema))
+	}
+	return nil
+}
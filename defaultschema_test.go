@@ -0,0 +1,55 @@
+package mssql
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSchemaMismatchFailsLogin(t *testing.T) {
+	// the fake server answers SCHEMA_NAME() with an int, which names no schema
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString() + ";default schema=sales")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	err = db.PingContext(testContext(t))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `not "sales"`)
+	assert.Contains(t, err.Error(), "ALTER USER ... WITH DEFAULT_SCHEMA = [sales]")
+	assert.Equal(t, []int{1}, server.requestsPerConn(), "one check, no other request")
+}
+
+func TestDefaultSchema(t *testing.T) {
+	checkConnStr(t)
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	var schema string
+	require.NoError(t, conn.QueryRow("SELECT SCHEMA_NAME()").Scan(&schema))
+
+	for _, tt := range []struct {
+		schema string
+		ok     bool
+	}{
+		{schema, true},
+		{strings.ToUpper(schema), true},
+		{"go_mssqldb_no_such_schema", false},
+	} {
+		config := testConnParams(t)
+		config.DefaultSchema = tt.schema
+		db, err := sql.Open("sqlserver", config.URL().String())
+		require.NoError(t, err)
+		err = db.PingContext(testContext(t))
+		db.Close()
+		if tt.ok {
+			assert.NoError(t, err, tt.schema)
+		} else {
+			assert.ErrorContains(t, err, "property of the database user", tt.schema)
+		}
+	}
+}
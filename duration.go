@@ -0,0 +1,47 @@
+package mssql
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxTimeOfDay is the first duration a TIME value cannot hold.
+const maxTimeOfDay = 24 * time.Hour
+
+// Duration is a time of day as the time elapsed since midnight: a Duration
+// parameter is sent as TIME(7), and a TIME column scans into one, so
+// binding and scanning round trip without constructing a time.Time. Use
+// sql.Null[Duration] for nullable columns. A time.Duration parameter is
+// sent as a bigint of nanoseconds, as for any int64.
+type Duration time.Duration
+
+// Scan implements the sql.Scanner interface.
+func (d *Duration) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*d = Duration(time.Duration(v.Hour())*time.Hour +
+			time.Duration(v.Minute())*time.Minute +
+			time.Duration(v.Second())*time.Second +
+			time.Duration(v.Nanosecond()))
+		return nil
+	case nil:
+		return fmt.Errorf("mssql: cannot scan NULL into Duration, use sql.Null[mssql.Duration]")
+	default:
+		return fmt.Errorf("mssql: cannot scan %T into Duration", value)
+	}
+}
+
+// checkTimeOfDay verifies that d fits within a TIME value.
+func checkTimeOfDay(d time.Duration) error {
+	if d < 0 || d >= maxTimeOfDay {
+		return fmt.Errorf("mssql: duration %v is outside the range of TIME, which holds 00:00:00 through 23:59:59.9999999", d)
+	}
+	return nil
+}
+
+// encodeDuration encodes a duration since midnight as a TIME value.
+func encodeDuration(d time.Duration, scale int) []byte {
+	buf := make([]byte, calcTimeSize(scale))
+	encodeTimeInt(int(d/time.Second), int(d%time.Second), scale, buf)
+	return buf
+}
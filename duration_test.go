@@ -0,0 +1,99 @@
+package mssql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationParam(t *testing.T) {
+	d := 13*time.Hour + 45*time.Minute + 30*time.Second + 1234567*100*time.Nanosecond
+	s := &Stmt{}
+	p, err := s.makeParam(mustConvert(t, Duration(d)))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeTimeN), p.ti.TypeId)
+	assert.Equal(t, uint8(7), p.ti.Scale)
+	assert.Equal(t, encodeTime(13, 45, 30, 123456700, 7), p.buffer)
+
+	// a time.Duration is an int64, of any length
+	p, err = s.makeParam(mustConvert(t, 36*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeIntN), p.ti.TypeId)
+	assert.Equal(t, 8, p.ti.Size)
+}
+
+func TestDurationParamOutOfRange(t *testing.T) {
+	c := &Conn{}
+	for _, d := range []Duration{Duration(24 * time.Hour), Duration(36 * time.Hour), Duration(-time.Second)} {
+		nv := &driver.NamedValue{Name: "p", Ordinal: 1, Value: d}
+		err := c.CheckNamedValue(nv)
+		require.Error(t, err, "%v", d)
+		assert.Contains(t, err.Error(), "outside the range of TIME")
+		assert.Contains(t, err.Error(), "@p")
+	}
+	nv := &driver.NamedValue{Ordinal: 1, Value: 25 * time.Hour}
+	require.NoError(t, c.CheckNamedValue(nv))
+	assert.Equal(t, int64(25*time.Hour), nv.Value)
+}
+
+func TestDurationScan(t *testing.T) {
+	for _, scale := range []uint8{0, 3, 7} {
+		want := 23*time.Hour + 59*time.Minute + 59*time.Second
+		buf := encodeDuration(want, int(scale))
+		var d Duration
+		require.NoError(t, d.Scan(decodeTime(scale, buf, time.UTC)))
+		assert.Equal(t, want, time.Duration(d), "scale %d", scale)
+	}
+
+	var d Duration
+	assert.Error(t, d.Scan(nil))
+	assert.Error(t, d.Scan("12:00:00"))
+
+	var n sql.Null[Duration]
+	require.NoError(t, n.Scan(nil))
+	assert.False(t, n.Valid)
+	require.NoError(t, n.Scan(time.Date(1, 1, 1, 8, 30, 0, 0, time.UTC)))
+	assert.True(t, n.Valid)
+	assert.Equal(t, Duration(8*time.Hour+30*time.Minute), n.V)
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	tests := []struct {
+		scale int
+		value time.Duration
+	}{
+		{0, 0},
+		{0, 13*time.Hour + 45*time.Minute + 30*time.Second},
+		{3, 9*time.Hour + 5*time.Second + 123*time.Millisecond},
+		{7, 23*time.Hour + 59*time.Minute + 59*time.Second + 9999999*100*time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("time(%d) %v", tt.scale, tt.value), func(t *testing.T) {
+			var d Duration
+			query := fmt.Sprintf("select cast(@p1 as time(%d))", tt.scale)
+			require.NoError(t, conn.QueryRow(query, Duration(tt.value)).Scan(&d))
+			assert.Equal(t, tt.value, time.Duration(d))
+		})
+	}
+
+	var n sql.Null[Duration]
+	require.NoError(t, conn.QueryRow("select cast(null as time)").Scan(&n))
+	assert.False(t, n.Valid)
+
+	_, err := conn.Exec("select @p1", Duration(25*time.Hour))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside the range of TIME")
+
+	var baseType string
+	require.NoError(t, conn.QueryRow("select sql_variant_property(@p1, 'BaseType')", 36*time.Hour).Scan(&baseType))
+	assert.Equal(t, "bigint", baseType, "a time.Duration is sent as before")
+}
@@ -22,9 +22,34 @@ type Error struct {
 	// All lists all errors that were received from first to last.
 	// This includes the last one, which is described in the other members.
 	All []Error
+	// RowsAffected is the number of rows affected by the statements of the
+	// request that completed, when the error is returned by Exec: a batch
+	// goes on after most errors, and the statements before and after the
+	// one that failed may have changed rows, which stay changed outside a
+	// transaction. It is counted as for Result.RowsAffected, and is 0 for
+	// errors returned by other calls.
+	RowsAffected int64
+
+	// login is set for the error of a rejected login, which errors.As
+	// returns as a LoginError.
+	login *loginInfo
+	// bulk is set for the error of a rejected bulk copy, which errors.As
+	// returns as a BulkError.
+	bulk *BulkError
+}
+
+// loginInfo is the login an Error rejected.
+type loginInfo struct {
+	database string
 }
 
 func (e Error) Error() string {
+	if e.login != nil {
+		return e.loginError().Error()
+	}
+	if e.bulk != nil {
+		return e.bulk.Error()
+	}
 	errChain := e.All
 	if len(errChain) == 0 {
 		errChain = []Error{e}
@@ -39,6 +64,35 @@ func (e Error) Error() string {
 	return msg.String()
 }
 
+// As lets errors.As find an Error with a pointer to an *Error variable as
+// well as with a pointer to an Error variable, the LoginError of the Error
+// of a rejected login and the BulkError of that of a rejected bulk copy.
+func (e Error) As(target interface{}) bool {
+	switch p := target.(type) {
+	case **Error:
+		*p = &e
+		return true
+	case *LoginError:
+		if e.login != nil {
+			*p = e.loginError()
+			return true
+		}
+	case *BulkError:
+		if e.bulk != nil {
+			*p = *e.bulk
+			return true
+		}
+	}
+	return false
+}
+
+// loginError returns the LoginError of the Error of a rejected login.
+func (e Error) loginError() LoginError {
+	database := e.login.database
+	e.login = nil
+	return LoginError{Err: e, Database: database}
+}
+
 func (e Error) String() string {
 	return e.Message
 }
@@ -80,6 +134,12 @@ func (e StreamError) Error() string {
 	return "Invalid TDS stream: " + e.InnerError.Error()
 }
 
+// Unwrap returns the error that made the stream invalid, such as the
+// net.Error of a connection that broke while a reply was read.
+func (e StreamError) Unwrap() error {
+	return e.InnerError
+}
+
 func badStreamPanic(err error) {
 	panic(StreamError{InnerError: err})
 }
@@ -131,3 +191,156 @@ func (r RetryableError) Unwrap() error {
 func (r RetryableError) Is(err error) bool {
 	return err == driver.ErrBadConn
 }
+
+// LoginFailure classifies the cause of a LoginError.
+type LoginFailure int
+
+const (
+	// LoginFailureUnknown is reported when the server does not reveal the
+	// cause. SQL Server sends error 18456 with state 1 for most failed
+	// logins and records the real state only in its error log.
+	LoginFailureUnknown LoginFailure = iota
+	// LoginFailureCredentials means the login name or password was
+	// rejected, or the login may not use the requested authentication.
+	LoginFailureCredentials
+	// LoginFailureAccountDisabled means the login is disabled or locked out.
+	LoginFailureAccountDisabled
+	// LoginFailurePasswordExpired means the password expired or must be changed.
+	LoginFailurePasswordExpired
+	// LoginFailureDatabaseUnavailable means the login is valid but the
+	// requested database could not be opened, for example while it is being
+	// recovered or failed over.
+	LoginFailureDatabaseUnavailable
+	// LoginFailureServerUnavailable means the server is paused, busy or
+	// reconfiguring and does not accept new logins right now.
+	LoginFailureServerUnavailable
+	// LoginFailureContainedUser means the database of the connection
+	// string rejected the password of one of its contained database users.
+	LoginFailureContainedUser
+)
+
+func (f LoginFailure) String() string {
+	switch f {
+	case LoginFailureCredentials:
+		return "invalid credentials"
+	case LoginFailureAccountDisabled:
+		return "account disabled"
+	case LoginFailurePasswordExpired:
+		return "password expired"
+	case LoginFailureDatabaseUnavailable:
+		return "database unavailable"
+	case LoginFailureServerUnavailable:
+		return "server unavailable"
+	case LoginFailureContainedUser:
+		return "contained user rejected"
+	}
+	return "unknown"
+}
+
+// LoginError describes a login the server rejected. The connection returns
+// the errors sent by the server as an Error, as for any other request, and
+// errors.As with a LoginError finds the LoginError of that Error, whose
+// Err holds it.
+//
+// Cause is derived from the error numbers and, for error 18456, from its
+// state. The states of 18456 that identify a cause are:
+//
+//	2, 5       unknown login name              LoginFailureCredentials
+//	7          login disabled                  LoginFailureAccountDisabled
+//	8, 9       wrong password                  LoginFailureCredentials
+//	11, 12     login has no access to server   LoginFailureCredentials
+//	13         server paused                   LoginFailureServerUnavailable
+//	18         password must be changed        LoginFailurePasswordExpired
+//	38, 40, 46 database could not be opened    LoginFailureDatabaseUnavailable
+//	58         authentication mode mismatch    LoginFailureCredentials
+//	65         wrong contained user password   LoginFailureContainedUser
+//
+// On-premises servers usually report state 1 to clients, in which case
+// Cause is LoginFailureUnknown unless another error number identifies it.
+//
+// A contained database user, including a Microsoft Entra user of an Azure
+// SQL database, is authenticated by its database rather than the server,
+// so the database of the connection string must be set to it. The error
+// message of a login rejected by such a database names it, and that of a
+// login whose credentials were rejected without a database tells so.
+type LoginError struct {
+	Err Error
+	// Database is the database of the connection string, which the login
+	// opens and, for contained database users, authenticates against. It
+	// is empty when the login was authenticated by the server.
+	Database string
+}
+
+func (e LoginError) Error() string {
+	switch cause := e.Cause(); {
+	case cause == LoginFailureContainedUser:
+		return fmt.Sprintf("%s\nmssql: the password of the contained user was rejected by database %q", e.Err.Error(), e.Database)
+	case cause == LoginFailureCredentials && e.Database == "":
+		return e.Err.Error() + "\nmssql: no database is set, so the server authenticated the login: set database in the connection string for a contained database user"
+	}
+	return e.Err.Error()
+}
+
+func (e LoginError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the classified cause of the login failure.
+func (e LoginError) Cause() LoginFailure {
+	errs := e.Err.All
+	if len(errs) == 0 {
+		errs = []Error{e.Err}
+	}
+	// The server may send a specific error before the generic 18456.
+	cause := LoginFailureUnknown
+	for _, err := range errs {
+		if c := loginFailureOf(err); c != LoginFailureUnknown {
+			cause = c
+		}
+	}
+	return cause
+}
+
+// Temporary reports whether the login may succeed if retried later, as
+// when the database or server is unavailable. Credential failures are not
+// temporary.
+func (e LoginError) Temporary() bool {
+	switch e.Cause() {
+	case LoginFailureDatabaseUnavailable, LoginFailureServerUnavailable:
+		return true
+	}
+	return false
+}
+
+func loginFailureOf(err Error) LoginFailure {
+	switch err.Number {
+	case 18456:
+		switch err.State {
+		case 2, 5, 8, 9, 11, 12, 58:
+			return LoginFailureCredentials
+		case 65:
+			return LoginFailureContainedUser
+		case 7:
+			return LoginFailureAccountDisabled
+		case 13:
+			return LoginFailureServerUnavailable
+		case 18:
+			return LoginFailurePasswordExpired
+		case 38, 40, 46:
+			return LoginFailureDatabaseUnavailable
+		}
+	case 18470, 18486:
+		// account disabled, account locked out
+		return LoginFailureAccountDisabled
+	case 18487, 18488:
+		// password expired, password must be changed
+		return LoginFailurePasswordExpired
+	case 4060, 40613:
+		// cannot open database, Azure SQL database not currently available
+		return LoginFailureDatabaseUnavailable
+	case 40197, 40501, 49918, 49919, 49920:
+		// Azure SQL service errors, busy or throttled
+		return LoginFailureServerUnavailable
+	}
+	return LoginFailureUnknown
+}
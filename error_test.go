@@ -1,13 +1,20 @@
 package mssql
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServerError(t *testing.T) {
@@ -129,3 +136,190 @@ func TestError_Methods(t *testing.T) {
 	// Test SQLErrorLineNo()
 	assert.Equal(t, err.LineNo, err.SQLErrorLineNo(), "SQLErrorLineNo()")
 }
+
+func TestLoginErrorCause(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []Error
+		cause     LoginFailure
+		temporary bool
+	}{
+		{"state hidden", []Error{{Number: 18456, State: 1}}, LoginFailureUnknown, false},
+		{"wrong password", []Error{{Number: 18456, State: 8}}, LoginFailureCredentials, false},
+		{"disabled", []Error{{Number: 18456, State: 7}}, LoginFailureAccountDisabled, false},
+		{"must change password", []Error{{Number: 18456, State: 18}}, LoginFailurePasswordExpired, false},
+		{"database unavailable", []Error{{Number: 18456, State: 38}}, LoginFailureDatabaseUnavailable, true},
+		{"server paused", []Error{{Number: 18456, State: 13}}, LoginFailureServerUnavailable, true},
+		{"locked out", []Error{{Number: 18486, State: 1}}, LoginFailureAccountDisabled, false},
+		{"cannot open database before 18456", []Error{{Number: 4060, State: 1}, {Number: 18456, State: 1}}, LoginFailureDatabaseUnavailable, true},
+		{"azure database unavailable", []Error{{Number: 40613, State: 1}}, LoginFailureDatabaseUnavailable, true},
+		{"contained user password", []Error{{Number: 18456, State: 65}}, LoginFailureContainedUser, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.errs[len(tt.errs)-1]
+			err.All = tt.errs
+			le := LoginError{Err: err}
+			assert.Equal(t, tt.cause, le.Cause())
+			assert.Equal(t, tt.temporary, le.Temporary())
+		})
+	}
+}
+
+func TestLoginErrorUnwrap(t *testing.T) {
+	var err error = LoginError{Err: Error{Number: 18456, State: 1, Message: "login error: Login failed for user 'sa'."}}
+	assert.Equal(t, "mssql: login error: Login failed for user 'sa'. (18456)", err.Error())
+	var sqlErr Error
+	assert.True(t, errors.As(err, &sqlErr))
+	assert.Equal(t, int32(18456), sqlErr.Number)
+	assert.Equal(t, "unknown", LoginError{}.Cause().String())
+}
+
+func TestLoginErrorContainedUser(t *testing.T) {
+	failed := Error{Number: 18456, Message: "login error: Login failed for user 'ann'."}
+	failed.State = 65
+	assert.Equal(t, "mssql: login error: Login failed for user 'ann'. (18456)\n"+
+		`mssql: the password of the contained user was rejected by database "sales"`, LoginError{Err: failed, Database: "sales"}.Error())
+
+	failed.State = 5
+	assert.Equal(t, "mssql: login error: Login failed for user 'ann'. (18456)\n"+
+		"mssql: no database is set, so the server authenticated the login: set database in the connection string for a contained database user", LoginError{Err: failed}.Error())
+	assert.Equal(t, "mssql: login error: Login failed for user 'ann'. (18456)", LoginError{Err: failed, Database: "sales"}.Error(),
+		"the database authenticated the login")
+	failed.State = 1
+	assert.Equal(t, "mssql: login error: Login failed for user 'ann'. (18456)", LoginError{Err: failed}.Error(), "the cause is unknown")
+}
+
+func TestLoginErrorOfError(t *testing.T) {
+	failed := Error{Number: 18456, State: 65, Message: "login error: Login failed for user 'ann'."}
+	failed.login = &loginInfo{database: "sales"}
+	var err error = failed
+	want := "mssql: login error: Login failed for user 'ann'. (18456)\n" +
+		`mssql: the password of the contained user was rejected by database "sales"`
+	assert.Equal(t, want, err.Error())
+
+	var loginErr LoginError
+	require.True(t, errors.As(fmt.Errorf("connect: %w", err), &loginErr))
+	assert.Equal(t, "sales", loginErr.Database)
+	assert.Equal(t, LoginFailureContainedUser, loginErr.Cause())
+	assert.Equal(t, want, loginErr.Error(), "the hint is added once")
+	var sqlErr Error
+	require.True(t, errors.As(loginErr, &sqlErr))
+	assert.Equal(t, int32(18456), sqlErr.Number)
+
+	assert.False(t, errors.As(Error{Number: 208}, &loginErr), "not a login error")
+}
+
+func TestErrorsAsServerError(t *testing.T) {
+	reply := errorToken(8134, 1, "Divide by zero error encountered.")
+	reply = append(reply, doneToken(doneError|doneFinal, 0)...)
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, splitTransport{bytes.NewReader(makeReplyPacket(reply))}),
+		logger: optionalLogger{},
+	}
+	err := startReading(sess, context.Background(), outputs{}).iterateResponse()
+	require.Error(t, err)
+
+	for _, err := range []error{err, fmt.Errorf("failed to run query: %w", err), ServerError{sqlError: err.(Error)}} {
+		var sqlErr Error
+		require.True(t, errors.As(err, &sqlErr), "%T: %v", err, err)
+		assert.Equal(t, int32(8134), sqlErr.Number)
+		assert.Equal(t, uint8(1), sqlErr.State)
+		assert.Equal(t, uint8(14), sqlErr.Class)
+		assert.Equal(t, "srv", sqlErr.ServerName)
+		var sqlErrPtr *Error
+		require.True(t, errors.As(err, &sqlErrPtr), "%T: %v", err, err)
+		assert.Equal(t, int32(8134), sqlErrPtr.SQLErrorNumber())
+		assert.Equal(t, uint8(1), sqlErrPtr.SQLErrorState())
+	}
+}
+
+func TestErrorsAsNetworkError(t *testing.T) {
+	server := newRowServer(t)
+	db, err := sql.Open("sqlserver", server.connString()+";disableretry=true")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = queryConnNumber(ctx, conn)
+	require.NoError(t, err)
+
+	server.closeConns()
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = queryConnNumber(ctx, conn)
+	require.Error(t, err)
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr), "%T: %v", err, err)
+	var sqlErr Error
+	assert.False(t, errors.As(err, &sqlErr), "a network error is not a server error")
+	var sqlErrPtr *Error
+	assert.False(t, errors.As(err, &sqlErrPtr), "a network error is not a server error")
+
+	err = StreamError{InnerError: &net.OpError{Op: "read", Err: io.EOF}}
+	assert.True(t, errors.As(err, &netErr), "a stream error wraps the network error")
+	assert.False(t, errors.As(err, &sqlErr))
+}
+
+func TestErrorRowsAffectedByPartialBatch(t *testing.T) {
+	doneInProc := func(status uint16, rowCount uint64) []byte {
+		done := doneToken(status, rowCount)
+		done[0] = byte(tokenDoneInProc)
+		return done
+	}
+	doneProc := func(status uint16) []byte {
+		done := doneToken(status, 0)
+		done[0] = byte(tokenDoneProc)
+		return done
+	}
+	divideByZero := errorToken(8134, 1, "Divide by zero error encountered.")
+	tests := []struct {
+		name  string
+		reply [][]byte
+	}{
+		{"batch", [][]byte{
+			doneToken(doneMore|doneCount, 3),
+			divideByZero,
+			doneToken(doneMore|doneError, 0),
+			doneToken(doneCount, 2),
+		}},
+		{"sp_executesql", [][]byte{
+			doneInProc(doneMore|doneCount, 3),
+			divideByZero,
+			doneInProc(doneMore|doneError, 0),
+			doneInProc(doneMore|doneCount, 2),
+			doneProc(doneError),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &tdsSession{
+				buf:    newTdsBuffer(defaultPacketSize, splitTransport{bytes.NewReader(makeReplyPacket(bytes.Join(tt.reply, nil)))}),
+				logger: optionalLogger{},
+			}
+			err := startReading(sess, context.Background(), outputs{}).iterateResponse()
+			var sqlErr Error
+			require.True(t, errors.As(err, &sqlErr), "%T: %v", err, err)
+			assert.Equal(t, int32(8134), sqlErr.Number)
+			assert.Equal(t, int64(5), sqlErr.RowsAffected, "the rows of the statements before and after the error")
+		})
+	}
+}
+
+func TestExecErrorRowsAffected(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec(`create table #partial (n int);
+insert into #partial values (1), (2), (3);
+select 1 / 0;
+insert into #partial values (4), (5);`)
+	var sqlErr Error
+	require.True(t, errors.As(err, &sqlErr), "%T: %v", err, err)
+	assert.Equal(t, int32(8134), sqlErr.Number)
+	assert.Equal(t, int64(5), sqlErr.RowsAffected)
+}
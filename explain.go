@@ -0,0 +1,210 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// showplanColumn names the column of the result sets holding plans.
+const showplanColumn = "Microsoft SQL Server 2005 XML Showplan"
+
+// ExplainEstimated returns the estimated execution plan XML of query
+// without running it. It turns SHOWPLAN_XML on for the session of conn,
+// which makes the server compile the statements it receives instead of
+// executing them, and turns it off again before returning.
+//
+// If turning the setting off fails, the error is returned and conn should
+// be closed, as its statements would no longer run.
+func ExplainEstimated(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) (plan string, err error) {
+	return explain(ctx, conn, "SHOWPLAN_XML", query, args)
+}
+
+// ExplainActual runs query and returns its actual execution plan XML, which
+// adds the row counts and other run time statistics of each operator to the
+// estimated plan. It turns STATISTICS XML on for the session of conn and
+// off again before returning. The rows returned by query are discarded.
+//
+// When query runs several statements the plan of the last one is returned.
+func ExplainActual(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) (plan string, err error) {
+	return explain(ctx, conn, "STATISTICS XML", query, args)
+}
+
+// ExplainProfile runs query with STATISTICS PROFILE on and returns the plan
+// rows the server appends after the results of each statement, with the
+// actual row and execution counts of each operator next to the estimates.
+// It turns the setting off again before returning. The rows returned by
+// query are discarded; to read them too, turn the setting on and read the
+// plan result sets with ScanPlanRows.
+func ExplainProfile(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) (plan []PlanRow, err error) {
+	err = explainResults(ctx, conn, "STATISTICS PROFILE", query, args, func(rows *sql.Rows, cols []string) error {
+		if !IsProfileResultSet(cols) {
+			return nil
+		}
+		stmt, err := ScanPlanRows(rows)
+		plan = append(plan, stmt...)
+		return err
+	})
+	if err == nil && len(plan) == 0 {
+		err = errors.New("mssql: the server returned no execution plan")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func explain(ctx context.Context, conn *sql.Conn, setting, query string, args []interface{}) (plan string, err error) {
+	found := false
+	err = explainResults(ctx, conn, setting, query, args, func(rows *sql.Rows, cols []string) error {
+		if len(cols) != 1 || cols[0] != showplanColumn {
+			return nil
+		}
+		for rows.Next() {
+			if err := rows.Scan(&plan); err != nil {
+				return err
+			}
+			found = true
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = errors.New("mssql: the server returned no execution plan")
+	}
+	if err != nil {
+		return "", err
+	}
+	return plan, nil
+}
+
+// explainResults runs query with setting on, calling read for each of its
+// result sets, and turns setting off again.
+func explainResults(ctx context.Context, conn *sql.Conn, setting, query string, args []interface{}, read func(rows *sql.Rows, cols []string) error) (err error) {
+	// the SET statement must be alone in its batch
+	if _, err = conn.ExecContext(ctx, "SET "+setting+" ON"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, offErr := conn.ExecContext(context.Background(), "SET "+setting+" OFF"); offErr != nil && err == nil {
+			err = fmt.Errorf("mssql: turning %s off failed: %w", setting, offErr)
+		}
+	}()
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for {
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		if err = read(rows, cols); err != nil {
+			return err
+		}
+		for rows.Next() {
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+// PlanRow is an operator of the plan of a statement, a row of the result
+// sets of SET STATISTICS PROFILE ON, or of SHOWPLAN_ALL without the actual
+// counts. The first row of each statement describes the statement itself,
+// with its text in StmtText and no operator.
+type PlanRow struct {
+	// Rows and Executes are the actual number of rows the operator
+	// returned and of times it ran. They are only reported by STATISTICS
+	// PROFILE.
+	Rows     int64
+	Executes int64
+	// StmtText is the text of the statement, or the operator and its
+	// arguments as indented text.
+	StmtText string
+	StmtID   int
+	NodeID   int
+	// Parent is the NodeID of the operator this one feeds, 0 for the top.
+	Parent        int
+	PhysicalOp    string
+	LogicalOp     string
+	Argument      string
+	DefinedValues string
+	EstimateRows  float64
+	EstimateIO    float64
+	EstimateCPU   float64
+	// AvgRowSize is the estimated average row size in bytes.
+	AvgRowSize       int
+	TotalSubtreeCost float64
+	OutputList       string
+	Warnings         string
+	// Type is PLAN_ROW for operators, or the kind of the statement for
+	// statement rows, such as SELECT.
+	Type               string
+	Parallel           bool
+	EstimateExecutions float64
+}
+
+// IsProfileResultSet reports whether cols, the columns of a result set, are
+// those of the plan rows appended by SET STATISTICS PROFILE ON.
+func IsProfileResultSet(cols []string) bool {
+	return len(cols) >= 3 && cols[0] == "Rows" && cols[1] == "Executes" && cols[2] == "StmtText"
+}
+
+// ScanPlanRows reads the rows of the current result set of rows, one of
+// plan rows such as those of SET STATISTICS PROFILE ON, by column name.
+// Columns unknown to PlanRow are skipped and NULL values are left zero.
+func ScanPlanRows(rows *sql.Rows) ([]PlanRow, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var plan []PlanRow
+	dest := make([]interface{}, len(cols))
+	for rows.Next() {
+		var (
+			row                                                PlanRow
+			counts                                             [2]sql.NullInt64
+			stmtID, nodeID, parent, avgRowSize                 sql.NullInt64
+			texts                                              [8]sql.NullString
+			estRows, estIO, estCPU, subtreeCost, estExecutions sql.NullFloat64
+			parallel                                           sql.NullBool
+		)
+		fields := map[string]interface{}{
+			"Rows": &counts[0], "Executes": &counts[1], "StmtText": &texts[0],
+			"StmtId": &stmtID, "NodeId": &nodeID, "Parent": &parent,
+			"PhysicalOp": &texts[1], "LogicalOp": &texts[2], "Argument": &texts[3],
+			"DefinedValues": &texts[4], "EstimateRows": &estRows, "EstimateIO": &estIO,
+			"EstimateCPU": &estCPU, "AvgRowSize": &avgRowSize, "TotalSubtreeCost": &subtreeCost,
+			"OutputList": &texts[5], "Warnings": &texts[6], "Type": &texts[7],
+			"Parallel": &parallel, "EstimateExecutions": &estExecutions,
+		}
+		for i, col := range cols {
+			if f, ok := fields[col]; ok {
+				dest[i] = f
+			} else {
+				dest[i] = new(interface{})
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row.Rows, row.Executes = counts[0].Int64, counts[1].Int64
+		row.StmtText = texts[0].String
+		row.StmtID, row.NodeID, row.Parent = int(stmtID.Int64), int(nodeID.Int64), int(parent.Int64)
+		row.PhysicalOp, row.LogicalOp = texts[1].String, texts[2].String
+		row.Argument, row.DefinedValues = texts[3].String, texts[4].String
+		row.EstimateRows, row.EstimateIO, row.EstimateCPU = estRows.Float64, estIO.Float64, estCPU.Float64
+		row.AvgRowSize = int(avgRowSize.Int64)
+		row.TotalSubtreeCost = subtreeCost.Float64
+		row.OutputList, row.Warnings, row.Type = texts[5].String, texts[6].String, texts[7].String
+		row.Parallel = parallel.Bool
+		row.EstimateExecutions = estExecutions.Float64
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
@@ -0,0 +1,113 @@
+package mssql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "DROP TABLE IF EXISTS dbo.explain_test; CREATE TABLE dbo.explain_test (id int NOT NULL)")
+	require.NoError(t, err)
+	defer conn.ExecContext(ctx, "DROP TABLE IF EXISTS dbo.explain_test")
+	count := func() int {
+		var n int
+		require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM dbo.explain_test").Scan(&n))
+		return n
+	}
+
+	plan, err := ExplainEstimated(ctx, conn, "INSERT INTO dbo.explain_test (id) VALUES (@p1)", 1)
+	require.NoError(t, err)
+	assert.Contains(t, plan, "<ShowPlanXML")
+	assert.NotContains(t, plan, "RunTimeInformation")
+	assert.Equal(t, 0, count(), "the estimated plan does not run the statement, and SHOWPLAN_XML is off")
+
+	plan, err = ExplainActual(ctx, conn, "INSERT INTO dbo.explain_test (id) VALUES (@p1); SELECT id FROM dbo.explain_test", 2)
+	require.NoError(t, err)
+	assert.Contains(t, plan, "<ShowPlanXML")
+	assert.Contains(t, plan, "RunTimeInformation")
+	assert.Equal(t, 1, count(), "the actual plan runs the statement")
+
+	rows, err := conn.QueryContext(ctx, "SELECT id FROM dbo.explain_test")
+	require.NoError(t, err)
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"id"}, cols, "STATISTICS XML is off")
+}
+
+func TestStatisticsProfile(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #profile (id int NOT NULL PRIMARY KEY); INSERT INTO #profile VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "SET STATISTICS PROFILE ON")
+	require.NoError(t, err)
+	rows, err := conn.QueryContext(ctx, "SELECT id FROM #profile WHERE id >= @p1 ORDER BY id", 2)
+	require.NoError(t, err)
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id"}, cols)
+	assert.False(t, IsProfileResultSet(cols))
+	var ids []int
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.Equal(t, []int{2, 3}, ids)
+
+	require.True(t, rows.NextResultSet(), "the plan follows the results")
+	cols, err = rows.Columns()
+	require.NoError(t, err)
+	assert.True(t, IsProfileResultSet(cols), "%v", cols)
+	plan, err := ScanPlanRows(rows)
+	require.NoError(t, err)
+	assert.False(t, rows.NextResultSet())
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.GreaterOrEqual(t, len(plan), 2)
+	assert.Contains(t, plan[0].StmtText, "SELECT id FROM #profile")
+	assert.Equal(t, int64(2), plan[0].Rows)
+	assert.Equal(t, 0, plan[0].Parent)
+	var seek bool
+	for _, op := range plan[1:] {
+		assert.Equal(t, "PLAN_ROW", op.Type)
+		if op.PhysicalOp == "Clustered Index Seek" {
+			seek = true
+			assert.Equal(t, int64(2), op.Rows)
+			assert.Equal(t, int64(1), op.Executes)
+			assert.Greater(t, op.TotalSubtreeCost, 0.0)
+		}
+	}
+	assert.True(t, seek, "%+v", plan)
+	_, err = conn.ExecContext(ctx, "SET STATISTICS PROFILE OFF")
+	require.NoError(t, err)
+
+	plan, err = ExplainProfile(ctx, conn, "SELECT COUNT(*) FROM #profile; SELECT MAX(id) FROM #profile")
+	require.NoError(t, err)
+	stmts := map[int]bool{}
+	for _, op := range plan {
+		stmts[op.StmtID] = true
+	}
+	assert.Len(t, stmts, 2, "the plans of both statements are returned")
+
+	var n int
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM #profile").Scan(&n))
+	assert.Equal(t, 3, n, "STATISTICS PROFILE is off")
+}
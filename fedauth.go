@@ -2,7 +2,11 @@ package mssql
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -57,6 +61,20 @@ func NewSecurityTokenConnector(config msdsn.Config, tokenProvider func(ctx conte
 	return conn, nil
 }
 
+// errTokenWithCredentials is returned for connection strings of access token
+// connectors that also hold SQL credentials, which a login with a token does
+// not send.
+var errTokenWithCredentials = errors.New("mssql: the connection string of an access token connector must not set a user id or password: the login authenticates with the token only")
+
+// checkTokenConfig returns errTokenWithCredentials if config, the
+// configuration of an access token connector, has SQL credentials.
+func checkTokenConfig(config msdsn.Config) error {
+	if config.User != "" || config.Password != "" {
+		return errTokenWithCredentials
+	}
+	return nil
+}
+
 // newADALTokenConnector creates a new connector from a Config and a Active Directory token provider.
 // Token provider implementations are called during federated
 // authentication login sequences where the server provides a service
@@ -79,3 +97,28 @@ func NewActiveDirectoryTokenConnector(config msdsn.Config, adalWorkflow byte, to
 
 	return conn, nil
 }
+
+// fedAuthTokenRefreshMargin is how long before its token expires a
+// connection is replaced. It matches the margin azidentity credentials
+// refresh their cached tokens with, so the new connection gets a new token.
+const fedAuthTokenRefreshMargin = 5 * time.Minute
+
+// fedAuthTokenExpiry returns the expiry of a JWT access token, or the zero
+// time if the token is not a JWT or has no expiry.
+func fedAuthTokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
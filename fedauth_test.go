@@ -2,11 +2,16 @@ package mssql
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFedAuthLibraryConstants(t *testing.T) {
@@ -243,3 +248,62 @@ func TestActiveDirectoryTokenConnector_SPNAndSTSPassedCorrectly(t *testing.T) {
 	assert.Equal(t, expectedSPN, receivedSPN, "adalTokenProvider() received SPN = %q, want %q", receivedSPN, expectedSPN)
 	assert.Equal(t, expectedSTS, receivedSTS, "adalTokenProvider() received STS = %q, want %q", receivedSTS, expectedSTS)
 }
+
+// jwtExpiringAt returns an unsigned JWT whose exp claim is t.
+func jwtExpiringAt(t time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"aud":"https://database.windows.net/","exp":%d}`, t.Unix())))
+	return "e30." + payload + ".sig"
+}
+
+func TestFedAuthTokenExpiry(t *testing.T) {
+	exp := time.Unix(1900000000, 0)
+	assert.Equal(t, exp, fedAuthTokenExpiry(jwtExpiringAt(exp)))
+	assert.True(t, fedAuthTokenExpiry("opaque-token").IsZero(), "not a JWT")
+	assert.True(t, fedAuthTokenExpiry("e30.e30.sig").IsZero(), "no exp claim")
+	assert.True(t, fedAuthTokenExpiry("e30.!!!.sig").IsZero(), "bad encoding")
+}
+
+func TestConnWithExpiringTokenIsReplaced(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		accepted int
+	}{
+		{"fresh token", time.Hour, 1},
+		{"expiring token", time.Minute, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRowServer(t)
+			config, err := msdsn.Parse(server.connString())
+			require.NoError(t, err)
+			connector, err := NewSecurityTokenConnector(config, func(ctx context.Context) (string, error) {
+				return jwtExpiringAt(time.Now().Add(tt.lifetime)), nil
+			})
+			require.NoError(t, err)
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			var n int
+			for i := 0; i < 2; i++ {
+				require.NoError(t, db.QueryRowContext(ctx, "select 1").Scan(&n))
+			}
+			assert.Equal(t, tt.accepted, n, "connection of the last query")
+			assert.Equal(t, tt.accepted, server.accepted())
+		})
+	}
+}
+
+func TestTokenExpiring(t *testing.T) {
+	c := &Conn{sess: &tdsSession{}, connectionGood: true}
+	assert.False(t, c.tokenExpiring(), "no token")
+	c.sess.tokenExpiry = time.Now().Add(time.Hour)
+	assert.False(t, c.tokenExpiring())
+	assert.True(t, c.IsValid())
+	c.sess.tokenExpiry = time.Now().Add(fedAuthTokenRefreshMargin - time.Second)
+	assert.True(t, c.tokenExpiring())
+	assert.False(t, c.IsValid())
+}
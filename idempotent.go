@@ -0,0 +1,172 @@
+package mssql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"io"
+	"strings"
+)
+
+// idempotentWriteParam is the parameter carrying the id of a statement run
+// by Connector.IdempotentWrites, declared by idempotentWriteDecl.
+const (
+	idempotentWriteParam = "@go_mssqldb_write_id"
+	idempotentWriteDecl  = idempotentWriteParam + " uniqueidentifier"
+)
+
+type idempotentWriteKey struct{}
+
+// idempotentWrite is a statement run by Connector.IdempotentWrites: its id
+// is recorded in table by the transaction running it.
+type idempotentWrite struct {
+	table string
+	id    UniqueIdentifier
+}
+
+// idempotentWrite returns a copy of ctx that runs query as an idempotent
+// write, and whether it does: with Connector.IdempotentWrites and
+// Reconnect set, outside transactions, for statements other than stored
+// procedure calls, SQL batches and the statements wrap cannot take, that
+// return no output parameters.
+func (c *Conn) idempotentWrite(ctx context.Context, query string) (context.Context, *idempotentWrite, error) {
+	if c.connector == nil || c.connector.IdempotentWrites == "" || !c.mayReconnect() {
+		return ctx, nil, nil
+	}
+	if isProc(query) || sqlBatchFromContext(ctx) || len(c.outs.params) > 0 || !mayWrap(query) {
+		return ctx, nil, nil
+	}
+	w := &idempotentWrite{table: c.connector.IdempotentWrites}
+	if _, err := rand.Read(w.id[:]); err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, idempotentWriteKey{}, w), w, nil
+}
+
+func idempotentWriteFromContext(ctx context.Context) *idempotentWrite {
+	w, _ := ctx.Value(idempotentWriteKey{}).(*idempotentWrite)
+	return w
+}
+
+// batchFirstObjects are the objects CREATE and ALTER must be the first
+// statement of a batch for.
+var batchFirstObjects = map[string]bool{
+	"DEFAULT": true, "FUNCTION": true, "PROC": true, "PROCEDURE": true,
+	"RULE": true, "SCHEMA": true, "TRIGGER": true, "VIEW": true,
+}
+
+// untransactedObjects are the objects CREATE, ALTER and DROP cannot change
+// in a user transaction.
+var untransactedObjects = map[string]bool{
+	"DATABASE": true, "FULLTEXT": true,
+}
+
+// mayWrap reports whether wrap may run query in a transaction after other
+// statements: query does not start with a statement that must start a
+// batch, such as CREATE PROCEDURE, nor with one that cannot run in a user
+// transaction, such as ALTER DATABASE or BACKUP.
+func mayWrap(query string) bool {
+	words := leadingWords(query, 4)
+	if len(words) == 0 {
+		return true
+	}
+	switch words[0] {
+	case "BACKUP", "RESTORE", "RECONFIGURE", "KILL":
+		return false
+	case "CREATE", "ALTER", "DROP":
+		verb, object := words[0], words[1:]
+		if len(object) >= 2 && object[0] == "OR" && object[1] == "ALTER" {
+			object = object[2:]
+		}
+		if len(object) == 0 {
+			return true
+		}
+		return !untransactedObjects[object[0]] && (verb == "DROP" || !batchFirstObjects[object[0]])
+	}
+	return true
+}
+
+// leadingWords returns the first n words of query, upper cased, skipping
+// white space and comments, up to the first other character.
+func leadingWords(query string, n int) []string {
+	var words []string
+	for i := 0; i < len(query) && len(words) < n; i++ {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case strings.HasPrefix(query[i:], "--"):
+			i = skipUntil(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			i = skipComment(query, i+2)
+		case isNameChar(c):
+			end := skipName(query, i)
+			words = append(words, strings.ToUpper(query[i:end]))
+			i = end - 1
+		default:
+			return words
+		}
+	}
+	return words
+}
+
+// wrap returns query run in a transaction that first records the id of w,
+// which keeps the row locked until the transaction ends, then runs query
+// and records its rows affected. XACT_ABORT rolls the transaction back on
+// any error. Only the rows affected by query are counted, as NOCOUNT is
+// set around the other statements; the SET options revert when
+// sp_executesql returns.
+func (w *idempotentWrite) wrap(query string) string {
+	if !strings.HasSuffix(strings.TrimSpace(query), ";") {
+		query += ";"
+	}
+	return "SET XACT_ABORT ON;\n" +
+		"DECLARE @go_mssqldb_nocount int = @@OPTIONS & 512;\n" +
+		"SET NOCOUNT ON;\n" +
+		"BEGIN TRANSACTION;\n" +
+		"INSERT INTO " + w.table + " (id) VALUES (" + idempotentWriteParam + ");\n" +
+		"IF @go_mssqldb_nocount = 0 SET NOCOUNT OFF;\n" +
+		query + "\n" +
+		"DECLARE @go_mssqldb_rows bigint = ROWCOUNT_BIG();\n" +
+		"SET NOCOUNT ON;\n" +
+		"UPDATE " + w.table + " SET rows_affected = @go_mssqldb_rows WHERE id = " + idempotentWriteParam + ";\n" +
+		"COMMIT TRANSACTION;"
+}
+
+// param returns the parameter of the id of w.
+func (w *idempotentWrite) param() param {
+	var p param
+	p.Name = idempotentWriteParam
+	p.ti.TypeId = typeGuid
+	p.ti.Size = 16
+	guid, _ := w.id.Value()
+	p.buffer = guid.([]byte)
+	return p
+}
+
+// committed reports whether the transaction of w committed, on the new
+// session of c after the session that sent it broke, and the result of
+// the statement if it did. Reading the id with a locking read waits for
+// the transaction to end if it is still running on the server, even when
+// the database reads committed rows from snapshots.
+func (w *idempotentWrite) committed(ctx context.Context, c *Conn) (driver.Result, bool, error) {
+	// keep the outputs of the statement, which is sent again if it did not
+	// commit
+	outs := c.outs
+	defer func() { c.outs = outs }()
+	c.clearOuts()
+	s := &Stmt{c: c, query: "SELECT rows_affected FROM " + w.table + " WITH (READCOMMITTEDLOCK) WHERE id = @p1", paramCount: 1}
+	rows, err := s.queryContext(ctx, []namedValue{{Ordinal: 1, Value: w.id}})
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	switch err = rows.Next(dest); err {
+	case nil:
+		affected, _ := dest[0].(int64)
+		return &Result{c, affected}, true, nil
+	case io.EOF:
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
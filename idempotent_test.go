@@ -0,0 +1,224 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWritesTable = "dbo.go_mssqldb_writes"
+
+func TestIdempotentWriteSetting(t *testing.T) {
+	ctx := context.Background()
+	c := &Conn{connector: &Connector{Reconnect: true, driver: driverInstance, IdempotentWrites: testWritesTable}}
+	wctx, w, err := c.idempotentWrite(ctx, "update t set x = 1")
+	require.NoError(t, err)
+	require.NotNil(t, w)
+	assert.Equal(t, testWritesTable, w.table)
+	assert.NotEqual(t, UniqueIdentifier{}, w.id)
+	assert.Same(t, w, idempotentWriteFromContext(wctx))
+	_, w2, _ := c.idempotentWrite(ctx, "update t set x = 1")
+	assert.NotEqual(t, w.id, w2.id, "each statement has an id of its own")
+
+	for name, tt := range map[string]struct {
+		ctx   context.Context
+		query string
+		setup func()
+	}{
+		"stored procedure": {ctx, "sp_who", nil},
+		"batch first DDL":  {ctx, "create procedure dbo.p as select 1", nil},
+		"no transaction":   {ctx, "alter database current set recovery simple", nil},
+		"SQL batch":        {WithSQLBatch(ctx), "update t set x = 1", nil},
+		"output parameter": {ctx, "set @p1 = 1", func() { c.outs.params = map[string]interface{}{"p1": new(int)} }},
+		"transaction":      {ctx, "update t set x = 1", func() { c.inTransaction = true }},
+		"no Reconnect":     {ctx, "update t set x = 1", func() { c.connector.Reconnect = false }},
+		"no marker table":  {ctx, "update t set x = 1", func() { c.connector.IdempotentWrites = "" }},
+	} {
+		c.outs = outputs{}
+		c.inTransaction = false
+		c.connector.Reconnect = true
+		c.connector.IdempotentWrites = testWritesTable
+		if tt.setup != nil {
+			tt.setup()
+		}
+		wctx, w, err := c.idempotentWrite(tt.ctx, tt.query)
+		require.NoError(t, err)
+		assert.Nil(t, w, name)
+		assert.Nil(t, idempotentWriteFromContext(wctx), name)
+	}
+}
+
+func TestMayWrap(t *testing.T) {
+	for query, want := range map[string]bool{
+		"update t set x = 1":                                    true,
+		"create table t (id int)":                               true,
+		"create unique index ix on t (id)":                      true,
+		"drop procedure dbo.p":                                  true,
+		"drop view dbo.v":                                       true,
+		"alter table t add y int":                               true,
+		"":                                                      true,
+		"CREATE PROCEDURE dbo.p AS SELECT 1":                    false,
+		"create proc dbo.p as select 1":                         false,
+		"create or alter view dbo.v as select 1 a":              false,
+		"-- the view\n/* v2 */ alter VIEW v as select 2":        false,
+		"create function f() returns int as begin return 1 end": false,
+		"create trigger tr on t after insert as select 1":       false,
+		"create schema s":                                       false,
+		"alter database current set recovery simple":            false,
+		"create database d":                                     false,
+		"drop database d":                                       false,
+		"create fulltext index on t (x) key index pk":           false,
+		"backup database d to disk = 'd.bak'":                   false,
+		"restore database d from disk = 'd.bak'":                false,
+		"reconfigure":                                           false,
+	} {
+		assert.Equal(t, want, mayWrap(query), query)
+	}
+}
+
+func TestIdempotentWriteWrap(t *testing.T) {
+	w := &idempotentWrite{table: testWritesTable}
+	query := w.wrap("update t set x = 1")
+	assert.Contains(t, query, "SET XACT_ABORT ON;\n")
+	assert.Contains(t, query, "BEGIN TRANSACTION;\nINSERT INTO dbo.go_mssqldb_writes (id) VALUES (@go_mssqldb_write_id);\n")
+	assert.Contains(t, query, "\nupdate t set x = 1;\nDECLARE @go_mssqldb_rows bigint = ROWCOUNT_BIG();\n")
+	assert.True(t, bytes.HasSuffix([]byte(query), []byte("COMMIT TRANSACTION;")))
+	assert.Contains(t, w.wrap("merge t using s on 1 = 0 when not matched then insert default values; "), "insert default values; \n", "a terminated statement is kept as is")
+}
+
+func TestSendIdempotentWrite(t *testing.T) {
+	memBuf := new(MockTransport)
+	conn := &Conn{
+		connector:      &Connector{Reconnect: true, driver: driverInstance, IdempotentWrites: testWritesTable},
+		sess:           &tdsSession{buf: newTdsBuffer(4096, memBuf), logger: optionalLogger{}},
+		connectionGood: true,
+	}
+	ctx, w, err := conn.idempotentWrite(context.Background(), "delete from t")
+	require.NoError(t, err)
+	stmt := &Stmt{c: conn, query: "delete from t"}
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	packet := memBuf.Bytes()
+	assert.Equal(t, byte(packRPCRequest), packet[0], "sent as a parameterized statement")
+	assert.True(t, bytes.Contains(packet, str2ucs2(w.wrap("delete from t"))))
+	assert.True(t, bytes.Contains(packet, str2ucs2(idempotentWriteDecl)))
+	guid, _ := w.id.Value()
+	assert.True(t, bytes.Contains(packet, guid.([]byte)))
+}
+
+// idempotentWriteServer answers the requests of a rowServer for a
+// statement whose first connection breaks before the statement is
+// answered. The lookup of the statement on the next connection finds it
+// committed with 3 rows affected, or not at all, and the statement sent
+// again affects 2 rows.
+type idempotentWriteServer struct {
+	committed bool
+
+	mu       sync.Mutex
+	requests [][]byte
+}
+
+func (s *idempotentWriteServer) answer(conn int, request []byte) ([]byte, bool) {
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	s.mu.Unlock()
+	if conn == 1 {
+		return nil, false
+	}
+	if !bytes.Contains(request, str2ucs2("WITH (READCOMMITTEDLOCK)")) {
+		return doneToken(doneCount, 2), true
+	}
+	stream := []byte{byte(tokenColMetadata), 0x01, 0x00, 0, 0, 0, 0, 0, 0, typeIntN, 8, 0}
+	if s.committed {
+		stream = append(stream, byte(tokenRow), 8)
+		stream = binary.LittleEndian.AppendUint64(stream, 3)
+		return append(stream, doneToken(doneCount, 1)...), true
+	}
+	return append(stream, doneToken(doneCount, 0)...), true
+}
+
+func TestIdempotentWriteAfterConnectionDropped(t *testing.T) {
+	for _, committed := range []bool{true, false} {
+		fake := &idempotentWriteServer{committed: committed}
+		server := newRowServer(t)
+		server.answer = fake.answer
+		connector, err := NewConnector(server.connString())
+		require.NoError(t, err)
+		connector.Reconnect = true
+		connector.IdempotentWrites = testWritesTable
+		db := sql.OpenDB(connector)
+		defer db.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		res, err := conn.ExecContext(ctx, "update t set x = @p1", 1)
+		require.NoError(t, err, "committed %v", committed)
+		affected, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, 2, server.accepted(), "committed %v", committed)
+
+		want, sent := 2, 3
+		if committed {
+			want, sent = 3, 2
+		}
+		assert.Equal(t, int64(want), affected, "committed %v", committed)
+		require.Len(t, fake.requests, sent, "committed %v", committed)
+		assert.True(t, bytes.Contains(fake.requests[0], str2ucs2("INSERT INTO "+testWritesTable)))
+		if !committed {
+			assert.Equal(t, fake.requests[0], fake.requests[2], "sent again with the same id")
+		}
+	}
+}
+
+func TestIdempotentWrite(t *testing.T) {
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	connector.Reconnect = true
+	connector.IdempotentWrites = "dbo.go_mssql_test_writes"
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `if object_id('dbo.go_mssql_test_writes') is not null drop table dbo.go_mssql_test_writes;
+create table dbo.go_mssql_test_writes (id uniqueidentifier primary key, rows_affected bigint, created datetime2 default sysutcdatetime())`)
+	require.NoError(t, err)
+	defer db.Exec("drop table dbo.go_mssql_test_writes")
+	_, err = conn.ExecContext(ctx, "create table #t (x int)")
+	require.NoError(t, err)
+
+	res, err := conn.ExecContext(ctx, "insert into #t values (@p1), (@p2)", 1, 2)
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, affected, "only the rows of the statement are counted")
+
+	var rows int64
+	err = conn.QueryRowContext(ctx, "select rows_affected from dbo.go_mssql_test_writes").Scan(&rows)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, rows)
+
+	_, err = conn.ExecContext(ctx, "insert into #t values ('x')")
+	assert.Error(t, err)
+	var inserted, markers int
+	require.NoError(t, conn.QueryRowContext(ctx, "select count(*) from #t").Scan(&inserted))
+	require.NoError(t, conn.QueryRowContext(ctx, "select count(*) from dbo.go_mssql_test_writes").Scan(&markers))
+	assert.Equal(t, 2, inserted)
+	assert.Equal(t, 1, markers, "a failed statement rolls back its marker")
+
+	var xactAbort int
+	require.NoError(t, conn.QueryRowContext(ctx, "select @@options & 16384").Scan(&xactAbort))
+	assert.Zero(t, xactAbort, "XACT_ABORT is restored")
+}
@@ -0,0 +1,158 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// maxRequestParams is the most parameters a request accepts: SQL Server
+	// allows 2100, and sp_executesql takes two for the statement and the
+	// parameter declarations.
+	maxRequestParams = 2100 - 2
+	// maxValuesRows is the most rows a VALUES list may hold.
+	maxValuesRows = 1000
+
+	insertValuesTag = "db"
+)
+
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// InsertValues inserts rows, a slice of structs or of pointers to structs,
+// into table with multi-row INSERT ... VALUES statements and returns the
+// number of rows inserted. It suits small batches, where bulk copy or a
+// TVP cost more than they save.
+//
+// Each exported field is a column named by its db tag, or by the field name
+// when it has none. Fields tagged db:"-" are skipped. Nil pointer fields
+// insert NULL. table may be schema qualified and is quoted by InsertValues.
+//
+// Rows are sent in as many statements as needed to stay within the 2100
+// parameters of a request and the 1000 rows of a VALUES list. The
+// statements are not atomic unless db is a *sql.Tx, and a failure returns
+// the rows inserted by the statements that succeeded.
+func InsertValues(ctx context.Context, db execer, table string, rows interface{}) (int64, error) {
+	val := reflect.ValueOf(rows)
+	if val.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("mssql: InsertValues requires a slice of structs, got %T", rows)
+	}
+	rowType := val.Type().Elem()
+	if rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	if rowType.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("mssql: InsertValues requires a slice of structs, got %T", rows)
+	}
+	columns, fields := insertValuesColumns(rowType)
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("mssql: %s has no columns to insert", rowType)
+	}
+
+	chunk := maxRequestParams / len(columns)
+	if chunk > maxValuesRows {
+		chunk = maxValuesRows
+	}
+	var inserted int64
+	for start := 0; start < val.Len(); start += chunk {
+		end := start + chunk
+		if end > val.Len() {
+			end = val.Len()
+		}
+		query, args, err := insertValuesStatement(table, columns, fields, val.Slice(start, end))
+		if err != nil {
+			return inserted, err
+		}
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return inserted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return inserted, err
+		}
+		inserted += n
+	}
+	return inserted, nil
+}
+
+// insertValuesColumns returns the column names of the struct type t and the
+// indexes of the fields holding them.
+func insertValuesColumns(t reflect.Type) ([]string, []int) {
+	var columns []string
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup(insertValuesTag); ok {
+			if tag == skipTagValue {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		columns = append(columns, name)
+		fields = append(fields, i)
+	}
+	return columns, fields
+}
+
+func insertValuesStatement(table string, columns []string, fields []int, rows reflect.Value) (string, []interface{}, error) {
+	q := TSQLQuoter{}
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(quoteMultipartName(table))
+	sb.WriteString(" (")
+	for i, col := range columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(q.ID(col))
+	}
+	sb.WriteString(") VALUES ")
+
+	args := make([]interface{}, 0, rows.Len()*len(fields))
+	for r := 0; r < rows.Len(); r++ {
+		row := rows.Index(r)
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				return "", nil, errors.New("mssql: InsertValues got a nil row")
+			}
+			row = row.Elem()
+		}
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for i, f := range fields {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			args = append(args, insertValuesArg(row.Field(f)))
+			fmt.Fprintf(&sb, "@p%d", len(args))
+		}
+		sb.WriteString(")")
+	}
+	return sb.String(), args, nil
+}
+
+// insertValuesArg dereferences pointer fields so nil ones insert NULL.
+func insertValuesArg(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		return v.Elem().Interface()
+	}
+	return v.Interface()
+}
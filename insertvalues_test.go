@@ -0,0 +1,123 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExecer records the statements it is given and reports every row
+// of a VALUES list as inserted.
+type recordingExecer struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (e *recordingExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	return driverResult(strings.Count(query, "(@p")), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, ErrorLastInsertIdNotSupported }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+type insertValuesRow struct {
+	ID      int     `db:"id"`
+	Name    *string `db:"name"`
+	Comment string
+	Ignored string `db:"-"`
+	hidden  string
+}
+
+func TestInsertValuesStatement(t *testing.T) {
+	name := "one"
+	rows := []insertValuesRow{
+		{ID: 1, Name: &name, Comment: "a"},
+		{ID: 2, Comment: "b", Ignored: "x", hidden: "y"},
+	}
+	e := &recordingExecer{}
+	n, err := InsertValues(context.Background(), e, "dbo.t", rows)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	require.Len(t, e.queries, 1)
+	assert.Equal(t, "INSERT INTO [dbo].[t] ([id], [name], [Comment]) VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)", e.queries[0])
+	assert.Equal(t, []interface{}{1, "one", "a", 2, nil, "b"}, e.args[0], "nil pointers insert NULL")
+}
+
+func TestInsertValuesChunking(t *testing.T) {
+	type wide struct {
+		A, B, C int
+	}
+	perStatement := maxRequestParams / 3
+	for _, tt := range []struct {
+		rows       int
+		statements int
+	}{
+		{0, 0},
+		{perStatement, 1},
+		{perStatement + 1, 2},
+		{2*perStatement + 5, 3},
+	} {
+		e := &recordingExecer{}
+		n, err := InsertValues(context.Background(), e, "t", make([]wide, tt.rows))
+		require.NoError(t, err)
+		assert.Equal(t, int64(tt.rows), n)
+		require.Len(t, e.queries, tt.statements, "%d rows", tt.rows)
+		for _, args := range e.args {
+			assert.LessOrEqual(t, len(args), maxRequestParams)
+		}
+	}
+
+	// narrow rows are limited by the rows of a VALUES list
+	e := &recordingExecer{}
+	_, err := InsertValues(context.Background(), e, "t", make([]struct{ A int }, maxValuesRows+1))
+	require.NoError(t, err)
+	require.Len(t, e.args, 2)
+	assert.Len(t, e.args[0], maxValuesRows)
+	assert.Len(t, e.args[1], 1)
+}
+
+func TestInsertValuesErrors(t *testing.T) {
+	e := &recordingExecer{}
+	_, err := InsertValues(context.Background(), e, "t", insertValuesRow{})
+	assert.Error(t, err, "not a slice")
+	_, err = InsertValues(context.Background(), e, "t", []int{1})
+	assert.Error(t, err, "not structs")
+	_, err = InsertValues(context.Background(), e, "t", []struct{ a int }{{1}})
+	assert.Error(t, err, "no exported fields")
+	_, err = InsertValues(context.Background(), e, "t", []*insertValuesRow{nil})
+	assert.Error(t, err, "nil row")
+	assert.Empty(t, e.queries)
+}
+
+func TestInsertValues(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec("DROP TABLE IF EXISTS dbo.insert_values_test; CREATE TABLE dbo.insert_values_test (id int NOT NULL, name nvarchar(20) NULL, Comment nvarchar(20) NOT NULL)")
+	require.NoError(t, err)
+	defer conn.Exec("DROP TABLE IF EXISTS dbo.insert_values_test")
+
+	name := "one"
+	rows := make([]*insertValuesRow, 1500)
+	for i := range rows {
+		rows[i] = &insertValuesRow{ID: i, Comment: "c"}
+	}
+	rows[0].Name = &name
+	n, err := InsertValues(testContext(t), conn, "dbo.insert_values_test", rows)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(rows)), n)
+
+	var count, nulls int
+	require.NoError(t, conn.QueryRow("SELECT COUNT(*), COUNT(*) - COUNT(name) FROM dbo.insert_values_test").Scan(&count, &nulls))
+	assert.Equal(t, len(rows), count)
+	assert.Equal(t, len(rows)-1, nulls)
+}
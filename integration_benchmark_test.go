@@ -392,3 +392,93 @@ select @d
 		rows.Close()
 	}
 }
+
+// BenchmarkRoundTrip_TransactionInserts compares inserting 100 rows in a
+// transaction one request each with Connector.CoalesceInserts sending them
+// as one bulk copy.
+func BenchmarkRoundTrip_TransactionInserts(b *testing.B) {
+	for _, coalesce := range []bool{false, true} {
+		name := "Looped"
+		if coalesce {
+			name = "Coalesced"
+		}
+		b.Run(name, func(b *testing.B) {
+			connector, err := NewConnector(makeConnStr(b).String())
+			if err != nil {
+				b.Fatal("Open connection failed:", err.Error())
+			}
+			connector.CoalesceInserts = coalesce
+			db := sql.OpenDB(connector)
+			db.SetMaxOpenConns(1)
+			b.Cleanup(func() { db.Close() })
+			ctx := context.Background()
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				b.Fatal("Conn failed:", err)
+			}
+			b.Cleanup(func() { conn.Close() })
+			_, err = conn.ExecContext(ctx, `CREATE TABLE #bench_tx_inserts (id INT, val NVARCHAR(50), amount FLOAT)`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tx, err := conn.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				stmt, err := tx.PrepareContext(ctx, "INSERT INTO #bench_tx_inserts (id, val, amount) VALUES (@p1, @p2, @p3)")
+				if err != nil {
+					b.Fatal(err)
+				}
+				for r := 0; r < 100; r++ {
+					if _, err = stmt.ExecContext(ctx, r, "row", float64(r)*1.5); err != nil {
+						tx.Rollback()
+						b.Fatal(err)
+					}
+				}
+				stmt.Close()
+				if err := tx.Commit(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRoundTrip_TempProc(b *testing.B) {
+	conn, ctx := benchmarkConn(b)
+	const body = "SELECT COUNT(*) FROM sys.objects WHERE object_id > @id AND name <> @name"
+	b.Run("ExecuteSQL", func(b *testing.B) {
+		query := strings.NewReplacer("@id", "@p1", "@name", "@p2").Replace(body)
+		for i := 0; i < b.N; i++ {
+			var n int
+			if err := conn.QueryRowContext(ctx, query, i, "x").Scan(&n); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("TempProc", func(b *testing.B) {
+		proc, err := PrepareTempProc(ctx, conn, "@id int, @name nvarchar(128)", body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { proc.Close(ctx) })
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rows, err := proc.QueryContext(ctx, i, "x")
+			if err != nil {
+				b.Fatal(err)
+			}
+			var n int
+			for rows.Next() {
+				if err = rows.Scan(&n); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err = rows.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package cp
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 type charsetMap struct {
@@ -99,21 +100,42 @@ func CharsetToUTF8(col Collation, s []byte) string {
 	buf := strings.Builder{}
 	buf.Grow(len(s))
 	for i := 0; i < len(s); i++ {
-		ch := cm.sb[s[i]]
-		if ch == -1 {
-			if i+1 == len(s) {
-				ch = 0xfffd
-			} else {
-				n := int(s[i+1]) + (int(s[i]) << 8)
-				i++
-				var ok bool
-				ch, ok = cm.db[n]
-				if !ok {
-					ch = 0xfffd
-				}
-			}
-		}
+		var ch rune
+		ch, i = cm.decode(s, i)
 		buf.WriteRune(ch)
 	}
 	return buf.String()
 }
+
+// AppendUTF8 appends the UTF-8 encoding of s to dst, like CharsetToUTF8
+// but without allocating a string.
+func AppendUTF8(dst []byte, col Collation, s []byte) []byte {
+	cm := collation2charset(col)
+	if cm == nil {
+		return append(dst, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		var ch rune
+		ch, i = cm.decode(s, i)
+		dst = utf8.AppendRune(dst, ch)
+	}
+	return dst
+}
+
+// decode returns the character starting at s[i] and the index of its last byte.
+func (cm *charsetMap) decode(s []byte, i int) (rune, int) {
+	ch := cm.sb[s[i]]
+	if ch == -1 {
+		if i+1 == len(s) {
+			return 0xfffd, i
+		}
+		n := int(s[i+1]) + (int(s[i]) << 8)
+		var ok bool
+		ch, ok = cm.db[n]
+		if !ok {
+			ch = 0xfffd
+		}
+		return ch, i + 1
+	}
+	return ch, i
+}
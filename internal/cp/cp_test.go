@@ -248,6 +248,22 @@ func TestCharsetToUTF8_IncompleteDoubleByte(t *testing.T) {
 	assert.GreaterOrEqual(t, len(result), 2, "Expected at least 2 characters, got %q", result)
 }
 
+func TestAppendUTF8(t *testing.T) {
+	t.Parallel()
+	inputs := []struct {
+		c     Collation
+		input []byte
+	}{
+		{Collation{SortId: 50}, []byte{'a', 0x80, 'b'}},
+		{Collation{SortId: 192}, []byte{0x41, 0x82, 0xa0, 0x81}},
+		{Collation{LcidAndFlags: 0x0439}, []byte("test")},
+	}
+	for _, in := range inputs {
+		result := AppendUTF8([]byte("x"), in.c, in.input)
+		assert.Equal(t, "x"+CharsetToUTF8(in.c, in.input), string(result))
+	}
+}
+
 // Test each code page getter returns a valid charset map
 func TestGetCodePages(t *testing.T) {
 	t.Parallel()
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -156,6 +157,45 @@ func Float64ToDecimalScale(f float64, scale uint8) (Decimal, error) {
 	return dec, nil
 }
 
+// FloatToDecimalRound converts f, a float of bitSize bits, to a decimal of
+// the given scale. f is taken as its shortest decimal representation, as
+// strconv.FormatFloat formats it, so 2.345 is 2.345 and not the binary
+// value just below it. The digits beyond the scale are rounded to nearest,
+// with halfway values rounded to even when halfEven is set and away from
+// zero otherwise.
+func FloatToDecimalRound(f float64, bitSize int, scale uint8, halfEven bool) (Decimal, error) {
+	if math.IsNaN(f) {
+		return Decimal{}, errors.New("NaN")
+	}
+	if math.IsInf(f, 0) {
+		return Decimal{}, errors.New("Infinity can't be converted to decimal")
+	}
+	s := strconv.FormatFloat(math.Abs(f), 'f', -1, bitSize)
+	intPart, frac := s, ""
+	if point := strings.IndexByte(s, '.'); point >= 0 {
+		intPart, frac = s[:point], s[point+1:]
+	}
+	if len(frac) > int(scale) {
+		next, rest := frac[scale], strings.TrimRight(frac[scale+1:], "0")
+		kept := intPart + frac[:scale]
+		up := next > '5' || next == '5' && (rest != "" || !halfEven || (kept[len(kept)-1]-'0')%2 == 1)
+		var r big.Int
+		r.SetString(kept, 10)
+		if up {
+			r.Add(&r, big.NewInt(1))
+		}
+		s = string(ScaleBytes(r.String(), scale))
+	}
+	if f < 0 {
+		s = "-" + s
+	}
+	dec, err := StringToDecimalScale(s, scale)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("float value %v is out of range of the decimal", f)
+	}
+	return dec, nil
+}
+
 // Int64ToDecimalScale converts float64 to decimal; user can specify the scale
 func Int64ToDecimalScale(v int64, scale uint8) Decimal {
 	positive := v >= 0
@@ -181,3 +181,57 @@ func TestFromStringBad(t *testing.T) {
 		}
 	}
 }
+
+func TestFloatToDecimalRound(t *testing.T) {
+	values := []struct {
+		in       float64
+		scale    uint8
+		halfUp   string
+		halfEven string
+	}{
+		{0.5, 0, "1", "0"},
+		{1.5, 0, "2", "2"},
+		{2.5, 0, "3", "2"},
+		{-2.5, 0, "-3", "-2"},
+		{-0.5, 0, "-1", "0"},
+		{2.345, 2, "2.35", "2.34"},
+		{2.355, 2, "2.36", "2.36"},
+		{2.675, 2, "2.68", "2.68"},
+		{0.125, 2, "0.13", "0.12"},
+		{-0.125, 2, "-0.13", "-0.12"},
+		{2.5000001, 0, "3", "3"},
+		{2.4999999, 0, "2", "2"},
+		{9.995, 2, "10.00", "10.00"},
+		{0.004, 2, "0.00", "0.00"},
+		{-1234.560001, 2, "-1234.56", "-1234.56"},
+		{1.5, 3, "1.500", "1.500"},
+		{1e20, 0, "100000000000000000000", "100000000000000000000"},
+	}
+	for _, v := range values {
+		for _, mode := range []struct {
+			halfEven bool
+			out      string
+		}{{false, v.halfUp}, {true, v.halfEven}} {
+			dec, err := FloatToDecimalRound(v.in, 64, v.scale, mode.halfEven)
+			if err != nil {
+				t.Errorf("FloatToDecimalRound(%v, %d, %v) failed: %v", v.in, v.scale, mode.halfEven, err)
+			} else if dec.String() != mode.out {
+				t.Errorf("FloatToDecimalRound(%v, %d, %v) = %s, expected %s", v.in, v.scale, mode.halfEven, dec.String(), mode.out)
+			}
+		}
+	}
+
+	dec, err := FloatToDecimalRound(float64(float32(0.125)), 32, 2, false)
+	if err != nil || dec.String() != "0.13" {
+		t.Errorf("float32 0.125 = %v, %v", dec, err)
+	}
+	dec, err = FloatToDecimalRound(float64(float32(2.345)), 32, 2, false)
+	if err != nil || dec.String() != "2.35" {
+		t.Errorf("float32 2.345 is formatted as a float32: %v, %v", dec, err)
+	}
+	for _, bad := range []float64{math.NaN(), math.Inf(1), 1e39} {
+		if _, err := FloatToDecimalRound(bad, 64, 2, false); err == nil {
+			t.Errorf("expected %v to fail", bad)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// request is the request whose response a connection is reading.
+type request struct {
+	cancel context.CancelFunc
+}
+
+// Interrupt stops the request running on the connection, as cancelling its
+// context would: it sends an attention to the server and the call running
+// the request returns context.Canceled once the server confirmed it. Rows
+// being read end with the same error. It reports whether a request was
+// running, and does nothing otherwise.
+//
+// Interrupt may be called from any goroutine. The connection stays usable
+// for the next request once the interrupted call returned, which drains
+// what the server sent before it confirmed the attention.
+func (c *Conn) Interrupt() bool {
+	c.requestMu.Lock()
+	r := c.request
+	c.requestMu.Unlock()
+	if r == nil {
+		return false
+	}
+	r.cancel()
+	return true
+}
+
+// Interrupter returns Interrupt of the driver connection of conn, to stop
+// its queries from another goroutine. Get it before running the query, as
+// conn cannot be used while the query runs.
+func Interrupter(conn *sql.Conn) (func() bool, error) {
+	var interrupt func() bool
+	err := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return errors.New("mssql: Interrupter requires a connection of this driver")
+		}
+		interrupt = c.Interrupt
+		return nil
+	})
+	return interrupt, err
+}
+
+// interruptible makes cancel interrupt the request Interrupt stops, and
+// returns the function to call instead of cancel once the response is read.
+func (c *Conn) interruptible(cancel context.CancelFunc) context.CancelFunc {
+	r := &request{cancel: cancel}
+	c.requestMu.Lock()
+	c.request = r
+	c.requestMu.Unlock()
+	return func() {
+		c.requestMu.Lock()
+		if c.request == r {
+			c.request = nil
+		}
+		c.requestMu.Unlock()
+		cancel()
+	}
+}
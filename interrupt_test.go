@@ -0,0 +1,74 @@
+package mssql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptRegistration(t *testing.T) {
+	c := &Conn{}
+	assert.False(t, c.Interrupt(), "nothing to interrupt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := c.interruptible(cancel)
+	// a request that started since does not unregister when done
+	_, otherCancel := context.WithCancel(context.Background())
+	otherDone := c.interruptible(otherCancel)
+	otherDone()
+	assert.False(t, c.Interrupt())
+	assert.NoError(t, ctx.Err())
+	done()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	done = c.interruptible(cancel)
+	assert.True(t, c.Interrupt())
+	assert.Equal(t, context.Canceled, ctx.Err())
+	done()
+	assert.False(t, c.Interrupt())
+}
+
+func TestInterrupt(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	interrupt, err := Interrupter(conn)
+	require.NoError(t, err)
+
+	interrupted := make(chan bool)
+	go func() {
+		time.Sleep(getLatency(t) + 500*time.Millisecond)
+		interrupted <- interrupt()
+	}()
+	start := time.Now()
+	_, err = conn.ExecContext(ctx, "waitfor delay '00:00:20'")
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, <-interrupted)
+	assert.Less(t, time.Since(start), 15*time.Second)
+
+	go func() {
+		time.Sleep(getLatency(t) + 500*time.Millisecond)
+		interrupted <- interrupt()
+	}()
+	rows, err := conn.QueryContext(ctx, "select 1; waitfor delay '00:00:20'; select 2")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	assert.Equal(t, context.Canceled, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.True(t, <-interrupted)
+
+	// the connection is usable once the interrupted call returned
+	var val int
+	require.NoError(t, conn.QueryRowContext(ctx, "select 1").Scan(&val))
+	assert.Equal(t, 1, val)
+	assert.False(t, interrupt(), "nothing to interrupt")
+}
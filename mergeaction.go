@@ -0,0 +1,105 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// mergeActionColumn is the name of the $action column of the OUTPUT clause
+// of a MERGE.
+const mergeActionColumn = "$action"
+
+// MergeCounts are the rows a MERGE inserted, updated and deleted, tallied
+// from the $action column of its OUTPUT clause.
+type MergeCounts struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+}
+
+// Total returns the number of rows the MERGE changed.
+func (c MergeCounts) Total() int64 {
+	return c.Inserted + c.Updated + c.Deleted
+}
+
+// MergeActions runs query, a MERGE whose OUTPUT clause returns $action,
+// and returns the rows it inserted, updated and deleted:
+//
+//	counts, err := mssql.MergeActions(ctx, db, `MERGE dbo.stock AS t
+//	USING @rows AS s ON t.sku = s.sku
+//	WHEN MATCHED THEN UPDATE SET t.qty = s.qty
+//	WHEN NOT MATCHED THEN INSERT (sku, qty) VALUES (s.sku, s.qty)
+//	OUTPUT $action;`, sql.Named("rows", tvp))
+//
+// The RowsAffected of Exec only reports their sum. The result sets of the
+// query without a $action column, such as those of other statements of
+// the batch, are skipped, and those of several MERGE statements are added.
+func MergeActions(ctx context.Context, db queryer, query string, args ...interface{}) (MergeCounts, error) {
+	var counts MergeCounts
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+	for {
+		set, err := ScanMergeActions(rows)
+		if err != nil && err != errNoMergeAction {
+			return counts, err
+		}
+		counts.Inserted += set.Inserted
+		counts.Updated += set.Updated
+		counts.Deleted += set.Deleted
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return counts, err
+	}
+	return counts, rows.Close()
+}
+
+var errNoMergeAction = fmt.Errorf("mssql: the result set has no %s column", mergeActionColumn)
+
+// ScanMergeActions reads the rows of the current result set of rows, the
+// OUTPUT of a MERGE, and tallies their $action column. The other columns
+// are skipped.
+func ScanMergeActions(rows *sql.Rows) (MergeCounts, error) {
+	var counts MergeCounts
+	cols, err := rows.Columns()
+	if err != nil {
+		return counts, err
+	}
+	var action string
+	dest := make([]interface{}, len(cols))
+	found := false
+	for i, col := range cols {
+		if col == mergeActionColumn && !found {
+			dest[i] = &action
+			found = true
+		} else {
+			dest[i] = new(interface{})
+		}
+	}
+	if !found {
+		return counts, errNoMergeAction
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return counts, err
+		}
+		switch strings.ToUpper(action) {
+		case "INSERT":
+			counts.Inserted++
+		case "UPDATE":
+			counts.Updated++
+		case "DELETE":
+			counts.Deleted++
+		default:
+			return counts, fmt.Errorf("mssql: unknown MERGE action %q", action)
+		}
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,93 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeOutputStream returns the tokens of the OUTPUT of a MERGE, an int id
+// column and the nvarchar $action column, with a row per action.
+func mergeOutputStream(actions ...string) []byte {
+	stream := []byte{byte(tokenColMetadata), 0x02, 0x00}
+	stream = append(stream, 0, 0, 0, 0, 0, 0, typeInt4, byte(len("id")))
+	stream = append(stream, str2ucs2("id")...)
+	// user type, flags, type, size, collation (Latin1_General_CI_AS)
+	stream = append(stream, 0, 0, 0, 0, 0, 0, typeNVarChar, 20, 0, 0x09, 0x04, 0xd0, 0x00, 0x34)
+	stream = append(stream, byte(len(mergeActionColumn)))
+	stream = append(stream, str2ucs2(mergeActionColumn)...)
+	for i, action := range actions {
+		stream = append(stream, byte(tokenRow))
+		stream = binary.LittleEndian.AppendUint32(stream, uint32(i))
+		stream = binary.LittleEndian.AppendUint16(stream, uint16(2*len(action)))
+		stream = append(stream, str2ucs2(action)...)
+	}
+	return append(stream, doneToken(doneMore|doneCount, uint64(len(actions)))...)
+}
+
+func TestMergeActionsTallied(t *testing.T) {
+	server := newRowServer(t)
+	server.answer = func(int, []byte) ([]byte, bool) {
+		// a result set without $action, then the OUTPUT of two MERGEs
+		stream := textResultStream(1, 0, 1)
+		binary.LittleEndian.PutUint16(stream[len(stream)-12:], doneMore|doneCount)
+		stream = append(stream, mergeOutputStream("INSERT", "UPDATE", "INSERT")...)
+		stream = append(stream, mergeOutputStream("DELETE", "INSERT")...)
+		return append(stream, doneToken(doneFinal, 0)...), true
+	}
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	counts, err := MergeActions(ctx, db, "merge ...")
+	require.NoError(t, err)
+	assert.Equal(t, MergeCounts{Inserted: 3, Updated: 1, Deleted: 1}, counts)
+	assert.Equal(t, int64(5), counts.Total())
+
+	server.answer = func(int, []byte) ([]byte, bool) {
+		return append(mergeOutputStream("INSERT", "UPSERT"), doneToken(doneFinal, 0)...), true
+	}
+	_, err = MergeActions(ctx, db, "merge ...")
+	assert.EqualError(t, err, `mssql: unknown MERGE action "UPSERT"`)
+}
+
+func TestMergeActions(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	_, err := db.ExecContext(ctx, `if object_id('dbo.go_mssql_merge_stock') is not null drop table dbo.go_mssql_merge_stock;
+create table dbo.go_mssql_merge_stock (sku int primary key, qty int);
+insert into dbo.go_mssql_merge_stock values (1, 10), (2, 20), (3, 30)`)
+	require.NoError(t, err)
+	defer db.ExecContext(ctx, "drop table dbo.go_mssql_merge_stock")
+
+	counts, err := MergeActions(ctx, db, `merge dbo.go_mssql_merge_stock as t
+using (values (1, 11), (2, 21), (4, 40), (5, 50), (6, 60)) as s (sku, qty) on t.sku = s.sku
+when matched then update set t.qty = s.qty
+when not matched by target then insert (sku, qty) values (s.sku, s.qty)
+when not matched by source and t.sku = @p1 then delete
+output inserted.sku, $action;`, 3)
+	require.NoError(t, err)
+	assert.Equal(t, MergeCounts{Inserted: 3, Updated: 2, Deleted: 1}, counts)
+
+	rows, err := db.QueryContext(ctx, `merge dbo.go_mssql_merge_stock as t
+using (values (1, 12), (7, 70)) as s (sku, qty) on t.sku = s.sku
+when matched then update set t.qty = s.qty
+when not matched then insert (sku, qty) values (s.sku, s.qty)
+output $action, inserted.qty;`)
+	require.NoError(t, err)
+	defer rows.Close()
+	counts, err = ScanMergeActions(rows)
+	require.NoError(t, err)
+	assert.Equal(t, MergeCounts{Inserted: 1, Updated: 1}, counts)
+}
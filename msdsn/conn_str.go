@@ -2,8 +2,11 @@ package msdsn
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -89,14 +92,42 @@ const (
 	NoTraceID              = "notraceid"
 	GuidConversion         = "guid conversion"
 	Timezone               = "timezone"
+	ZonelessTimeParam      = "zoneless time"
 	EpaEnabled             = "epa enabled"
+	ReadTimeout            = "read timeout"
+	WriteTimeout           = "write timeout"
+	DefaultSchema          = "default schema"
+	DateFormat             = "dateformat"
+	DateFirst              = "datefirst"
+	// SqlClient pooling keywords, applied by mssql.Connector.ConfigurePool.
+	Pooling            = "pooling"
+	MaxPoolSize        = "max pool size"
+	MinPoolSize        = "min pool size"
+	ConnectionLifetime = "connection lifetime"
+	// MultipleActiveResultSets is accepted for compatibility with SqlClient
+	// connection strings. MARS is not supported and the value is ignored.
+	MultipleActiveResultSets = "multipleactiveresultsets"
+	// ServerCertificateFingerprint pins the server certificate by the
+	// SHA-256 hash of its DER encoding, as hexadecimal digits.
+	ServerCertificateFingerprint = "servercertificatefingerprint"
+)
+
+const (
+	defaultAppName   = "go-mssqldb"
+	defaultKeepAlive = 30 * time.Second
 )
 
 type EncodeParameters struct {
 	// Properly convert GUIDs, using correct byte endianness
 	GuidConversion bool
 	// Timezone is the timezone to use for encoding and decoding datetime values.
+	// Values of the types without a time zone, such as datetime2, are read in
+	// it, and unless it is UTC, the default, time.Time values are converted
+	// to it before their wall clock is written.
 	Timezone *time.Location
+	// ZonelessTime is how time.Time values are written to the types
+	// without a time zone.
+	ZonelessTime ZonelessTime
 }
 
 func (e EncodeParameters) GetTimezone() *time.Location {
@@ -106,6 +137,52 @@ func (e EncodeParameters) GetTimezone() *time.Location {
 	return e.Timezone
 }
 
+// ZonelessLocation returns the location time.Time values are converted to
+// before their wall clock is written to the types without a time zone, or
+// nil when it is written as is.
+func (e EncodeParameters) ZonelessLocation() *time.Location {
+	switch e.ZonelessTime {
+	case ZonelessWallClock:
+		return nil
+	case ZonelessUTC:
+		return time.UTC
+	case ZonelessTimezone:
+		return e.GetTimezone()
+	}
+	if tz := e.GetTimezone(); tz != time.UTC {
+		return tz
+	}
+	return nil
+}
+
+// ZonelessTime is how time.Time values are written to the types without a
+// time zone, date, time, smalldatetime, datetime and datetime2, which store
+// a wall clock: set by the zoneless time connection parameter to
+// wallclock, utc or timezone.
+type ZonelessTime uint8
+
+const (
+	// ZonelessDefault converts values to the timezone parameter, unless it
+	// is UTC, its default, in which case it writes the wall clock of
+	// values as is, whatever their location, as the driver always did.
+	ZonelessDefault ZonelessTime = iota
+	// ZonelessWallClock writes the wall clock of values as is: 10:00 in
+	// New York is stored as 10:00.
+	ZonelessWallClock
+	// ZonelessUTC converts values to UTC: 10:00 in New York is stored as
+	// 14:00 or 15:00.
+	ZonelessUTC
+	// ZonelessTimezone converts values to the timezone parameter, UTC
+	// included, so a value read back is the same instant.
+	ZonelessTimezone
+)
+
+var zonelessTimeNames = map[ZonelessTime]string{
+	ZonelessWallClock: "wallclock",
+	ZonelessUTC:       "utc",
+	ZonelessTimezone:  "timezone",
+}
+
 type Config struct {
 	Port       uint64
 	Host       string
@@ -131,7 +208,12 @@ type Config struct {
 
 	ServerSPN   string
 	Workstation string
-	AppName     string
+	// WorkstationProvided is set when the connection string sets the
+	// workstation id, rather than Parse defaulting Workstation to the host
+	// name. URL writes Workstation then, or when it is set to another name
+	// than the host name.
+	WorkstationProvided bool
+	AppName             string
 
 	// If true disables database/sql's automatic retry of queries
 	// that start on bad connections.
@@ -141,9 +223,34 @@ type Config struct {
 
 	DialTimeout time.Duration // DialTimeout defaults to 15s per protocol. Set negative to disable.
 	ConnTimeout time.Duration // Use context for timeouts.
-	KeepAlive   time.Duration // Leave at default.
+	KeepAlive   time.Duration // TCP keep-alive interval. Zero uses the 30s default, negative disables keep-alives.
 	PacketSize  uint16
 
+	// ReadTimeout and WriteTimeout bound each read from and write to the
+	// network connection. Unlike a context deadline, which cancels a query
+	// by sending an attention request to the server, they interrupt I/O that
+	// is stuck in the operating system, such as a read from a server that
+	// stopped responding. The connection is unusable after they expire.
+	// When zero, ConnTimeout is used.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// DefaultSchema is the schema unqualified object names must resolve to.
+	// SQL Server has no session setting for it: the default schema is a
+	// property of the database user, changed with ALTER USER ... WITH
+	// DEFAULT_SCHEMA. When set, each new connection checks SCHEMA_NAME()
+	// and fails if it names another schema.
+	DefaultSchema string
+
+	// DateFormat and DateFirst, when set, are applied to each session with
+	// SET DATEFORMAT and SET DATEFIRST, overriding the defaults of the
+	// language of the login. DateFormat is the order of the month, day and
+	// year of date strings, one of mdy, dmy, ymd, ydm, myd and dym. DateFirst
+	// is the first day of the week, from 1 for Monday to 7 for Sunday, or 0
+	// when unset.
+	DateFormat string
+	DateFirst  uint8
+
 	Parameters map[string]string
 	// Protocols is an ordered list of protocols to dial
 	Protocols []string
@@ -169,6 +276,35 @@ type Config struct {
 	Encoding EncodeParameters
 	// EPA mode determines how the Channel Bindings are calculated.
 	EpaEnabled bool
+	// Pool holds the SqlClient pooling keywords of the connection string.
+	Pool PoolConfig
+}
+
+// PoolConfig holds the SqlClient connection pool keywords. database/sql owns
+// the pool, so they only take effect when applied to a *sql.DB with
+// mssql.Connector.ConfigurePool.
+type PoolConfig struct {
+	// Disabled is true when the connection string sets Pooling=false.
+	Disabled bool
+	// MaxSize is the Max Pool Size keyword. Zero means unset.
+	MaxSize int
+	// MinSize is the Min Pool Size keyword. Zero means unset. A value
+	// larger than MaxSize is lowered to MaxSize.
+	MinSize int
+	// ConnMaxLifetime is the Connection Lifetime keyword. Zero means unset.
+	ConnMaxLifetime time.Duration
+}
+
+// parseSqlClientBool parses a boolean keyword as SqlClient does, which
+// also accepts yes and no, in any case.
+func parseSqlClientBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
 }
 
 func readDERFile(filename string) ([]byte, error) {
@@ -290,6 +426,30 @@ func setupTLSServerCertificateOnly(config *tls.Config, pemData []byte) error {
 	return nil
 }
 
+// setupTLSFingerprint accepts only a server certificate whose SHA-256
+// fingerprint is fingerprint, hexadecimal digits optionally separated by
+// colons or spaces. Like setupTLSServerCertificateOnly it skips chain,
+// expiry and hostname validation, so a self-signed certificate can be
+// trusted without a CA or a copy of the certificate.
+func setupTLSFingerprint(config *tls.Config, fingerprint string) error {
+	expected, err := hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(fingerprint))
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("invalid serverCertificateFingerprint %q: expected the %d hexadecimal digits of a SHA-256 hash", fingerprint, 2*sha256.Size)
+	}
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no peer certificates provided")
+		}
+		actual := sha256.Sum256(rawCerts[0])
+		if subtle.ConstantTimeCompare(actual[:], expected) != 1 {
+			return fmt.Errorf("server certificate fingerprint %X doesn't match the pinned fingerprint", actual)
+		}
+		return nil
+	}
+	return nil
+}
+
 // parseTLS parses encryption parameters and returns the TLS configuration and trustServerCertificate value.
 func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, bool, error) {
 	trustServerCert := false
@@ -326,6 +486,7 @@ func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, b
 	certificate := params[Certificate]
 	serverCertificate := params[ServerCertificate]
 	hostInCertificate := params[HostNameInCertificate]
+	fingerprint := params[ServerCertificateFingerprint]
 
 	// Validate parameter combinations
 	if len(serverCertificate) > 0 {
@@ -336,6 +497,13 @@ func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, b
 			return encryption, nil, false, errors.New("cannot specify both 'serverCertificate' and 'hostnameincertificate' parameters")
 		}
 	}
+	if len(fingerprint) > 0 {
+		for _, other := range []string{Certificate, ServerCertificate, HostNameInCertificate} {
+			if len(params[other]) > 0 {
+				return encryption, nil, false, fmt.Errorf("cannot specify both 'serverCertificateFingerprint' and '%s' parameters", other)
+			}
+		}
+	}
 
 	if encryption != EncryptionDisabled {
 		tlsMin := params[TLSMin]
@@ -346,6 +514,11 @@ func parseTLS(params map[string]string, host string) (Encryption, *tls.Config, b
 		if err != nil {
 			return encryption, nil, trustServerCert, fmt.Errorf("failed to setup TLS: %w", err)
 		}
+		if len(fingerprint) > 0 {
+			if err = setupTLSFingerprint(tlsConfig, fingerprint); err != nil {
+				return encryption, nil, trustServerCert, fmt.Errorf("failed to setup TLS: %w", err)
+			}
+		}
 		return encryption, tlsConfig, trustServerCert, nil
 	}
 	return encryption, nil, trustServerCert, nil
@@ -424,6 +597,17 @@ func Parse(dsn string) (Config, error) {
 		}
 		p.Encoding.Timezone = location
 	}
+	if zoneless, ok := params[ZonelessTimeParam]; ok {
+		found := false
+		for mode, name := range zonelessTimeNames {
+			if strings.EqualFold(zoneless, name) {
+				p.Encoding.ZonelessTime, found = mode, true
+			}
+		}
+		if !found {
+			return p, fmt.Errorf("invalid zoneless time '%s': must be one of wallclock, utc or timezone", zoneless)
+		}
+	}
 
 	p.Database = params[Database]
 	p.User = params[UserID]
@@ -475,9 +659,37 @@ func Parse(dsn string) (Config, error) {
 		p.ConnTimeout = time.Duration(timeout) * time.Second
 	}
 
+	for name, timeout := range map[string]*time.Duration{ReadTimeout: &p.ReadTimeout, WriteTimeout: &p.WriteTimeout} {
+		if str, ok := params[name]; ok {
+			seconds, err := strconv.ParseUint(str, 10, 32)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s '%s': %v", name, str, err)
+			}
+			*timeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	p.DefaultSchema = params[DefaultSchema]
+
+	if dateFormat, ok := params[DateFormat]; ok {
+		p.DateFormat = strings.ToLower(dateFormat)
+		switch p.DateFormat {
+		case "mdy", "dmy", "ymd", "ydm", "myd", "dym":
+		default:
+			return p, fmt.Errorf("invalid dateformat '%s': must be one of mdy, dmy, ymd, ydm, myd or dym", dateFormat)
+		}
+	}
+	if dateFirst, ok := params[DateFirst]; ok {
+		first, err := strconv.ParseUint(dateFirst, 10, 8)
+		if err != nil || first < 1 || first > 7 {
+			return p, fmt.Errorf("invalid datefirst '%s': must be a day of the week from 1 (Monday) to 7 (Sunday)", dateFirst)
+		}
+		p.DateFirst = uint8(first)
+	}
+
 	// default keep alive should be 30 seconds according to spec:
 	// https://msdn.microsoft.com/library/dd341108.aspx
-	p.KeepAlive = 30 * time.Second
+	p.KeepAlive = defaultKeepAlive
 	if keepAlive, ok := params[KeepAlive]; ok {
 		timeout, err := strconv.ParseUint(keepAlive, 10, 64)
 		if err != nil {
@@ -485,6 +697,10 @@ func Parse(dsn string) (Config, error) {
 			return p, fmt.Errorf(f, keepAlive, err.Error())
 		}
 		p.KeepAlive = time.Duration(timeout) * time.Second
+		if p.KeepAlive == 0 {
+			// keepAlive=0 disables keep-alives, a zero KeepAlive field means the default.
+			p.KeepAlive = -1
+		}
 	}
 
 	serverSPN, ok := params[ServerSpn]
@@ -495,6 +711,7 @@ func Parse(dsn string) (Config, error) {
 	workstation, ok := params[WorkstationID]
 	if ok {
 		p.Workstation = workstation
+		p.WorkstationProvided = true
 	} else {
 		workstation, err := os.Hostname()
 		if err == nil {
@@ -504,7 +721,7 @@ func Parse(dsn string) (Config, error) {
 
 	appname, ok := params[AppName]
 	if !ok {
-		appname = "go-mssqldb"
+		appname = defaultAppName
 	}
 	p.AppName = appname
 
@@ -652,6 +869,33 @@ func Parse(dsn string) (Config, error) {
 		p.Encoding.GuidConversion = false
 	}
 
+	if pooling, ok := params[Pooling]; ok {
+		enabled, err := parseSqlClientBool(pooling)
+		if err != nil {
+			return p, fmt.Errorf("invalid pooling value '%s': %v", pooling, err)
+		}
+		p.Pool.Disabled = !enabled
+	}
+	for name, size := range map[string]*int{MaxPoolSize: &p.Pool.MaxSize, MinPoolSize: &p.Pool.MinSize} {
+		if str, ok := params[name]; ok {
+			n, err := strconv.ParseUint(str, 10, 31)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s '%s': %v", name, str, err)
+			}
+			*size = int(n)
+		}
+	}
+	if p.Pool.MaxSize > 0 && p.Pool.MinSize > p.Pool.MaxSize {
+		p.Pool.MinSize = p.Pool.MaxSize
+	}
+	if lifetime, ok := params[ConnectionLifetime]; ok {
+		seconds, err := strconv.ParseUint(lifetime, 10, 32)
+		if err != nil {
+			return p, fmt.Errorf("invalid connection lifetime '%s': %v", lifetime, err)
+		}
+		p.Pool.ConnMaxLifetime = time.Duration(seconds) * time.Second
+	}
+
 	p.EpaEnabled = false
 	epaString, ok := params[EpaEnabled]
 	if !ok {
@@ -722,9 +966,18 @@ func (p Config) URL() *url.URL {
 		q.Add(Encrypt, "DISABLE")
 	case EncryptionRequired:
 		q.Add(Encrypt, "true")
+	case EncryptionStrict:
+		q.Add(Encrypt, "strict")
+	default:
+		if _, ok := p.Parameters[Encrypt]; ok {
+			q.Add(Encrypt, "false")
+		}
 	}
-	// Only include TrustServerCertificate if it was explicitly set in the original connection string
-	if _, ok := p.Parameters[TrustServerCertificate]; ok {
+	// Only include TrustServerCertificate if it was explicitly set in the original
+	// connection string or differs from the default Parse would pick.
+	_, trustSet := p.Parameters[TrustServerCertificate]
+	defaultTrust := !q.Has(Encrypt)
+	if trustSet || (p.Encryption != EncryptionStrict && p.TrustServerCertificate != defaultTrust) {
 		q.Add(TrustServerCertificate, strconv.FormatBool(p.TrustServerCertificate))
 	}
 	if p.ColumnEncryption {
@@ -738,6 +991,9 @@ func (p Config) URL() *url.URL {
 	if tz := p.Encoding.Timezone; tz != nil && tz != time.UTC {
 		q.Add(Timezone, tz.String())
 	}
+	if name, ok := zonelessTimeNames[p.Encoding.ZonelessTime]; ok {
+		q.Add(ZonelessTimeParam, name)
+	}
 
 	if p.FailOverPartner != "" {
 		q.Add(FailoverPartner, p.FailOverPartner)
@@ -751,6 +1007,73 @@ func (p Config) URL() *url.URL {
 	if p.FailOverPartnerSPN != "" {
 		q.Add(FailoverPartnerSpn, p.FailOverPartnerSPN)
 	}
+	if p.ChangePassword != "" {
+		q.Add(ChangePassword, p.ChangePassword)
+	}
+	if p.AppName != "" && p.AppName != defaultAppName {
+		q.Add(AppName, p.AppName)
+	}
+	if hostname, _ := os.Hostname(); p.WorkstationProvided || p.Workstation != "" && p.Workstation != hostname {
+		q.Add(WorkstationID, p.Workstation)
+	}
+	if p.ReadOnlyIntent {
+		q.Add(ApplicationIntent, "ReadOnly")
+	}
+	if p.ConnTimeout != 0 {
+		q.Add(ConnectionTimeout, strconv.FormatFloat(p.ConnTimeout.Seconds(), 'f', 0, 64))
+	}
+	if p.ReadTimeout != 0 {
+		q.Add(ReadTimeout, strconv.FormatFloat(p.ReadTimeout.Seconds(), 'f', 0, 64))
+	}
+	if p.WriteTimeout != 0 {
+		q.Add(WriteTimeout, strconv.FormatFloat(p.WriteTimeout.Seconds(), 'f', 0, 64))
+	}
+	if p.DefaultSchema != "" {
+		q.Add(DefaultSchema, p.DefaultSchema)
+	}
+	if p.DateFormat != "" {
+		q.Add(DateFormat, p.DateFormat)
+	}
+	if p.DateFirst != 0 {
+		q.Add(DateFirst, strconv.Itoa(int(p.DateFirst)))
+	}
+	if p.KeepAlive < 0 {
+		q.Add(KeepAlive, "0")
+	} else if p.KeepAlive != defaultKeepAlive && p.KeepAlive != 0 {
+		q.Add(KeepAlive, strconv.FormatFloat(p.KeepAlive.Seconds(), 'f', 0, 64))
+	}
+	if p.PacketSize != 0 {
+		q.Add(PacketSize, strconv.FormatUint(uint64(p.PacketSize), 10))
+	}
+	if !p.MultiSubnetFailover {
+		q.Add(MultiSubnetFailover, "false")
+	}
+	if p.NoTraceID {
+		q.Add(NoTraceID, "true")
+	}
+	if p.EpaEnabled {
+		q.Add(EpaEnabled, "true")
+	}
+	if p.Pool.Disabled {
+		q.Add(Pooling, "false")
+	}
+	if p.Pool.MaxSize != 0 {
+		q.Add(MaxPoolSize, strconv.Itoa(p.Pool.MaxSize))
+	}
+	if p.Pool.MinSize != 0 {
+		q.Add(MinPoolSize, strconv.Itoa(p.Pool.MinSize))
+	}
+	if p.Pool.ConnMaxLifetime != 0 {
+		q.Add(ConnectionLifetime, strconv.FormatFloat(p.Pool.ConnMaxLifetime.Seconds(), 'f', 0, 64))
+	}
+
+	// Pass through parameters without a Config field, such as
+	// authentication, certificate and protocol specific settings.
+	for k, v := range p.Parameters {
+		if _, ok := urlFieldParameters[k]; !ok && !q.Has(k) {
+			q.Add(k, v)
+		}
+	}
 
 	if len(q) > 0 {
 		res.RawQuery = q.Encode()
@@ -759,6 +1082,20 @@ func (p Config) URL() *url.URL {
 	return &res
 }
 
+// urlFieldParameters are the parameters URL() derives from Config fields
+// rather than copying them from Config.Parameters.
+var urlFieldParameters = map[string]struct{}{
+	Server: {}, Port: {}, UserID: {}, Password: {}, Database: {}, LogParam: {},
+	DisableRetry: {}, Protocol: {}, Pipe: {}, DialTimeout: {}, Encrypt: {},
+	TrustServerCertificate: {}, "columnencryption": {}, GuidConversion: {},
+	Timezone: {}, ZonelessTimeParam: {}, FailoverPartner: {}, FailOverPort: {}, ServerSpn: {},
+	FailoverPartnerSpn: {}, ChangePassword: {}, AppName: {}, WorkstationID: {},
+	ApplicationIntent: {}, ConnectionTimeout: {}, KeepAlive: {}, PacketSize: {},
+	MultiSubnetFailover: {}, NoTraceID: {}, EpaEnabled: {}, Pooling: {},
+	MaxPoolSize: {}, MinPoolSize: {}, ConnectionLifetime: {}, ReadTimeout: {},
+	WriteTimeout: {}, DefaultSchema: {}, DateFormat: {}, DateFirst: {},
+}
+
 // adoSynonyms maps ADO.Net alternate keyword forms to this driver's canonical keys.
 // See https://learn.microsoft.com/dotnet/api/microsoft.data.sqlclient.sqlconnection.connectionstring
 var adoSynonyms = map[string]string{
@@ -784,6 +1121,8 @@ var adoSynonyms = map[string]string{
 	"server certificate":        ServerCertificate,
 	"wsid":                      WorkstationID,
 	"column encryption setting": "columnencryption",
+	"load balance timeout":      ConnectionLifetime,
+	"mars connection":           MultipleActiveResultSets,
 }
 
 func splitConnectionString(dsn string) (res map[string]string) {
@@ -1,11 +1,23 @@
 package msdsn
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
+	"encoding/pem"
+	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +40,7 @@ func TestInvalidConnectionString(t *testing.T) {
 		"disableretry=invalid",
 		"multisubnetfailover=invalid",
 		"timezone=invalid",
+		"zoneless time=local",
 		"epa enabled=invalid",
 
 		// ODBC mode
@@ -152,6 +165,9 @@ func TestValidConnectionString(t *testing.T) {
 		{"MultiSubnetFailover=true;NoTraceID=true", func(p Config) bool { return p.MultiSubnetFailover && p.NoTraceID }},
 		{"MultiSubnetFailover=false", func(p Config) bool { return !p.MultiSubnetFailover }},
 		{"timezone=Asia/Shanghai", func(p Config) bool { return p.Encoding.Timezone.String() == "Asia/Shanghai" }},
+		{"zoneless time=UTC", func(p Config) bool { return p.Encoding.ZonelessTime == ZonelessUTC }},
+		{"zoneless time=wallclock", func(p Config) bool { return p.Encoding.ZonelessTime == ZonelessWallClock }},
+		{"zoneless time=timezone", func(p Config) bool { return p.Encoding.ZonelessTime == ZonelessTimezone }},
 		{"Pwd=placeholder", func(p Config) bool { return p.Password == "placeholder" }},
 		{"epa enabled=true", func(p Config) bool { return p.EpaEnabled }},
 		{"epa enabled=false", func(p Config) bool { return !p.EpaEnabled }},
@@ -206,7 +222,7 @@ func TestValidConnectionString(t *testing.T) {
 		{"Multi Subnet Failover=false", func(p Config) bool { return !p.MultiSubnetFailover }},
 		{"Host Name In Certificate=myhost", func(p Config) bool { return p.HostInCertificateProvided }},
 		{"Server SPN=MSSQLSvc/myhost:1433", func(p Config) bool { return p.ServerSPN == "MSSQLSvc/myhost:1433" }},
-		{"WSID=myworkstation", func(p Config) bool { return p.Workstation == "myworkstation" }},
+		{"WSID=myworkstation", func(p Config) bool { return p.Workstation == "myworkstation" && p.WorkstationProvided }},
 		{"Column Encryption Setting=true", func(p Config) bool { return p.ColumnEncryption }},
 		// Verify synonym keys work together in the same connection string
 		{"Data Source=somehost;Initial Catalog=mydb;Connect Timeout=30", func(p Config) bool {
@@ -364,6 +380,207 @@ func TestConnParseRoundTripFixed(t *testing.T) {
 	}
 }
 
+func TestConnParseRoundTripAllFields(t *testing.T) {
+	defaults, err := Parse("sqlserver://localhost")
+	require.NoError(t, err)
+
+	tz, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("Europe/Paris timezone not available")
+	}
+	p := defaults
+	p.Parameters = map[string]string{
+		"fedauth":               "ActiveDirectoryServicePrincipal",
+		"applicationclientid":   "client",
+		HostNameInCertificate:   "cert.example.com",
+		TLSMin:                  "1.2",
+		"krb5-configfile":       "/etc/krb5.conf",
+		TrustServerCertificate:  "false",
+		"some future parameter": "value",
+	}
+	p.Port = 1444
+	p.Host = "db.example.com"
+	p.Instance = "inst"
+	p.Database = "appdb"
+	p.User = "user"
+	p.Password = "p@ss;word"
+	p.Encryption = EncryptionRequired
+	p.FailOverPartner = "mirror"
+	p.FailOverPort = 2000
+	p.FailOverPartnerSPN = "MSSQLSvc/mirror:2000"
+	p.HostInCertificateProvided = true
+	p.ReadOnlyIntent = true
+	p.LogFlags = LogErrors | LogSQL
+	p.ServerSPN = "MSSQLSvc/db:1444"
+	p.Workstation = "build-agent"
+	p.WorkstationProvided = true
+	p.AppName = "migrator"
+	p.DisableRetry = !defaults.DisableRetry
+	p.DialTimeout = 7 * time.Second
+	p.ConnTimeout = 9 * time.Second
+	p.KeepAlive = 11 * time.Second
+	p.ReadTimeout = 13 * time.Second
+	p.WriteTimeout = 17 * time.Second
+	p.DefaultSchema = "sales"
+	p.DateFormat = "dmy"
+	p.DateFirst = 1
+	p.PacketSize = 8192
+	p.ChangePassword = "newpass"
+	p.ColumnEncryption = true
+	p.MultiSubnetFailover = false
+	p.NoTraceID = true
+	p.TrustServerCertificate = false
+	p.Encoding = EncodeParameters{GuidConversion: true, Timezone: tz, ZonelessTime: ZonelessUTC}
+	p.EpaEnabled = true
+	p.Pool = PoolConfig{Disabled: true, MaxSize: 50, MinSize: 5, ConnMaxLifetime: time.Minute}
+
+	rt, err := Parse(p.URL().String())
+	require.NoError(t, err, "URL: %s", p.URL())
+
+	// Fields that are derived by Parse rather than serialized.
+	derived := map[string]bool{
+		"TLSConfig": true, "Parameters": true, "Protocols": true,
+		"ProtocolParameters": true, "BrowserMessage": true, "ActivityID": true,
+	}
+	pv, rv, dv := reflect.ValueOf(p), reflect.ValueOf(rt), reflect.ValueOf(defaults)
+	for i := 0; i < pv.NumField(); i++ {
+		name := pv.Type().Field(i).Name
+		if derived[name] {
+			continue
+		}
+		assert.False(t, reflect.DeepEqual(pv.Field(i).Interface(), dv.Field(i).Interface()),
+			"%s must be set to a non-default value to be covered by the round trip", name)
+		assert.Equal(t, pv.Field(i).Interface(), rv.Field(i).Interface(), "%s did not survive the round trip", name)
+	}
+	for k, v := range p.Parameters {
+		assert.Equal(t, v, rt.Parameters[k], "parameter %q did not survive the round trip", k)
+	}
+	assert.Equal(t, "cert.example.com", rt.TLSConfig.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS12), rt.TLSConfig.MinVersion)
+}
+
+func TestURLWritesProvidedWorkstation(t *testing.T) {
+	p, err := Parse("server=localhost")
+	require.NoError(t, err)
+	assert.False(t, p.WorkstationProvided)
+	assert.NotContains(t, p.URL().RawQuery, "workstation", "the host name default is not written")
+	p.Workstation = "etl-7"
+	rt, err := Parse(p.URL().String())
+	require.NoError(t, err)
+	assert.Equal(t, "etl-7", rt.Workstation, "a workstation changed in code is written")
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	p, err = Parse("server=localhost;workstation id=" + hostname)
+	require.NoError(t, err)
+	assert.True(t, p.WorkstationProvided)
+	rt, err = Parse(p.URL().String())
+	require.NoError(t, err)
+	assert.Equal(t, hostname, rt.Workstation)
+	assert.True(t, rt.WorkstationProvided, "a workstation set to the host name is kept")
+
+	built := Config{Host: "db.example.com", Workstation: "etl-7"}
+	rt, err = Parse(built.URL().String())
+	require.NoError(t, err)
+	assert.Equal(t, "etl-7", rt.Workstation, "a Config built in code keeps its workstation")
+	assert.Equal(t, "db.example.com", rt.Host)
+}
+
+func TestParseKeepAlive(t *testing.T) {
+	p, err := Parse("server=localhost")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, p.KeepAlive, "default")
+
+	p, err = Parse("server=localhost;keepAlive=10")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, p.KeepAlive)
+
+	p, err = Parse("server=localhost;keepAlive=0")
+	require.NoError(t, err)
+	assert.Less(t, p.KeepAlive, time.Duration(0), "keepAlive=0 disables keep-alives")
+	rt, err := Parse(p.URL().String())
+	require.NoError(t, err)
+	assert.Equal(t, p.KeepAlive, rt.KeepAlive, "disabled keep-alive must survive a round trip")
+}
+
+func TestParseReadWriteTimeout(t *testing.T) {
+	p, err := Parse("server=localhost;read timeout=5;write timeout=10")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, p.ReadTimeout)
+	assert.Equal(t, 10*time.Second, p.WriteTimeout)
+
+	_, err = Parse("sqlserver://localhost?read+timeout=-1")
+	assert.Error(t, err)
+	_, err = Parse("sqlserver://localhost?write+timeout=1s")
+	assert.Error(t, err)
+}
+
+func TestParseDefaultSchema(t *testing.T) {
+	p, err := Parse("server=localhost;default schema=sales")
+	require.NoError(t, err)
+	assert.Equal(t, "sales", p.DefaultSchema)
+	p, err = Parse("sqlserver://localhost?default+schema=sales")
+	require.NoError(t, err)
+	assert.Equal(t, "sales", p.DefaultSchema)
+}
+
+func TestParseDateSettings(t *testing.T) {
+	p, err := Parse("server=localhost;dateformat=DMY;datefirst=1")
+	require.NoError(t, err)
+	assert.Equal(t, "dmy", p.DateFormat)
+	assert.Equal(t, uint8(1), p.DateFirst)
+	p, err = Parse("sqlserver://localhost")
+	require.NoError(t, err)
+	assert.Equal(t, "", p.DateFormat)
+	assert.Equal(t, uint8(0), p.DateFirst)
+
+	for _, dsn := range []string{
+		"server=localhost;dateformat=dd/mm/yyyy",
+		"server=localhost;datefirst=0",
+		"server=localhost;datefirst=8",
+		"server=localhost;datefirst=monday",
+	} {
+		_, err = Parse(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestParsePoolKeywords(t *testing.T) {
+	p, err := Parse("server=localhost;Pooling=true;Max Pool Size=100;Min Pool Size=10;Load Balance Timeout=300;MultipleActiveResultSets=True")
+	require.NoError(t, err)
+	assert.Equal(t, PoolConfig{MaxSize: 100, MinSize: 10, ConnMaxLifetime: 300 * time.Second}, p.Pool)
+
+	p, err = Parse("sqlserver://localhost?pooling=false&connection+lifetime=60")
+	require.NoError(t, err)
+	assert.Equal(t, PoolConfig{Disabled: true, ConnMaxLifetime: time.Minute}, p.Pool)
+
+	p, err = Parse("server=localhost;MARS Connection=yes")
+	require.NoError(t, err, "MARS is accepted and ignored")
+	assert.Equal(t, PoolConfig{}, p.Pool)
+	assert.Equal(t, "yes", p.Parameters[MultipleActiveResultSets])
+
+	p, err = Parse("server=localhost;Pooling=No")
+	require.NoError(t, err)
+	assert.True(t, p.Pool.Disabled, "SqlClient spells booleans yes and no too")
+	p, err = Parse("server=localhost;Pooling=YES")
+	require.NoError(t, err)
+	assert.False(t, p.Pool.Disabled)
+
+	p, err = Parse("server=localhost;Max Pool Size=5;Min Pool Size=10")
+	require.NoError(t, err)
+	assert.Equal(t, PoolConfig{MaxSize: 5, MinSize: 5}, p.Pool, "the minimum is lowered to the maximum")
+
+	for _, dsn := range []string{
+		"server=localhost;Pooling=maybe",
+		"server=localhost;Max Pool Size=-1",
+		"server=localhost;Min Pool Size=abc",
+		"server=localhost;Connection Lifetime=1.5",
+	} {
+		_, err := Parse(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
 func TestURLWithIPv6Address(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -758,6 +975,21 @@ func TestEncodeParametersGetTimezone(t *testing.T) {
 	assert.Equal(t, loc, result2, "GetTimezone() should return the set timezone")
 }
 
+func TestEncodeParametersZonelessLocation(t *testing.T) {
+	t.Parallel()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("America/New_York timezone not available")
+	}
+	assert.Nil(t, EncodeParameters{}.ZonelessLocation(), "the wall clock is written as is by default")
+	assert.Nil(t, EncodeParameters{Timezone: time.UTC}.ZonelessLocation())
+	assert.Equal(t, loc, EncodeParameters{Timezone: loc}.ZonelessLocation(), "converted to a timezone other than UTC by default")
+	assert.Nil(t, EncodeParameters{Timezone: loc, ZonelessTime: ZonelessWallClock}.ZonelessLocation())
+	assert.Equal(t, time.UTC, EncodeParameters{Timezone: loc, ZonelessTime: ZonelessUTC}.ZonelessLocation())
+	assert.Equal(t, time.UTC, EncodeParameters{ZonelessTime: ZonelessTimezone}.ZonelessLocation())
+	assert.Equal(t, loc, EncodeParameters{Timezone: loc, ZonelessTime: ZonelessTimezone}.ZonelessLocation())
+}
+
 func TestConfigURL(t *testing.T) {
 	t.Parallel()
 
@@ -819,3 +1051,137 @@ func TestConfigURL(t *testing.T) {
 		})
 	}
 }
+
+// selfSignedCertificate returns a TLS certificate for host, and the other
+// names of its subject alternative names, signed by its own key, as
+// servers without a CA certificate use.
+func selfSignedCertificate(t *testing.T, host string, names ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     append([]string{host}, names...),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshake runs a TLS handshake of a client using config with a server
+// presenting cert.
+func handshake(config *tls.Config, cert tls.Certificate) error {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		_ = tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+		server.Close()
+	}()
+	return tls.Client(client, config).Handshake()
+}
+
+func TestServerCertificateFingerprint(t *testing.T) {
+	cert := selfSignedCertificate(t, "sqlhost")
+	sum := sha256.Sum256(cert.Certificate[0])
+	other := sha256.Sum256([]byte("another certificate"))
+
+	for _, tt := range []struct {
+		name        string
+		fingerprint string
+		ok          bool
+	}{
+		{"matching", hex.EncodeToString(sum[:]), true},
+		{"matching with colons", strings.ReplaceAll(fmt.Sprintf("% X", sum[:]), " ", ":"), true},
+		{"not matching", hex.EncodeToString(other[:]), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// the host name does not match the certificate and no CA signed it
+			p, err := Parse("server=otherhost;encrypt=true;serverCertificateFingerprint=" + tt.fingerprint)
+			require.NoError(t, err)
+			require.NotNil(t, p.TLSConfig)
+			assert.False(t, p.TrustServerCertificate, "pinning is not blanket trust")
+			err = handshake(p.TLSConfig, cert)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "doesn't match the pinned fingerprint")
+			}
+		})
+	}
+
+	for _, dsn := range []string{
+		"server=host;encrypt=true;serverCertificateFingerprint=abcd",
+		"server=host;encrypt=true;serverCertificateFingerprint=" + strings.Repeat("zz", 32),
+		"server=host;encrypt=true;serverCertificateFingerprint=" + hex.EncodeToString(sum[:]) + ";hostnameincertificate=host",
+		"server=host;encrypt=true;serverCertificateFingerprint=" + hex.EncodeToString(sum[:]) + ";certificate=ca.pem",
+	} {
+		_, err := Parse(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestHostNameInCertificateListener(t *testing.T) {
+	// the certificate of the replicas of an availability group, which holds
+	// the listener name and the node names in its subject alternative names
+	cert := selfSignedCertificate(t, "aglistener.contoso.com", "*.nodes.contoso.com")
+	file := filepath.Join(t.TempDir(), "listener.pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+
+	for _, tt := range []struct {
+		name string
+		dsn  string
+		sni  string
+		ok   bool
+	}{
+		{"listener", "server=aglistener.contoso.com", "aglistener.contoso.com", true},
+		{"node in the wildcard", "server=sql1.nodes.contoso.com", "sql1.nodes.contoso.com", true},
+		{"address of the listener", "server=10.1.2.3;hostnameincertificate=aglistener.contoso.com", "aglistener.contoso.com", true},
+		{"node not in the certificate", "server=sql1.contoso.com;hostnameincertificate=aglistener.contoso.com", "aglistener.contoso.com", true},
+		{"address without the listener name", "server=10.1.2.3", "", false},
+		{"other node name", "server=sql1.contoso.com", "sql1.contoso.com", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.dsn + ";encrypt=true;certificate=" + file)
+			require.NoError(t, err)
+			// a TCP connection rather than a synchronous pipe, with deadlines,
+			// so the side of a failed handshake cannot block the other
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer listener.Close()
+			sni := make(chan string, 1)
+			go func() {
+				server, err := listener.Accept()
+				if err != nil {
+					sni <- ""
+					return
+				}
+				defer server.Close()
+				_ = server.SetDeadline(time.Now().Add(10 * time.Second))
+				_ = tls.Server(server, &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+					sni <- hello.ServerName
+					return &cert, nil
+				}}).Handshake()
+			}()
+			client, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+			defer client.Close()
+			require.NoError(t, client.SetDeadline(time.Now().Add(10*time.Second)))
+			err = tls.Client(client, p.TLSConfig).Handshake()
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			select {
+			case got := <-sni:
+				assert.Equal(t, tt.sni, got, "SNI")
+			case <-time.After(10 * time.Second):
+				t.Fatal("the server received no client hello")
+			}
+		})
+	}
+}
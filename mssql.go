@@ -13,6 +13,7 @@ import (
 	"net"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -38,6 +39,7 @@ func init() {
 	sql.Register("mssql", driverInstance)
 	sql.Register("sqlserver", driverInstanceNoProcess)
 	createDialer = func(p *msdsn.Config) Dialer {
+		// A negative KeepAlive disables TCP keep-alives.
 		ka := p.KeepAlive
 		if ka == 0 {
 			ka = 30 * time.Second
@@ -126,13 +128,38 @@ func NewConnector(dsn string) (*Connector, error) {
 	return c, nil
 }
 
+// NewConnectorWithConn creates a new connector from a DSN that runs the
+// TDS handshake over the connections returned by dial instead of dialing
+// the server of the DSN, for custom tunnels and test harnesses. The
+// returned connector may be used with sql.OpenDB.
+//
+// dial is called for every new connection and must return a connection to
+// the server that has not been used yet. The driver closes it when the
+// connection is closed or fails to log in, and sets its deadlines for the
+// timeouts of the DSN. What a dialer would otherwise set up, such as TCP
+// keep-alives, is left to dial. A server that routes the connection to
+// another server, as Azure SQL read replicas do, fails the login, since
+// dial cannot reach the routed address.
+func NewConnectorWithConn(dsn string, dial func(ctx context.Context) (net.Conn, error)) (*Connector, error) {
+	c, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c.dialConn = dial
+	return c, nil
+}
+
 // NewConnectorWithAccessTokenProvider creates a new connector from a DSN using the given
 // access token provider. The returned connector may be used with sql.OpenDB.
+// As with NewAccessTokenConnector, the DSN must not set user id or password.
 func NewConnectorWithAccessTokenProvider(dsn string, tokenProvider func(ctx context.Context) (string, error)) (*Connector, error) {
 	params, err := msdsn.Parse(dsn)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkTokenConfig(params); err != nil {
+		return nil, err
+	}
 
 	return NewSecurityTokenConnector(
 		params,
@@ -180,6 +207,10 @@ type Connector struct {
 	// callback that can provide a security token during login
 	securityTokenProvider func(ctx context.Context) (string, error)
 
+	// dialConn returns the connections of a connector of
+	// NewConnectorWithConn.
+	dialConn func(ctx context.Context) (net.Conn, error)
+
 	// callback that can provide a security token during ADAL login
 	adalTokenProvider func(ctx context.Context, serverSPN, stsURL string) (string, error)
 
@@ -204,6 +235,9 @@ type Connector struct {
 	//
 	// SessionInitSQL is optional. The session will be reset even if
 	// SessionInitSQL is empty.
+	//
+	// The dateformat and datefirst connection parameters are applied in the
+	// same batch, before SessionInitSQL, which may override them.
 	SessionInitSQL string
 
 	// Dialer sets a custom dialer for all network operations, except DNS resolution unless
@@ -212,7 +246,140 @@ type Connector struct {
 	// If Dialer is not set, normal net dialers are used.
 	Dialer Dialer
 
+	// QueryTag, when set, adds a comment to the text of every query sent on
+	// connections created by this Connector. See QueryTag for the effect on
+	// plan caching.
+	QueryTag *QueryTag
+
+	// EmptyStringsAsNull, when set, sends empty string parameters as NULL,
+	// for applications ported from databases that do not distinguish the
+	// two. It applies to string values, including those of defined string
+	// types; the driver string types such as VarChar, and OUTPUT
+	// parameters, are sent unchanged.
+	//
+	// The driver does not know the target of a parameter, so an empty
+	// string becomes NULL everywhere it is used: comparisons such as
+	// WHERE name = @p1 no longer match empty values, and inserts into NOT
+	// NULL columns fail. Empty strings can no longer be stored through
+	// parameters on these connections.
+	EmptyStringsAsNull bool
+
+	// NullsAsZero, when set, returns the zero value of the type of a column
+	// instead of NULL for all the queries of the connections. See
+	// WithNullsAsZero for the data this loses.
+	NullsAsZero bool
+
+	// TextNormalization, when set, returns the text values of the queries
+	// of the connections in the Unicode normalization form. See
+	// WithTextNormalization for its cost.
+	TextNormalization TextNormalization
+
+	// TraceContextInfo, when set, sets CONTEXT_INFO to the trace id stored
+	// in the context of a query by WithTraceID before the query runs, so
+	// extended events, audits and DMVs such as sys.dm_exec_requests can
+	// report it. The id is set with a request of its own before the query,
+	// which costs a round trip, only when it differs from the id the session
+	// holds; the query itself is sent unchanged. Ids longer than 128 bytes
+	// are truncated.
+	//
+	// CONTEXT_INFO belongs to the session and outlasts the query: the next
+	// query on the connection without a trace id clears it, as does the
+	// reset of a connection returned to the pool. Stored
+	// procedures called by name and queries run with WithSQLBatch do not
+	// set it, and run with the value left by the query before them.
+	TraceContextInfo bool
+
+	// CoalesceInserts, when set, sends the single row inserts a transaction
+	// runs with the same statement, such as INSERT INTO t (a, b) VALUES
+	// (@p1, @p2) in a loop, as one bulk copy instead of one request each.
+	// The rows are streamed to the server as they are inserted, and the
+	// copy ends before the next other statement of the transaction or its
+	// commit, so the transaction reads its own inserts.
+	//
+	// The server checks the rows once the copy ends: the error of a
+	// rejected row is returned by the next statement, or by Commit, which
+	// then rolls the transaction back, rather than by the Exec inserting
+	// it, which reports one row affected. Triggers fire once for the rows
+	// of the copy. Inserts outside transactions, into identity columns, or
+	// of values other than parameters run as usual.
+	CoalesceInserts bool
+
+	// Reconnect, when set, lets a connection whose network session broke
+	// outside of a transaction open a new session on its next use instead
+	// of failing for the rest of its life. It is meant for a *sql.Conn held
+	// for a long time by a worker, which otherwise has to be replaced after
+	// a brief network failure.
+	//
+	// The new session starts from the defaults of the connection string
+	// and runs SessionInitSQL again. Temporary tables, SET options and any
+	// other session state created by earlier statements are lost. An open
+	// transaction cannot be recovered: its statements fail as before.
+	//
+	// A request sent on a connection that turns out to be broken before
+	// the server answered is sent once more on the new session. A server
+	// that fails after running the request but before replying runs it
+	// twice, so only set Reconnect where statements are safe to repeat, or
+	// see IdempotentWrites.
+	Reconnect bool
+
+	// IdempotentWrites, when set with Reconnect, names a marker table, as
+	// written in T-SQL, such as dbo.go_mssqldb_writes, that makes the
+	// statements run by Exec outside transactions safe to send again. The
+	// driver does not quote the name. Create the table once with
+	//
+	//	CREATE TABLE dbo.go_mssqldb_writes (
+	//		id uniqueidentifier PRIMARY KEY,
+	//		rows_affected bigint,
+	//		created datetime2 DEFAULT SYSUTCDATETIME())
+	//
+	// Each statement then runs in a transaction of its own, with XACT_ABORT
+	// on, which also inserts a row with a new id into the table. When the
+	// connection breaks after the statement was sent, the driver reconnects
+	// and looks the id up: a statement that committed is reported with the
+	// rows it affected rather than run twice, and one that did not is sent
+	// again with the same id, which cannot commit twice. The lookup waits
+	// for a transaction still running on the server to end.
+	//
+	// This costs an insert and an update of the marker table, in the
+	// transaction log too, for every statement, and the rows of the table
+	// are never deleted by the driver: delete the old ones, by created, from
+	// time to time. The statement must be a single statement that commits
+	// or rolls back no transaction of its own. Queries, stored procedure
+	// calls, SQL batches, statements with output parameters and statements
+	// in transactions run as usual, as do the statements that must start a
+	// batch, CREATE and ALTER of a PROCEDURE, VIEW, FUNCTION, TRIGGER,
+	// SCHEMA, DEFAULT or RULE, and those that cannot run in a transaction:
+	// CREATE, ALTER and DROP of a DATABASE or FULLTEXT catalog or index,
+	// BACKUP, RESTORE, RECONFIGURE and KILL.
+	IdempotentWrites string
+
+	// RetryReads, when set, sends a query again, once, when it fails with
+	// a transient error, as reported by IsTransient, before its first row
+	// was read: a deadlock, a busy or failing over Azure SQL database, or a
+	// broken connection when Reconnect is set too. The caller receives the
+	// rows of the second attempt as if the first had not happened.
+	//
+	// Only queries that cannot change anything are sent again: those that
+	// start with SELECT or WITH and use none of the keywords of statements
+	// with side effects, such as INTO, INSERT, UPDATE, DELETE, MERGE, EXEC,
+	// SET or DECLARE, nor NEXT VALUE FOR, outside string literals, quoted
+	// identifiers and comments. Exec, stored procedure calls, queries with
+	// output parameters or a return status, queries in transactions, whose
+	// failure rolls the transaction back, and queries whose rows have begun
+	// to be read fail as before. Functions called by the query are not
+	// checked: a CLR function or an extended procedure with side effects
+	// runs twice.
+	RetryReads bool
+
+	// PacketCapture, when set, writes the raw TDS traffic of the
+	// connections to a writer for debugging. See PacketCapture.
+	PacketCapture *PacketCapture
+
 	keyProviders aecmk.ColumnEncryptionKeyProviderMap
+
+	// prelogin is the pre-login of the last connection, see PreloginInfo.
+	preloginMu sync.Mutex
+	prelogin   *PreloginInfo
 }
 
 type Dialer interface {
@@ -233,6 +400,69 @@ func (c *Connector) getDialer(p *msdsn.Config) Dialer {
 	return createDialer(p)
 }
 
+// ConfigurePool applies the SqlClient pooling keywords of the connection
+// string (Pooling, Max Pool Size, Min Pool Size and Connection Lifetime) to
+// db, which should be opened with sql.OpenDB(c). database/sql owns the pool,
+// so the keywords have no effect unless ConfigurePool is called.
+//
+// Max Pool Size sets the maximum number of open connections and Connection
+// Lifetime the maximum connection lifetime. database/sql does not open
+// connections in advance, so Min Pool Size only sets the number of idle
+// connections kept open. Pooling=false keeps no idle connections.
+func (c *Connector) ConfigurePool(db *sql.DB) {
+	pool := c.params.Pool
+	if pool.MaxSize > 0 {
+		db.SetMaxOpenConns(pool.MaxSize)
+	}
+	if pool.Disabled {
+		db.SetMaxIdleConns(0)
+	} else if pool.MinSize > 0 {
+		db.SetMaxIdleConns(pool.MinSize)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+}
+
+// Warmup opens n connections of db at once and returns them to its idle
+// pool, so the first queries do not pay for dialing, TLS and login. db
+// should be opened with sql.OpenDB(c).
+//
+// database/sql keeps at most MaxIdleConns idle connections, 2 by default,
+// and closes the others as soon as they are returned; call SetMaxIdleConns
+// with at least n, or ConfigurePool with a Min Pool Size, before Warmup.
+// n is capped to MaxOpenConns when it is set. Idle connections are still
+// closed by SetConnMaxIdleTime and SetConnMaxLifetime.
+//
+// Connections that fail to open are reported in the returned error; the
+// others stay in the pool.
+func (c *Connector) Warmup(ctx context.Context, db *sql.DB, n int) error {
+	if maxOpen := db.Stats().MaxOpenConnections; maxOpen > 0 && n > maxOpen {
+		n = maxOpen
+	}
+	if n <= 0 {
+		return nil
+	}
+	// Hold every connection until all are open, so none is reused.
+	conns := make([]*sql.Conn, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conns[i], errs[i] = db.Conn(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for _, conn := range conns {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // RegisterCekProvider associates the given provider with the named key store. If an entry of the given name already exists, that entry is overwritten
 func (c *Connector) RegisterCekProvider(name string, provider aecmk.ColumnEncryptionKeyProvider) {
 	c.keyProviders[name] = aecmk.NewCekProvider(provider)
@@ -250,18 +480,69 @@ type Conn struct {
 	// True between Begin() and Commit()/Rollback(); detects server-side rollback.
 	inTransaction bool
 
+	// Cached by ServerTime.
+	serverTime *ServerTime
+
+	// request is the request Interrupt stops, or nil.
+	requestMu sync.Mutex
+	request   *request
+
+	// inserts is the bulk copy taking the inserts of the transaction with
+	// Connector.CoalesceInserts, and uncoalesced the inserts it cannot take.
+	inserts     *coalescedInsert
+	uncoalesced map[string]bool
+
+	// sessionContextInfo is the CONTEXT_INFO the session holds, set with
+	// Connector.TraceContextInfo.
+	sessionContextInfo []byte
+
 	outs outputs
 }
 
 type outputs struct {
 	params       map[string]interface{}
 	returnStatus *ReturnStatus
+	baseColumns  *BaseColumns
+	xmlSchemas   *XMLSchemaCollections
 	msgq         *sqlexp.ReturnMessage
+	// stringsAsBytes is set by a StringsAsBytes argument
+	stringsAsBytes bool
+}
+
+// outParam returns the destination of the output parameter name. The
+// server returns the name of a parameter as declared, as in the @params of
+// sp_executesql, which may differ in case from the name it was passed as.
+func (o outputs) outParam(name string) (interface{}, bool) {
+	if dest, has := o.params[name]; has {
+		return dest, true
+	}
+	for n, dest := range o.params {
+		if strings.EqualFold(n, name) {
+			return dest, true
+		}
+	}
+	return nil, false
+}
+
+// hasOutputs reports whether the query has output parameters or a return
+// status to receive.
+func (o outputs) hasOutputs() bool {
+	return len(o.params) > 0 || o.returnStatus != nil
 }
 
 // IsValid satisfies the driver.Validator interface.
 func (c *Conn) IsValid() bool {
-	return c.connectionGood
+	return c.connectionGood && !c.tokenExpiring()
+}
+
+// tokenExpiring reports whether the federated authentication token of the
+// session is about to expire. SQL Server cannot re-authenticate a session
+// with a new token, so such connections are replaced by the pool.
+func (c *Conn) tokenExpiring() bool {
+	if c.sess == nil || c.sess.tokenExpiry.IsZero() {
+		return false
+	}
+	return time.Until(c.sess.tokenExpiry) < fedAuthTokenRefreshMargin
 }
 
 // checkBadConn marks the connection as bad based on the characteristics
@@ -294,7 +575,7 @@ func (c *Conn) checkBadConn(ctx context.Context, err error, mayRetry bool) error
 		c.connectionGood = false
 	}
 
-	if !c.connectionGood && mayRetry && !c.connector.params.DisableRetry {
+	if !c.connectionGood && mayRetry && !c.connector.params.DisableRetry && !c.mayReconnect() {
 		c.sess.Log(ctx, msdsn.LogRetries, err.Error)
 		return newRetryableError(err)
 	}
@@ -302,6 +583,73 @@ func (c *Conn) checkBadConn(ctx context.Context, err error, mayRetry bool) error
 	return err
 }
 
+// mayReconnect reports whether a broken connection may open a new
+// session. See Connector.Reconnect.
+func (c *Conn) mayReconnect() bool {
+	return c.connector != nil && c.connector.Reconnect && c.connector.driver != nil && !c.inTransaction
+}
+
+// ensureConnected returns driver.ErrBadConn for a broken connection, unless
+// it may reconnect, in which case it replaces the session with a new one.
+func (c *Conn) ensureConnected(ctx context.Context) error {
+	if c.connectionGood {
+		return nil
+	}
+	if !c.mayReconnect() {
+		return driver.ErrBadConn
+	}
+	conn, err := c.connector.driver.connect(ctx, c.connector, c.connector.params)
+	if err != nil {
+		// Not driver.ErrBadConn, which would make database/sql discard
+		// a *sql.Conn that may reconnect later.
+		return fmt.Errorf("mssql: reconnect failed: %w", err)
+	}
+	_ = c.Close()
+	c.sess = conn.sess
+	c.connectionGood = true
+	c.resetSession = false
+	c.transactionCtx = context.Background()
+	c.serverTime = nil
+	c.sessionContextInfo = nil
+	c.sess.LogS(ctx, msdsn.LogRetries, "reconnected after the connection broke")
+
+	if initSQL := c.connector.sessionInitSQL(); len(initSQL) > 0 {
+		// keep the outputs of the request that triggered the reconnect
+		outs := c.outs
+		defer func() { c.outs = outs }()
+		s, _ := c.prepareContext(ctx, initSQL)
+		if err = s.sendQuery(ctx, nil); err == nil {
+			_, err = s.processExec(ctx)
+		}
+		if err != nil {
+			c.connectionGood = false
+			return fmt.Errorf("mssql: session init after reconnect failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// mayReplay reports whether a request that failed with err is sent again
+// on a new session: the connection broke before the server answered, and
+// it may reconnect. Requests that failed to send never reached the server.
+func (c *Conn) mayReplay(err error, sent bool) bool {
+	if c.connectionGood || !c.mayReconnect() {
+		return false
+	}
+	if !sent {
+		return true
+	}
+	// The response reader reports a failure to read the first packet of
+	// the response as a *net.OpError, later failures as a StreamError,
+	// which may wrap one.
+	var streamErr StreamError
+	if errors.As(err, &streamErr) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
+
 func (c *Conn) clearOuts() {
 	c.outs = outputs{}
 }
@@ -336,6 +684,11 @@ func (c *Conn) Commit() error {
 		return driver.ErrBadConn
 	}
 	defer func() { c.inTransaction = false }()
+	if err := c.flushInserts(); err != nil {
+		// some rows of the transaction were rejected
+		c.Rollback()
+		return err
+	}
 	if err := c.checkServerAbortedTransaction(); err != nil {
 		return err
 	}
@@ -355,7 +708,7 @@ func (c *Conn) sendCommitRequest() error {
 	if err := sendCommitXact(c.sess.buf, headers, "", 0, 0, "", reset); err != nil {
 		c.sess.LogF(c.transactionCtx, msdsn.LogErrors, "Failed to send CommitXact with %v", err)
 		c.connectionGood = false
-		return fmt.Errorf("faild to send CommitXact: %v", err)
+		return fmt.Errorf("faild to send CommitXact: %w", err)
 	}
 	return nil
 }
@@ -365,6 +718,8 @@ func (c *Conn) Rollback() error {
 		return driver.ErrBadConn
 	}
 	defer func() { c.inTransaction = false }()
+	// the rows of the coalesced inserts are rolled back with the rest
+	_ = c.flushInserts()
 	// Server already rolled back (e.g. XACT_ABORT); nothing to send.
 	if c.inTransaction && c.sess.tranid == 0 {
 		return nil
@@ -385,7 +740,7 @@ func (c *Conn) sendRollbackRequest() error {
 	if err := sendRollbackXact(c.sess.buf, headers, "", 0, 0, "", reset); err != nil {
 		c.sess.LogF(c.transactionCtx, msdsn.LogErrors, "Failed to send RollbackXact with %v", err)
 		c.connectionGood = false
-		return fmt.Errorf("failed to send RollbackXact: %v", err)
+		return fmt.Errorf("failed to send RollbackXact: %w", err)
 	}
 	return nil
 }
@@ -395,8 +750,8 @@ func (c *Conn) Begin() (driver.Tx, error) {
 }
 
 func (c *Conn) begin(ctx context.Context, tdsIsolation isoLevel) (tx driver.Tx, err error) {
-	if !c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err = c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
 	err = c.sendBeginRequest(ctx, tdsIsolation)
 	if err != nil {
@@ -420,7 +775,7 @@ func (c *Conn) sendBeginRequest(ctx context.Context, tdsIsolation isoLevel) erro
 	if err := sendBeginXact(c.sess.buf, headers, tdsIsolation, "", reset); err != nil {
 		c.sess.LogF(ctx, msdsn.LogErrors, "Failed to send BeginXact with %v", err)
 		c.connectionGood = false
-		return fmt.Errorf("failed to send BeginXact: %v", err)
+		return fmt.Errorf("failed to send BeginXact: %w", err)
 	}
 	return nil
 }
@@ -456,6 +811,7 @@ func failoverPartnerParams(params msdsn.Config) *msdsn.Config {
 	if params.FailOverPartnerSPN != "" {
 		params.ServerSPN = params.FailOverPartnerSPN
 	}
+	retargetTLS(&params)
 	return &params
 }
 
@@ -476,6 +832,9 @@ func (d *Driver) connect(ctx context.Context, c *Connector, params msdsn.Config)
 		}
 	}
 
+	if c != nil {
+		c.setPreloginInfo(sess.prelogin)
+	}
 	conn := &Conn{
 		connector:        c,
 		sess:             sess,
@@ -483,6 +842,12 @@ func (d *Driver) connect(ctx context.Context, c *Connector, params msdsn.Config)
 		processQueryText: d.processQueryText,
 		connectionGood:   true,
 	}
+	if params.DefaultSchema != "" {
+		if err = conn.checkDefaultSchema(ctx, params.DefaultSchema); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
 
 	return conn, nil
 }
@@ -493,11 +858,17 @@ func (c *Conn) Close() error {
 }
 
 type Stmt struct {
-	c              *Conn
-	query          string
+	c     *Conn
+	query string
+	// paramCount is the number of parameters of the query, checked against
+	// the arguments by checkParamCount, or -1 to not check them.
 	paramCount     int
 	notifSub       *queryNotifSub
 	skipEncryption bool
+	// unparameterizable are the uses of variables of the query that no
+	// argument may be passed for, nil until checkParameterizable scanned
+	// the query.
+	unparameterizable []UnparameterizableStatement
 }
 
 type queryNotifSub struct {
@@ -506,6 +877,12 @@ type queryNotifSub struct {
 	timeout uint32
 }
 
+// Prepare returns a statement running query. The query is not prepared on
+// the server: each execution sends its text with sp_executesql, or as a
+// batch when it has no arguments, and the server caches its plan and
+// compiles it again when needed, such as after a table it reads was
+// altered. There is no prepared handle to become stale, so executions
+// are not retried after errors such as 8180.
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 	if !c.connectionGood {
 		return nil, driver.ErrBadConn
@@ -517,18 +894,26 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 }
 
 func (c *Conn) prepareContext(ctx context.Context, query string) (*Stmt, error) {
-	paramCount := -1
+	var paramCount int
 	if c.processQueryText {
 		query, paramCount = querytext.ParseParams(query)
+	} else {
+		paramCount = ordinalParams(query)
 	}
-	return &Stmt{c, query, paramCount, nil, false}, nil
+	return &Stmt{c, query, paramCount, nil, false, nil}, nil
 }
 
 func (s *Stmt) Close() error {
 	return nil
 }
 
+// SetQueryNotification subscribes the queries run by s to a query
+// notification. See WithQueryNotification for a form usable from database/sql.
 func (s *Stmt) SetQueryNotification(id, options string, timeout time.Duration) {
+	s.notifSub = newQueryNotifSub(id, options, timeout)
+}
+
+func newQueryNotifSub(id, options string, timeout time.Duration) *queryNotifSub {
 	// 2.2.5.3.1 Query Notifications Header
 	// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/e168d373-a7b7-41aa-b6ca-25985466a7e0
 	// Timeout in milliseconds in TDS protocol.
@@ -536,14 +921,25 @@ func (s *Stmt) SetQueryNotification(id, options string, timeout time.Duration) {
 	if to < 1 {
 		to = 1
 	}
-	s.notifSub = &queryNotifSub{id, options, to}
+	return &queryNotifSub{id, options, to}
 }
 
+// NumInput returns the number of placeholders with query text processing,
+// which database/sql checks the arguments against, and -1 otherwise, as
+// the arguments are then checked when the query is sent and named
+// arguments are not counted.
 func (s *Stmt) NumInput() int {
-	return s.paramCount
+	if s.c.processQueryText {
+		return s.paramCount
+	}
+	return -1
 }
 
 func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
+	// The rows of coalesced inserts are sent before any other statement.
+	if err := s.c.flushInserts(); err != nil {
+		return err
+	}
 	// Fail fast if XACT_ABORT rolled back the transaction. The mssql.Error
 	// type avoids triggering checkBadConn's retry/reconnect path.
 	if err := s.c.checkServerAbortedTransaction(); err != nil {
@@ -554,22 +950,53 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 			data: transDescrHdr{s.c.sess.tranid, 1}.pack()},
 	}
 
-	if s.notifSub != nil {
+	notifSub := s.notifSub
+	if notifSub == nil {
+		notifSub = queryNotificationFromContext(ctx)
+	}
+	if notifSub != nil {
 		headers = append(headers,
 			headerStruct{
 				hdrtype: dataStmHdrQueryNotif,
 				data: queryNotifHdr{
-					s.notifSub.msgText,
-					s.notifSub.options,
-					s.notifSub.timeout,
+					notifSub.msgText,
+					notifSub.options,
+					notifSub.timeout,
 				}.pack(),
 			})
 	}
 
 	conn := s.c
+	batch := sqlBatchFromContext(ctx)
+	readUncommitted := readUncommittedFromContext(ctx)
+	isProc := isProc(s.query) && !batch
+	query := s.query
+	write := idempotentWriteFromContext(ctx)
+	if write != nil {
+		query = write.wrap(query)
+	}
+	if readUncommitted {
+		if batch || isProc {
+			return errors.New("mssql: WithReadUncommitted does not apply to stored procedure calls or queries run with WithSQLBatch")
+		}
+		query = readUncommittedSQL + query
+	}
+	if !isProc && conn.connector != nil {
+		query = conn.connector.QueryTag.apply(ctx, query)
+	}
 
-	// no need to check number of parameters here, it is checked by database/sql
-	conn.sess.LogS(ctx, msdsn.LogSQL, s.query)
+	if batch && len(args) > 0 {
+		return errors.New("mssql: queries run with WithSQLBatch take no arguments, as a SQL batch carries no parameters")
+	}
+	if err = s.checkParamCount(isProc, args); err != nil {
+		return err
+	}
+	if !isProc && len(args) > 0 {
+		if err = s.checkParameterizable(args); err != nil {
+			return err
+		}
+	}
+	conn.sess.LogS(ctx, msdsn.LogSQL, query)
 	if conn.sess.logFlags&logParams != 0 && len(args) > 0 {
 		for i := 0; i < len(args); i++ {
 			if len(args[i].Name) > 0 {
@@ -582,12 +1009,21 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 
 	reset := conn.resetSession
 	conn.resetSession = false
-	isProc := isProc(s.query)
-	if len(args) == 0 && !isProc {
-		if err = sendSqlBatch72(conn.sess.buf, s.query, headers, reset); err != nil {
+	if reset {
+		// the reset clears CONTEXT_INFO
+		conn.sessionContextInfo = nil
+	}
+	if contextInfo, ok := conn.contextInfo(ctx, isProc, batch); ok {
+		if err = conn.setContextInfo(ctx, headers[:1], contextInfo, reset); err != nil {
+			return err
+		}
+		reset = false
+	}
+	if len(args) == 0 && !isProc && !readUncommitted && write == nil {
+		if err = sendSqlBatch72(conn.sess.buf, query, headers, reset); err != nil {
 			conn.sess.LogF(ctx, msdsn.LogErrors, "Failed to send SqlBatch with %v", err)
 			conn.connectionGood = false
-			return fmt.Errorf("failed to send SQL Batch: %v", err)
+			return fmt.Errorf("failed to send SQL Batch: %w", err)
 		}
 	} else {
 		proc := sp_ExecuteSql
@@ -604,13 +1040,17 @@ func (s *Stmt) sendQuery(ctx context.Context, args []namedValue) (err error) {
 			if err != nil {
 				return
 			}
-			params[0] = makeStrParam(s.query)
+			if write != nil {
+				params = append(params, write.param())
+				decls = append(decls, idempotentWriteDecl)
+			}
+			params[0] = makeStrParam(query)
 			params[1] = makeStrParam(strings.Join(decls, ","))
 		}
 		if err = sendRpc(conn.sess.buf, headers, proc, 0, params, reset, conn.sess.encoding); err != nil {
 			conn.sess.LogF(ctx, msdsn.LogErrors, "Failed to send Rpc with %v", err)
 			conn.connectionGood = false
-			return fmt.Errorf("failed to send RPC: %v", err)
+			return fmt.Errorf("failed to send RPC: %w", err)
 		}
 	}
 	return
@@ -771,8 +1211,12 @@ func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *Stmt) queryContext(ctx context.Context, args []namedValue) (rows driver.Rows, err error) {
-	if !s.c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err = s.c.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	args, deferred, err := s.c.evalDeferredArgs(args)
+	if err != nil {
+		return nil, err
 	}
 	if s.doEncryption() && len(args) > 0 {
 		args, err = s.encryptArgs(ctx, args)
@@ -780,20 +1224,67 @@ func (s *Stmt) queryContext(ctx context.Context, args []namedValue) (rows driver
 	if err != nil {
 		return nil, err
 	}
-	if err = s.sendQuery(ctx, args); err != nil {
-		return nil, s.c.checkBadConn(ctx, err, true)
+	outs := s.c.outs
+	retryRead := s.c.mayRetryRead(s.query)
+	for replayed := false; ; replayed = true {
+		if err = s.sendQuery(ctx, args); err != nil {
+			// a retry by database/sql would compute deferred values again
+			err = s.c.checkBadConn(ctx, err, !deferred)
+			if !replayed && s.c.mayReplay(err, false) && s.c.ensureConnected(ctx) == nil {
+				s.c.outs = outs
+				continue
+			}
+			return nil, err
+		}
+		var reader *tokenProcessor
+		rows, reader, err = s.readQueryResponse(ctx)
+		if err != nil && !replayed && (s.c.mayReplay(err, true) || retryRead && s.c.mayRetryAfter(err)) {
+			// on a good connection the rest of the failed response is
+			// read before the query is sent again
+			if s.c.connectionGood && reader != nil && drainResponse(reader) != nil {
+				return nil, err
+			}
+			if s.c.ensureConnected(ctx) == nil {
+				s.c.outs = outs
+				continue
+			}
+		}
+		if r, ok := rows.(*Rows); ok && retryRead && !replayed {
+			r.retry = func() (*Rows, error) {
+				if err := s.c.ensureConnected(ctx); err != nil {
+					return nil, err
+				}
+				s.c.outs = outs
+				if err := s.sendQuery(ctx, args); err != nil {
+					return nil, s.c.checkBadConn(ctx, err, false)
+				}
+				rows, err := s.processQueryResponse(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return rows.(*Rows), nil
+			}
+		}
+		return rows, err
 	}
-	return s.processQueryResponse(ctx)
 }
 
 func (s *Stmt) processQueryResponse(ctx context.Context) (res driver.Rows, err error) {
+	res, _, err = s.readQueryResponse(ctx)
+	return res, err
+}
+
+// readQueryResponse is processQueryResponse, which also returns the reader
+// of the response.
+func (s *Stmt) readQueryResponse(ctx context.Context) (res driver.Rows, reader *tokenProcessor, err error) {
 	ctx, cancel := context.WithCancel(ctx)
-	reader := startReading(s.c.sess, ctx, s.c.outs)
+	cancel = s.c.interruptible(cancel)
+	reader = startReading(s.c.sess, ctx, s.c.outs)
 	s.c.clearOuts()
 	// For apps using a message queue, return right away and let Rowsq do all the work
 	if reader.outs.msgq != nil {
-		res = &Rowsq{stmt: s, reader: reader, cols: nil, cancel: cancel}
-		return res, nil
+		res = &Rowsq{stmt: s, reader: reader, cols: nil, cancel: cancel, nullsAsZero: s.c.nullsAsZero(ctx), budget: resultBudgetFromContext(ctx), textNormalization: s.c.textNormalization(ctx)}
+		return res, reader, nil
 	}
 	// process metadata
 	var cols []columnStruct
@@ -819,7 +1310,7 @@ loop:
 					if token.isError() {
 						// need to cleanup cancellable context
 						cancel()
-						return nil, s.c.checkBadConn(ctx, token.getError(), false)
+						return nil, reader, s.c.checkBadConn(ctx, token.getError(), false)
 					}
 				case ReturnStatus:
 					if reader.outs.returnStatus != nil {
@@ -830,10 +1321,10 @@ loop:
 		} else {
 			// need to cleanup cancellable context
 			cancel()
-			return nil, s.c.checkBadConn(ctx, err, false)
+			return nil, reader, s.c.checkBadConn(ctx, err, false)
 		}
 	}
-	res = &Rows{stmt: s, reader: reader, cols: cols, cancel: cancel}
+	res = &Rows{stmt: s, reader: reader, cols: cols, cancel: cancel, nullsAsZero: s.c.nullsAsZero(ctx), budget: resultBudgetFromContext(ctx), textNormalization: s.c.textNormalization(ctx)}
 	return
 }
 
@@ -844,8 +1335,15 @@ func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
 }
 
 func (s *Stmt) exec(ctx context.Context, args []namedValue) (res driver.Result, err error) {
-	if !s.c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err = s.c.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	args, deferred, err := s.c.evalDeferredArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if res, ok, err := s.coalesceInsert(ctx, args); ok {
+		return res, err
 	}
 	if s.doEncryption() && len(args) > 0 {
 		args, err = s.encryptArgs(ctx, args)
@@ -853,16 +1351,44 @@ func (s *Stmt) exec(ctx context.Context, args []namedValue) (res driver.Result,
 	if err != nil {
 		return nil, err
 	}
-	if err = s.sendQuery(ctx, args); err != nil {
-		return nil, s.c.checkBadConn(ctx, err, true)
-	}
-	if res, err = s.processExec(ctx); err != nil {
+	outs := s.c.outs
+	writeCtx, write, err := s.c.idempotentWrite(ctx, s.query)
+	if err != nil {
 		return nil, err
 	}
-	return
+	for replayed := false; ; replayed = true {
+		if err = s.sendQuery(writeCtx, args); err != nil {
+			// a retry by database/sql would compute deferred values again
+			err = s.c.checkBadConn(ctx, err, !deferred)
+			if !replayed && s.c.mayReplay(err, false) && s.c.ensureConnected(ctx) == nil {
+				s.c.outs = outs
+				continue
+			}
+			return nil, err
+		}
+		if res, err = s.processExec(ctx); err != nil {
+			// An idempotent write that may have run is looked up rather
+			// than sent again, even when the connection broke mid-response.
+			mayReplay := s.c.mayReplay(err, true) || write != nil && !s.c.connectionGood && s.c.mayReconnect()
+			if !replayed && mayReplay && s.c.ensureConnected(ctx) == nil {
+				if write != nil {
+					var committed bool
+					if res, committed, err = write.committed(ctx, s.c); err != nil || committed {
+						return res, err
+					}
+				}
+				s.c.outs = outs
+				continue
+			}
+			return nil, err
+		}
+		return
+	}
 }
 
 func (s *Stmt) processExec(ctx context.Context) (res driver.Result, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer s.c.interruptible(cancel)()
 	reader := startReading(s.c.sess, ctx, s.c.outs)
 	s.c.clearOuts()
 	err = reader.iterateResponse()
@@ -879,14 +1405,31 @@ type Rows struct {
 	reader   *tokenProcessor
 	nextCols []columnStruct
 	cancel   func()
+	// nullsAsZero is set by WithNullsAsZero or Connector.NullsAsZero
+	nullsAsZero bool
+	budget      resultBudget
+	// textNormalization is set by WithTextNormalization or
+	// Connector.TextNormalization
+	textNormalization TextNormalization
+	// retry sends the query again, until the first row is read, when
+	// Connector.RetryReads applies to it
+	retry func() (*Rows, error)
+	// row is the row read by NextRow
+	row []driver.Value
 }
 
 func (rc *Rows) Close() error {
-	// Cancel the context first to prevent blocking indefinitely if
-	// processSingleResponse is waiting on a network read. This is safe
-	// because nextToken's non-blocking first select still delivers any
-	// tokens already buffered in the channel before ctx.Done() fires.
-	rc.cancel()
+	defer rc.cancel()
+	// Output parameters and the return status follow the result sets, so
+	// when the query has them the rest of the response is read to receive
+	// them, only stopped by the context of the query.
+	if !rc.reader.outs.hasOutputs() {
+		// Cancel the context first to prevent blocking indefinitely if
+		// processSingleResponse is waiting on a network read. This is safe
+		// because nextToken's non-blocking first select still delivers any
+		// tokens already buffered in the channel before ctx.Done() fires.
+		rc.cancel()
+	}
 
 	var closeErr error
 	for {
@@ -903,9 +1446,14 @@ func (rc *Rows) Close() error {
 			// subsequent doneStruct (via the errs slice in
 			// processSingleResponse). A standalone doneInProcStruct
 			// error here would be a duplicate.
-			if done, ok := tok.(doneStruct); ok && done.isError() {
-				if closeErr == nil {
-					closeErr = rc.stmt.c.checkBadConn(rc.reader.ctx, done.getError(), false)
+			switch tok := tok.(type) {
+			case doneStruct:
+				if tok.isError() && closeErr == nil {
+					closeErr = rc.stmt.c.checkBadConn(rc.reader.ctx, tok.getError(), false)
+				}
+			case ReturnStatus:
+				if rc.reader.outs.returnStatus != nil {
+					*rc.reader.outs.returnStatus = tok
 				}
 			}
 			continue
@@ -945,34 +1493,64 @@ func (rc *Rows) Next(dest []driver.Value) error {
 				// processQueryResponse may have delegated all the token reading to us
 				case []columnStruct:
 					rc.nextCols = tokdata
+					rc.retry = nil
 					return io.EOF
 				case []interface{}:
+					rc.retry = nil
+					if err := checkTruncated(rc.cols, tokdata); err != nil {
+						return err
+					}
+					if rc.nullsAsZero && nullsZeroedByNext {
+						zeroNulls(rc.cols, tokdata)
+					}
+					normalizeText(rc.textNormalization, tokdata)
+					if err := rc.budget.add(tokdata); err != nil {
+						// interrupting the query discards the rest of the results
+						rc.cancel()
+						return err
+					}
 					for i := range dest {
 						dest[i] = tokdata[i]
 					}
 					return nil
 				case doneStruct:
 					if tokdata.isError() {
-						return rc.stmt.c.checkBadConn(rc.reader.ctx, tokdata.getError(), false)
+						return rc.retryRead(dest, rc.stmt.c.checkBadConn(rc.reader.ctx, tokdata.getError(), false))
 					}
 				case ReturnStatus:
 					if rc.reader.outs.returnStatus != nil {
 						*rc.reader.outs.returnStatus = tokdata
 					}
+				case BaseColumns:
+					*rc.reader.outs.baseColumns = tokdata
+				case XMLSchemaCollections:
+					*rc.reader.outs.xmlSchemas = tokdata
 				}
 			}
 
 		} else {
-			return rc.stmt.c.checkBadConn(rc.reader.ctx, err, false)
+			return rc.retryRead(dest, rc.stmt.c.checkBadConn(rc.reader.ctx, err, false))
 		}
 	}
 }
 
+// HasNextResultSet reports whether another result set follows the one
+// whose rows were all read by Next. Output parameters and the return
+// status, which follow the last result set, are not one.
 func (rc *Rows) HasNextResultSet() bool {
 	return rc.nextCols != nil
 }
 
+// NextResultSet moves to the next result set, discarding the rows of the
+// current one that were not read.
 func (rc *Rows) NextResultSet() error {
+	for rc.nextCols == nil {
+		if err := rc.Next(nil); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
 	rc.cols = rc.nextCols
 	rc.nextCols = nil
 	if rc.cols == nil {
@@ -1034,6 +1612,8 @@ func (r *Rows) ColumnTypeNullable(index int) (nullable, ok bool) {
 	return
 }
 
+// makeStrParam returns an nvarchar parameter. Strings over 4000 UTF-16 code
+// units (8000 bytes) are declared as nvarchar(max) and sent as PLP.
 func makeStrParam(val string) (res param) {
 	res.ti.TypeId = typeNVarChar
 	res.buffer = str2ucs2(val)
@@ -1185,6 +1765,8 @@ func (s *Stmt) makeParam(val driver.Value) (res param, err error) {
 		res.buffer = []byte{}
 
 	case []byte:
+		// Values over 8000 bytes are declared as varbinary(max) and sent
+		// as PLP by writeVarLen.
 		res.ti.TypeId = typeBigVarBin
 		res.ti.Size = len(val)
 		res.buffer = val
@@ -1209,6 +1791,10 @@ func (s *Stmt) makeParam(val driver.Value) (res param, err error) {
 		res.buffer = []byte{}
 
 	case time.Time:
+		// sent as datetimeoffset, converted as set by the zoneless time and
+		// timezone parameters, so a column without a time zone stores its
+		// wall clock
+		val = zoneless(val, getZonelessLocation(s.c))
 		if s.c.sess.loginAck.TDSVersion >= verTDS73 {
 			res.ti.TypeId = typeDateTimeOffsetN
 			res.ti.Scale = 7
@@ -1265,10 +1851,10 @@ var _ driver.Pinger = &Conn{}
 
 // Ping is used to check if the remote server is available and satisfies the Pinger interface.
 func (c *Conn) Ping(ctx context.Context) error {
-	if !c.connectionGood {
-		return driver.ErrBadConn
+	if err := c.ensureConnected(ctx); err != nil {
+		return err
 	}
-	stmt := &Stmt{c, `select 1;`, 0, nil, true}
+	stmt := &Stmt{c, `select 1;`, 0, nil, true, nil}
 	_, err := stmt.ExecContext(ctx, nil)
 	return err
 }
@@ -1300,8 +1886,8 @@ func convertIsolationLevel(level sql.IsolationLevel) (isoLevel, error) {
 
 // BeginTx satisfies ConnBeginTx.
 func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if !c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err := c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
 	if opts.ReadOnly {
 		return nil, errors.New("read-only transactions are not supported")
@@ -1315,8 +1901,8 @@ func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 }
 
 func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	if !c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err := c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
 	if len(query) > 10 && strings.EqualFold(query[:10], "INSERTBULK") {
 		return c.prepareCopyIn(ctx, query)
@@ -1328,26 +1914,34 @@ func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
 	defer s.c.clearOuts()
 
-	if !s.c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err := s.c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
 	list := make([]namedValue, len(args))
 	for i, nv := range args {
 		list[i] = namedValueFromDriverNamedValue(nv)
 	}
+	list, err := s.c.expandMapArgs(list)
+	if err != nil {
+		return nil, err
+	}
 	return s.queryContext(ctx, list)
 }
 
 func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
 	defer s.c.clearOuts()
 
-	if !s.c.connectionGood {
-		return nil, driver.ErrBadConn
+	if err := s.c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
 	list := make([]namedValue, len(args))
 	for i, nv := range args {
 		list[i] = namedValueFromDriverNamedValue(nv)
 	}
+	list, err := s.c.expandMapArgs(list)
+	if err != nil {
+		return nil, err
+	}
 	return s.exec(ctx, list)
 }
 
@@ -1364,6 +1958,11 @@ type Rowsq struct {
 	cancel      func()
 	requestDone bool
 	inResultSet bool
+	nullsAsZero bool
+	budget      resultBudget
+	// textNormalization is set by WithTextNormalization or
+	// Connector.TextNormalization
+	textNormalization TextNormalization
 }
 
 func (rc *Rowsq) Close() error {
@@ -1435,6 +2034,18 @@ func (rc *Rowsq) Next(dest []driver.Value) error {
 				}
 				switch tokdata := tok.(type) {
 				case []interface{}:
+					if err := checkTruncated(rc.cols, tokdata); err != nil {
+						return err
+					}
+					if rc.nullsAsZero {
+						zeroNulls(rc.cols, tokdata)
+					}
+					normalizeText(rc.textNormalization, tokdata)
+					if err := rc.budget.add(tokdata); err != nil {
+						// interrupting the query discards the rest of the results
+						rc.cancel()
+						return err
+					}
 					for i := range dest {
 						dest[i] = tokdata[i]
 					}
@@ -1461,6 +2072,10 @@ func (rc *Rowsq) Next(dest []driver.Value) error {
 					if rc.reader.outs.returnStatus != nil {
 						*rc.reader.outs.returnStatus = tokdata
 					}
+				case BaseColumns:
+					*rc.reader.outs.baseColumns = tokdata
+				case XMLSchemaCollections:
+					*rc.reader.outs.xmlSchemas = tokdata
 				case ServerError:
 					rc.requestDone = true
 					return tokdata
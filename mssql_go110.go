@@ -18,11 +18,15 @@ func (c *Conn) ResetSession(ctx context.Context) error {
 	}
 	c.resetSession = true
 
-	if c.connector == nil || len(c.connector.SessionInitSQL) == 0 {
+	if c.connector == nil {
+		return nil
+	}
+	initSQL := c.connector.sessionInitSQL()
+	if len(initSQL) == 0 {
 		return nil
 	}
 
-	s, err := c.prepareContext(ctx, c.connector.SessionInitSQL)
+	s, err := c.prepareContext(ctx, initSQL)
 	if err != nil {
 		return driver.ErrBadConn
 	}
@@ -48,6 +52,19 @@ func (c *Connector) Driver() driver.Driver {
 	return c.driver
 }
 
+// ErrorLastInsertIdNotSupported is returned by Result.LastInsertId.
+var ErrorLastInsertIdNotSupported = errors.New("LastInsertId is not supported. Please use the OUTPUT clause or add `select ID = convert(bigint, SCOPE_IDENTITY())` to the end of your query")
+
+// LastInsertId always returns -1 and ErrorLastInsertIdNotSupported.
+//
+// The identity of an inserted row is only available from the batch or
+// procedure that inserted it: SCOPE_IDENTITY() queried in a later request
+// belongs to another scope, and @@IDENTITY can return an identity generated
+// by a trigger. Read generated values in the same request instead, either
+// with an OUTPUT INSERTED.<column> clause or by appending
+// select ID = convert(bigint, SCOPE_IDENTITY()) to the query, and read them
+// with Query or QueryRow. Keys generated by NEWID() or NEWSEQUENTIALID()
+// defaults are only available through OUTPUT, scanned into UniqueIdentifier.
 func (r *Result) LastInsertId() (int64, error) {
-	return -1, errors.New("LastInsertId is not supported. Please use the OUTPUT clause or add `select ID = convert(bigint, SCOPE_IDENTITY())` to the end of your query")
+	return -1, ErrorLastInsertIdNotSupported
 }
@@ -168,3 +168,98 @@ func TestConnector_MultipleConnections_Integration(t *testing.T) {
 
 	assert.True(t, mssqlConn1.connectionGood && mssqlConn2.connectionGood, "Both connections should be marked as good")
 }
+
+func TestResult_LastInsertId_Integration(t *testing.T) {
+	db := requireTestDB(t)
+	conn, err := db.Conn(testContext(t))
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	defer conn.Close()
+	ctx := testContext(t)
+
+	_, err = conn.ExecContext(ctx, "create table #withid (id int identity(7, 1), v int); create table #noid (v int)")
+	if err != nil {
+		t.Fatalf("create tables failed: %v", err)
+	}
+	defer conn.ExecContext(ctx, "drop table #withid; drop table #noid")
+
+	for _, table := range []string{"#withid", "#noid"} {
+		res, err := conn.ExecContext(ctx, "insert into "+table+" (v) values (@p1)", 1)
+		if err != nil {
+			t.Fatalf("insert into %s failed: %v", table, err)
+		}
+		id, err := res.LastInsertId()
+		assert.ErrorIs(t, err, ErrorLastInsertIdNotSupported, table)
+		assert.Equal(t, int64(-1), id, table)
+	}
+
+	// The documented alternatives, read in the same request as the insert.
+	var id sql.NullInt64
+	err = conn.QueryRowContext(ctx, "insert into #withid (v) values (@p1); select ID = convert(bigint, SCOPE_IDENTITY())", 2).Scan(&id)
+	if err != nil {
+		t.Fatalf("SCOPE_IDENTITY failed: %v", err)
+	}
+	assert.Equal(t, sql.NullInt64{Int64: 8, Valid: true}, id)
+
+	err = conn.QueryRowContext(ctx, "insert into #withid (v) output inserted.id values (@p1)", 3).Scan(&id)
+	if err != nil {
+		t.Fatalf("OUTPUT INSERTED failed: %v", err)
+	}
+	assert.Equal(t, sql.NullInt64{Int64: 9, Valid: true}, id)
+
+	err = conn.QueryRowContext(ctx, "insert into #noid (v) values (@p1); select ID = convert(bigint, SCOPE_IDENTITY())", 4).Scan(&id)
+	if err != nil {
+		t.Fatalf("SCOPE_IDENTITY failed: %v", err)
+	}
+	assert.False(t, id.Valid, "SCOPE_IDENTITY is NULL for a table without an identity column")
+}
+
+func TestOutputInsertedSequentialGUID(t *testing.T) {
+	db := requireTestDB(t)
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #docs (id uniqueidentifier not null default newsequentialid() primary key, title nvarchar(20))")
+	if err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	defer conn.ExecContext(ctx, "drop table #docs")
+
+	var ids []UniqueIdentifier
+	for _, title := range []string{"first", "second"} {
+		var id UniqueIdentifier
+		err = conn.QueryRowContext(ctx, "insert into #docs (title) output inserted.id values (@p1)", title).Scan(&id)
+		if err != nil {
+			t.Fatalf("OUTPUT INSERTED failed: %v", err)
+		}
+		ids = append(ids, id)
+
+		// the scanned bytes are in the order SQL Server displays
+		var text string
+		err = conn.QueryRowContext(ctx, "select convert(char(36), id) from #docs where title = @p1", title).Scan(&text)
+		if err != nil {
+			t.Fatalf("select failed: %v", err)
+		}
+		assert.Equal(t, text, id.String(), title)
+
+		// and the value sent back as a parameter finds the row
+		var found string
+		err = conn.QueryRowContext(ctx, "select title from #docs where id = @p1", id).Scan(&found)
+		if err != nil {
+			t.Fatalf("select by id failed: %v", err)
+		}
+		assert.Equal(t, title, found)
+	}
+
+	var ordered bool
+	err = conn.QueryRowContext(ctx, "select case when @p1 < @p2 then 1 else 0 end", ids[0], ids[1]).Scan(&ordered)
+	if err != nil {
+		t.Fatalf("compare failed: %v", err)
+	}
+	assert.True(t, ordered, "NEWSEQUENTIALID values increase in SQL Server order: %v, %v", ids[0], ids[1])
+}
@@ -16,6 +16,7 @@ func TestResult_LastInsertId(t *testing.T) {
 	id, err := r.LastInsertId()
 	
 	assert.Error(t, err, "LastInsertId() should return an error")
+	assert.ErrorIs(t, err, ErrorLastInsertIdNotSupported)
 	assert.Equal(t, int64(-1), id, "LastInsertId() should return -1")
 	
 	expectedMsg := "LastInsertId is not supported"
@@ -6,10 +6,15 @@ package mssql
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math/big"
+	"net"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-sql/sqlexp"
@@ -73,6 +78,11 @@ func convertInputParameter(val interface{}) (interface{}, error) {
 		return val, nil
 	case civil.Time:
 		return val, nil
+	case Duration:
+		if err := checkTimeOfDay(time.Duration(v)); err != nil {
+			return nil, err
+		}
+		return val, nil
 	case NullDate:
 		if v.Valid {
 			return v.Date, nil
@@ -92,20 +102,168 @@ func convertInputParameter(val interface{}) (interface{}, error) {
 	// 	return nil
 	case float32:
 		return val, nil
+	case []float32:
+		return Vector{Data: v}, nil
+	case *big.Int:
+		if v == nil {
+			return nil, nil
+		}
+		if v.IsInt64() {
+			return v.Int64(), nil
+		}
+		// Integers beyond int64 are sent as decimal text, which the server
+		// converts to the DECIMAL or NUMERIC type of the target.
+		return decimal.NewFromBigInt(v, 0), nil
+	case net.IP:
+		if v == nil {
+			return nil, nil
+		}
+		return v.String(), nil
 	case driver.Valuer:
 		return val, nil
+	case time.Time:
+		// time.Time is a driver.Value, not to be sent as its marshaled text
+		return val, nil
+	case encoding.TextMarshaler, encoding.BinaryMarshaler:
+		// An enum of a primitive kind with a text form, such as a
+		// `type Color int` with MarshalText, is sent as its value.
+		if hasPrimitiveKind(val) {
+			if conv, ok := convertDefinedType(val); ok {
+				return conv, nil
+			}
+			return driver.DefaultParameterConverter.ConvertValue(v)
+		}
+		return convertMarshaler(val)
 	default:
+		if conv, ok := convertDefinedType(val); ok {
+			return conv, nil
+		}
 		return driver.DefaultParameterConverter.ConvertValue(v)
 	}
 }
 
+// convertMarshaler converts a value implementing encoding.TextMarshaler to
+// its text, sent as nvarchar, or one implementing only
+// encoding.BinaryMarshaler to its bytes, sent as varbinary. Values of a
+// primitive kind are not converted here but as their primitive. A type
+// implementing driver.Valuer is converted by its Value method instead, and
+// one implementing both marshalers by MarshalText. A nil pointer is NULL.
+func convertMarshaler(val interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		// A pointer to a value with the methods of its own, such as a
+		// *time.Time, is converted as the value.
+		if elem := rv.Elem().Interface(); isMarshaler(elem) {
+			return convertInputParameter(elem)
+		}
+	}
+	if m, ok := val.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("mssql: MarshalText of %T: %w", val, err)
+		}
+		return string(text), nil
+	}
+	b, err := val.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("mssql: MarshalBinary of %T: %w", val, err)
+	}
+	if b == nil {
+		// an empty value, not NULL
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// hasPrimitiveKind reports whether val, or the value it points to, is of
+// a bool, integer, float or string kind.
+func hasPrimitiveKind(val interface{}) bool {
+	t := reflect.TypeOf(val)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isMarshaler(val interface{}) bool {
+	switch val.(type) {
+	case encoding.TextMarshaler, encoding.BinaryMarshaler, driver.Valuer:
+		return true
+	}
+	return false
+}
+
+// convertDefinedType converts a value of a defined type such as
+// `type Status int16` to its underlying primitive type, so it is sent with
+// the same SQL type as the primitive would be. Byte arrays such as a
+// [32]byte hash are sent as varbinary, like a []byte. Types implementing
+// driver.Valuer, encoding.TextMarshaler or encoding.BinaryMarshaler never
+// get here.
+func convertDefinedType(val interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int:
+		return int(rv.Int()), true
+	case reflect.Int8:
+		return int8(rv.Int()), true
+	case reflect.Int16:
+		return int16(rv.Int()), true
+	case reflect.Int32:
+		return int32(rv.Int()), true
+	case reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint8:
+		return byte(rv.Uint()), true
+	case reflect.Float32:
+		return float32(rv.Float()), true
+	case reflect.Float64:
+		return rv.Float(), true
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		b := make([]byte, rv.Len())
+		for i := range b {
+			b[i] = byte(rv.Index(i).Uint())
+		}
+		return b, true
+	}
+	return nil, false
+}
+
+// paramDescription names a parameter in error messages.
+func paramDescription(nv *driver.NamedValue) string {
+	if nv.Name != "" {
+		return "@" + nv.Name
+	}
+	return fmt.Sprintf("%d", nv.Ordinal)
+}
+
 func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 	switch v := nv.Value.(type) {
 	case sql.Out:
 		if c.outs.params == nil {
 			c.outs.params = make(map[string]interface{})
 		}
-		c.outs.params[nv.Name] = v.Dest
+		name := nv.Name
+		if name == "" {
+			// unnamed parameters of queries are sent as @p1, @p2...
+			name = fmt.Sprintf("p%d", nv.Ordinal)
+		}
+		c.outs.params[name] = v.Dest
 
 		if v.Dest == nil {
 			return errors.New("destination is a nil pointer")
@@ -135,7 +293,7 @@ func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 		}
 		conv, err := convertInputParameter(val)
 		if err != nil {
-			return err
+			return fmt.Errorf("mssql: OUTPUT parameter %s: %w", paramDescription(nv), err)
 		}
 		if conv == nil {
 			// if we replace with nil we would lose type information
@@ -148,17 +306,142 @@ func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 		*v = 0 // By default the return value should be zero.
 		c.outs.returnStatus = v
 		return driver.ErrRemoveArgument
+	case *BaseColumns:
+		*v = nil
+		c.outs.baseColumns = v
+		return driver.ErrRemoveArgument
+	case *XMLSchemaCollections:
+		*v = nil
+		c.outs.xmlSchemas = v
+		return driver.ErrRemoveArgument
+	case StringsAsBytes:
+		c.outs.stringsAsBytes = true
+		return driver.ErrRemoveArgument
 	case TVP:
 		return nil
+	case DeferredValue:
+		// called by evalDeferredArgs when the query is sent
+		return nil
+	case map[string]interface{}:
+		// expanded into named parameters by expandMapArgs
+		return nil
 	case *sqlexp.ReturnMessage:
 		sqlexp.ReturnMessageInit(v)
 		c.outs.msgq = v
 		return driver.ErrRemoveArgument
 	default:
-		var err error
-		nv.Value, err = convertInputParameter(nv.Value)
-		return err
+		conv, err := convertInputParameter(nv.Value)
+		if err != nil {
+			return fmt.Errorf("mssql: parameter %s: %w", paramDescription(nv), err)
+		}
+		if str, ok := conv.(string); ok && str == "" && c.connector != nil && c.connector.EmptyStringsAsNull {
+			// A NULL typed as nvarchar, like the empty string it replaces.
+			conv = sql.NullString{}
+		}
+		nv.Value = conv
+		return nil
+	}
+}
+
+// DeferredValue is an argument whose value is computed by calling it when
+// the query is sent, rather than when the arguments are built, such as
+// data generated for the parameter of a query run in a pipeline. The value
+// it returns is checked and converted as other arguments are.
+//
+// It is called once per query, on the goroutine running the query, while
+// the connection is held: it must not run queries on the same *sql.Conn or
+// *sql.Tx, which would wait for it forever. A query with a DeferredValue is
+// not retried on a new connection by database/sql, as that would call it
+// again, but may still be replayed by the driver with the value computed.
+// The value is held until the request is built, so it is not streamed: a
+// large value is in memory once, as other arguments are.
+type DeferredValue = func() (driver.Value, error)
+
+// evalDeferredArgs replaces each DeferredValue argument by the value it
+// returns, and reports whether there was one.
+func (c *Conn) evalDeferredArgs(args []namedValue) ([]namedValue, bool, error) {
+	deferred := false
+	for i, arg := range args {
+		fn, ok := arg.Value.(DeferredValue)
+		if !ok {
+			continue
+		}
+		if !deferred {
+			// the arguments of the caller are left unchanged
+			args = append([]namedValue(nil), args...)
+			deferred = true
+		}
+		nv := driver.NamedValue{Name: arg.Name, Ordinal: arg.Ordinal}
+		v, err := fn()
+		if err != nil {
+			return nil, true, fmt.Errorf("mssql: parameter %s: %w", paramDescription(&nv), err)
+		}
+		nv.Value = v
+		switch v.(type) {
+		case DeferredValue, map[string]interface{}, sql.Out:
+			return nil, true, fmt.Errorf("mssql: parameter %s: a deferred value cannot return a %T", paramDescription(&nv), v)
+		}
+		if err := c.CheckNamedValue(&nv); err != nil {
+			if err == driver.ErrRemoveArgument {
+				err = fmt.Errorf("mssql: parameter %s: a deferred value cannot return a %T", paramDescription(&nv), v)
+			}
+			return nil, true, err
+		}
+		args[i].Value = nv.Value
 	}
+	return args, deferred, nil
+}
+
+// expandMapArgs replaces each map[string]interface{} argument by a named
+// argument for each of its keys, which may start with @, in the order of
+// the sorted keys. The values are checked as other arguments are.
+func (c *Conn) expandMapArgs(args []namedValue) ([]namedValue, error) {
+	var res []namedValue
+	for i, arg := range args {
+		m, ok := arg.Value.(map[string]interface{})
+		if !ok {
+			if res != nil {
+				res = append(res, arg)
+			}
+			continue
+		}
+		if res == nil {
+			res = append(make([]namedValue, 0, len(args)+len(m)), args[:i]...)
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			nv := driver.NamedValue{Name: strings.TrimPrefix(k, "@"), Ordinal: arg.Ordinal, Value: m[k]}
+			if _, nested := nv.Value.(map[string]interface{}); nested {
+				return nil, fmt.Errorf("mssql: parameter %s: a map cannot be nested", paramDescription(&nv))
+			}
+			err := c.CheckNamedValue(&nv)
+			if err == driver.ErrRemoveArgument {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, namedValueFromDriverNamedValue(nv))
+		}
+	}
+	if res == nil {
+		return args, nil
+	}
+	seen := make(map[string]bool, len(res))
+	for _, arg := range res {
+		if arg.Name == "" {
+			continue
+		}
+		if seen[strings.ToLower(arg.Name)] {
+			return nil, fmt.Errorf("mssql: parameter @%s is passed more than once", arg.Name)
+		}
+		seen[strings.ToLower(arg.Name)] = true
+	}
+	return res, nil
 }
 
 func makeMoneyParam(val decimal.Decimal) (res param) {
@@ -194,8 +477,12 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.TypeId = typeNChar
 		res.buffer = str2ucs2(string(val))
 		res.ti.Size = len(res.buffer)
+		if res.ti.Size > 8000 {
+			// nchar is limited to 4000 characters and has no max form.
+			res.ti.TypeId = typeNVarChar
+		}
 	case DateTime1:
-		t := time.Time(val)
+		t := zoneless(time.Time(val), getZonelessLocation(s.c))
 		res.ti.TypeId = typeDateTimeN
 		res.buffer = encodeDateTime(t)
 		res.ti.Size = len(res.buffer)
@@ -218,6 +505,12 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.Scale = 7
 		res.buffer = encodeTime(val.Hour, val.Minute, val.Second, val.Nanosecond, int(res.ti.Scale))
 		res.ti.Size = len(res.buffer)
+	case Duration:
+		// durations are a time of day, checked by convertInputParameter
+		res.ti.TypeId = typeTimeN
+		res.ti.Scale = 7
+		res.buffer = encodeDuration(time.Duration(val), int(res.ti.Scale))
+		res.ti.Size = len(res.buffer)
 	case sql.Out:
 		switch dest := val.Dest.(type) {
 		case Money[decimal.Decimal]:
@@ -246,6 +539,22 @@ func (s *Stmt) makeParamExtra(val driver.Value) (res param, err error) {
 		res.ti.UdtInfo.TypeName = name
 		res.ti.UdtInfo.SchemaName = schema
 		res.ti.TypeId = typeTvp
+		if rows, ok := val.Value.([]map[string]interface{}); ok {
+			res.buffer, err = val.encodeMaps(schema, name, rows, s.c.sess.encoding)
+			if err != nil {
+				return
+			}
+			res.ti.Size = len(res.buffer)
+			break
+		}
+		if vectors, ok := val.Value.([]Vector); ok {
+			res.buffer, err = val.encodeVectors(schema, name, vectors, s.c.sess.encoding)
+			if err != nil {
+				return
+			}
+			res.ti.Size = len(res.buffer)
+			break
+		}
 		columnStr, tvpFieldIndexes, errCalTypes := val.columnTypes()
 		if errCalTypes != nil {
 			err = errCalTypes
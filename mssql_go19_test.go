@@ -0,0 +1,575 @@
+//go:build go1.9
+// +build go1.9
+
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/golang-sql/civil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnum int
+
+type testSmallEnum int16
+
+type testStringEnum string
+
+func TestCheckNamedValueCustomTypes(t *testing.T) {
+	huge, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"float32 slice", []float32{1, 2.5}, Vector{Data: []float32{1, 2.5}}},
+		{"nil float32 slice", []float32(nil), Vector{}},
+		{"big.Int in int64 range", big.NewInt(-42), int64(-42)},
+		{"big.Int beyond int64", huge, decimal.NewFromBigInt(huge, 0)},
+		{"nil big.Int", (*big.Int)(nil), nil},
+		{"IPv4", net.ParseIP("192.0.2.1"), "192.0.2.1"},
+		{"IPv6", net.ParseIP("2001:db8::1"), "2001:db8::1"},
+		{"nil IP", net.IP(nil), nil},
+		{"enum", testEnum(3), int(3)},
+	}
+	c := &Conn{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nv := &driver.NamedValue{Name: "p", Ordinal: 1, Value: tt.value}
+			require.NoError(t, c.CheckNamedValue(nv))
+			assert.Equal(t, tt.expected, nv.Value)
+		})
+	}
+}
+
+func TestConvertInputParameterDefinedTypes(t *testing.T) {
+	type flag bool
+	type ratio float32
+	type code uint8
+	type digest [4]byte
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{testEnum(3), int(3)},
+		{testSmallEnum(-2), int16(-2)},
+		{testStringEnum("active"), "active"},
+		{flag(true), true},
+		{ratio(0.5), float32(0.5)},
+		{code(200), byte(200)},
+		{[4]byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+		{digest{5, 6, 7, 8}, []byte{5, 6, 7, 8}},
+		{[2]code{9, 10}, []byte{9, 10}},
+	}
+	for _, tt := range tests {
+		got, err := convertInputParameter(tt.value)
+		require.NoError(t, err, "%T", tt.value)
+		assert.Equal(t, tt.expected, got, "%T", tt.value)
+	}
+
+	// The underlying type decides the SQL type, as it does for the primitive.
+	s := &Stmt{}
+	p, err := s.makeParam(mustConvert(t, testSmallEnum(7)))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeIntN), p.ti.TypeId)
+	assert.Equal(t, 2, p.ti.Size)
+	p, err = s.makeParam(mustConvert(t, testStringEnum("active")))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeNVarChar), p.ti.TypeId)
+}
+
+// testPoint is sent as the 4 bytes of MarshalBinary.
+type testPoint struct{ X, Y int16 }
+
+func (p testPoint) MarshalBinary() ([]byte, error) {
+	return []byte{byte(p.X >> 8), byte(p.X), byte(p.Y >> 8), byte(p.Y)}, nil
+}
+
+func (p *testPoint) UnmarshalBinary(b []byte) error {
+	if len(b) != 4 {
+		return fmt.Errorf("testPoint needs 4 bytes, got %d", len(b))
+	}
+	p.X, p.Y = int16(b[0])<<8|int16(b[1]), int16(b[2])<<8|int16(b[3])
+	return nil
+}
+
+// testLevel is sent as its name, with a pointer receiver.
+type testLevel struct{ n int }
+
+func (l *testLevel) MarshalText() ([]byte, error) {
+	if l.n < 0 {
+		return nil, errors.New("negative level")
+	}
+	return []byte(fmt.Sprintf("level-%d", l.n)), nil
+}
+
+// testColor is an int enum with a text form, sent as its number.
+type testColor int
+
+func (c testColor) MarshalText() ([]byte, error) {
+	return []byte([]string{"red", "green", "blue"}[c]), nil
+}
+
+// testValuerText prefers Value to MarshalText.
+type testValuerText string
+
+func (v testValuerText) Value() (driver.Value, error) { return "value", nil }
+func (v testValuerText) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+func TestConvertInputParameterMarshalers(t *testing.T) {
+	level := testLevel{2}
+	color := testColor(1)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var nilLevel *testLevel
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{netip.MustParseAddr("192.0.2.1"), "192.0.2.1"},
+		{testPoint{1, 258}, []byte{0, 1, 1, 2}},
+		{&level, "level-2"},
+		{nilLevel, nil},
+		{color, 1},
+		{&color, int64(1)},
+		{testValuerText("x"), testValuerText("x")},
+		{now, now},
+		{&now, now},
+		{civil.DateOf(now), civil.DateOf(now)},
+	}
+	for _, tt := range tests {
+		got, err := convertInputParameter(tt.value)
+		require.NoError(t, err, "%T", tt.value)
+		assert.Equal(t, tt.expected, got, "%T", tt.value)
+	}
+
+	negative := testLevel{-1}
+	_, err := convertInputParameter(&negative)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "negative level")
+
+	s := &Stmt{}
+	p, err := s.makeParam(mustConvert(t, &level))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeNVarChar), p.ti.TypeId)
+	p, err = s.makeParam(mustConvert(t, testPoint{}))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeBigVarBin), p.ti.TypeId)
+	p, err = s.makeParam(mustConvert(t, color))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeIntN), p.ti.TypeId, "an int enum is sent as bigint, not as its text")
+}
+
+func mustConvert(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	conv, err := convertInputParameter(v)
+	require.NoError(t, err)
+	return conv
+}
+
+func TestCheckNamedValueUnsupportedType(t *testing.T) {
+	c := &Conn{}
+	nv := &driver.NamedValue{Name: "p", Ordinal: 1, Value: struct{ A int }{1}}
+	err := c.CheckNamedValue(nv)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "@p")
+
+	nv = &driver.NamedValue{Ordinal: 2, Value: make(chan int)}
+	err = c.CheckNamedValue(nv)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parameter 2")
+}
+
+func TestCustomTypeParametersIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	var small int64
+	var large, ip string
+	err := conn.QueryRow("select @p1, cast(cast(@p2 as decimal(38, 0)) as varchar(40)), @p3",
+		big.NewInt(7), huge, net.ParseIP("192.0.2.1")).Scan(&small, &large, &ip)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), small)
+	assert.Equal(t, huge.String(), large)
+	assert.Equal(t, "192.0.2.1", ip)
+
+	var status int16
+	var state string
+	err = conn.QueryRow("select @p1, sql_variant_property(@p1, 'BaseType') + ':' + @p2",
+		testSmallEnum(5), testStringEnum("active")).Scan(&status, &state)
+	require.NoError(t, err)
+	assert.Equal(t, int16(5), status)
+	assert.Equal(t, "smallint:active", state)
+
+	_, err = conn.Exec("select @p1", struct{ A int }{1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported type")
+}
+
+func TestMarshalerParametersIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	level := testLevel{3}
+	var addr netip.Addr
+	var point testPoint
+	var levelText, types string
+	err := conn.QueryRow("select @p1, @p2, @p3, "+
+		"cast(sql_variant_property(@p1, 'BaseType') as varchar(20)) + ':' + cast(sql_variant_property(@p2, 'BaseType') as varchar(20))",
+		netip.MustParseAddr("2001:db8::1"), testPoint{-1, 7}, &level).
+		Scan(Unmarshal(&addr), Unmarshal(&point), &levelText, &types)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), addr)
+	assert.Equal(t, testPoint{-1, 7}, point)
+	assert.Equal(t, "level-3", levelText)
+	assert.Equal(t, "nvarchar:varbinary", types)
+
+	var colorType string
+	err = conn.QueryRow("select cast(sql_variant_property(@p1, 'BaseType') as varchar(20))", testColor(2)).Scan(&colorType)
+	require.NoError(t, err)
+	assert.Equal(t, "bigint", colorType, "an int enum with MarshalText is sent as its number")
+}
+
+func TestCheckNamedValueEmptyStringsAsNull(t *testing.T) {
+	check := func(c *Conn, v interface{}) interface{} {
+		nv := &driver.NamedValue{Ordinal: 1, Value: v}
+		require.NoError(t, c.CheckNamedValue(nv))
+		return nv.Value
+	}
+	off := &Conn{connector: &Connector{}}
+	assert.Equal(t, "", check(off, ""), "disabled by default")
+
+	on := &Conn{connector: &Connector{EmptyStringsAsNull: true}}
+	assert.Equal(t, sql.NullString{}, check(on, ""))
+	assert.Equal(t, sql.NullString{}, check(on, testStringEnum("")))
+	assert.Equal(t, " ", check(on, " "), "only empty strings become NULL")
+	assert.Equal(t, VarChar(""), check(on, VarChar("")), "driver string types are unchanged")
+
+	p, err := (&Stmt{}).makeParam(check(on, ""))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(typeNVarChar), p.ti.TypeId)
+	assert.Nil(t, p.buffer)
+}
+
+func TestEmptyStringsAsNullIntegration(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	query := "SELECT CASE WHEN @p1 IS NULL THEN 1 ELSE 0 END"
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	var isNull bool
+	require.NoError(t, db.QueryRow(query, "").Scan(&isNull))
+	assert.False(t, isNull, "empty strings are sent as is by default")
+
+	connector.EmptyStringsAsNull = true
+	db2 := sql.OpenDB(connector)
+	defer db2.Close()
+	require.NoError(t, db2.QueryRow(query, "").Scan(&isNull))
+	assert.True(t, isNull)
+	require.NoError(t, db2.QueryRow(query, "x").Scan(&isNull))
+	assert.False(t, isNull)
+}
+
+func TestCheckNamedValueUnnamedOut(t *testing.T) {
+	c := &Conn{sess: &tdsSession{}, connectionGood: true}
+	var a, b int64
+	require.NoError(t, c.CheckNamedValue(&driver.NamedValue{Ordinal: 2, Value: sql.Out{Dest: &a}}))
+	require.NoError(t, c.CheckNamedValue(&driver.NamedValue{Ordinal: 3, Value: sql.Out{Dest: &b}}))
+	assert.Equal(t, map[string]interface{}{"p2": &a, "p3": &b}, c.outs.params, "sent as @p2 and @p3")
+}
+
+// returnValueToken returns a RETURNVALUE token of the int output parameter
+// name.
+func returnValueToken(name string, value int32) []byte {
+	tok := []byte{byte(tokenReturnValue), 0, 0, byte(len(name))}
+	tok = append(tok, str2ucs2(name)...)
+	// status (output), user type, flags, intn(4) and the value
+	tok = append(tok, 1, 0, 0, 0, 0, 0x01, 0, typeIntN, 4, 4)
+	return binary.LittleEndian.AppendUint32(tok, uint32(value))
+}
+
+func TestReturnValueOfOutParam(t *testing.T) {
+	var unnamed, declared int64
+	outs := outputs{params: map[string]interface{}{"p2": &unnamed, "result": &declared}}
+	stream := append(returnValueToken("@p2", 42), returnValueToken("@Result", 7)...)
+	stream = append(stream, doneToken(doneFinal, 0)...)
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+		logger: optionalLogger{},
+	}
+	ch := make(chan tokenStruct, 5)
+	processSingleResponse(context.Background(), sess, ch, outs)
+	for tok := range ch {
+		if err, ok := tok.(error); ok {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, int64(42), unnamed)
+	assert.Equal(t, int64(7), declared, "the name as declared in @params differs in case")
+}
+
+func TestOutputsAfterRowsReadLazily(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	rows := textResultStream(1, 0, 3)
+	// the rows are followed by the outputs of the procedure
+	binary.LittleEndian.PutUint16(rows[len(rows)-12:], doneMore|doneCount)
+	outs := append(returnValueToken("@total", 42), byte(tokenReturnStatus), 7, 0, 0, 0)
+	outs = append(outs, doneToken(doneFinal, 0)...)
+
+	closing := make(chan struct{})
+	go func() {
+		first := makeReplyPacket(rows)
+		// more packets follow
+		first[1] = 0
+		server.Write(first)
+		<-closing
+		// a cancelled query is answered without its outputs
+		server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if _, err := server.Read(make([]byte, 8)); err == nil {
+			server.Write(makeReplyPacket(doneToken(doneAttn, 0)))
+			return
+		}
+		server.Write(makeReplyPacket(outs))
+	}()
+
+	var total int64
+	var status ReturnStatus
+	c := &Conn{
+		sess:           &tdsSession{buf: newTdsBuffer(defaultPacketSize, client), logger: optionalLogger{}},
+		connectionGood: true,
+		outs:           outputs{params: map[string]interface{}{"total": &total}, returnStatus: &status},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := (&Stmt{c: c}).processQueryResponse(ctx)
+	require.NoError(t, err)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, res.Next(dest))
+	assert.Equal(t, "n0-0", dest[0])
+
+	// closing the rows before reading them all still receives the outputs
+	close(closing)
+	require.NoError(t, res.Close())
+	assert.Equal(t, int64(42), total)
+	assert.Equal(t, ReturnStatus(7), status)
+}
+
+// resultSetsRows returns the rows of a response of sets result sets of
+// two rows each, followed by the @total output parameter.
+func resultSetsRows(t *testing.T, sets int, total *int64) driver.Rows {
+	var stream []byte
+	for i := 0; i < sets; i++ {
+		rows := textResultStream(1, 0, 2)
+		binary.LittleEndian.PutUint16(rows[len(rows)-12:], doneMore|doneCount)
+		stream = append(stream, rows...)
+	}
+	stream = append(stream, returnValueToken("@total", 42)...)
+	stream = append(stream, doneToken(doneFinal, 0)...)
+	c := &Conn{
+		sess: &tdsSession{
+			buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+			logger: optionalLogger{},
+		},
+		connectionGood: true,
+		outs:           outputs{params: map[string]interface{}{"total": total}},
+	}
+	res, err := (&Stmt{c: c}).processQueryResponse(context.Background())
+	require.NoError(t, err)
+	return res
+}
+
+func TestHasNextResultSetBeforeOutputParams(t *testing.T) {
+	var total int64
+	rows := resultSetsRows(t, 1, &total)
+	next := rows.(driver.RowsNextResultSet)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, "n0-1", dest[0])
+	assert.Equal(t, io.EOF, rows.Next(dest))
+	assert.False(t, next.HasNextResultSet(), "the output parameter is not a result set")
+	assert.Equal(t, io.EOF, next.NextResultSet())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(42), total)
+}
+
+func TestNextResultSetDiscardsUnreadRows(t *testing.T) {
+	var total int64
+	rows := resultSetsRows(t, 2, &total)
+	next := rows.(driver.RowsNextResultSet)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.NoError(t, next.NextResultSet(), "the second row of the first set is skipped")
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, "n0-0", dest[0], "the first row of the second set")
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, io.EOF, rows.Next(dest))
+	assert.False(t, next.HasNextResultSet())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(42), total)
+}
+
+func TestHasNextResultSetWithOutputParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	var total int64
+	rows, err := conn.QueryContext(testContext(t), "select 1 union all select 2; set @total = 42", sql.Named("total", sql.Out{Dest: &total}))
+	require.NoError(t, err)
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 2, n)
+	assert.False(t, rows.NextResultSet(), "only the output parameter follows")
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(42), total)
+}
+
+func TestExpandMapArgs(t *testing.T) {
+	c := &Conn{sess: &tdsSession{}, connectionGood: true}
+	var out int64
+	args := []namedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Value: map[string]interface{}{"name": "a", "@id": 7, "total": sql.Out{Dest: &out}, "status": new(ReturnStatus)}},
+		{Ordinal: 3, Name: "extra", Value: "x"},
+	}
+	got, err := c.expandMapArgs(args)
+	require.NoError(t, err)
+	assert.Equal(t, []namedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Name: "id", Value: 7},
+		{Ordinal: 2, Name: "name", Value: "a"},
+		{Ordinal: 2, Name: "total", Value: sql.Out{Dest: int64(0)}},
+		{Ordinal: 3, Name: "extra", Value: "x"},
+	}, got, "sorted by key, checked as other arguments")
+	assert.Contains(t, c.outs.params, "total")
+	assert.NotNil(t, c.outs.returnStatus)
+
+	unchanged := []namedValue{{Ordinal: 1, Value: "a"}}
+	got, err = c.expandMapArgs(unchanged)
+	require.NoError(t, err)
+	assert.Equal(t, unchanged, got)
+
+	_, err = c.expandMapArgs([]namedValue{{Ordinal: 1, Value: map[string]interface{}{"a": map[string]interface{}{}}}})
+	assert.EqualError(t, err, "mssql: parameter @a: a map cannot be nested")
+	_, err = c.expandMapArgs([]namedValue{
+		{Ordinal: 1, Value: map[string]interface{}{"A": 1}},
+		{Ordinal: 2, Name: "a", Value: 2},
+	})
+	assert.EqualError(t, err, "mssql: parameter @a is passed more than once")
+}
+
+func TestDeferredValueIsCalledOnceWhenSent(t *testing.T) {
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	connector.Reconnect = true
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	calls := 0
+	value := DeferredValue(func() (driver.Value, error) {
+		calls++
+		return "computed", nil
+	})
+	var n int
+	require.NoError(t, conn.QueryRowContext(ctx, "select @p1", value).Scan(&n))
+	assert.Equal(t, 1, calls)
+
+	// the query replayed on a new session sends the value computed
+	server.closeConns()
+	time.Sleep(50 * time.Millisecond)
+	calls = 0
+	require.NoError(t, conn.QueryRowContext(ctx, "select @p1", value).Scan(&n))
+	assert.Equal(t, 2, n, "answered on the second connection")
+	assert.Equal(t, 1, calls)
+
+	_, err = conn.ExecContext(ctx, "select @p1", DeferredValue(func() (driver.Value, error) {
+		return nil, errors.New("no data")
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parameter 1: no data")
+}
+
+func TestDeferredValueIsNotRetried(t *testing.T) {
+	server := newRowServer(t)
+	db, err := sql.Open("sqlserver", server.connString())
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxIdleConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, db.PingContext(ctx))
+
+	server.closeConns()
+	time.Sleep(50 * time.Millisecond)
+	calls := 0
+	_, err = db.ExecContext(ctx, "select @p1", DeferredValue(func() (driver.Value, error) {
+		calls++
+		return int64(1), nil
+	}))
+	assert.Error(t, err, "no retry on a new connection")
+	assert.Equal(t, 1, calls)
+}
+
+func TestEvalDeferredArgs(t *testing.T) {
+	c := &Conn{connector: &Connector{EmptyStringsAsNull: true}}
+	args := []namedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Name: "s", Ordinal: 2, Value: DeferredValue(func() (driver.Value, error) { return "", nil })},
+		{Ordinal: 3, Value: DeferredValue(func() (driver.Value, error) { return testSmallEnum(4), nil })},
+	}
+	got, deferred, err := c.evalDeferredArgs(args)
+	require.NoError(t, err)
+	assert.True(t, deferred)
+	assert.Equal(t, int64(1), got[0].Value)
+	assert.Equal(t, sql.NullString{}, got[1].Value, "checked as other arguments are")
+	assert.Equal(t, int16(4), got[2].Value)
+	assert.IsType(t, DeferredValue(nil), args[1].Value, "the arguments passed are unchanged")
+
+	_, deferred, err = c.evalDeferredArgs(args[:1])
+	require.NoError(t, err)
+	assert.False(t, deferred)
+
+	for _, v := range []driver.Value{
+		DeferredValue(func() (driver.Value, error) { return "", nil }),
+		sql.Out{Dest: new(int)},
+		new(ReturnStatus),
+		struct{ A int }{1},
+	} {
+		v := v
+		_, _, err = c.evalDeferredArgs([]namedValue{{Ordinal: 1, Value: DeferredValue(func() (driver.Value, error) { return v, nil })}})
+		assert.Error(t, err, "%T", v)
+	}
+}
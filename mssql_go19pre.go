@@ -19,3 +19,11 @@ func scanIntoOut(name string, fromServer, scanInto interface{}) error {
 func isOutputValue(val driver.Value) bool {
 	return false
 }
+
+func (c *Conn) expandMapArgs(args []namedValue) ([]namedValue, error) {
+	return args, nil
+}
+
+func (c *Conn) evalDeferredArgs(args []namedValue) ([]namedValue, bool, error) {
+	return args, false, nil
+}
@@ -1,14 +1,20 @@
 package mssql
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 )
@@ -188,6 +194,29 @@ func TestFailoverPartnerParams(t *testing.T) {
 	}
 }
 
+func TestFailoverPartnerTLSServerName(t *testing.T) {
+	params, err := msdsn.Parse("server=primary;failoverpartner=mirror;encrypt=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := failoverPartnerParams(params)
+	if result.TLSConfig.ServerName != "mirror" {
+		t.Errorf("ServerName = %q, want the failover partner", result.TLSConfig.ServerName)
+	}
+	if params.TLSConfig.ServerName != "primary" {
+		t.Errorf("ServerName of the primary = %q, want primary", params.TLSConfig.ServerName)
+	}
+
+	params, err = msdsn.Parse("server=primary;failoverpartner=mirror;encrypt=true;hostnameincertificate=aglistener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = failoverPartnerParams(params)
+	if result.TLSConfig.ServerName != "aglistener" {
+		t.Errorf("ServerName = %q, want the host name in the certificate", result.TLSConfig.ServerName)
+	}
+}
+
 // equalErrors is a helper function that compares two errors
 // by comparing their nilness, underlying type, and Error messages
 func equalErrors(e1 error, e2 error) bool {
@@ -346,3 +375,250 @@ func TestBadConnRejection(t *testing.T) {
 	}
 
 }
+
+func TestConnectorConfigurePool(t *testing.T) {
+	c, err := NewConnector("server=localhost;Max Pool Size=7;Min Pool Size=3;Connection Lifetime=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(c)
+	defer db.Close()
+	c.ConfigurePool(db)
+	if n := db.Stats().MaxOpenConnections; n != 7 {
+		t.Errorf("expected 7 max open connections, got %d", n)
+	}
+
+	c, err = NewConnector("server=localhost;Pooling=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sql.OpenDB(c)
+	defer db.Close()
+	c.ConfigurePool(db)
+	if n := db.Stats().MaxOpenConnections; n != 0 {
+		t.Errorf("expected unlimited open connections, got %d", n)
+	}
+}
+
+func TestCreateDialerKeepAlive(t *testing.T) {
+	tests := []struct {
+		name      string
+		keepAlive time.Duration
+		expected  time.Duration
+	}{
+		{"explicit interval", 10 * time.Second, 10 * time.Second},
+		{"zero uses the default", 0, 30 * time.Second},
+		{"negative disables", -1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := createDialer(&msdsn.Config{KeepAlive: tt.keepAlive}).(netDialer)
+			if !ok {
+				t.Fatalf("expected a netDialer, got %T", d)
+			}
+			if d.nd.KeepAlive != tt.expected {
+				t.Errorf("expected KeepAlive %v, got %v", tt.expected, d.nd.KeepAlive)
+			}
+		})
+	}
+
+	// The keep-alive of a parsed connection string reaches the dialer.
+	p, err := msdsn.Parse("server=localhost;keepAlive=45")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := (&Connector{params: p}).getDialer(&p).(netDialer)
+	if d.nd.KeepAlive != 45*time.Second {
+		t.Errorf("expected KeepAlive 45s, got %v", d.nd.KeepAlive)
+	}
+}
+
+// checkLargeParam verifies the declaration of p and that its value is
+// written whole, as a PLP stream when plp is set.
+func checkLargeParam(t *testing.T, p param, decl string, plp bool) {
+	t.Helper()
+	if got := makeDecl(p.ti); got != decl {
+		t.Errorf("declaration %q, expected %q", got, decl)
+	}
+	var w bytes.Buffer
+	if err := writeTypeInfo(&w, &p.ti, false, msdsn.EncodeParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint16(w.Bytes()[1:]) == 0xffff; got != plp {
+		t.Errorf("PLP %v, expected %v", got, plp)
+	}
+	w.Reset()
+	if err := p.ti.Writer(&w, p.ti, p.buffer, msdsn.EncodeParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	// PLP values are written as an 8 byte length, one chunk with a 4 byte
+	// length and a 4 byte terminator; others with a 2 byte length.
+	overhead := 2
+	if plp {
+		overhead = 8 + 4 + 4
+	}
+	if w.Len() != len(p.buffer)+overhead {
+		t.Errorf("wrote %d bytes for a %d byte value", w.Len(), len(p.buffer))
+	}
+}
+
+func TestMakeParamLargeBinary(t *testing.T) {
+	s := &Stmt{}
+	tests := []struct {
+		size int
+		decl string
+		plp  bool
+	}{
+		{1, "varbinary(1)", false},
+		{8000, "varbinary(8000)", false},
+		{8001, "varbinary(max)", true},
+		{1 << 20, "varbinary(max)", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			p, err := s.makeParam(make([]byte, tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLargeParam(t, p, tt.decl, tt.plp)
+		})
+	}
+}
+
+func TestMakeParamLongString(t *testing.T) {
+	s := &Stmt{}
+	tests := []struct {
+		name  string
+		value driver.Value
+		decl  string
+		plp   bool
+	}{
+		{"4000 chars", strings.Repeat("a", 4000), "nvarchar(4000)", false},
+		{"4001 chars", strings.Repeat("a", 4001), "nvarchar(max)", true},
+		{"10000 chars", strings.Repeat("a", 10000), "nvarchar(max)", true},
+		// Characters outside the BMP take two UTF-16 code units.
+		{"2001 surrogate pairs", strings.Repeat("\U0001F600", 2001), "nvarchar(max)", true},
+		{"varchar 8001", VarChar(strings.Repeat("a", 8001)), "varchar(max)", true},
+		{"nchar 4000", NChar(strings.Repeat("a", 4000)), "nchar(4000)", false},
+		{"nchar 4001", NChar(strings.Repeat("a", 4001)), "nvarchar(max)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := s.makeParam(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLargeParam(t, p, tt.decl, tt.plp)
+		})
+	}
+}
+
+type countingDialer struct {
+	mu    sync.Mutex
+	dials int
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dials++
+	d.mu.Unlock()
+	return nil, errors.New("dial refused")
+}
+
+func TestConnectorWarmupDialsEachConnection(t *testing.T) {
+	params, err := msdsn.Parse("sqlserver://127.0.0.1:1433?dial+timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &countingDialer{}
+	connector := NewConnectorConfig(params)
+	connector.Dialer = dialer
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if err = connector.Warmup(context.Background(), db, 0); err != nil {
+		t.Errorf("Warmup of 0 connections failed: %v", err)
+	}
+	if err = connector.Warmup(context.Background(), db, 3); err == nil {
+		t.Fatal("expected the dial errors to be returned")
+	}
+	if dialer.dials != 3 {
+		t.Errorf("dialed %d times, expected 3", dialer.dials)
+	}
+
+	dialer.dials = 0
+	db.SetMaxOpenConns(2)
+	_ = connector.Warmup(context.Background(), db, 5)
+	if dialer.dials != 2 {
+		t.Errorf("dialed %d times, expected MaxOpenConns 2", dialer.dials)
+	}
+}
+
+func TestConnectorWarmup(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxIdleConns(4)
+
+	if err = connector.Warmup(testContext(t), db, 4); err != nil {
+		t.Fatal(err)
+	}
+	stats := db.Stats()
+	if stats.OpenConnections != 4 || stats.Idle != 4 {
+		t.Errorf("got %d open and %d idle connections, expected 4 and 4", stats.OpenConnections, stats.Idle)
+	}
+}
+
+func TestNewConnectorWithConn(t *testing.T) {
+	server := newRowServer(t)
+	var mu sync.Mutex
+	var clients []net.Conn
+	connector, err := NewConnectorWithConn("server=tunnel;user id=sa;password=x", func(ctx context.Context) (net.Conn, error) {
+		client, conn := net.Pipe()
+		server.start(conn)
+		mu.Lock()
+		clients = append(clients, client)
+		mu.Unlock()
+		return client, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var n int
+	if err = db.QueryRowContext(ctx, "select 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got the row of connection %d, expected 1", n)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(clients) != 1 {
+		t.Fatalf("dial was called %d times, expected once", len(clients))
+	}
+	if _, err = clients[0].Write([]byte{0}); err != io.ErrClosedPipe {
+		t.Errorf("the connection should be closed with the db, writing returned %v", err)
+	}
+
+	failing, err := NewConnectorWithConn("server=tunnel", func(ctx context.Context) (net.Conn, error) {
+		return nil, errors.New("tunnel down")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = failing.Connect(ctx); err == nil || !strings.Contains(err.Error(), "tunnel down") {
+		t.Errorf("expected the dial error, got %v", err)
+	}
+}
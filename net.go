@@ -9,6 +9,9 @@ import (
 type timeoutConn struct {
 	c       net.Conn
 	timeout time.Duration
+	// readTimeout and writeTimeout override timeout for reads and writes.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
 func newTimeoutConn(conn net.Conn, timeout time.Duration) *timeoutConn {
@@ -19,21 +22,25 @@ func newTimeoutConn(conn net.Conn, timeout time.Duration) *timeoutConn {
 }
 
 func (c *timeoutConn) Read(b []byte) (n int, err error) {
-	if c.timeout > 0 {
+	if c.readTimeout > 0 {
+		err = c.c.SetReadDeadline(time.Now().Add(c.readTimeout))
+	} else if c.timeout > 0 {
 		err = c.c.SetDeadline(time.Now().Add(c.timeout))
-		if err != nil {
-			return
-		}
+	}
+	if err != nil {
+		return
 	}
 	return c.c.Read(b)
 }
 
 func (c *timeoutConn) Write(b []byte) (n int, err error) {
-	if c.timeout > 0 {
+	if c.writeTimeout > 0 {
+		err = c.c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	} else if c.timeout > 0 {
 		err = c.c.SetDeadline(time.Now().Add(c.timeout))
-		if err != nil {
-			return
-		}
+	}
+	if err != nil {
+		return
 	}
 	return c.c.Write(b)
 }
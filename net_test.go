@@ -260,3 +260,26 @@ func TestTlsHandshakeConn_SetDeadline(t *testing.T) {
 	assert.NoError(t, conn.SetReadDeadline(deadline), "SetReadDeadline()")
 	assert.NoError(t, conn.SetWriteDeadline(deadline), "SetWriteDeadline()")
 }
+
+func TestTimeoutConn_ReadWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	tc := newTimeoutConn(client, time.Hour)
+	tc.readTimeout = 20 * time.Millisecond
+	tc.writeTimeout = 20 * time.Millisecond
+
+	// the peer never writes, so the read must time out
+	_, err := tc.Read(make([]byte, 1))
+	var netErr net.Error
+	if assert.ErrorAs(t, err, &netErr, "Read()") {
+		assert.True(t, netErr.Timeout(), "Read() should fail with a timeout")
+	}
+
+	// the peer never reads, so the write must time out
+	_, err = tc.Write([]byte("x"))
+	if assert.ErrorAs(t, err, &netErr, "Write()") {
+		assert.True(t, netErr.Timeout(), "Write() should fail with a timeout")
+	}
+}
@@ -0,0 +1,74 @@
+package mssql
+
+import (
+	"context"
+	"time"
+)
+
+type nullsAsZeroKey struct{}
+
+// WithNullsAsZero returns a copy of ctx whose queries return the zero value
+// of the type of a column instead of NULL: 0 for numbers, false for bit, ""
+// for text, empty bytes for binary and the zero time.Time for dates and
+// times. Scanning NULL into an int, string or float64 then stores 0 or ""
+// instead of failing with "converting NULL to int is unsupported", as it
+// does with drivers that are laxer about NULL. Connector.NullsAsZero sets
+// this for all the queries of its connections.
+//
+// With Go 1.27 and later, pointers, sql.Scanner types such as
+// sql.NullInt64, []byte and any still receive NULL, as database/sql tells
+// the driver the destinations of the values. Older versions of Go do not,
+// so there NULL is replaced for every destination, and neither does a
+// query whose messages are read with sqlexp.ReturnMessage. This loses
+// data: a NULL scanned into a zero value can no longer be told from a
+// zero. Columns of sql_variant and vector types, which have no zero value,
+// still return NULL.
+func WithNullsAsZero(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nullsAsZeroKey{}, true)
+}
+
+// nullsAsZero reports whether queries run with ctx on c return zero values
+// for NULL.
+func (c *Conn) nullsAsZero(ctx context.Context) bool {
+	if c.connector != nil && c.connector.NullsAsZero {
+		return true
+	}
+	on, _ := ctx.Value(nullsAsZeroKey{}).(bool)
+	return on
+}
+
+// zeroNulls replaces the NULL values of row by the zero values of the types
+// of their columns.
+func zeroNulls(cols []columnStruct, row []interface{}) {
+	for i, v := range row {
+		if v == nil && i < len(cols) {
+			row[i] = zeroValue(cols[i].originalTypeInfo())
+		}
+	}
+}
+
+// zeroValue returns the zero value of a column of type ti, of the Go type
+// its values are returned as, or nil when it has none.
+func zeroValue(ti typeInfo) interface{} {
+	switch ti.TypeId {
+	case typeInt1, typeInt2, typeInt4, typeInt8, typeIntN:
+		return int64(0)
+	case typeFlt4, typeFlt8, typeFltN:
+		return float64(0)
+	case typeBit, typeBitN:
+		return false
+	case typeDecimalN, typeNumericN, typeDecimal, typeNumeric, typeMoney, typeMoney4, typeMoneyN:
+		// decimals are returned as their text
+		return []byte("0")
+	case typeVarChar, typeNVarChar, typeBigVarChar, typeBigChar, typeChar, typeNChar,
+		typeText, typeNText, typeXml:
+		return ""
+	case typeBigVarBin, typeBigBinary, typeVarBinary, typeBinary, typeImage, typeUdt:
+		return []byte{}
+	case typeGuid:
+		return make([]byte, 16)
+	case typeDateTim4, typeDateTime, typeDateTimeN, typeDateTime2N, typeDateN, typeTimeN, typeDateTimeOffsetN:
+		return time.Time{}
+	}
+	return nil
+}
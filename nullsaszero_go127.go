@@ -0,0 +1,53 @@
+//go:build go1.27
+// +build go1.27
+
+package mssql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+)
+
+var _ driver.RowsColumnScanner = &Rows{}
+
+// nullsZeroedByNext is false as database/sql scans the rows with NextRow
+// and ScanColumn, which know the destinations of WithNullsAsZero.
+const nullsZeroedByNext = false
+
+// NextRow reads the next row, whose columns ScanColumn scans.
+func (rc *Rows) NextRow() error {
+	if len(rc.row) != len(rc.cols) {
+		rc.row = make([]driver.Value, len(rc.cols))
+	}
+	return rc.Next(rc.row)
+}
+
+// ScanColumn scans the column at index of the row read by NextRow into
+// dest. With WithNullsAsZero, a NULL is scanned as the zero value of the
+// type of the column into a destination that cannot hold NULL.
+func (rc *Rows) ScanColumn(scanCtx driver.ScanContext, index int, dest any) error {
+	v := rc.row[index]
+	if v == nil && rc.nullsAsZero && !canHoldNull(dest) {
+		v = zeroValue(rc.cols[index].originalTypeInfo())
+	}
+	return sql.ConvertAssign(scanCtx, dest, v)
+}
+
+// canHoldNull reports whether dest, a destination of Rows.Scan, stores
+// NULL as such: a sql.Scanner such as sql.NullInt64, or a pointer to a
+// pointer, an interface or a slice, such as **int, *any or *[]byte.
+func canHoldNull(dest any) bool {
+	if _, ok := dest.(sql.Scanner); ok {
+		return true
+	}
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return false
+	}
+	switch t.Elem().Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+//go:build go1.27
+// +build go1.27
+
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nullIntStream is a result set of one row of six NULL int columns.
+func nullIntStream() []byte {
+	stream := []byte{byte(tokenColMetadata), 6, 0}
+	for i := 0; i < 6; i++ {
+		// user type, flags (nullable), type, size, no name
+		stream = append(stream, 0, 0, 0, 0, 0x01, 0, typeIntN, 4, 0)
+	}
+	stream = append(stream, byte(tokenRow))
+	stream = append(stream, make([]byte, 6)...)
+	return append(stream, doneToken(doneCount, 1)...)
+}
+
+func TestNullsAsZeroKeepsNullForNullableDestinations(t *testing.T) {
+	server := newRowServer(t)
+	server.answer = func(int, []byte) ([]byte, bool) { return nullIntStream(), true }
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	connector.NullsAsZero = true
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	i, f := 1, 1.5
+	p := new(int)
+	var n sql.NullInt64
+	var g sql.Null[int32]
+	var a any = 1
+	require.NoError(t, db.QueryRowContext(ctx, "select 1").Scan(&i, &f, &p, &n, &g, &a))
+	assert.Equal(t, 0, i)
+	assert.Equal(t, 0.0, f)
+	assert.Nil(t, p, "a pointer holds NULL")
+	assert.False(t, n.Valid, "a sql.Null type holds NULL")
+	assert.False(t, g.Valid)
+	assert.Nil(t, a)
+}
+
+func TestCanHoldNull(t *testing.T) {
+	var i int
+	var s string
+	var p *int
+	var b []byte
+	var a any
+	var n sql.NullString
+	assert.False(t, canHoldNull(&i))
+	assert.False(t, canHoldNull(&s))
+	assert.True(t, canHoldNull(&p))
+	assert.True(t, canHoldNull(&b))
+	assert.True(t, canHoldNull(&a))
+	assert.True(t, canHoldNull(&n))
+	assert.False(t, canHoldNull(nil))
+}
@@ -0,0 +1,9 @@
+//go:build !go1.27
+// +build !go1.27
+
+package mssql
+
+// nullsZeroedByNext is true as database/sql scans the values returned by
+// Rows.Next without telling their destinations, so WithNullsAsZero
+// replaces every NULL.
+const nullsZeroedByNext = true
@@ -0,0 +1,61 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroNulls(t *testing.T) {
+	cols := []columnStruct{
+		{ti: typeInfo{TypeId: typeIntN, Size: 4}},
+		{ti: typeInfo{TypeId: typeNVarChar}},
+		{ti: typeInfo{TypeId: typeFltN, Size: 8}},
+		{ti: typeInfo{TypeId: typeDecimalN}},
+		{ti: typeInfo{TypeId: typeBitN}},
+		{ti: typeInfo{TypeId: typeDateTime2N}},
+		{ti: typeInfo{TypeId: typeBigVarBin}},
+		{ti: typeInfo{TypeId: typeVariant}},
+		{ti: typeInfo{TypeId: typeIntN, Size: 4}},
+	}
+	row := []interface{}{nil, nil, nil, nil, nil, nil, nil, nil, int64(7)}
+	zeroNulls(cols, row)
+	assert.Equal(t, []interface{}{int64(0), "", float64(0), []byte("0"), false, time.Time{}, []byte{}, nil, int64(7)}, row)
+
+	assert.False(t, (&Conn{}).nullsAsZero(context.Background()), "NULL is kept by default")
+	assert.True(t, (&Conn{}).nullsAsZero(WithNullsAsZero(context.Background())))
+	assert.True(t, (&Conn{connector: &Connector{NullsAsZero: true}}).nullsAsZero(context.Background()))
+}
+
+func TestNullsAsZeroIntegration(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+
+	const query = "SELECT CAST(NULL AS int), CAST(NULL AS nvarchar(10)), CAST(NULL AS float)"
+	var i int
+	var s string
+	var f float64
+	err := db.QueryRow(query).Scan(&i, &s, &f)
+	assert.Error(t, err, "NULL is not scanned into an int by default")
+
+	ctx := WithNullsAsZero(context.Background())
+	i, s, f = 1, "x", 1.5
+	require.NoError(t, db.QueryRowContext(ctx, query).Scan(&i, &s, &f))
+	assert.Equal(t, 0, i)
+	assert.Equal(t, "", s)
+	assert.Equal(t, 0.0, f)
+
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	connector.NullsAsZero = true
+	zdb := sql.OpenDB(connector)
+	defer zdb.Close()
+	i = 1
+	require.NoError(t, zdb.QueryRow(query).Scan(&i, &s, &f))
+	assert.Equal(t, 0, i)
+}
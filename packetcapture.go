@@ -0,0 +1,128 @@
+package mssql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// PacketCapture writes the raw TDS traffic of the connections of a
+// Connector to W, for debugging protocol issues. It is off unless set as
+// Connector.PacketCapture, and meant for reproducing problems, not for
+// production use: capturing slows every read and write.
+//
+// Each read or write is a line starting with > for bytes sent and < for
+// bytes received, the number of the connection and the size, followed by
+// a hex dump of the bytes:
+//
+//	> #1 47 bytes
+//	00000000  12 01 00 2f 00 00 01 00  00 00 1a 00 06 01 00 20  |.../........... |
+//	...
+//
+// By default the packets are captured as the driver reads and writes them,
+// after decryption, one TDS packet per record. The login and
+// authentication packets hold passwords and tokens, so only their size is
+// captured unless IncludeAuth is set.
+type PacketCapture struct {
+	// W receives the capture. Writes to W are serialized.
+	W io.Writer
+	// Wire captures the bytes as sent on the network, encrypted once TLS
+	// is set up, instead of the decrypted TDS packets.
+	Wire bool
+	// IncludeAuth captures the login and authentication packets in full.
+	IncludeAuth bool
+
+	mu    sync.Mutex
+	conns int
+}
+
+// connCapture is the capture of one connection.
+type connCapture struct {
+	pc   *PacketCapture
+	conn int
+	// redact is set while an authentication packet is written.
+	redact bool
+}
+
+// start returns the capture of a new connection, or nil when pc is nil.
+func (pc *PacketCapture) start() *connCapture {
+	if pc == nil || pc.W == nil {
+		return nil
+	}
+	pc.mu.Lock()
+	pc.conns++
+	conn := pc.conns
+	pc.mu.Unlock()
+	return &connCapture{pc: pc, conn: conn}
+}
+
+// record writes b, sent when out is set, to the capture.
+func (c *connCapture) record(out bool, b []byte) {
+	dir := "<"
+	if out {
+		dir = ">"
+	}
+	c.pc.mu.Lock()
+	defer c.pc.mu.Unlock()
+	if out && c.redact && !c.pc.IncludeAuth {
+		fmt.Fprintf(c.pc.W, "%s #%d %d bytes redacted\n", dir, c.conn, len(b))
+		return
+	}
+	fmt.Fprintf(c.pc.W, "%s #%d %d bytes\n", dir, c.conn, len(b))
+	io.WriteString(c.pc.W, hex.Dump(b))
+}
+
+// packet records the TDS packet p, unless the bytes on the wire are
+// captured instead. It does nothing when c is nil.
+func (c *connCapture) packet(out bool, p []byte) {
+	if c == nil || c.pc.Wire {
+		return
+	}
+	c.record(out, p)
+}
+
+// writing marks the start of writing a packet of type t.
+func (c *connCapture) writing(t packetType) {
+	if c == nil {
+		return
+	}
+	switch t {
+	case packLogin7, packSSPIMessage, packFedAuthToken:
+		c.redact = true
+	default:
+		c.redact = false
+	}
+}
+
+// wire returns conn capturing its bytes when the capture is of the wire.
+func (c *connCapture) wire(conn net.Conn) net.Conn {
+	if c == nil || !c.pc.Wire {
+		return conn
+	}
+	return &captureConn{Conn: conn, c: c}
+}
+
+// captureConn records the bytes read from and written to a network
+// connection.
+type captureConn struct {
+	net.Conn
+	c *connCapture
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.c.record(false, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.c.record(true, b[:n])
+	}
+	return n, err
+}
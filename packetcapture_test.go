@@ -0,0 +1,53 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func capturedQuery(t *testing.T, server *rowServer, pc *PacketCapture) {
+	t.Helper()
+	connector, err := NewConnector(server.connString() + ";user id=sa;password=secret")
+	require.NoError(t, err)
+	connector.PacketCapture = pc
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "select 1").Scan(&n))
+}
+
+func TestPacketCapture(t *testing.T) {
+	server := newRowServer(t)
+
+	var buf bytes.Buffer
+	capturedQuery(t, server, &PacketCapture{W: &buf})
+	captured := buf.String()
+	assert.Contains(t, captured, "> #1 ", "bytes sent")
+	assert.Contains(t, captured, "< #1 ", "bytes received")
+	assert.Regexp(t, regexp.MustCompile(`(?m)^> #1 \d+ bytes\n00000000  01 0[19] `), captured, "the query batch")
+	assert.Regexp(t, regexp.MustCompile(`(?m)^< #1 \d+ bytes\n00000000  04 01 `), captured, "its reply")
+	assert.Regexp(t, regexp.MustCompile(`(?m)^> #1 \d+ bytes redacted$`), captured, "the login")
+	assert.NotRegexp(t, regexp.MustCompile(`(?m)^00000000  10 `), captured, "the login is not dumped")
+
+	buf.Reset()
+	capturedQuery(t, server, &PacketCapture{W: &buf, IncludeAuth: true})
+	captured = buf.String()
+	assert.NotContains(t, captured, "redacted")
+	assert.Regexp(t, regexp.MustCompile(`(?m)^> #1 \d+ bytes\n00000000  10 01 `), captured, "the login")
+
+	buf.Reset()
+	capturedQuery(t, server, &PacketCapture{W: &buf, Wire: true})
+	captured = buf.String()
+	assert.Regexp(t, regexp.MustCompile(`(?m)^> #1 \d+ bytes\n00000000  01 0[19] `), captured, "the query batch")
+	assert.Contains(t, captured, "< #1 ")
+	assert.Regexp(t, regexp.MustCompile(`(?m)^> #1 \d+ bytes redacted$`), captured, "the login")
+}
@@ -0,0 +1,162 @@
+package mssql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParameterCountMismatch is returned when a query is run with a number of
+// arguments other than the number of parameters its text references.
+type ParameterCountMismatch struct {
+	Expected int
+	Provided int
+}
+
+func (e ParameterCountMismatch) Error() string {
+	return fmt.Sprintf("mssql: the query has %d parameters but %d arguments were provided", e.Expected, e.Provided)
+}
+
+// checkParamCount compares args to the parameters of the query text. With
+// query text processing they are its placeholders, which must match the
+// arguments. Otherwise they are the highest @pN the text references, which
+// the arguments passed without a name must reach: extra arguments are
+// sent and ignored, as the text may not reference all of them. Stored
+// procedures are not checked, nor queries run without arguments, which
+// are sent as a batch where @pN may be a local variable.
+func (s *Stmt) checkParamCount(isProc bool, args []namedValue) error {
+	if isProc || s.paramCount < 0 {
+		return nil
+	}
+	provided := len(args)
+	if !s.c.processQueryText {
+		if len(args) == 0 {
+			return nil
+		}
+		provided = 0
+		for _, arg := range args {
+			if arg.Name == "" {
+				provided++
+			}
+		}
+		if provided >= s.paramCount {
+			return nil
+		}
+	}
+	if provided != s.paramCount {
+		return ParameterCountMismatch{Expected: s.paramCount, Provided: provided}
+	}
+	return nil
+}
+
+// ordinalParams returns the highest N of the @pN parameters referenced by
+// query, ignoring those in string literals, quoted identifiers and comments
+// and the local variables query declares, including table and cursor
+// variables.
+func ordinalParams(query string) int {
+	var referenced []int
+	declared := map[int]bool{}
+	// declaring is set in a DECLARE statement, and nameNext where it
+	// declares the next variable; depth is the depth of its parentheses.
+	var declaring, nameNext bool
+	var depth int
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			// a doubled quote is read as two literals, which is the same
+			i = skipUntil(query, i+1, string(c))
+		case c == '[':
+			i = skipUntil(query, i+1, "]")
+		case strings.HasPrefix(query[i:], "--"):
+			i = skipUntil(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			i = skipComment(query, i+2)
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',':
+			nameNext = declaring && depth == 0
+		case c == ';':
+			declaring, nameNext = false, false
+		case c == '@' && i+1 < len(query) && query[i+1] == '@':
+			// @@ starts a system function such as @@ROWCOUNT
+			i = skipName(query, i+2) - 1
+		case c == '@':
+			end := skipName(query, i+1)
+			name := query[i+1 : end]
+			// the parameters are declared as @p1, @p2..., so @p01 is not one
+			if len(name) > 1 && (name[0] == 'p' || name[0] == 'P') && name[1] != '0' {
+				if n, err := strconv.Atoi(name[1:]); err == nil {
+					if nameNext {
+						declared[n] = true
+					} else {
+						referenced = append(referenced, n)
+					}
+				}
+			}
+			nameNext = false
+			i = end - 1
+		case isNameChar(c):
+			// not a parameter, such as the @ of an e-mail address in a name
+			end := skipName(query, i)
+			word := strings.ToUpper(query[i:end])
+			nameNext = word == "DECLARE"
+			if nameNext {
+				declaring, depth = true, 0
+			} else if declaring && depth == 0 && statementStarts[word] {
+				declaring = false
+			}
+			i = end - 1
+		}
+	}
+	// a declared variable is not a parameter, wherever the batch uses it
+	var max int
+	for _, n := range referenced {
+		if n > max && !declared[n] {
+			max = n
+		}
+	}
+	return max
+}
+
+// skipUntil returns the index of the first end in query from i, or the end
+// of query.
+func skipUntil(query string, i int, end string) int {
+	if n := strings.Index(query[i:], end); n >= 0 {
+		return i + n + len(end) - 1
+	}
+	return len(query)
+}
+
+// skipComment returns the index of the */ ending the block comment at i,
+// which may nest.
+func skipComment(query string, i int) int {
+	depth := 1
+	for ; i < len(query); i++ {
+		switch {
+		case strings.HasPrefix(query[i:], "/*"):
+			depth++
+			i++
+		case strings.HasPrefix(query[i:], "*/"):
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(query)
+}
+
+func skipName(query string, i int) int {
+	for i < len(query) && isNameChar(query[i]) {
+		i++
+	}
+	return i
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '@' || c == '#' || c == '$' || c >= 0x80 ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,130 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdinalParams(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"select 1", 0},
+		{"select @p1, @p2", 2},
+		{"select @p2 where x = @P1", 2},
+		{"select @p10", 10},
+		{"select '@p3', N'it''s @p4', @p1", 1},
+		{`select "@p3" from [t @p4]]x] where a = @p1`, 1},
+		{"select @p1 -- and @p2\n, 2", 1},
+		{"select /* @p2 /* nested @p3 */ @p4 */ @p1", 1},
+		{"select @@ROWCOUNT, @p1", 1},
+		{"select @param, @p1x, @p01, @p, @name", 0},
+		{"select x@p5 from t", 0},
+		{"select 'unterminated @p2", 0},
+		{"DECLARE @p2 int = 5; SELECT @p1 + @p2", 1},
+		{"declare @x int = coalesce(@p3, 1), @p4 int select @p1, @p4, @x", 3},
+		{"declare @p2 table (a int, b int); insert @p2 values (@p1, @p3)", 3},
+		{"declare @p5 cursor; set @p5 = cursor for select @p1, @p2 from t", 2},
+		{"declare @x int select @p1, @p2", 2},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ordinalParams(tt.query), tt.query)
+	}
+}
+
+func TestCheckParamCount(t *testing.T) {
+	named := namedValue{Name: "x"}
+	tests := []struct {
+		name         string
+		processQuery bool
+		paramCount   int
+		isProc       bool
+		args         []namedValue
+		want         error
+	}{
+		{"match", false, 2, false, []namedValue{{}, {}}, nil},
+		{"too few", false, 2, false, []namedValue{{}}, ParameterCountMismatch{Expected: 2, Provided: 1}},
+		{"extra arguments ignored", false, 1, false, []namedValue{{}, {}}, nil},
+		{"query text too many", true, 1, false, []namedValue{{}, {}}, ParameterCountMismatch{Expected: 1, Provided: 2}},
+		{"named not counted", false, 1, false, []namedValue{{}, named}, nil},
+		{"no arguments sent as a batch", false, 1, false, nil, nil},
+		{"procedure", false, 0, true, []namedValue{{}}, nil},
+		{"query text", true, 2, false, []namedValue{{}, named}, nil},
+		{"query text without arguments", true, 1, false, nil, ParameterCountMismatch{Expected: 1, Provided: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Stmt{c: &Conn{processQueryText: tt.processQuery}, paramCount: tt.paramCount}
+			assert.Equal(t, tt.want, s.checkParamCount(tt.isProc, tt.args))
+		})
+	}
+	assert.Equal(t, "mssql: the query has 2 parameters but 1 arguments were provided",
+		ParameterCountMismatch{Expected: 2, Provided: 1}.Error())
+}
+
+func TestParameterCountMismatchIsNotSent(t *testing.T) {
+	server := newRowServer(t)
+	for _, driverName := range []string{"sqlserver", "mssql"} {
+		t.Run(driverName, func(t *testing.T) {
+			db, err := sql.Open(driverName, server.connString())
+			require.NoError(t, err)
+			defer db.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			conn, err := db.Conn(ctx)
+			require.NoError(t, err)
+			defer conn.Close()
+			query := "select @p1, @p2, '@p3' -- @p4"
+			if driverName == "mssql" {
+				query = "select ?, ?, '?' -- ?"
+			}
+
+			before := server.requestsPerConn()[server.accepted()-1]
+			_, err = conn.ExecContext(ctx, query, 1)
+			if driverName == "mssql" {
+				// database/sql checks the count given by NumInput
+				require.EqualError(t, err, "sql: expected 2 arguments, got 1")
+			} else {
+				var mismatch ParameterCountMismatch
+				require.True(t, errors.As(err, &mismatch), "%T: %v", err, err)
+				assert.Equal(t, ParameterCountMismatch{Expected: 2, Provided: 1}, mismatch)
+			}
+			assert.Equal(t, before, server.requestsPerConn()[server.accepted()-1], "the query is not sent")
+
+			_, err = conn.ExecContext(ctx, query, 1, 2)
+			assert.NoError(t, err, "placeholders in literals and comments are not counted")
+		})
+	}
+}
+
+func TestParameterCountOfSQLServerDriver(t *testing.T) {
+	server := newRowServer(t)
+	db, err := sql.Open("sqlserver", server.connString())
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, "DECLARE @p2 int = 5; SELECT @p1 + @p2", 1)
+	assert.NoError(t, err, "a declared @pN is not a parameter")
+	_, err = db.ExecContext(ctx, "select @p1", 1, 2)
+	assert.NoError(t, err, "unreferenced arguments are sent and ignored")
+}
+
+func TestNumInput(t *testing.T) {
+	c := &Conn{processQueryText: true}
+	s, err := c.prepareContext(context.Background(), "select ?, ?")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.NumInput())
+	c.processQueryText = false
+	s, err = c.prepareContext(context.Background(), "select @p1, @p2")
+	require.NoError(t, err)
+	assert.Equal(t, -1, s.NumInput())
+}
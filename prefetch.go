@@ -0,0 +1,46 @@
+package mssql
+
+import "context"
+
+// defaultPrefetchRows is the number of tokens, most of them rows, the
+// response reader buffers ahead of the caller when the context does not set
+// a prefetch window.
+const defaultPrefetchRows = 5
+
+// maxPrefetchRows is the largest prefetch window a context may set. The
+// reader allocates a channel of the window's size for every request, so
+// the window is bounded to keep a mistaken value from exhausting memory.
+const maxPrefetchRows = 10000
+
+type prefetchRowsKey struct{}
+
+// WithPrefetchRows returns a copy of ctx that sets how many rows the driver
+// decodes and buffers ahead of the caller for queries run with it.
+//
+// Rows are decoded by a goroutine reading the network while the caller
+// consumes them. A larger window lets decoding run further ahead, which
+// raises throughput when the caller does work between rows, at the cost of
+// holding up to n decoded rows in memory at once; for rows with large
+// values such as nvarchar(max) or varbinary(max) this can be significant.
+// A window of 0 makes the reader wait for the caller after every row,
+// keeping memory to a single row. The default is 5 and the largest window
+// is 10000; n outside 0 to 10000 is ignored and ctx is returned unchanged.
+//
+// The window only affects client side buffering. It does not change how
+// the server sends results, and the network buffer of the connection is
+// still filled according to the packet size.
+func WithPrefetchRows(ctx context.Context, n int) context.Context {
+	if n < 0 || n > maxPrefetchRows {
+		return ctx
+	}
+	return context.WithValue(ctx, prefetchRowsKey{}, n)
+}
+
+// prefetchRows returns the prefetch window set on ctx by WithPrefetchRows,
+// clamped to maxPrefetchRows.
+func prefetchRows(ctx context.Context) int {
+	if n, ok := ctx.Value(prefetchRowsKey{}).(int); ok && n >= 0 {
+		return min(n, maxPrefetchRows)
+	}
+	return defaultPrefetchRows
+}
@@ -0,0 +1,81 @@
+package mssql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefetchRows(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, defaultPrefetchRows, prefetchRows(ctx))
+	assert.Equal(t, 0, prefetchRows(WithPrefetchRows(ctx, 0)))
+	assert.Equal(t, 500, prefetchRows(WithPrefetchRows(ctx, 500)))
+	assert.Equal(t, defaultPrefetchRows, prefetchRows(WithPrefetchRows(ctx, -1)), "negative windows use the default")
+	assert.Equal(t, maxPrefetchRows, prefetchRows(WithPrefetchRows(ctx, maxPrefetchRows)))
+	assert.Equal(t, defaultPrefetchRows, prefetchRows(WithPrefetchRows(ctx, maxPrefetchRows+1)), "windows above the maximum are ignored")
+	assert.Equal(t, 500, prefetchRows(WithPrefetchRows(WithPrefetchRows(ctx, 500), math.MaxInt)), "an ignored window keeps the outer one")
+	assert.Equal(t, maxPrefetchRows, prefetchRows(context.WithValue(ctx, prefetchRowsKey{}, math.MaxInt)), "windows are clamped to the maximum")
+	assert.NotPanics(t, func() {
+		_ = make(chan tokenStruct, prefetchRows(WithPrefetchRows(ctx, math.MaxInt)))
+	})
+}
+
+func TestPrefetchRowsQueries(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, n := range []int{0, 1, 1000} {
+		ctx := WithPrefetchRows(testContext(t), n)
+		rows, err := conn.QueryContext(ctx, "SELECT TOP 100 object_id FROM sys.all_objects")
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		assert.Equal(t, 100, count, "window %d", n)
+	}
+}
+
+// BenchmarkPrefetchRows scans a large result set with different prefetch
+// windows while doing a little work per row. Compare ns/op for throughput
+// and B/op for the memory held by buffered rows.
+func BenchmarkPrefetchRows(b *testing.B) {
+	db := benchmarkDB(b)
+	query := `SELECT TOP 10000 a.object_id, a.name, REPLICATE(N'x', 200)
+		FROM sys.all_objects a CROSS JOIN sys.all_objects b`
+	for _, n := range []int{0, defaultPrefetchRows, 100, 1000} {
+		b.Run(fmt.Sprintf("window=%d", n), func(b *testing.B) {
+			ctx := WithPrefetchRows(context.Background(), n)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rows, err := db.QueryContext(ctx, query)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var sum int
+				for rows.Next() {
+					var id int
+					var name, pad string
+					if err := rows.Scan(&id, &name, &pad); err != nil {
+						b.Fatal(err)
+					}
+					for _, r := range pad {
+						sum += int(r)
+					}
+				}
+				rows.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ServerEncryption is the encryption level a server answered in the
+// pre-login of a connection.
+type ServerEncryption byte
+
+const (
+	// ServerEncryptionOff means only the login is encrypted.
+	ServerEncryptionOff ServerEncryption = encryptOff
+	// ServerEncryptionOn means the session is encrypted because the client
+	// asked for it.
+	ServerEncryptionOn ServerEncryption = encryptOn
+	// ServerEncryptionNotSupported means nothing is encrypted.
+	ServerEncryptionNotSupported ServerEncryption = encryptNotSup
+	// ServerEncryptionRequired means the server forces the encryption of
+	// the session.
+	ServerEncryptionRequired ServerEncryption = encryptReq
+	// ServerEncryptionStrict means the connection uses TDS 8.0, encrypted
+	// with TLS before the pre-login.
+	ServerEncryptionStrict ServerEncryption = encryptStrict
+)
+
+func (e ServerEncryption) String() string {
+	switch e {
+	case ServerEncryptionOff:
+		return "off"
+	case ServerEncryptionOn:
+		return "on"
+	case ServerEncryptionNotSupported:
+		return "not supported"
+	case ServerEncryptionRequired:
+		return "required"
+	case ServerEncryptionStrict:
+		return "strict"
+	}
+	return fmt.Sprintf("ServerEncryption(%d)", byte(e))
+}
+
+// PreloginInfo is what the server answered in the pre-login of a
+// connection, the exchange before the login that negotiates encryption.
+type PreloginInfo struct {
+	// Major, Minor and Build are the version of the server, as in 16.0.1000.
+	Major, Minor uint8
+	Build        uint16
+	// Encryption is the encryption level the server chose.
+	Encryption ServerEncryption
+	// InstanceValid means the server is the instance of the connection
+	// string, or the default instance when it names none.
+	InstanceValid bool
+	// MARS means the server accepted multiple active result sets. The
+	// driver does not ask for them, so it is normally false.
+	MARS bool
+	// FedAuthRequired means the server asked for the federated
+	// authentication flag to be echoed in the login.
+	FedAuthRequired bool
+}
+
+// Version returns the version of the server as major.minor.build.
+func (p PreloginInfo) Version() string {
+	return fmt.Sprintf("%d.%d.%d", p.Major, p.Minor, p.Build)
+}
+
+// newPreloginInfo returns the PreloginInfo of the fields of a pre-login
+// response. Missing or short fields are left zero.
+func newPreloginInfo(fields map[uint8][]byte) PreloginInfo {
+	var p PreloginInfo
+	if v := fields[preloginVERSION]; len(v) >= 4 {
+		p.Major, p.Minor = v[0], v[1]
+		p.Build = binary.BigEndian.Uint16(v[2:4])
+	}
+	if v := fields[preloginENCRYPTION]; len(v) > 0 {
+		p.Encryption = ServerEncryption(v[0])
+	}
+	// the server answers 0 when the instance matches
+	if v := fields[preloginINSTOPT]; len(v) > 0 {
+		p.InstanceValid = v[0] == 0
+	}
+	if v := fields[preloginMARS]; len(v) > 0 {
+		p.MARS = v[0] == 1
+	}
+	if v := fields[preloginFEDAUTHREQUIRED]; len(v) > 0 {
+		p.FedAuthRequired = v[0] == 1
+	}
+	return p
+}
+
+// PreloginInfo returns what the server answered in the pre-login of the
+// connection.
+//
+// Use sql.Conn.Raw to call it from database/sql:
+//
+//	err = conn.Raw(func(dc any) error {
+//		info = dc.(*mssql.Conn).PreloginInfo()
+//		return nil
+//	})
+func (c *Conn) PreloginInfo() PreloginInfo {
+	return c.sess.prelogin
+}
+
+// PreloginInfo returns what the server answered in the pre-login of the
+// last connection made by c, and false when c has not connected yet.
+func (c *Connector) PreloginInfo() (PreloginInfo, bool) {
+	c.preloginMu.Lock()
+	defer c.preloginMu.Unlock()
+	if c.prelogin == nil {
+		return PreloginInfo{}, false
+	}
+	return *c.prelogin, true
+}
+
+func (c *Connector) setPreloginInfo(p PreloginInfo) {
+	c.preloginMu.Lock()
+	c.prelogin = &p
+	c.preloginMu.Unlock()
+}
@@ -0,0 +1,76 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreloginInfo(t *testing.T) {
+	response := []byte{
+		preloginVERSION, 0, 31, 0, 6,
+		preloginENCRYPTION, 0, 37, 0, 1,
+		preloginINSTOPT, 0, 38, 0, 1,
+		preloginTHREADID, 0, 39, 0, 0,
+		preloginMARS, 0, 39, 0, 1,
+		preloginFEDAUTHREQUIRED, 0, 40, 0, 1,
+		preloginTERMINATOR,
+		// 16.0.1000, sub build 0
+		0x10, 0x00, 0x03, 0xe8, 0x00, 0x00,
+		encryptReq,
+		0,
+		1,
+		1,
+	}
+	buf := newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(response))})
+	fields, err := readPrelogin(buf)
+	require.NoError(t, err)
+	info := newPreloginInfo(fields)
+	assert.Equal(t, PreloginInfo{
+		Major:           16,
+		Build:           1000,
+		Encryption:      ServerEncryptionRequired,
+		InstanceValid:   true,
+		MARS:            true,
+		FedAuthRequired: true,
+	}, info)
+	assert.Equal(t, "16.0.1000", info.Version())
+	assert.Equal(t, "required", info.Encryption.String())
+
+	info = newPreloginInfo(map[uint8][]byte{
+		preloginENCRYPTION: {encryptOff},
+		preloginINSTOPT:    {1},
+		preloginVERSION:    {0x0f},
+	})
+	assert.Equal(t, PreloginInfo{Encryption: ServerEncryptionOff}, info, "invalid instance and short version")
+	assert.Equal(t, "ServerEncryption(9)", ServerEncryption(9).String())
+}
+
+func TestConnectorPreloginInfo(t *testing.T) {
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	_, ok := connector.PreloginInfo()
+	assert.False(t, ok, "not connected yet")
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	info, ok := connector.PreloginInfo()
+	require.True(t, ok)
+	assert.Equal(t, ServerEncryptionNotSupported, info.Encryption)
+	require.NoError(t, conn.Raw(func(dc interface{}) error {
+		assert.Equal(t, info, dc.(*Conn).PreloginInfo())
+		return nil
+	}))
+}
@@ -1442,6 +1442,99 @@ END;
 // variable is shared between the driver and the client application.
 //
 // Issue https://github.com/denisenkom/go-mssqldb/issues/378
+func TestOutputParamDynamicSQL(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	t.Run("call sp_executesql", func(t *testing.T) {
+		var doubled int64
+		_, err := db.ExecContext(ctx, "sp_executesql",
+			sql.Named("stmt", "SET @Doubled = @in * 2"),
+			sql.Named("params", "@in int, @Doubled int OUTPUT"),
+			sql.Named("in", 21),
+			sql.Named("doubled", sql.Out{Dest: &doubled}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doubled != 42 {
+			t.Errorf("expected 42, got %d", doubled)
+		}
+	})
+
+	t.Run("EXEC sp_executesql in a query", func(t *testing.T) {
+		var doubled int64
+		var name string
+		rows, err := db.QueryContext(ctx,
+			"EXEC sp_executesql N'SELECT @name = N''dynamic''; SET @r = @a * 2; SELECT @name', N'@a int, @r int OUTPUT, @name nvarchar(20) OUTPUT', @a = @p1, @r = @p2 OUTPUT, @name = @p3 OUTPUT",
+			21, sql.Out{Dest: &doubled}, sql.Out{Dest: &name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var selected string
+		for rows.Next() {
+			if err = rows.Scan(&selected); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if selected != "dynamic" {
+			t.Errorf("expected the dynamic result set, got %q", selected)
+		}
+		if doubled != 42 || name != "dynamic" {
+			t.Errorf("expected output parameters 42 and dynamic, got %d and %q", doubled, name)
+		}
+	})
+}
+
+func TestOutputParamAfterLargeResultSet(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	sqltextdrop := `DROP PROCEDURE IF EXISTS splargewithoutput`
+	db.ExecContext(ctx, sqltextdrop)
+	_, err := db.ExecContext(ctx, `
+	CREATE PROCEDURE splargewithoutput
+		@count INT OUTPUT
+	AS BEGIN
+		SELECT TOP 20000 ROW_NUMBER() OVER (ORDER BY (SELECT NULL)), REPLICATE('x', 100)
+		FROM sys.all_columns a CROSS JOIN sys.all_columns b
+		SET @count = @@ROWCOUNT
+		RETURN 3
+	END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, sqltextdrop)
+
+	for _, read := range []int{20000, 10} {
+		var count int64
+		var status ReturnStatus
+		rows, err := db.QueryContext(ctx, "splargewithoutput", sql.Named("count", sql.Out{Dest: &count}), &status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < read && rows.Next(); i++ {
+			var n int64
+			var s string
+			if err = rows.Scan(&n, &s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if count != 20000 || status != 3 {
+			t.Errorf("reading %d rows: expected the output 20000 and status 3, got %d and %d", read, count, status)
+		}
+	}
+}
+
 func TestOutputParamWithRows(t *testing.T) {
 	sqltextcreate := `
 	CREATE PROCEDURE spwithoutputandrows
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"math"
 	"net"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -628,6 +630,179 @@ func TestParams(t *testing.T) {
 	}
 }
 
+func TestScanFloat32(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	var real, float float32
+	err := conn.QueryRow("select cast(0.5 as real), cast(0.1 as float)").Scan(&real, &float)
+	if err != nil {
+		t.Fatal("Scan into float32 failed", err.Error())
+	}
+	if real != 0.5 || float != 0.1 {
+		t.Errorf("Expected 0.5 and 0.1, got %v and %v", real, float)
+	}
+
+	var outReal float32
+	_, err = conn.Exec("select @out = cast(1.25 as real)", sql.Named("out", sql.Out{Dest: &outReal}))
+	if err != nil {
+		t.Fatal("float32 OUTPUT parameter failed", err.Error())
+	}
+	if outReal != 1.25 {
+		t.Errorf("Expected 1.25, got %v", outReal)
+	}
+
+	t.Run("VECTOR_DISTANCE", func(t *testing.T) {
+		skipIfVectorUnsupported(t, conn)
+		var dist float32
+		err := conn.QueryRow("select VECTOR_DISTANCE('euclidean', cast('[0,0]' as vector(2)), cast('[3,4]' as vector(2)))").Scan(&dist)
+		if err != nil {
+			t.Fatal("Scan of VECTOR_DISTANCE into float32 failed", err.Error())
+		}
+		if dist != 5 {
+			t.Errorf("Expected distance 5, got %v", dist)
+		}
+	})
+}
+
+// skipIfVectorUnsupported skips the test when the server has no VECTOR type.
+func skipIfVectorUnsupported(t *testing.T, conn *sql.DB) {
+	t.Helper()
+	var s string
+	if err := conn.QueryRow("select cast(cast('[1]' as vector(1)) as nvarchar(max))").Scan(&s); err != nil {
+		t.Skip("VECTOR is not supported by this server:", err)
+	}
+}
+
+func TestJSONAggregates(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	const values = "(values (1, N'a'), (2, N'b')) v(id, name)"
+	var probe string
+	if err := conn.QueryRow("select json_arrayagg(id) from " + values).Scan(&probe); err != nil {
+		t.Skip("JSON aggregate functions are not supported by this server:", err)
+	}
+
+	// Without the native JSON type the aggregates are returned as nvarchar
+	// text, which scans into strings and json.RawMessage alike.
+	var array json.RawMessage
+	var object string
+	err := conn.QueryRow("select json_arrayagg(id order by id), json_objectagg(name:id) from "+values).Scan(&array, &object)
+	if err != nil {
+		t.Fatal("query failed:", err)
+	}
+	var ids []int
+	if err = json.Unmarshal(array, &ids); err != nil || !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("json_arrayagg returned %s (%v)", array, err)
+	}
+	var byName map[string]int
+	if err = json.Unmarshal([]byte(object), &byName); err != nil || !reflect.DeepEqual(byName, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("json_objectagg returned %s (%v)", object, err)
+	}
+}
+
+func TestDateTime2ScaleRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec("create table #dt2 (d0 datetime2(0), d3 datetime2(3), d7 datetime2(7))")
+	if err != nil {
+		t.Fatal("create table failed:", err)
+	}
+	want := []time.Time{
+		time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC),
+		time.Date(2024, 2, 29, 23, 59, 59, 999000000, time.UTC),
+		time.Date(2024, 2, 29, 23, 59, 59, 999999900, time.UTC),
+	}
+	_, err = conn.Exec("insert into #dt2 values (@p1, @p2, @p3), ('2024-02-29T23:59:59', '2024-02-29T23:59:59.999', '2024-02-29T23:59:59.9999999')",
+		want[0], want[1], want[2])
+	if err != nil {
+		t.Fatal("insert failed:", err)
+	}
+
+	rows, err := conn.Query("select d0, d3, d7 from #dt2")
+	if err != nil {
+		t.Fatal("select failed:", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		got := make([]time.Time, 3)
+		if err = rows.Scan(&got[0], &got[1], &got[2]); err != nil {
+			t.Fatal("scan failed:", err)
+		}
+		for i := range got {
+			if !got[i].Equal(want[i]) || got[i].Nanosecond() != want[i].Nanosecond() {
+				t.Errorf("row %d column %d: got %v, want %v", n, i, got[i].Format(time.RFC3339Nano), want[i].Format(time.RFC3339Nano))
+			}
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("read %d rows, want 2", n)
+	}
+}
+
+func TestBigintIdentity(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec("create table #big (id bigint identity(9223372036854775806, 1), v bigint)")
+	if err != nil {
+		t.Fatal("create table failed:", err)
+	}
+	var id int64
+	if err = conn.QueryRow("insert into #big (v) output inserted.id values (@p1)", int64(math.MinInt64)).Scan(&id); err != nil {
+		t.Fatal("insert failed:", err)
+	}
+	if id != math.MaxInt64-1 {
+		t.Errorf("OUTPUT returned identity %d, want %d", id, int64(math.MaxInt64-1))
+	}
+	var uid uint64
+	err = conn.QueryRow("insert into #big (v) values (@p1); select convert(bigint, SCOPE_IDENTITY())", int64(-1)).Scan(&uid)
+	if err != nil {
+		t.Fatal("insert failed:", err)
+	}
+	if uid != math.MaxInt64 {
+		t.Errorf("SCOPE_IDENTITY returned %d, want %d", uid, uint64(math.MaxInt64))
+	}
+
+	rows, err := conn.Query("select id, v from #big order by id")
+	if err != nil {
+		t.Fatal("select failed:", err)
+	}
+	defer rows.Close()
+	var got [][2]int64
+	for rows.Next() {
+		var r [2]int64
+		if err = rows.Scan(&r[0], &r[1]); err != nil {
+			t.Fatal("scan failed:", err)
+		}
+		got = append(got, r)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int64{{math.MaxInt64 - 1, math.MinInt64}, {math.MaxInt64, -1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %v, want %v", got, want)
+	}
+
+	// a negative bigint does not fit an unsigned destination
+	var neg uint64
+	if err = conn.QueryRow("select v from #big where v = -1").Scan(&neg); err == nil {
+		t.Errorf("scanning -1 into a uint64 returned %d without an error", neg)
+	}
+}
+
 func TestExec(t *testing.T) {
 	conn, logger := open(t)
 	defer conn.Close()
@@ -955,6 +1130,162 @@ func TestAffectedRows(t *testing.T) {
 	}
 }
 
+func TestAffectedRowsSelectIntoAndMerge(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal("Begin tran failed", err)
+	}
+	defer tx.Rollback()
+
+	tests := []struct {
+		name  string
+		query string
+		want  int64
+	}{
+		{"select into", "select n into #src from (values (1), (2), (3)) v(n)", 3},
+		{"select into with assignment", "declare @c int; select @c = count(*) from #src; select n into #dst from #src where n < 3", 2},
+		{"merge", `merge #dst as t using #src as s on t.n = s.n
+			when matched then update set n = s.n
+			when not matched then insert (n) values (s.n);`, 3},
+		{"multiple statements", "update #src set n = n; delete from #dst where n = 1", 4},
+	}
+	for _, tt := range tests {
+		res, err := tx.Exec(tt.query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", tt.name, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			t.Fatalf("%s: rows affected failed: %v", tt.name, err)
+		}
+		if n != tt.want {
+			t.Errorf("%s: expected %d rows affected, got %d", tt.name, tt.want, n)
+		}
+	}
+}
+
+func TestMergeOutput(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal("Begin tran failed", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("create table #stock (item nvarchar(10) primary key, qty int); insert into #stock values ('apple', 1), ('pear', 2)")
+	if err != nil {
+		t.Fatal("create table failed", err)
+	}
+	rows, err := tx.Query(`merge #stock as t
+		using (values (@p1, @p2), (@p3, @p4)) as s (item, qty) on t.item = s.item
+		when matched then update set qty = t.qty + s.qty
+		when not matched by target then insert (item, qty) values (s.item, s.qty)
+		when not matched by source then delete
+		output $action, coalesce(inserted.item, deleted.item), inserted.qty;`, "apple", 10, "plum", 3)
+	if err != nil {
+		t.Fatal("merge failed", err)
+	}
+	got := map[string]string{}
+	for rows.Next() {
+		var action, item string
+		var qty sql.NullInt64
+		if err = rows.Scan(&action, &item, &qty); err != nil {
+			t.Fatal("scan failed", err)
+		}
+		got[item] = fmt.Sprintf("%s %v", action, qty)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal("rows failed", err)
+	}
+	rows.Close()
+	want := map[string]string{
+		"apple": "UPDATE {11 true}",
+		"plum":  "INSERT {3 true}",
+		"pear":  "DELETE {0 false}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	var count int
+	if err = tx.QueryRow("select count(*) from #stock").Scan(&count); err != nil {
+		t.Fatal("count failed", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows after merge, got %d", count)
+	}
+}
+
+func TestDMLOutput(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal("Begin tran failed", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("create table #orders (id int identity(10, 5) primary key, item nvarchar(10))")
+	if err != nil {
+		t.Fatal("create table failed", err)
+	}
+
+	var id int64
+	if err = tx.QueryRow("insert into #orders (item) output inserted.id values (@p1)", "apple").Scan(&id); err != nil {
+		t.Fatal("insert failed", err)
+	}
+	if id != 10 {
+		t.Errorf("expected generated id 10, got %d", id)
+	}
+
+	rows, err := tx.Query("insert into #orders (item) output inserted.id, inserted.item values (@p1), (@p2)", "pear", "plum")
+	if err != nil {
+		t.Fatal("multi-row insert failed", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var item string
+		if err = rows.Scan(&id, &item); err != nil {
+			t.Fatal("scan failed", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal("rows failed", err)
+	}
+	rows.Close()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if !reflect.DeepEqual(ids, []int64{15, 20}) {
+		t.Errorf("expected ids [15 20], got %v", ids)
+	}
+
+	var before, after string
+	err = tx.QueryRow("update #orders set item = @p1 output deleted.item, inserted.item where id = @p2", "fig", 15).Scan(&before, &after)
+	if err != nil {
+		t.Fatal("update failed", err)
+	}
+	if before != "pear" || after != "fig" {
+		t.Errorf("expected pear -> fig, got %s -> %s", before, after)
+	}
+
+	var deleted int64
+	if err = tx.QueryRow("delete from #orders output deleted.id where item = @p1", "plum").Scan(&deleted); err != nil {
+		t.Fatal("delete failed", err)
+	}
+	if deleted != 20 {
+		t.Errorf("expected deleted id 20, got %d", deleted)
+	}
+}
+
 func queryParamRoundTrip(db *sql.DB, param interface{}, dest interface{}) {
 	err := db.QueryRow("select @p1", param).Scan(dest)
 	if err != nil {
@@ -3216,3 +3547,220 @@ func runRollbackCancellationTest(t *testing.T, attempt int) {
 		t.Fatalf("Expected no outstanding transactions; got %d; failed in %d attempts", *retval, attempt)
 	}
 }
+
+func TestLargeVarBinaryParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	data := make([]byte, 1<<20)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	_, err := conn.Exec("DROP TABLE IF EXISTS dbo.large_varbinary_param_test; CREATE TABLE dbo.large_varbinary_param_test (data varbinary(max))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec("DROP TABLE IF EXISTS dbo.large_varbinary_param_test")
+
+	if _, err = conn.Exec("INSERT INTO dbo.large_varbinary_param_test (data) VALUES (@p1)", data); err != nil {
+		t.Fatal("insert failed:", err)
+	}
+	var got []byte
+	if err = conn.QueryRow("SELECT data FROM dbo.large_varbinary_param_test").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read back %d bytes that differ from the %d bytes inserted", len(got), len(data))
+	}
+}
+
+func TestLongNVarCharParam(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	long := strings.Repeat("éabcdefghi", 1000)
+	_, err := conn.Exec("DROP TABLE IF EXISTS dbo.long_nvarchar_param_test; CREATE TABLE dbo.long_nvarchar_param_test (s nvarchar(max), n nvarchar(max))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec("DROP TABLE IF EXISTS dbo.long_nvarchar_param_test")
+
+	if _, err = conn.Exec("INSERT INTO dbo.long_nvarchar_param_test (s, n) VALUES (@p1, @p2)", long, NChar(long)); err != nil {
+		t.Fatal("insert failed:", err)
+	}
+	var s, n string
+	var length int
+	if err = conn.QueryRow("SELECT s, n, LEN(s) FROM dbo.long_nvarchar_param_test").Scan(&s, &n, &length); err != nil {
+		t.Fatal(err)
+	}
+	if length != 10000 {
+		t.Errorf("server stored %d characters, expected 10000", length)
+	}
+	if s != long || n != long {
+		t.Errorf("read back strings of %d and %d characters that differ from the one inserted", len([]rune(s)), len([]rune(n)))
+	}
+}
+
+func TestLegacyLOBColumns(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec("DROP TABLE IF EXISTS dbo.legacy_lob_test; CREATE TABLE dbo.legacy_lob_test (id int, t text, nt ntext, img image)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Exec("DROP TABLE IF EXISTS dbo.legacy_lob_test")
+
+	// Values larger than a packet are split across several of them.
+	text := strings.Repeat("legacy text ", 5000)
+	ntext := strings.Repeat("ntext é ", 5000)
+	image := make([]byte, 100000)
+	for i := range image {
+		image[i] = byte(i)
+	}
+	// Legacy LOB columns are best written with the MAX types.
+	_, err = conn.Exec(`INSERT INTO dbo.legacy_lob_test (id, t, nt, img) VALUES
+		(1, CAST(@p1 AS varchar(max)), @p2, @p3), (2, NULL, NULL, NULL)`,
+		VarCharMax(text), NVarCharMax(ntext), image)
+	if err != nil {
+		t.Fatal("insert failed:", err)
+	}
+
+	rows, err := conn.Query("SELECT t, nt, img FROM dbo.legacy_lob_test ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal("no rows")
+	}
+	var gotText, gotNText string
+	var gotImage []byte
+	if err = rows.Scan(&gotText, &gotNText, &gotImage); err != nil {
+		t.Fatal(err)
+	}
+	if gotText != text {
+		t.Errorf("text column: read %d characters, expected %d", len(gotText), len(text))
+	}
+	if gotNText != ntext {
+		t.Errorf("ntext column: read %d characters, expected %d", len([]rune(gotNText)), len([]rune(ntext)))
+	}
+	if !bytes.Equal(gotImage, image) {
+		t.Errorf("image column: read %d bytes, expected %d", len(gotImage), len(image))
+	}
+	if !rows.Next() {
+		t.Fatal("missing NULL row")
+	}
+	var nullText, nullNText sql.NullString
+	var nullImage []byte
+	if err = rows.Scan(&nullText, &nullNText, &nullImage); err != nil {
+		t.Fatal(err)
+	}
+	if nullText.Valid || nullNText.Valid || nullImage != nil {
+		t.Errorf("expected NULLs, got %v %v %v", nullText, nullNText, nullImage)
+	}
+}
+
+func TestPreparedStatementAfterAlterTable(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "CREATE TABLE #altered (id int NOT NULL, old_name nvarchar(10) NULL); INSERT INTO #altered (id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	query, err := conn.PrepareContext(ctx, "SELECT * FROM #altered WHERE id = @p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer query.Close()
+	insert, err := conn.PrepareContext(ctx, "INSERT INTO #altered (id) VALUES (@p1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer insert.Close()
+	columns := func() []string {
+		rows, err := query.QueryContext(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cols
+	}
+	if cols := columns(); len(cols) != 2 {
+		t.Fatalf("columns before ALTER TABLE: %v", cols)
+	}
+	if _, err = insert.ExecContext(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// the statements are compiled again by the server, there is no handle
+	// of the driver to invalidate
+	if _, err = conn.ExecContext(ctx, "ALTER TABLE #altered DROP COLUMN old_name; ALTER TABLE #altered ADD name nvarchar(10) NULL, created int NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if cols := columns(); strings.Join(cols, ",") != "id,name,created" {
+		t.Fatalf("columns after ALTER TABLE: %v", cols)
+	}
+	if _, err = insert.ExecContext(ctx, 3); err != nil {
+		t.Fatalf("Exec after ALTER TABLE: %v", err)
+	}
+	var n int
+	if err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM #altered").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 rows, got %d", n)
+	}
+}
+
+func TestSurrogatePairRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	// U+1F600 and U+20BB7 are outside the BMP, sent as surrogate pairs
+	value := "a\U0001F600\U00020BB7z"
+	_, err := conn.Exec("create table #surrogates (s nvarchar(6), m nvarchar(max))")
+	if err != nil {
+		t.Fatal("create table failed:", err)
+	}
+	_, err = conn.Exec("insert into #surrogates (s, m) values (@p1, @p2)", value, NVarCharMax(strings.Repeat(value, 2000)))
+	if err != nil {
+		t.Fatal("insert failed:", err)
+	}
+
+	var s, m string
+	var length int
+	var stored []byte
+	err = conn.QueryRow("select s, m, datalength(s), cast(s as varbinary(12)) from #surrogates").Scan(&s, &m, &length, &stored)
+	if err != nil {
+		t.Fatal("select failed:", err)
+	}
+	if s != value {
+		t.Errorf("nvarchar read back as %q, want %q", s, value)
+	}
+	if m != strings.Repeat(value, 2000) {
+		t.Error("nvarchar(max) did not round trip")
+	}
+	// 6 UTF-16 code units: a, two surrogate pairs and z
+	if length != 12 {
+		t.Errorf("datalength is %d, want 12", length)
+	}
+	if !bytes.Equal(stored, str2ucs2(value)) {
+		t.Errorf("stored bytes are % x, want % x", stored, str2ucs2(value))
+	}
+}
@@ -0,0 +1,137 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryNotification describes a query notification subscription. When the
+// results of a subscribed query change, SQL Server sends a message to the
+// Service Broker service named by Service. ListenQueryNotifications reads
+// these messages from the queue of the service.
+//
+// Subscribed queries must meet the requirements of indexed views, such as
+// using two-part table names and listing columns instead of *. Queries that
+// cannot be subscribed produce a notification with Info "invalid" or
+// "query" right away instead of an error.
+type QueryNotification struct {
+	// Message is returned in every notification fired for the subscription
+	// and identifies it to the listener.
+	Message string
+	// Service is the Service Broker service receiving the notifications.
+	Service string
+	// Database is the database of Service. Defaults to the current database.
+	Database string
+	// Timeout is how long the subscription stays active on the server. A
+	// notification with Info "timeout" is sent when it expires. Zero uses
+	// the server default of 432000 seconds.
+	Timeout time.Duration
+}
+
+func (n QueryNotification) options() string {
+	options := "service=" + n.Service
+	if n.Database != "" {
+		options += ";local database=" + n.Database
+	}
+	return options
+}
+
+type queryNotificationKey struct{}
+
+// WithQueryNotification returns a copy of ctx that subscribes queries run
+// with it to the query notification n. Each subscription fires once; run
+// the query again with the subscription to be notified of later changes.
+func WithQueryNotification(ctx context.Context, n QueryNotification) context.Context {
+	var sub *queryNotifSub
+	if n.Timeout > 0 {
+		sub = newQueryNotifSub(n.Message, n.options(), n.Timeout)
+	} else {
+		sub = &queryNotifSub{msgText: n.Message, options: n.options()}
+	}
+	return context.WithValue(ctx, queryNotificationKey{}, sub)
+}
+
+func queryNotificationFromContext(ctx context.Context) *queryNotifSub {
+	sub, _ := ctx.Value(queryNotificationKey{}).(*queryNotifSub)
+	return sub
+}
+
+// QueryNotificationEvent is a query notification received from a Service
+// Broker queue.
+type QueryNotificationEvent struct {
+	// Message is the Message of the QueryNotification that fired.
+	Message string
+	// Type is "change" when the results changed and "subscribe" when the
+	// subscription failed.
+	Type string
+	// Source is what caused the notification, such as "data", "timeout",
+	// "object" or "statement".
+	Source string
+	// Info gives details, such as "insert", "update", "delete", "truncate"
+	// or, for failed subscriptions, the reason such as "invalid".
+	Info string
+}
+
+// queryNotificationMessageType is the Service Broker message type of query notifications.
+const queryNotificationMessageType = "http://schemas.microsoft.com/SQL/Notifications/QueryNotification"
+
+type queryNotificationXML struct {
+	XMLName xml.Name `xml:"http://schemas.microsoft.com/SQL/Notifications/QueryNotification QueryNotification"`
+	Type    string   `xml:"type,attr"`
+	Source  string   `xml:"source,attr"`
+	Info    string   `xml:"info,attr"`
+	Message string   `xml:"http://schemas.microsoft.com/SQL/Notifications/QueryNotification Message"`
+}
+
+func parseQueryNotification(body string) (QueryNotificationEvent, error) {
+	var n queryNotificationXML
+	if err := xml.NewDecoder(strings.NewReader(body)).Decode(&n); err != nil {
+		return QueryNotificationEvent{}, fmt.Errorf("mssql: invalid query notification: %w", err)
+	}
+	return QueryNotificationEvent{Message: n.Message, Type: n.Type, Source: n.Source, Info: n.Info}, nil
+}
+
+// queryNotificationWait is how long a single RECEIVE waits for a message.
+const queryNotificationWait = 30 * time.Second
+
+// ListenQueryNotifications receives query notifications from the Service
+// Broker queue of a notification service and calls handler for each of
+// them, ending the conversation of every message received. queue may be
+// schema qualified and is quoted by ListenQueryNotifications.
+//
+// It holds a connection of db while it runs and returns when ctx is done,
+// with the error of ctx, or when receiving fails.
+func ListenQueryNotifications(ctx context.Context, db *sql.DB, queue string, handler func(QueryNotificationEvent)) error {
+	if queue == "" {
+		return errors.New("mssql: query notifications require a queue")
+	}
+	query := fmt.Sprintf(`DECLARE @h uniqueidentifier, @t sysname, @b varbinary(max);
+WAITFOR (RECEIVE TOP(1) @h = conversation_handle, @t = message_type_name, @b = message_body FROM %s), TIMEOUT @wait;
+IF @h IS NOT NULL END CONVERSATION @h;
+SELECT @t, CAST(CAST(@b AS xml) AS nvarchar(max));`, quoteMultipartName(queue))
+	wait := sql.Named("wait", int32(queryNotificationWait/time.Millisecond))
+	for {
+		var msgType, body sql.NullString
+		err := db.QueryRowContext(ctx, query, wait).Scan(&msgType, &body)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if msgType.String != queryNotificationMessageType {
+			// Timed out, or an end dialog or error message.
+			continue
+		}
+		ev, err := parseQueryNotification(body.String)
+		if err != nil {
+			return err
+		}
+		handler(ev)
+	}
+}
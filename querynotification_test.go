@@ -0,0 +1,117 @@
+package mssql
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryNotification(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, queryNotificationFromContext(ctx))
+
+	ctx = WithQueryNotification(ctx, QueryNotification{
+		Message:  "orders",
+		Service:  "OrderCache",
+		Database: "shop",
+		Timeout:  90 * time.Second,
+	})
+	sub := queryNotificationFromContext(ctx)
+	require.NotNil(t, sub)
+	assert.Equal(t, "orders", sub.msgText)
+	assert.Equal(t, "service=OrderCache;local database=shop", sub.options)
+	assert.Equal(t, uint32(90000), sub.timeout)
+
+	sub = queryNotificationFromContext(WithQueryNotification(context.Background(), QueryNotification{Service: "s"}))
+	require.NotNil(t, sub)
+	assert.Equal(t, "service=s", sub.options)
+	assert.Equal(t, uint32(0), sub.timeout, "zero uses the server default")
+}
+
+func TestQueryNotifHdrPack(t *testing.T) {
+	b := queryNotifHdr{"ab", "service=s", 1000}.pack()
+	id := str2ucs2("ab")
+	opts := str2ucs2("service=s")
+	require.Len(t, b, 2+len(id)+2+len(opts)+4)
+	assert.Equal(t, uint16(len(id)), binary.LittleEndian.Uint16(b))
+	assert.Equal(t, id, b[2:2+len(id)])
+	b = b[2+len(id):]
+	assert.Equal(t, uint16(len(opts)), binary.LittleEndian.Uint16(b))
+	assert.Equal(t, opts, b[2:2+len(opts)])
+	assert.Equal(t, uint32(1000), binary.LittleEndian.Uint32(b[2+len(opts):]))
+}
+
+func TestParseQueryNotification(t *testing.T) {
+	body := `<qn:QueryNotification xmlns:qn="http://schemas.microsoft.com/SQL/Notifications/QueryNotification" id="2" type="change" source="data" info="insert" database_id="5" sid="0x01"><qn:Message>orders</qn:Message></qn:QueryNotification>`
+	ev, err := parseQueryNotification(body)
+	require.NoError(t, err)
+	assert.Equal(t, QueryNotificationEvent{Message: "orders", Type: "change", Source: "data", Info: "insert"}, ev)
+
+	_, err = parseQueryNotification("<other/>")
+	assert.Error(t, err)
+}
+
+func TestQueryNotificationIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	var brokerEnabled bool
+	if err := conn.QueryRow("SELECT is_broker_enabled FROM sys.databases WHERE database_id = DB_ID()").Scan(&brokerEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if !brokerEnabled {
+		t.Skip("Service Broker is not enabled in the test database")
+	}
+
+	setup := []string{
+		"IF OBJECT_ID('dbo.qn_test', 'U') IS NOT NULL DROP TABLE dbo.qn_test",
+		"IF EXISTS (SELECT 1 FROM sys.services WHERE name = 'qn_test_service') DROP SERVICE qn_test_service",
+		"IF OBJECT_ID('dbo.qn_test_queue', 'SQ') IS NOT NULL DROP QUEUE dbo.qn_test_queue",
+		"CREATE TABLE dbo.qn_test (id int NOT NULL PRIMARY KEY)",
+		"CREATE QUEUE dbo.qn_test_queue",
+		"CREATE SERVICE qn_test_service ON QUEUE dbo.qn_test_queue ([http://schemas.microsoft.com/SQL/Notifications/PostQueryNotification])",
+	}
+	for _, q := range setup {
+		if _, err := conn.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	defer func() {
+		_, _ = conn.Exec("DROP SERVICE qn_test_service; DROP QUEUE dbo.qn_test_queue; DROP TABLE dbo.qn_test")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	qctx := WithQueryNotification(ctx, QueryNotification{Message: "qn_test", Service: "qn_test_service", Timeout: time.Minute})
+	rows, err := conn.QueryContext(qctx, "SELECT id FROM dbo.qn_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	if _, err = conn.ExecContext(ctx, "INSERT INTO dbo.qn_test (id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan QueryNotificationEvent, 1)
+	lctx, lcancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- ListenQueryNotifications(lctx, conn, "dbo.qn_test_queue", func(ev QueryNotificationEvent) {
+			events <- ev
+			lcancel()
+		})
+	}()
+	select {
+	case ev := <-events:
+		assert.Equal(t, QueryNotificationEvent{Message: "qn_test", Type: "change", Source: "data", Info: "insert"}, ev)
+	case <-ctx.Done():
+		t.Fatal("no query notification received")
+	}
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
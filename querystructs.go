@@ -0,0 +1,111 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// QueryStructs runs query on db and returns the rows of its first result
+// set, each scanned into a T, which must be a struct.
+//
+// Columns are matched to exported fields by name, ignoring case. A field is
+// named by its db tag, or by its name when it has none, as for
+// InsertValues, and fields tagged db:"-" are skipped. Every column must
+// have a field, and every field a column: an error names the first that
+// does not, before any row is read. NULL scans into pointer and sql.Null
+// fields; scanning it into other types fails as it does with Rows.Scan.
+//
+//	type user struct {
+//		ID    int            `db:"id"`
+//		Name  string         `db:"name"`
+//		Email sql.NullString `db:"email"`
+//	}
+//	users, err := mssql.QueryStructs[user](ctx, db, "SELECT id, name, email FROM dbo.users WHERE active = @p1", true)
+func QueryStructs[T any](ctx context.Context, db queryer, query string, args ...interface{}) ([]T, error) {
+	if err := checkStructType[T](); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanStructs[T](rows)
+}
+
+func checkStructType[T any]() error {
+	if rowType := reflect.TypeOf((*T)(nil)).Elem(); rowType.Kind() != reflect.Struct {
+		return fmt.Errorf("mssql: scanning rows into structs requires a struct type, got %s", rowType)
+	}
+	return nil
+}
+
+// scanStructs scans the remaining rows of the current result set of rows
+// into a slice of T.
+func scanStructs[T any](rows *sql.Rows) ([]T, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	fields, err := structFieldsOfColumns(reflect.TypeOf((*T)(nil)).Elem(), cols)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []T
+	dest := make([]interface{}, len(cols))
+	for rows.Next() {
+		var row T
+		v := reflect.ValueOf(&row).Elem()
+		for i, f := range fields {
+			dest[i] = v.Field(f).Addr().Interface()
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// structFieldsOfColumns returns the index of the field of the struct type t
+// each of cols scans into.
+func structFieldsOfColumns(t reflect.Type, cols []string) ([]int, error) {
+	names, indexes := insertValuesColumns(t)
+	fields := make([]int, len(cols))
+	mapped := make([]bool, len(names))
+	for i, col := range cols {
+		found := -1
+		for j, name := range names {
+			if strings.EqualFold(col, name) {
+				found = j
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("mssql: column %q has no field in %s", col, t)
+		}
+		if mapped[found] {
+			return nil, fmt.Errorf("mssql: column %q appears more than once in the results", col)
+		}
+		mapped[found] = true
+		fields[i] = indexes[found]
+	}
+	for j, name := range names {
+		if !mapped[j] {
+			return nil, fmt.Errorf("mssql: field %s of %s has no column %q in the results", t.Field(indexes[j]).Name, t, name)
+		}
+	}
+	return fields, nil
+}
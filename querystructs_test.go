@@ -0,0 +1,72 @@
+package mssql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type queryStructsRow struct {
+	ID      int            `db:"id"`
+	Name    *string        `db:"name"`
+	Email   sql.NullString `db:"email"`
+	Comment string
+	Ignored string `db:"-"`
+	hidden  string
+}
+
+func TestStructFieldsOfColumns(t *testing.T) {
+	rowType := reflect.TypeOf(queryStructsRow{})
+	fields, err := structFieldsOfColumns(rowType, []string{"COMMENT", "Email", "id", "name"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2, 0, 1}, fields, "columns are matched ignoring case")
+
+	_, err = structFieldsOfColumns(rowType, []string{"id", "name", "email", "comment", "extra"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "extra" has no field`)
+
+	_, err = structFieldsOfColumns(rowType, []string{"id", "name", "email"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `field Comment of mssql.queryStructsRow has no column "Comment"`)
+
+	_, err = structFieldsOfColumns(rowType, []string{"id", "ID", "name", "email", "comment"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "ID" appears more than once`)
+
+	_, err = structFieldsOfColumns(rowType, []string{"Ignored"})
+	require.Error(t, err, "fields tagged db:\"-\" are not mapped")
+}
+
+func TestQueryStructs(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	rows, err := QueryStructs[queryStructsRow](ctx, conn, `SELECT * FROM (VALUES
+		(1, N'one', N'one@example.com', N'first'),
+		(2, NULL, NULL, N'second')) AS v(id, NAME, Email, comment) WHERE id >= @p1 ORDER BY id`, 1)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NotNil(t, rows[0].Name)
+	assert.Equal(t, "one", *rows[0].Name)
+	assert.Equal(t, sql.NullString{String: "one@example.com", Valid: true}, rows[0].Email)
+	assert.Equal(t, "first", rows[0].Comment)
+	assert.Equal(t, 2, rows[1].ID)
+	assert.Nil(t, rows[1].Name)
+	assert.False(t, rows[1].Email.Valid)
+
+	_, err = QueryStructs[queryStructsRow](ctx, conn, "SELECT 1 AS id, NULL AS name, NULL AS email, NULL AS comment")
+	require.Error(t, err, "NULL does not scan into a string field")
+
+	_, err = QueryStructs[queryStructsRow](ctx, conn, "SELECT 1 AS id, 2 AS unknown")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "unknown" has no field`)
+
+	_, err = QueryStructs[int](ctx, conn, "SELECT 1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires a struct type, got int")
+}
@@ -0,0 +1,100 @@
+package mssql
+
+import (
+	"context"
+	"strings"
+)
+
+// QueryTagPosition selects where a QueryTag comment is placed in the query text.
+type QueryTagPosition int
+
+const (
+	// QueryTagLeading places the comment before the query text.
+	QueryTagLeading QueryTagPosition = iota
+	// QueryTagTrailing places the comment on a new line after the query text.
+	QueryTagTrailing
+)
+
+// QueryTag adds a comment such as /* app=myservice trace=0af7651916cd43dd */
+// to the text of every query sent on connections created by a Connector.
+// The comment shows up in Query Store, extended events and DMVs such as
+// sys.dm_exec_requests, making it possible to correlate server side activity
+// with application traces.
+//
+// The comment is part of the query text SQL Server uses to look up cached
+// plans. Ad hoc batches and parameterized queries whose tags differ are
+// compiled and cached separately, so a tag that changes on every call (such
+// as a trace id) prevents plan reuse and grows the plan cache. Keep tag
+// values low-cardinality for hot queries, or set Format to return an empty
+// string for them. QueryTagTrailing keeps the statement at the start of the
+// text, which helps tools that truncate the query text, but it has the same
+// plan cache effect as QueryTagLeading.
+//
+// Stored procedure calls made by name are not tagged, since the procedure
+// name is not query text.
+type QueryTag struct {
+	// Format returns the text to place inside the comment for the query
+	// being sent with ctx. No comment is added when it returns an empty string.
+	Format func(ctx context.Context) string
+	// Position selects where the comment is placed. Defaults to QueryTagLeading.
+	Position QueryTagPosition
+}
+
+// NewTraceQueryTag returns a QueryTag producing comments of the form
+// /* app=<app> trace=<id> */, where the trace id is read from the query
+// context with TraceIDFromContext. The trace element is omitted when the
+// context carries no trace id.
+func NewTraceQueryTag(app string) *QueryTag {
+	return &QueryTag{
+		Format: func(ctx context.Context) string {
+			var b strings.Builder
+			if app != "" {
+				b.WriteString("app=")
+				b.WriteString(app)
+			}
+			if id, ok := TraceIDFromContext(ctx); ok {
+				if b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString("trace=")
+				b.WriteString(id)
+			}
+			return b.String()
+		},
+	}
+}
+
+type traceIDKey struct{}
+
+// WithTraceID returns a copy of ctx that carries the given trace id.
+// It is used by the QueryTag returned from NewTraceQueryTag.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace id stored in ctx by WithTraceID.
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(traceIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// apply returns query with the tag comment added.
+func (q *QueryTag) apply(ctx context.Context, query string) string {
+	if q == nil || q.Format == nil {
+		return query
+	}
+	tag := q.Format(ctx)
+	if tag == "" {
+		return query
+	}
+	// T-SQL block comments nest, so both the opening and closing
+	// sequences have to be broken up to keep the tag inside its comment.
+	tag = strings.ReplaceAll(tag, "/*", "/ *")
+	tag = strings.ReplaceAll(tag, "*/", "* /")
+	if q.Position == QueryTagTrailing {
+		// Start on a new line so a trailing -- comment in the query
+		// does not swallow the tag.
+		return query + "\n/* " + tag + " */"
+	}
+	return "/* " + tag + " */ " + query
+}
@@ -0,0 +1,108 @@
+package mssql
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTagPlacement(t *testing.T) {
+	ctx := WithTraceID(context.Background(), "abc123")
+	tag := NewTraceQueryTag("myservice")
+
+	assert.Equal(t, "/* app=myservice trace=abc123 */ select 1", tag.apply(ctx, "select 1"))
+
+	tag.Position = QueryTagTrailing
+	assert.Equal(t, "select 1 -- note\n/* app=myservice trace=abc123 */", tag.apply(ctx, "select 1 -- note"))
+}
+
+func TestQueryTagWithoutTraceID(t *testing.T) {
+	tag := NewTraceQueryTag("myservice")
+	assert.Equal(t, "/* app=myservice */ select 1", tag.apply(context.Background(), "select 1"))
+
+	tag = NewTraceQueryTag("")
+	assert.Equal(t, "select 1", tag.apply(context.Background(), "select 1"), "empty tag must leave the query unchanged")
+}
+
+func TestQueryTagNilAndEmptyFormat(t *testing.T) {
+	var tag *QueryTag
+	assert.Equal(t, "select 1", tag.apply(context.Background(), "select 1"))
+	assert.Equal(t, "select 1", (&QueryTag{}).apply(context.Background(), "select 1"))
+}
+
+func TestQueryTagEscapesCommentDelimiters(t *testing.T) {
+	tag := &QueryTag{Format: func(context.Context) string { return "x */ drop table t /* y" }}
+	assert.Equal(t, "/* x * / drop table t / * y */ select 1", tag.apply(context.Background(), "select 1"))
+}
+
+func TestSendQueryAppliesQueryTag(t *testing.T) {
+	memBuf := new(MockTransport)
+	conn := &Conn{
+		connector: &Connector{
+			params: msdsn.Config{},
+			QueryTag: &QueryTag{Format: func(ctx context.Context) string {
+				id, _ := TraceIDFromContext(ctx)
+				return "trace=" + id
+			}},
+		},
+		sess:           &tdsSession{buf: newTdsBuffer(1024, memBuf), logger: optionalLogger{}},
+		connectionGood: true,
+	}
+	ctx := WithTraceID(context.Background(), "t1")
+
+	stmt := &Stmt{c: conn, query: "select 1"}
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	assert.Equal(t, "/* trace=t1 */ select 1", sqlBatchText(t, memBuf.Bytes()))
+
+	memBuf.Reset()
+	stmt = &Stmt{c: conn, query: "sp_who"}
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	assert.NotContains(t, string(memBuf.Bytes()), string(str2ucs2("trace=t1")), "stored procedure names must not be tagged")
+}
+
+// sqlBatchText extracts the query text from a single SQLBatch packet.
+func sqlBatchText(t *testing.T, packet []byte) string {
+	t.Helper()
+	require.Greater(t, len(packet), 12)
+	require.Equal(t, byte(packSQLBatch), packet[0])
+	body := packet[8:]
+	headersLen := binary.LittleEndian.Uint32(body)
+	text, err := ucs22str(body[headersLen:])
+	require.NoError(t, err)
+	return text
+}
+
+func TestSendQueryWithSQLBatch(t *testing.T) {
+	memBuf := new(MockTransport)
+	conn := &Conn{
+		connector:      &Connector{},
+		sess:           &tdsSession{buf: newTdsBuffer(1024, memBuf), logger: optionalLogger{}},
+		connectionGood: true,
+	}
+	ctx := WithSQLBatch(context.Background())
+
+	// a stored procedure name is called with an RPC request without the option
+	stmt := &Stmt{c: conn, query: "sp_who"}
+	require.NoError(t, stmt.sendQuery(context.Background(), nil))
+	assert.Equal(t, byte(packRPCRequest), memBuf.Bytes()[0])
+	memBuf.Reset()
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	assert.Equal(t, "sp_who", sqlBatchText(t, memBuf.Bytes()))
+
+	memBuf.Reset()
+	stmt = &Stmt{c: conn, query: "select 1"}
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	assert.Equal(t, "select 1", sqlBatchText(t, memBuf.Bytes()))
+
+	memBuf.Reset()
+	stmt = &Stmt{c: conn, query: "select @p1"}
+	err := stmt.sendQuery(ctx, []namedValue{{Ordinal: 1, Value: int64(1)}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WithSQLBatch")
+	assert.Empty(t, memBuf.Bytes(), "nothing is sent")
+	assert.True(t, conn.connectionGood)
+}
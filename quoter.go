@@ -14,6 +14,42 @@ func (TSQLQuoter) ID(name string) string {
 	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
 }
 
+// QuoteIdentifier returns name as a bracket-quoted identifier safe to embed
+// in SQL text, such as [my]]table] for my]table, with each ] doubled. It
+// quotes one part: dots are part of the name, so quote each part of a
+// multi-part name on its own, as QuoteName does.
+//
+//	query := "SELECT " + mssql.QuoteIdentifier(column) + " FROM " + mssql.QuoteName(schema, table)
+func QuoteIdentifier(name string) string {
+	return TSQLQuoter{}.ID(name)
+}
+
+// QuoteName returns the two-part name of an object in schema, each part
+// quoted as by QuoteIdentifier, such as [sales].[order details]. An empty
+// schema returns the quoted object name alone, which the server looks up in
+// the default schema of the user.
+func QuoteName(schema, object string) string {
+	if schema == "" {
+		return QuoteIdentifier(object)
+	}
+	return QuoteIdentifier(schema) + "." + QuoteIdentifier(object)
+}
+
+// QuoteLiteral returns s as an nvarchar string literal safe to embed in SQL
+// text, such as N'it''s' for it's, with each ' doubled. The N prefix keeps
+// characters outside the code page of the database.
+//
+// Pass values as query parameters whenever possible: they are never parsed
+// as SQL, and the server reuses one plan for every value. QuoteLiteral is a
+// last resort for text parameters cannot reach, such as the statements of
+// a dynamic SQL string run with EXEC, or CREATE and ALTER statements, which
+// take no variables:
+//
+//	_, err := db.Exec("EXEC(" + mssql.QuoteLiteral("ALTER LOGIN app WITH PASSWORD = "+mssql.QuoteLiteral(password)) + ")")
+func QuoteLiteral(s string) string {
+	return "N" + sqlString(s)
+}
+
 // Value quotes database values such as string or []byte types as strings
 // that are suitable and safe to embed in SQL text. The returned value
 // of a string will include all surrounding quotes.
@@ -159,3 +159,79 @@ func TestTSQLQuoter_Value_Panic(t *testing.T) {
 		})
 	}
 }
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"orders", "[orders]"},
+		{"my]table", "[my]]table]"},
+		{"[orders]", "[[orders]]]"},
+		{"dbo.orders", "[dbo.orders]"},
+		{"a]; DROP TABLE t; --", "[a]]; DROP TABLE t; --]"},
+		{"commandes été", "[commandes été]"},
+		{"注文", "[注文]"},
+		{"", "[]"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, QuoteIdentifier(tt.input), tt.input)
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	assert.Equal(t, "[sales].[order details]", QuoteName("sales", "order details"))
+	assert.Equal(t, "[my.schema].[my]]table]", QuoteName("my.schema", "my]table"))
+	assert.Equal(t, "[ventes].[注文]", QuoteName("ventes", "注文"))
+	assert.Equal(t, "[orders]", QuoteName("", "orders"), "no schema")
+}
+
+func TestQuoteIdentifierMatchesQuotename(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, name := range []string{"orders", "my]table", "[x]", "a.b", "注文 été"} {
+		var quoted string
+		err := conn.QueryRow("select quotename(@p1)", name).Scan(&quoted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, quoted, QuoteIdentifier(name), name)
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abc", "N'abc'"},
+		{"it's", "N'it''s'"},
+		{"''", "N''''''"},
+		{"'; DROP TABLE t; --", "N'''; DROP TABLE t; --'"},
+		{"line 1\nline 2\r\n", "N'line 1\nline 2\r\n'"},
+		{"注文 été ✓", "N'注文 été ✓'"},
+		{"", "N''"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, QuoteLiteral(tt.input), tt.input)
+	}
+}
+
+func TestQuoteLiteralRoundTrip(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	for _, s := range []string{"it's", "a'';--", "line 1\nline 2", "注文 été ✓ 😀"} {
+		var got string
+		// the inner literal is parsed twice, by the batch and by sp_executesql
+		err := conn.QueryRow("declare @r nvarchar(100); exec sp_executesql " +
+			QuoteLiteral("select @r = "+QuoteLiteral(s)) + ", N'@r nvarchar(100) output', @r output; select @r").Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s, got)
+	}
+}
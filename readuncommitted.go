@@ -0,0 +1,36 @@
+package mssql
+
+import "context"
+
+type readUncommittedKey struct{}
+
+// readUncommittedSQL is prepended to the queries run with WithReadUncommitted.
+const readUncommittedSQL = "SET TRANSACTION ISOLATION LEVEL READ UNCOMMITTED;\n"
+
+// WithReadUncommitted returns a copy of ctx whose queries run at the READ
+// UNCOMMITTED isolation level, as if every table they read had the NOLOCK
+// hint, without editing their text. It suits monitoring queries reading
+// tables busy with writes, which do not wait for the locks of the writers.
+//
+// The query is sent with sp_executesql, also without arguments, after a SET
+// TRANSACTION ISOLATION LEVEL statement. The server restores the isolation
+// level of the session when sp_executesql returns, so the following
+// queries, and a transaction the query runs in, are not affected. Stored
+// procedure calls and queries run with WithSQLBatch fail with the option,
+// as the isolation level would stay set for the session.
+//
+// Dirty reads are not consistent: a query may read rows written by
+// transactions that are later rolled back, miss committed rows or read them
+// twice when a page splits during the scan, and fail with error 601 when
+// data it reads is moved. Do not use it for data that is written back or
+// must add up. There is no option for the READPAST hint, which skips locked
+// rows, as there is no isolation level behaving like it: write it in the
+// query text.
+func WithReadUncommitted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readUncommittedKey{}, true)
+}
+
+func readUncommittedFromContext(ctx context.Context) bool {
+	on, _ := ctx.Value(readUncommittedKey{}).(bool)
+	return on
+}
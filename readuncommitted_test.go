@@ -0,0 +1,77 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendQueryWithReadUncommitted(t *testing.T) {
+	memBuf := new(MockTransport)
+	conn := &Conn{
+		connector:      &Connector{},
+		sess:           &tdsSession{buf: newTdsBuffer(4096, memBuf), logger: optionalLogger{}},
+		connectionGood: true,
+	}
+	ctx := WithReadUncommitted(context.Background())
+
+	// without arguments, the query is sent with sp_executesql rather than as
+	// a batch, which would keep the isolation level set for the session
+	stmt := &Stmt{c: conn, query: "select count(*) from dbo.orders"}
+	require.NoError(t, stmt.sendQuery(ctx, nil))
+	packet := memBuf.Bytes()
+	assert.Equal(t, byte(packRPCRequest), packet[0])
+	assert.True(t, bytes.Contains(packet, str2ucs2(readUncommittedSQL+"select count(*) from dbo.orders")))
+
+	memBuf.Reset()
+	stmt = &Stmt{c: conn, query: "select * from dbo.orders where id = @p1", paramCount: 1}
+	require.NoError(t, stmt.sendQuery(ctx, []namedValue{{Ordinal: 1, Value: int64(1)}}))
+	assert.True(t, bytes.Contains(memBuf.Bytes(), str2ucs2(readUncommittedSQL+"select * from dbo.orders where id = @p1")))
+
+	memBuf.Reset()
+	require.NoError(t, stmt.sendQuery(context.Background(), []namedValue{{Ordinal: 1, Value: int64(1)}}))
+	assert.False(t, bytes.Contains(memBuf.Bytes(), str2ucs2("READ UNCOMMITTED")), "only with the option")
+
+	for _, tt := range []struct {
+		query string
+		ctx   context.Context
+	}{
+		{"sp_who", ctx},
+		{"select 1", WithSQLBatch(ctx)},
+	} {
+		memBuf.Reset()
+		stmt = &Stmt{c: conn, query: tt.query}
+		err := stmt.sendQuery(tt.ctx, nil)
+		require.Error(t, err, tt.query)
+		assert.Contains(t, err.Error(), "WithReadUncommitted")
+		assert.Empty(t, memBuf.Bytes())
+	}
+}
+
+func TestReadUncommittedIsScopedToTheQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	const level = "SELECT transaction_isolation_level FROM sys.dm_exec_sessions WHERE session_id = @@SPID"
+	var inQuery, after int
+	require.NoError(t, conn.QueryRowContext(WithReadUncommitted(ctx), level).Scan(&inQuery))
+	require.NoError(t, conn.QueryRowContext(ctx, level).Scan(&after))
+	assert.Equal(t, 1, inQuery, "READ UNCOMMITTED")
+	assert.Equal(t, 2, after, "READ COMMITTED is restored")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, tx.QueryRowContext(WithReadUncommitted(ctx), level).Scan(&inQuery))
+	require.NoError(t, tx.QueryRowContext(ctx, level).Scan(&after))
+	assert.Equal(t, 1, inQuery)
+	assert.Equal(t, 2, after, "the transaction keeps its isolation level")
+}
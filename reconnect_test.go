@@ -0,0 +1,259 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowServer is a fake server that accepts any login and answers every
+// request with a single int row holding the number of the connection,
+// 1 for the first, so clients can tell when they reconnected. An
+// attention is acknowledged.
+type rowServer struct {
+	t        *testing.T
+	listener *net.TCPListener
+	// rows is the number of rows of each answer, 1 when it is 0. Set it
+	// before connecting.
+	rows int
+	// answer, when set, returns the reply to the data of a request on the
+	// numbered connection, or false to close the connection instead of
+	// answering. Set it before connecting.
+	answer func(conn int, request []byte) ([]byte, bool)
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	requests []int // per connection
+}
+
+func newRowServer(t *testing.T) *rowServer {
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+	require.NoError(t, err)
+	s := &rowServer{t: t, listener: listener}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.closeConns()
+	})
+	return s
+}
+
+func (s *rowServer) connString() string {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return fmt.Sprintf("host=%s;port=%d;protocol=tcp;dial timeout=5", addr.IP.String(), addr.Port)
+}
+
+func (s *rowServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.start(conn)
+	}
+}
+
+// start serves the client connected to conn.
+func (s *rowServer) start(conn net.Conn) {
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.requests = append(s.requests, 0)
+	n := len(s.conns)
+	s.mu.Unlock()
+	go s.handle(conn, n)
+}
+
+// closeConns closes the server side of every connection, as a network
+// failure or a server restart would.
+func (s *rowServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *rowServer) accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *rowServer) requestsPerConn() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.requests...)
+}
+
+func (s *rowServer) handle(conn net.Conn, n int) {
+	buf := newTdsBuffer(defaultPacketSize, conn)
+	goodPreloginSequence(s.t, buf)
+
+	progName := str2ucs2("fake")
+	ack := []byte{1}
+	ack = binary.BigEndian.AppendUint32(ack, verTDS74)
+	ack = append(ack, byte(len(progName)/2))
+	ack = append(ack, progName...)
+	ack = append(ack, 16, 0, 0, 0)
+	login := []byte{byte(tokenLoginAck), byte(len(ack)), 0}
+	login = append(login, ack...)
+	if !s.reply(buf, append(login, doneToken(0, 0)...)) {
+		return
+	}
+
+	for {
+		packetType, err := buf.BeginRead()
+		if err != nil {
+			return
+		}
+		request, err := io.ReadAll(buf)
+		if err != nil {
+			return
+		}
+		if packetType == packAttention {
+			if !s.reply(buf, doneToken(doneAttn, 0)) {
+				return
+			}
+			continue
+		}
+		s.mu.Lock()
+		s.requests[n-1]++
+		s.mu.Unlock()
+		if s.answer != nil {
+			stream, ok := s.answer(n, request)
+			if !ok {
+				_ = conn.Close()
+				return
+			}
+			if !s.reply(buf, stream) {
+				return
+			}
+			continue
+		}
+		rows := s.rows
+		if rows == 0 {
+			rows = 1
+		}
+		var stream []byte
+		stream = append(stream, byte(tokenColMetadata), 0x01, 0x00, 0, 0, 0, 0, 0, 0, typeInt4, 0)
+		for i := 0; i < rows; i++ {
+			stream = append(stream, byte(tokenRow))
+			stream = binary.LittleEndian.AppendUint32(stream, uint32(n))
+		}
+		stream = append(stream, doneToken(doneCount, uint64(rows))...)
+		if !s.reply(buf, stream) {
+			return
+		}
+	}
+}
+
+func doneToken(status uint16, rowCount uint64) []byte {
+	done := make([]byte, 1+2+2+8)
+	done[0] = byte(tokenDone)
+	binary.LittleEndian.PutUint16(done[1:], status)
+	binary.LittleEndian.PutUint64(done[5:], rowCount)
+	return done
+}
+
+func (s *rowServer) reply(buf *tdsBuffer, stream []byte) bool {
+	buf.BeginPacket(packReply, false)
+	if _, err := buf.Write(stream); err != nil {
+		return false
+	}
+	return buf.FinishPacket() == nil
+}
+
+func queryConnNumber(ctx context.Context, conn *sql.Conn) (int, error) {
+	var n int
+	err := conn.QueryRowContext(ctx, "select 1").Scan(&n)
+	return n, err
+}
+
+func TestReconnectAfterSocketClosed(t *testing.T) {
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	connector.Reconnect = true
+	connector.SessionInitSQL = "set nocount on"
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	n, err := queryConnNumber(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	server.closeConns()
+	// let the client side see the connection close
+	time.Sleep(50 * time.Millisecond)
+
+	n, err = queryConnNumber(ctx, conn)
+	require.NoError(t, err, "the query is sent again on a new session")
+	assert.Equal(t, 2, n, "answered on the second connection")
+	_, err = conn.ExecContext(ctx, "select 1")
+	require.NoError(t, err)
+	// SessionInitSQL runs on each session before the queries
+	assert.Equal(t, []int{2, 3}, server.requestsPerConn())
+}
+
+func TestNoReconnectByDefault(t *testing.T) {
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = queryConnNumber(ctx, conn)
+	require.NoError(t, err)
+
+	server.closeConns()
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = queryConnNumber(ctx, conn)
+	assert.Error(t, err)
+	_, err = queryConnNumber(ctx, conn)
+	assert.Error(t, err, "the *sql.Conn stays broken")
+	assert.Equal(t, 1, server.accepted())
+}
+
+func TestMayReplay(t *testing.T) {
+	c := &Conn{connector: &Connector{Reconnect: true, driver: driverInstance}}
+	readErr := &net.OpError{Op: "Read", Err: io.EOF}
+	assert.True(t, c.mayReplay(nil, false), "request that was not sent")
+	assert.True(t, c.mayReplay(readErr, true), "no response")
+	assert.False(t, c.mayReplay(StreamError{InnerError: io.EOF}, true), "broken mid-response")
+	assert.False(t, c.mayReplay(StreamError{InnerError: readErr}, true), "broken mid-response by a network error")
+	assert.False(t, c.mayReplay(&net.OpError{Op: "Read", Err: timeoutErr{}}, true), "timeout")
+
+	c.inTransaction = true
+	assert.False(t, c.mayReplay(readErr, true), "transactions cannot be recovered")
+	c.inTransaction = false
+	c.connector.Reconnect = false
+	assert.False(t, c.mayReplay(readErr, true), "Reconnect not set")
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
@@ -0,0 +1,79 @@
+package mssql
+
+import (
+	"context"
+	"fmt"
+)
+
+type maxResultBytesKey struct{}
+
+// ResultTooLarge is returned by Rows.Next once the rows of a query run
+// with WithMaxResultBytes exceed its limit.
+type ResultTooLarge struct {
+	Limit int64
+}
+
+func (e ResultTooLarge) Error() string {
+	return fmt.Sprintf("mssql: the rows of the query exceed the limit of %d bytes set by WithMaxResultBytes", e.Limit)
+}
+
+// WithMaxResultBytes returns a copy of ctx that limits the rows read by
+// queries run with it to n bytes, to keep a query such as an accidental
+// SELECT * on a large table from exhausting the memory of a service. Once
+// the rows read exceed n, the next row is not returned: Rows.Next fails
+// with a ResultTooLarge error, and the query is interrupted as by
+// Interrupt, which discards the rest of the results and leaves the
+// connection usable. The rows read before stay valid.
+//
+// The size of a row is the length of its string and []byte values plus
+// 8 bytes for each other value that is not NULL, summed over every result
+// set of the query. Rows are streamed: the driver holds at most the
+// prefetch window set by WithPrefetchRows, so what the limit bounds is the
+// rows a caller may collect, such as QueryStructs and ReadResultSet, or
+// code appending rows to a slice. Rows are counted as they are returned,
+// whether the caller keeps them or not, and each value is read in full
+// before it is counted. A limit of 0 or less sets none, the default.
+func WithMaxResultBytes(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxResultBytesKey{}, n)
+}
+
+// resultBudget counts the bytes of the rows returned by a query against
+// the limit of WithMaxResultBytes.
+type resultBudget struct {
+	limit int64
+	used  int64
+}
+
+func resultBudgetFromContext(ctx context.Context) resultBudget {
+	n, _ := ctx.Value(maxResultBytesKey{}).(int64)
+	return resultBudget{limit: n}
+}
+
+// add counts row, and returns a ResultTooLarge error when it exceeds the
+// limit.
+func (b *resultBudget) add(row []interface{}) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	b.used += rowBytes(row)
+	if b.used > b.limit {
+		return ResultTooLarge{Limit: b.limit}
+	}
+	return nil
+}
+
+func rowBytes(row []interface{}) int64 {
+	var n int64
+	for _, v := range row {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			n += int64(len(v))
+		case []byte:
+			n += int64(len(v))
+		default:
+			n += 8
+		}
+	}
+	return n
+}
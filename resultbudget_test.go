@@ -0,0 +1,61 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultBudget(t *testing.T) {
+	assert.Equal(t, int64(3+2+8+8), rowBytes([]interface{}{"abc", []byte{1, 2}, int64(1), time.Time{}, nil}))
+
+	b := resultBudgetFromContext(context.Background())
+	assert.NoError(t, b.add([]interface{}{make([]byte, 1<<20)}), "no limit by default")
+
+	b = resultBudgetFromContext(WithMaxResultBytes(context.Background(), 10))
+	assert.NoError(t, b.add([]interface{}{"12345"}))
+	assert.NoError(t, b.add([]interface{}{"12345"}), "the limit may be reached")
+	assert.Equal(t, ResultTooLarge{Limit: 10}, b.add([]interface{}{"1"}))
+}
+
+func TestMaxResultBytesInterruptsQuery(t *testing.T) {
+	server := newRowServer(t)
+	server.rows = 1000
+	db, err := sql.Open("sqlserver", server.connString())
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	count := func(ctx context.Context) (int, error) {
+		rows, err := conn.QueryContext(ctx, "select n from dbo.large")
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		var n int
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	}
+
+	// each row holds an int of 8 bytes
+	n, err := count(WithMaxResultBytes(ctx, 80))
+	var tooLarge ResultTooLarge
+	require.True(t, errors.As(err, &tooLarge), "%T: %v", err, err)
+	assert.Equal(t, int64(80), tooLarge.Limit)
+	assert.Equal(t, 10, n, "the rows within the limit are returned")
+
+	n, err = count(ctx)
+	require.NoError(t, err, "the connection is usable after the query was interrupted")
+	assert.Equal(t, 1000, n)
+}
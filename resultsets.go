@@ -0,0 +1,113 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrNoMoreResultSets is returned by ReadResultSet when the results of the
+// query hold no further result set.
+var ErrNoMoreResultSets = errors.New("mssql: no more result sets")
+
+// errResultsUnread is returned by ResultSets.Output before Close.
+var errResultsUnread = errors.New("mssql: output parameters are set once all the result sets are read: call Close first")
+
+// ResultSets reads the result sets of a query, such as a stored procedure
+// returning several, one after the other with ReadResultSet. It is created
+// by QueryMultiple.
+type ResultSets struct {
+	rows *sql.Rows
+	// read is set once the current result set was read.
+	read    bool
+	closed  bool
+	outputs map[string]interface{}
+}
+
+// QueryMultiple runs query on db and returns its result sets, to read in
+// order with ReadResultSet:
+//
+//	var total int
+//	results, err := mssql.QueryMultiple(ctx, db, "dbo.OrderDetails",
+//		sql.Named("id", id), sql.Named("total", sql.Out{Dest: &total}))
+//	if err != nil { ... }
+//	defer results.Close()
+//	orders, err := mssql.ReadResultSet[order](results)
+//	lines, err := mssql.ReadResultSet[orderLine](results)
+//	err = results.Close()
+//	// total is set
+//
+// The output parameters are only set by the server after the last result
+// set, so they are read after Close, which discards the result sets left
+// unread. Output returns their values and fails until then.
+func QueryMultiple(ctx context.Context, db queryer, query string, args ...interface{}) (*ResultSets, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	r := &ResultSets{rows: rows, outputs: make(map[string]interface{})}
+	for _, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok {
+			if out, ok := named.Value.(sql.Out); ok {
+				r.outputs[named.Name] = out.Dest
+			}
+		}
+	}
+	return r, nil
+}
+
+// ReadResultSet reads the next result set of r into a slice of T, a struct
+// whose fields are matched to the columns as by QueryStructs. It returns
+// ErrNoMoreResultSets after the last result set.
+func ReadResultSet[T any](r *ResultSets) ([]T, error) {
+	if err := checkStructType[T](); err != nil {
+		return nil, err
+	}
+	if r.closed {
+		return nil, errors.New("mssql: ReadResultSet called after Close")
+	}
+	if r.read {
+		if !r.rows.NextResultSet() {
+			if err := r.rows.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrNoMoreResultSets
+		}
+	}
+	r.read = true
+	return scanStructs[T](r.rows)
+}
+
+// Close discards the result sets left unread, which sets the output
+// parameters, and closes the rows. It returns the first error of the
+// query, such as one raised by a statement after the last result set read.
+func (r *ResultSets) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for r.rows.NextResultSet() {
+		// the rows of the result sets left are discarded
+	}
+	err := r.rows.Err()
+	if closeErr := r.rows.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Output returns the value of the output parameter passed to QueryMultiple
+// as sql.Named(name, sql.Out{...}), which is also stored in its Dest. It
+// fails before Close, as the server only sends it after the result sets.
+func (r *ResultSets) Output(name string) (interface{}, error) {
+	if !r.closed {
+		return nil, errResultsUnread
+	}
+	dest, ok := r.outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("mssql: %s is not an output parameter of the query", name)
+	}
+	return reflect.ValueOf(dest).Elem().Interface(), nil
+}
@@ -0,0 +1,86 @@
+package mssql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultSetsOutputBeforeClose(t *testing.T) {
+	total := 0
+	r := &ResultSets{outputs: map[string]interface{}{"total": &total}}
+	_, err := r.Output("total")
+	assert.ErrorIs(t, err, errResultsUnread)
+
+	r.closed = true
+	total = 3
+	v, err := r.Output("total")
+	require.NoError(t, err)
+	assert.Equal(t, 3, v)
+	_, err = r.Output("missing")
+	assert.Error(t, err)
+
+	_, err = ReadResultSet[queryStructsRow](r)
+	assert.Error(t, err, "reading after Close")
+	_, err = ReadResultSet[string](r)
+	assert.Error(t, err)
+}
+
+type orderRow struct {
+	ID       int    `db:"id"`
+	Customer string `db:"customer"`
+}
+
+type orderLineRow struct {
+	OrderID  int     `db:"order_id"`
+	Product  string  `db:"product"`
+	Quantity int     `db:"quantity"`
+	Note     *string `db:"note"`
+}
+
+func TestQueryMultiple(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `CREATE PROCEDURE #order_details @id int, @total int OUTPUT AS
+SELECT @id AS id, N'contoso' AS customer;
+SELECT * FROM (VALUES (@id, N'pen', 2, NULL), (@id, N'ink', 3, N'blue')) AS v(order_id, product, quantity, note);
+SET @total = 5;`)
+	require.NoError(t, err)
+
+	var total int
+	results, err := QueryMultiple(ctx, conn, "#order_details", sql.Named("id", 7), sql.Named("total", sql.Out{Dest: &total}))
+	require.NoError(t, err)
+	defer results.Close()
+
+	_, err = results.Output("total")
+	assert.ErrorIs(t, err, errResultsUnread, "before the result sets are read")
+
+	orders, err := ReadResultSet[orderRow](results)
+	require.NoError(t, err)
+	assert.Equal(t, []orderRow{{7, "contoso"}}, orders)
+
+	lines, err := ReadResultSet[orderLineRow](results)
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "pen", lines[0].Product)
+	assert.Nil(t, lines[0].Note)
+	require.NotNil(t, lines[1].Note)
+	assert.Equal(t, "blue", *lines[1].Note)
+
+	_, err = ReadResultSet[orderRow](results)
+	assert.ErrorIs(t, err, ErrNoMoreResultSets)
+
+	require.NoError(t, results.Close())
+	v, err := results.Output("total")
+	require.NoError(t, err)
+	assert.Equal(t, 5, v)
+	assert.Equal(t, 5, total)
+}
@@ -0,0 +1,152 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// transientErrors are the numbers of the server errors after which the
+// same request may succeed when sent again.
+var transientErrors = map[int32]bool{
+	1205: true, // chosen as deadlock victim
+	4060: true, // cannot open database
+	4221: true, // read-only secondary waiting for row versioning
+	// Azure SQL: database not available, service busy or throttled,
+	// resource limits reached
+	40197: true, 40501: true, 40613: true, 49918: true, 49919: true, 49920: true,
+	10928: true, 10929: true,
+}
+
+// IsTransient reports whether err, returned by a request, is a transient
+// failure, after which the same request may succeed when sent again: a
+// deadlock, one of the errors of Azure SQL reporting a busy, throttled or
+// failing over database, or a broken connection. Timeouts, including those
+// of the context of the request, are not transient.
+func IsTransient(err error) bool {
+	var sqlErr Error
+	if errors.As(err, &sqlErr) {
+		if len(sqlErr.All) == 0 {
+			return transientErrors[sqlErr.Number]
+		}
+		for _, e := range sqlErr.All {
+			if transientErrors[e.Number] {
+				return true
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return !netErr.Timeout()
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sideEffectWords are the keywords of the statements that change data,
+// the schema, the session or the server, and INTO of SELECT ... INTO,
+// which make a query more than a read.
+var sideEffectWords = map[string]bool{
+	"ALTER": true, "BACKUP": true, "BEGIN": true, "BULK": true, "CHECKPOINT": true,
+	"COMMIT": true, "CREATE": true, "DBCC": true, "DECLARE": true, "DELETE": true,
+	"DENY": true, "DROP": true, "EXEC": true, "EXECUTE": true, "GRANT": true,
+	"INSERT": true, "INTO": true, "KILL": true, "MERGE": true, "OPENDATASOURCE": true,
+	"OPENQUERY": true, "OPENROWSET": true, "RECONFIGURE": true, "RESTORE": true,
+	"REVOKE": true, "ROLLBACK": true, "SAVE": true, "SET": true, "SHUTDOWN": true,
+	"TRUNCATE": true, "UPDATE": true, "UPDATETEXT": true, "USE": true,
+	"WRITETEXT": true,
+}
+
+// isReadOnlyQuery reports whether query only reads: it starts with SELECT
+// or WITH, and has none of sideEffectWords nor NEXT VALUE FOR, which
+// advances a sequence, outside string literals, quoted identifiers and
+// comments.
+func isReadOnlyQuery(query string) bool {
+	var words []string
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			i = skipUntil(query, i+1, string(c))
+		case c == '[':
+			i = skipUntil(query, i+1, "]")
+		case strings.HasPrefix(query[i:], "--"):
+			i = skipUntil(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			i = skipComment(query, i+2)
+		case isNameChar(c):
+			end := skipName(query, i)
+			word := strings.ToUpper(query[i:end])
+			if len(words) == 0 && word != "SELECT" && word != "WITH" || sideEffectWords[word] {
+				return false
+			}
+			words = append(words, word)
+			if n := len(words); n >= 3 && words[n-3] == "NEXT" && words[n-2] == "VALUE" && word == "FOR" {
+				return false
+			}
+			i = end - 1
+		}
+	}
+	return len(words) > 0
+}
+
+// mayRetryRead reports whether query is sent again after a transient
+// failure before its first row is read. See Connector.RetryReads.
+func (c *Conn) mayRetryRead(query string) bool {
+	return c.connector != nil && c.connector.RetryReads && !c.inTransaction &&
+		!c.outs.hasOutputs() && c.outs.msgq == nil && !isProc(query) && isReadOnlyQuery(query)
+}
+
+// mayRetryAfter reports whether a read that failed with err may be sent
+// again: the failure is transient, and the connection is still good or may
+// reconnect.
+func (c *Conn) mayRetryAfter(err error) bool {
+	return IsTransient(err) && (c.connectionGood || c.mayReconnect())
+}
+
+// retryRead sends the query of rc again, when the first row of rc failed
+// to read with err and the failure is transient, and goes on reading the
+// rows of the new response. The error of the new request is returned when
+// it fails too, err when it returns other columns.
+func (rc *Rows) retryRead(dest []driver.Value, err error) error {
+	retry := rc.retry
+	rc.retry = nil
+	if retry == nil || !rc.stmt.c.mayRetryAfter(err) {
+		return err
+	}
+	rc.cancel()
+	if drainResponse(rc.reader) != nil {
+		return err
+	}
+	next, retryErr := retry()
+	if retryErr != nil {
+		return retryErr
+	}
+	if len(next.cols) != len(rc.cols) {
+		next.Close()
+		return err
+	}
+	rc.reader, rc.cols, rc.cancel, rc.nextCols = next.reader, next.cols, next.cancel, nil
+	return rc.Next(dest)
+}
+
+// drainResponse reads the rest of the response of reader, whose context was
+// cancelled, as Rows.Close does: the reader sends an attention when the
+// response is not complete and reads up to its confirmation. The goroutine
+// reading the response then ends, so the session may send the next
+// request.
+func drainResponse(reader *tokenProcessor) error {
+	for {
+		tok, err := reader.nextToken()
+		if err != nil {
+			if err == reader.ctx.Err() {
+				return nil
+			}
+			return err
+		}
+		if tok == nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransient(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{Error{Number: 1205}, true},
+		{Error{Number: 40613}, true},
+		{Error{Number: 208, All: []Error{{Number: 1205}, {Number: 208}}}, true},
+		{Error{Number: 208}, false},
+		{fmt.Errorf("query: %w", newRetryableError(Error{Number: 40501})), true},
+		{StreamError{InnerError: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}, true},
+		{&net.OpError{Op: "read", Err: timeoutError{}}, false},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, false},
+		{errors.New("mssql: other"), false},
+	} {
+		assert.Equal(t, tt.want, IsTransient(tt.err), "%v", tt.err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"select 1": true,
+		"  -- the orders\nSELECT * FROM dbo.orders WHERE id = @p1":           true,
+		"with x as (select 1 as a) select a from x":                          true,
+		"select 'insert into t' as [delete]; select @set":                    true,
+		"select * from t order by id offset 10 rows fetch next 10 rows only": true,
+		"select * into #t from t":                                            false,
+		"with x as (select 1 as a) delete from t":                            false,
+		"select 1; update t set x = 1":                                       false,
+		"select next value for dbo.seq":                                      false,
+		"exec sp_who":                                                        false,
+		"set nocount on; select 1":                                           false,
+		"insert into t values (1)":                                           false,
+		"/* select */ update t set x = 1":                                    false,
+		"":                                                                   false,
+	} {
+		assert.Equal(t, want, isReadOnlyQuery(query), query)
+	}
+}
+
+// retryReadServer answers the first request of a rowServer with a deadlock,
+// after the columns of the result set when afterColumns is set, and the
+// next ones with two rows.
+func retryReadServer(server *rowServer, afterColumns bool) *int32 {
+	var requests int32
+	server.answer = func(int, []byte) ([]byte, bool) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			return textResultStream(1, 0, 2), true
+		}
+		var stream []byte
+		if afterColumns {
+			stream = textResultStream(1, 0, 0)
+			stream = stream[:len(stream)-len(doneToken(0, 0))]
+		}
+		stream = append(stream, errorToken(1205, 51, "Transaction was deadlocked and has been chosen as the deadlock victim.")...)
+		return append(stream, doneToken(doneError, 0)...), true
+	}
+	return &requests
+}
+
+func TestRetryReadAfterTransientError(t *testing.T) {
+	for _, afterColumns := range []bool{false, true} {
+		server := newRowServer(t)
+		requests := retryReadServer(server, afterColumns)
+		connector, err := NewConnector(server.connString())
+		require.NoError(t, err)
+		connector.RetryReads = true
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, "select n0 from t where id = @p1", 1)
+		require.NoError(t, err, "after columns %v", afterColumns)
+		var got []string
+		for rows.Next() {
+			var n0 string
+			require.NoError(t, rows.Scan(&n0))
+			got = append(got, n0)
+		}
+		require.NoError(t, rows.Err(), "after columns %v", afterColumns)
+		require.NoError(t, rows.Close())
+		assert.Equal(t, []string{"n0-0", "n0-1"}, got, "after columns %v", afterColumns)
+		assert.EqualValues(t, 2, atomic.LoadInt32(requests), "after columns %v", afterColumns)
+	}
+}
+
+func TestRetryReadDrainsTheFailedResponse(t *testing.T) {
+	for _, afterColumns := range []bool{false, true} {
+		server := newRowServer(t)
+		var requests int32
+		server.answer = func(int, []byte) ([]byte, bool) {
+			if atomic.AddInt32(&requests, 1) > 1 {
+				return textResultStream(1, 0, 2), true
+			}
+			var stream []byte
+			if afterColumns {
+				stream = textResultStream(1, 0, 0)
+				stream = stream[:len(stream)-len(doneToken(0, 0))]
+			}
+			// 4221 does not abort the batch, whose next result set follows
+			stream = append(stream, errorToken(4221, 1, "Login to read-secondary failed due to long wait on 'HADR_DATABASE_WAIT_FOR_TRANSITION_TO_VERSIONING'.")...)
+			stream = append(stream, doneToken(doneError|doneMore, 0)...)
+			return append(stream, textResultStream(1, 0, 3)...), true
+		}
+		connector, err := NewConnector(server.connString())
+		require.NoError(t, err)
+		connector.RetryReads = true
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// no window: the reader waits for every token to be read
+		rows, err := db.QueryContext(WithPrefetchRows(ctx, 0), "select n0 from t")
+		require.NoError(t, err, "after columns %v", afterColumns)
+		var got []string
+		for rows.Next() {
+			var n0 string
+			require.NoError(t, rows.Scan(&n0))
+			got = append(got, n0)
+		}
+		require.NoError(t, rows.Err(), "after columns %v", afterColumns)
+		require.NoError(t, rows.Close())
+		assert.Equal(t, []string{"n0-0", "n0-1"}, got, "after columns %v", afterColumns)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&requests), "after columns %v", afterColumns)
+	}
+}
+
+func TestRetryReadOnlyForReads(t *testing.T) {
+	for name, tt := range map[string]struct {
+		retryReads bool
+		query      string
+	}{
+		"not set": {false, "select n0 from t"},
+		"write":   {true, "select n0 into #t from t"},
+	} {
+		server := newRowServer(t)
+		requests := retryReadServer(server, false)
+		connector, err := NewConnector(server.connString())
+		require.NoError(t, err)
+		connector.RetryReads = tt.retryReads
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_, err = db.QueryContext(ctx, tt.query)
+		var sqlErr Error
+		require.ErrorAs(t, err, &sqlErr, name)
+		assert.EqualValues(t, 1205, sqlErr.Number, name)
+		assert.EqualValues(t, 1, atomic.LoadInt32(requests), name)
+	}
+}
+
+func TestMayRetryRead(t *testing.T) {
+	c := &Conn{connector: &Connector{RetryReads: true}}
+	assert.True(t, c.mayRetryRead("select 1"))
+	assert.False(t, c.mayRetryRead("sp_who"), "stored procedure")
+	c.outs = outputs{params: map[string]interface{}{"p1": new(int)}}
+	assert.False(t, c.mayRetryRead("select @p1 = 1"), "output parameter")
+	c.outs = outputs{}
+	c.inTransaction = true
+	assert.False(t, c.mayRetryRead("select 1"), "transaction")
+}
@@ -0,0 +1,82 @@
+package mssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ServerTime is a snapshot of the server clock taken with SYSDATETIMEOFFSET().
+type ServerTime struct {
+	// Now is the server time when the snapshot was taken, in Location.
+	Now time.Time
+	// Offset is the difference between the server's local time and UTC.
+	Offset time.Duration
+	// Location is a fixed zone with Offset. It can be passed to
+	// time.ParseInLocation or Time.In to interpret DATETIME and DATETIME2
+	// values, which carry no zone, as server local time.
+	Location *time.Location
+}
+
+func newServerTime(now time.Time) ServerTime {
+	_, offset := now.Zone()
+	d := time.Duration(offset) * time.Second
+	loc := time.FixedZone(formatOffset(d), offset)
+	return ServerTime{Now: now.In(loc), Offset: d, Location: loc}
+}
+
+// formatOffset formats d as +hh:mm, the form SQL Server uses for offsets.
+func formatOffset(d time.Duration) string {
+	sign := '+'
+	if d < 0 {
+		sign = '-'
+		d = -d
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, int(d.Hours()), int(d.Minutes())%60)
+}
+
+// ServerTime returns the server's current time and UTC offset. The result
+// is queried once and cached for the life of the connection.
+//
+// The cached offset is a snapshot. It is not updated when the server's
+// time zone enters or leaves daylight saving time, so long lived
+// connections may return a stale offset, and a single offset cannot convert
+// DATETIME values recorded on the other side of a DST transition. Use
+// DATETIMEOFFSET columns when the zone of stored values matters.
+//
+// Use sql.Conn.Raw to call it from database/sql:
+//
+//	err = conn.Raw(func(dc any) error {
+//		st, err = dc.(*mssql.Conn).ServerTime(ctx)
+//		return err
+//	})
+func (c *Conn) ServerTime(ctx context.Context) (ServerTime, error) {
+	if c.serverTime != nil {
+		return *c.serverTime, nil
+	}
+	stmt, err := c.prepareContext(ctx, "SELECT SYSDATETIMEOFFSET()")
+	if err != nil {
+		return ServerTime{}, err
+	}
+	rows, err := stmt.QueryContext(ctx, nil)
+	if err != nil {
+		return ServerTime{}, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("mssql: SYSDATETIMEOFFSET() returned no rows")
+		}
+		return ServerTime{}, err
+	}
+	now, ok := dest[0].(time.Time)
+	if !ok {
+		return ServerTime{}, fmt.Errorf("mssql: SYSDATETIMEOFFSET() returned %T", dest[0])
+	}
+	st := newServerTime(now)
+	c.serverTime = &st
+	return st, nil
+}
@@ -0,0 +1,62 @@
+package mssql
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerTime(t *testing.T) {
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "+00:00"},
+		{5*3600 + 30*60, "+05:30"},
+		{-(9*3600 + 45*60), "-09:45"},
+	}
+	for _, tt := range tests {
+		// Offsets of datetimeoffset values are decoded into unnamed fixed zones.
+		now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.FixedZone("", tt.offset))
+		st := newServerTime(now)
+		assert.Equal(t, time.Duration(tt.offset)*time.Second, st.Offset, tt.expected)
+		assert.Equal(t, tt.expected, st.Location.String())
+		assert.True(t, st.Now.Equal(now))
+
+		// A DATETIME value read as UTC reinterpreted as server local time.
+		local := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+		inServer := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, st.Location)
+		assert.Equal(t, local.Add(-st.Offset), inServer.UTC(), tt.expected)
+	}
+}
+
+func TestConnServerTimeIntegration(t *testing.T) {
+	checkConnStr(t)
+	connector, err := NewConnector(makeConnStr(t).String())
+	require.NoError(t, err)
+	ctx := testContext(t)
+	dc, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	defer dc.Close()
+	conn := dc.(*Conn)
+
+	st, err := conn.ServerTime(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), st.Now, time.Hour, "server clock")
+
+	stmt, err := conn.prepareContext(ctx, "SELECT DATEPART(tzoffset, SYSDATETIMEOFFSET())")
+	require.NoError(t, err)
+	rows, err := stmt.QueryContext(ctx, nil)
+	require.NoError(t, err)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.NoError(t, rows.Close())
+	assert.EqualValues(t, int64(st.Offset/time.Minute), dest[0])
+
+	cached, err := conn.ServerTime(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, st, cached, "ServerTime must be cached per connection")
+}
@@ -0,0 +1,28 @@
+package mssql
+
+import "context"
+
+type sqlBatchKey struct{}
+
+// WithSQLBatch returns a copy of ctx whose queries are sent as a SQL batch
+// holding only their text, the cheapest request there is for unique ad hoc
+// statements such as those of admin tools or one-off reports.
+//
+// The driver never prepares statements on the server with sp_prepare, so
+// there is no prepare round trip to save: queries without arguments are
+// already sent as batches, and those with arguments with sp_executesql,
+// which caches one plan for every run with different values. What the
+// option changes is that text the driver would call as a stored procedure,
+// such as "sp_who", is sent as a batch too, and that queries with
+// arguments fail instead of being sent with sp_executesql, since a batch
+// carries no parameters. Leave it off for statements that repeat with
+// different values: written as literals in the text, each value gets a
+// plan of its own.
+func WithSQLBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlBatchKey{}, true)
+}
+
+func sqlBatchFromContext(ctx context.Context) bool {
+	on, _ := ctx.Value(sqlBatchKey{}).(bool)
+	return on
+}
@@ -0,0 +1,130 @@
+package mssql
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/microsoft/go-mssqldb/internal/cp"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// StringsAsBytes may be passed as a query argument to receive the values of
+// char, varchar, nchar and nvarchar columns as []byte holding UTF-8 text
+// instead of as string. Scanned into *sql.RawBytes they are not copied, so
+// reading wide text result sets costs about one allocation per row instead
+// of one per value. The varchar(max) and nvarchar(max) types are still
+// returned as strings.
+//
+//	rows, err := db.Query("select name, city from dbo.customers", mssql.StringsAsBytes{})
+//	...
+//	var name, city sql.RawBytes
+//	for rows.Next() {
+//		err = rows.Scan(&name, &city)
+//		...
+//	}
+//
+// As for any sql.RawBytes, the scanned bytes are only valid until the next
+// call to Next, Scan or Close, and must be copied to be kept. Scanning into
+// *string still works and allocates the string.
+type StringsAsBytes struct{}
+
+// rowArena holds the text values of one row, so they share an allocation.
+type rowArena struct {
+	buf []byte
+	// used and peak are the bytes of the row and the most it needed at once,
+	// hint the peak of earlier rows.
+	used, peak, hint int
+}
+
+// next starts a new row, sized after the largest row so far.
+func (a *rowArena) next() {
+	if a.peak > a.hint {
+		a.hint = a.peak
+	}
+	a.buf = nil
+	a.used, a.peak = 0, 0
+}
+
+// appendValue converts the text in buf with conv and adds it to the row.
+func (a *rowArena) appendValue(buf []byte, conv func(dst, buf []byte) []byte) []byte {
+	// conversions produce at most three UTF-8 bytes for each input byte
+	n := 3 * len(buf)
+	if a.used+n > a.peak {
+		a.peak = a.used + n
+	}
+	if cap(a.buf)-len(a.buf) < n {
+		// earlier values of the row keep the old array
+		size := a.hint - a.used
+		if size < n {
+			size = 2*cap(a.buf) + n
+		}
+		a.buf = make([]byte, 0, size)
+	}
+	if n == 0 {
+		// empty, but not nil which would read as NULL
+		return []byte{}
+	}
+	start := len(a.buf)
+	a.buf = conv(a.buf, buf)
+	a.used += len(a.buf) - start
+	return a.buf[start:len(a.buf):len(a.buf)]
+}
+
+// stringsAsBytesColumns returns a copy of columns whose text columns are read
+// as []byte into arena.
+func stringsAsBytesColumns(columns []columnStruct, arena *rowArena) []columnStruct {
+	res := make([]columnStruct, len(columns))
+	copy(res, columns)
+	for i := range res {
+		ti := &res[i].ti
+		if res[i].isEncrypted() || ti.Size == 0xffff {
+			continue
+		}
+		switch ti.TypeId {
+		case typeBigVarChar, typeBigChar:
+			collation := ti.Collation
+			ti.Reader = arena.shortLenReader(func(dst, buf []byte) []byte {
+				return cp.AppendUTF8(dst, collation, buf)
+			})
+		case typeNVarChar, typeNChar:
+			ti.Reader = arena.shortLenReader(appendUcs2)
+		}
+	}
+	return res
+}
+
+func (a *rowArena) shortLenReader(conv func(dst, buf []byte) []byte) func(*typeInfo, *tdsBuffer, *cryptoMetadata, msdsn.EncodeParameters) interface{} {
+	return func(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.EncodeParameters) interface{} {
+		size := r.uint16()
+		if size == 0xffff {
+			return nil
+		}
+		buf := ti.Buffer[:size]
+		r.ReadFull(buf)
+		return a.appendValue(buf, conv)
+	}
+}
+
+// appendUcs2 appends the UTF-8 encoding of the UCS-2 text s to dst.
+func appendUcs2(dst, s []byte) []byte {
+	if len(s)%2 != 0 {
+		badStreamPanicf("Invalid UCS2 encoding: illegal UCS2 string length: %d", len(s))
+	}
+	for i := 0; i < len(s); i += 2 {
+		u := rune(s[i]) | rune(s[i+1])<<8
+		if u < utf8.RuneSelf {
+			dst = append(dst, byte(u))
+			continue
+		}
+		if utf16.IsSurrogate(u) && i+3 < len(s) {
+			if r := utf16.DecodeRune(u, rune(s[i+2])|rune(s[i+3])<<8); r != utf8.RuneError {
+				dst = utf8.AppendRune(dst, r)
+				i += 2
+				continue
+			}
+		}
+		// lone surrogates become U+FFFD, as with decodeUcs2
+		dst = utf8.AppendRune(dst, u)
+	}
+	return dst
+}
@@ -0,0 +1,155 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textResultStream returns the tokens of a result set of rows rows with
+// nvarchar(20) columns n0, n1... and varchar(20) columns v0, v1... The
+// value of the column named c in row r is "c-r".
+func textResultStream(nvarchars, varchars, rows int) []byte {
+	var stream []byte
+	stream = append(stream, byte(tokenColMetadata))
+	stream = binary.LittleEndian.AppendUint16(stream, uint16(nvarchars+varchars))
+	var names []string
+	for i := 0; i < nvarchars+varchars; i++ {
+		typeID, name := byte(typeNVarChar), fmt.Sprintf("n%d", i)
+		if i >= nvarchars {
+			typeID, name = typeBigVarChar, fmt.Sprintf("v%d", i-nvarchars)
+		}
+		names = append(names, name)
+		// user type, flags (nullable), type, size, collation (Latin1_General_CI_AS)
+		stream = append(stream, 0, 0, 0, 0, 0x01, 0, typeID, 40, 0, 0x09, 0x04, 0xd0, 0x00, 0x34)
+		stream = append(stream, byte(len(name)))
+		stream = append(stream, str2ucs2(name)...)
+	}
+	for r := 0; r < rows; r++ {
+		stream = append(stream, byte(tokenRow))
+		for i, name := range names {
+			value := []byte(fmt.Sprintf("%s-%d", name, r))
+			if i < nvarchars {
+				value = str2ucs2(string(value))
+			}
+			stream = binary.LittleEndian.AppendUint16(stream, uint16(len(value)))
+			stream = append(stream, value...)
+		}
+	}
+	return append(stream, doneToken(doneCount, uint64(rows))...)
+}
+
+func readTextRows(t testing.TB, stream []byte, outs outputs) [][]interface{} {
+	sess := &tdsSession{
+		buf:    newTdsBuffer(32767, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+		logger: optionalLogger{},
+	}
+	ch := make(chan tokenStruct, 100)
+	go processSingleResponse(context.Background(), sess, ch, outs)
+	var rows [][]interface{}
+	for tok := range ch {
+		switch tok := tok.(type) {
+		case error:
+			t.Fatal(tok)
+		case []interface{}:
+			rows = append(rows, tok)
+		}
+	}
+	return rows
+}
+
+func TestStringsAsBytes(t *testing.T) {
+	stream := textResultStream(2, 2, 3)
+	strs := readTextRows(t, stream, outputs{})
+	raw := readTextRows(t, stream, outputs{stringsAsBytes: true})
+	require.Len(t, raw, 3)
+	for r := range raw {
+		for i, v := range raw[r] {
+			b, ok := v.([]byte)
+			require.True(t, ok, "row %d column %d is %T", r, i, v)
+			assert.Equal(t, strs[r][i], string(b))
+		}
+	}
+	assert.Equal(t, "n0-0", string(raw[0][0].([]byte)))
+	assert.Equal(t, "v1-2", string(raw[2][3].([]byte)))
+	// the values of a row do not overlap
+	first := raw[0][0].([]byte)
+	assert.Equal(t, len(first), cap(first))
+}
+
+func TestStringsAsBytesNullAndEmpty(t *testing.T) {
+	var stream []byte
+	stream = append(stream, byte(tokenColMetadata), 2, 0)
+	for _, typeID := range []byte{typeNVarChar, typeBigVarChar} {
+		stream = append(stream, 0, 0, 0, 0, 0x01, 0, typeID, 40, 0, 0x09, 0x04, 0xd0, 0x00, 0x34, 1)
+		stream = append(stream, str2ucs2("c")...)
+	}
+	stream = append(stream, byte(tokenRow), 0xff, 0xff, 0, 0)
+	stream = append(stream, doneToken(doneCount, 1)...)
+
+	rows := readTextRows(t, stream, outputs{stringsAsBytes: true})
+	require.Len(t, rows, 1)
+	assert.Nil(t, rows[0][0], "NULL")
+	assert.Equal(t, []byte{}, rows[0][1], "empty is not NULL")
+}
+
+func TestAppendUcs2(t *testing.T) {
+	for _, s := range []string{"", "ascii", "é€", "𝄞 clef", "中文"} {
+		assert.Equal(t, s, string(appendUcs2(nil, str2ucs2(s))))
+	}
+	lone := []byte{0x00, 0xd8, 'a', 0}
+	assert.Equal(t, "�a", string(appendUcs2(nil, lone)))
+}
+
+func TestCheckNamedValueStringsAsBytes(t *testing.T) {
+	c := &Conn{sess: &tdsSession{}, connectionGood: true}
+	nv := &driver.NamedValue{Ordinal: 1, Value: StringsAsBytes{}}
+	assert.Equal(t, driver.ErrRemoveArgument, c.CheckNamedValue(nv))
+	assert.True(t, c.outs.stringsAsBytes)
+}
+
+func TestStringsAsBytesQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	rows, err := conn.Query("select N'é€', cast('abc' as varchar(10)), cast(null as nvarchar(5)), N'', cast(N'max' as nvarchar(max))", StringsAsBytes{})
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	var n, v, null, empty sql.RawBytes
+	var max string
+	require.NoError(t, rows.Scan(&n, &v, &null, &empty, &max))
+	assert.Equal(t, "é€", string(n))
+	assert.Equal(t, "abc", string(v))
+	assert.Nil(t, null)
+	assert.NotNil(t, empty)
+	assert.Empty(t, empty)
+	assert.Equal(t, "max", max)
+	require.NoError(t, rows.Close())
+}
+
+func BenchmarkWideTextRows(b *testing.B) {
+	stream := textResultStream(10, 10, 50)
+	for _, bc := range []struct {
+		name string
+		outs outputs
+	}{
+		{"string", outputs{}},
+		{"StringsAsBytes", outputs{stringsAsBytes: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				readTextRows(b, stream, bc.outs)
+			}
+		})
+	}
+}
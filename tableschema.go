@@ -0,0 +1,220 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TableInfo describes a table, as returned by TableSchema.
+type TableInfo struct {
+	// Schema and Name are the names of the table as declared.
+	Schema string
+	Name   string
+	// Columns are in the order of the table.
+	Columns []ColumnInfo
+	// PrimaryKeyName is the name of the primary key constraint and
+	// PrimaryKey its columns in key order. Both are empty when the table
+	// has no primary key.
+	PrimaryKeyName string
+	PrimaryKey     []string
+	// ForeignKeys are ordered by name.
+	ForeignKeys []ForeignKeyInfo
+}
+
+// ColumnInfo describes a column of a table.
+type ColumnInfo struct {
+	Name string
+	// Type is the system type of the column, such as nvarchar or decimal,
+	// also for columns of alias types.
+	Type string
+	// MaxLength is the size of the column in bytes, so twice the length of
+	// nchar and nvarchar columns, and -1 for the max types.
+	MaxLength int
+	// Precision and Scale apply to the numeric and the date and time types.
+	Precision int
+	Scale     int
+	Nullable  bool
+	Identity  bool
+	Computed  bool
+	// Default is the definition of the default of the column, such as
+	// (getdate()), or empty when it has none.
+	Default string
+}
+
+// ForeignKeyInfo describes a foreign key of a table.
+type ForeignKeyInfo struct {
+	Name string
+	// Columns of the table reference the RefColumns of RefSchema.RefTable,
+	// in the same order.
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	// OnDelete and OnUpdate are the referential actions, NO_ACTION,
+	// CASCADE, SET_NULL or SET_DEFAULT.
+	OnDelete string
+	OnUpdate string
+}
+
+// tableSchemaQuery returns the table, its columns, its primary key and its
+// foreign keys as four result sets, from the catalog views.
+const tableSchemaQuery = `DECLARE @id int = OBJECT_ID(QUOTENAME(ISNULL(NULLIF(@schema, N''), SCHEMA_NAME())) + N'.' + QUOTENAME(@table), N'U');
+SELECT SCHEMA_NAME(o.schema_id), o.name FROM sys.objects o WHERE o.object_id = @id;
+SELECT c.name, TYPE_NAME(c.system_type_id), c.max_length, c.precision, c.scale,
+	c.is_nullable, c.is_identity, c.is_computed, ISNULL(d.definition, N'')
+FROM sys.columns c LEFT JOIN sys.default_constraints d ON d.object_id = c.default_object_id
+WHERE c.object_id = @id ORDER BY c.column_id;
+SELECT k.name, c.name
+FROM sys.key_constraints k
+JOIN sys.index_columns ic ON ic.object_id = k.parent_object_id AND ic.index_id = k.unique_index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE k.parent_object_id = @id AND k.type = 'PK' ORDER BY ic.key_ordinal;
+SELECT fk.name, pc.name, SCHEMA_NAME(rt.schema_id), rt.name, rc.name,
+	fk.delete_referential_action_desc, fk.update_referential_action_desc
+FROM sys.foreign_keys fk
+JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+JOIN sys.objects rt ON rt.object_id = fk.referenced_object_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+WHERE fk.parent_object_id = @id ORDER BY fk.name, fkc.constraint_column_id;`
+
+// TableSchema returns the columns, primary key and foreign keys of the
+// table schema.table, read from the catalog views in one round trip. An
+// empty schema is the default schema of the user. It returns an error
+// wrapping sql.ErrNoRows when there is no such table, or when the user
+// cannot see it.
+func TableSchema(ctx context.Context, conn *sql.Conn, schema, table string) (*TableInfo, error) {
+	rows, err := conn.QueryContext(ctx, tableSchemaQuery, sql.Named("schema", schema), sql.Named("table", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	info := &TableInfo{}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("mssql: table %s not found: %w", tableSchemaKey(schema, table), sql.ErrNoRows)
+	}
+	if err = rows.Scan(&info.Schema, &info.Name); err != nil {
+		return nil, err
+	}
+
+	if err = nextSchemaResult(rows); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c ColumnInfo
+		if err = rows.Scan(&c.Name, &c.Type, &c.MaxLength, &c.Precision, &c.Scale,
+			&c.Nullable, &c.Identity, &c.Computed, &c.Default); err != nil {
+			return nil, err
+		}
+		info.Columns = append(info.Columns, c)
+	}
+
+	if err = nextSchemaResult(rows); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&info.PrimaryKeyName, &column); err != nil {
+			return nil, err
+		}
+		info.PrimaryKey = append(info.PrimaryKey, column)
+	}
+
+	if err = nextSchemaResult(rows); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, column, refSchema, refTable, refColumn, onDelete, onUpdate string
+		if err = rows.Scan(&name, &column, &refSchema, &refTable, &refColumn, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		n := len(info.ForeignKeys)
+		if n == 0 || info.ForeignKeys[n-1].Name != name {
+			info.ForeignKeys = append(info.ForeignKeys, ForeignKeyInfo{
+				Name: name, RefSchema: refSchema, RefTable: refTable, OnDelete: onDelete, OnUpdate: onUpdate,
+			})
+			n++
+		}
+		fk := &info.ForeignKeys[n-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// nextSchemaResult moves to the next result set of tableSchemaQuery.
+func nextSchemaResult(rows *sql.Rows) error {
+	for rows.Next() {
+	}
+	if !rows.NextResultSet() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("mssql: the table schema query returned too few result sets")
+	}
+	return nil
+}
+
+func tableSchemaKey(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// SchemaCache caches the TableInfo returned by TableSchema, for tools that
+// look up the same tables repeatedly. The names of tables are compared
+// case-insensitively, as with the default collations. It is safe for
+// concurrent use and its zero value is ready to use.
+//
+// A cached TableInfo is shared by the callers and must not be modified.
+// Call Forget after changing a table.
+type SchemaCache struct {
+	mu     sync.Mutex
+	tables map[string]*TableInfo
+}
+
+// TableSchema returns the cached TableInfo of schema.table, calling
+// TableSchema on conn when it is not cached. Errors are not cached.
+func (c *SchemaCache) TableSchema(ctx context.Context, conn *sql.Conn, schema, table string) (*TableInfo, error) {
+	key := strings.ToLower(tableSchemaKey(schema, table))
+	c.mu.Lock()
+	info, ok := c.tables[key]
+	c.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+	info, err := TableSchema(ctx, conn, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.tables == nil {
+		c.tables = map[string]*TableInfo{}
+	}
+	c.tables[key] = info
+	c.mu.Unlock()
+	return info, nil
+}
+
+// Forget removes schema.table from the cache, or all the tables when table
+// is empty.
+func (c *SchemaCache) Forget(schema, table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if table == "" {
+		c.tables = nil
+		return
+	}
+	delete(c.tables, strings.ToLower(tableSchemaKey(schema, table)))
+}
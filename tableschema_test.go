@@ -0,0 +1,115 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableSchema(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	drop := `IF OBJECT_ID('dbo.table_schema_line') IS NOT NULL DROP TABLE dbo.table_schema_line;
+IF OBJECT_ID('dbo.table_schema_order') IS NOT NULL DROP TABLE dbo.table_schema_order;
+IF OBJECT_ID('dbo.table_schema_product') IS NOT NULL DROP TABLE dbo.table_schema_product;`
+	_, err = conn.ExecContext(ctx, drop)
+	require.NoError(t, err)
+	defer conn.ExecContext(context.Background(), drop)
+	_, err = conn.ExecContext(ctx, `CREATE TABLE dbo.table_schema_order (
+	region char(2) NOT NULL,
+	order_id int NOT NULL,
+	created datetime2(3) NOT NULL DEFAULT (sysutcdatetime()),
+	CONSTRAINT pk_table_schema_order PRIMARY KEY (region, order_id)
+);
+CREATE TABLE dbo.table_schema_product (
+	product_id int IDENTITY(1,1) NOT NULL CONSTRAINT pk_table_schema_product PRIMARY KEY
+);
+CREATE TABLE dbo.table_schema_line (
+	line_no smallint NOT NULL,
+	order_id int NOT NULL,
+	region char(2) NOT NULL,
+	product_id int NULL,
+	price decimal(10, 2) NOT NULL,
+	note nvarchar(max) NULL,
+	CONSTRAINT pk_table_schema_line PRIMARY KEY (order_id, region, line_no),
+	CONSTRAINT fk_table_schema_line_order FOREIGN KEY (region, order_id)
+		REFERENCES dbo.table_schema_order (region, order_id) ON DELETE CASCADE,
+	CONSTRAINT fk_table_schema_line_product FOREIGN KEY (product_id)
+		REFERENCES dbo.table_schema_product (product_id)
+);`)
+	require.NoError(t, err)
+
+	info, err := TableSchema(ctx, conn, "dbo", "TABLE_SCHEMA_LINE")
+	require.NoError(t, err)
+	assert.Equal(t, "dbo", info.Schema)
+	assert.Equal(t, "table_schema_line", info.Name)
+	assert.Equal(t, []ColumnInfo{
+		{Name: "line_no", Type: "smallint", MaxLength: 2, Precision: 5},
+		{Name: "order_id", Type: "int", MaxLength: 4, Precision: 10},
+		{Name: "region", Type: "char", MaxLength: 2},
+		{Name: "product_id", Type: "int", MaxLength: 4, Precision: 10, Nullable: true},
+		{Name: "price", Type: "decimal", MaxLength: 9, Precision: 10, Scale: 2},
+		{Name: "note", Type: "nvarchar", MaxLength: -1, Nullable: true},
+	}, info.Columns)
+	assert.Equal(t, "pk_table_schema_line", info.PrimaryKeyName)
+	assert.Equal(t, []string{"order_id", "region", "line_no"}, info.PrimaryKey)
+	assert.Equal(t, []ForeignKeyInfo{
+		{
+			Name:       "fk_table_schema_line_order",
+			Columns:    []string{"region", "order_id"},
+			RefSchema:  "dbo",
+			RefTable:   "table_schema_order",
+			RefColumns: []string{"region", "order_id"},
+			OnDelete:   "CASCADE",
+			OnUpdate:   "NO_ACTION",
+		},
+		{
+			Name:       "fk_table_schema_line_product",
+			Columns:    []string{"product_id"},
+			RefSchema:  "dbo",
+			RefTable:   "table_schema_product",
+			RefColumns: []string{"product_id"},
+			OnDelete:   "NO_ACTION",
+			OnUpdate:   "NO_ACTION",
+		},
+	}, info.ForeignKeys)
+
+	info, err = TableSchema(ctx, conn, "", "table_schema_order")
+	require.NoError(t, err)
+	require.Len(t, info.Columns, 3)
+	assert.Equal(t, "(sysutcdatetime())", info.Columns[2].Default)
+	assert.Equal(t, 3, info.Columns[2].Scale)
+	assert.Equal(t, []string{"region", "order_id"}, info.PrimaryKey)
+	assert.Empty(t, info.ForeignKeys)
+
+	info, err = TableSchema(ctx, conn, "dbo", "table_schema_product")
+	require.NoError(t, err)
+	assert.True(t, info.Columns[0].Identity)
+
+	_, err = TableSchema(ctx, conn, "dbo", "table_schema_missing")
+	assert.True(t, errors.Is(err, sql.ErrNoRows), "%v", err)
+
+	var cache SchemaCache
+	first, err := cache.TableSchema(ctx, conn, "dbo", "table_schema_line")
+	require.NoError(t, err)
+	cached, err := cache.TableSchema(ctx, conn, "DBO", "Table_Schema_Line")
+	require.NoError(t, err)
+	assert.Same(t, first, cached, "the cached schema is returned")
+	cache.Forget("dbo", "table_schema_line")
+	reread, err := cache.TableSchema(ctx, conn, "dbo", "table_schema_line")
+	require.NoError(t, err)
+	assert.NotSame(t, first, reread, "a forgotten table is read again")
+	assert.Equal(t, first, reread)
+}
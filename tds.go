@@ -10,10 +10,10 @@ import (
 	"net"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf16"
-	"unicode/utf8"
 
 	"github.com/microsoft/go-mssqldb/aecmk"
 	"github.com/microsoft/go-mssqldb/integratedauth"
@@ -175,6 +175,14 @@ type tdsSession struct {
 	connid          UniqueIdentifier
 	activityid      UniqueIdentifier
 	encoding        msdsn.EncodeParameters
+	// spid is the server process id of the session, from the header of
+	// the login response.
+	spid uint16
+	// prelogin is the answer of the server to the pre-login.
+	prelogin PreloginInfo
+	// tokenExpiry is when the federated authentication token of the login
+	// expires. It is zero for other logins and for tokens of unknown expiry.
+	tokenExpiry time.Time
 	// readDone is closed when the current processSingleResponse goroutine
 	// completes. startResponseReader waits on this to prevent concurrent buffer reads.
 	readDone chan struct{}
@@ -366,8 +374,9 @@ func readPreloginOptionData(plOption *preloginOption, buffer []byte) ([]byte, er
 // OptionFlags1
 // http://msdn.microsoft.com/en-us/library/dd304019.aspx
 const (
-	fUseDB   = 0x20
-	fSetLang = 0x80
+	fUseDB    = 0x20
+	fDatabase = 0x40
+	fSetLang  = 0x80
 )
 
 // OptionFlags2
@@ -615,6 +624,8 @@ func sendLogin(w *tdsBuffer, login *login) error {
 	changepassword := manglePassword(login.ChangePassword)
 	featureExt := login.FeatureExt.toBytes()
 
+	// the lengths count UTF-16 code units, two for characters outside the
+	// BMP such as emoji
 	hdr := loginHeader{
 		TDSVersion:           login.TDSVersion,
 		PacketSize:           login.PacketSize,
@@ -627,18 +638,18 @@ func sendLogin(w *tdsBuffer, login *login) error {
 		OptionFlags3:         login.OptionFlags3,
 		ClientTimeZone:       login.ClientTimeZone,
 		ClientLCID:           login.ClientLCID,
-		HostNameLength:       uint16(utf8.RuneCountInString(login.HostName)),
-		UserNameLength:       uint16(utf8.RuneCountInString(login.UserName)),
-		PasswordLength:       uint16(utf8.RuneCountInString(login.Password)),
-		AppNameLength:        uint16(utf8.RuneCountInString(login.AppName)),
-		ServerNameLength:     uint16(utf8.RuneCountInString(login.ServerName)),
-		CtlIntNameLength:     uint16(utf8.RuneCountInString(login.CtlIntName)),
-		LanguageLength:       uint16(utf8.RuneCountInString(login.Language)),
-		DatabaseLength:       uint16(utf8.RuneCountInString(login.Database)),
+		HostNameLength:       uint16(len(hostname) / 2),
+		UserNameLength:       uint16(len(username) / 2),
+		PasswordLength:       uint16(len(password) / 2),
+		AppNameLength:        uint16(len(appname) / 2),
+		ServerNameLength:     uint16(len(servername) / 2),
+		CtlIntNameLength:     uint16(len(ctlintname) / 2),
+		LanguageLength:       uint16(len(language) / 2),
+		DatabaseLength:       uint16(len(database) / 2),
 		ClientID:             login.ClientID,
 		SSPILength:           uint16(len(login.SSPI)),
-		AtchDBFileLength:     uint16(utf8.RuneCountInString(login.AtchDBFile)),
-		ChangePasswordLength: uint16(utf8.RuneCountInString(login.ChangePassword)),
+		AtchDBFileLength:     uint16(len(atchdbfile) / 2),
+		ChangePasswordLength: uint16(len(changepassword) / 2),
 	}
 	offset := uint16(binary.Size(hdr))
 	hdr.HostNameOffset = offset
@@ -959,6 +970,9 @@ func sendAttention(buf *tdsBuffer) error {
 
 // Makes an attempt to connect with each available protocol, in order, until one succeeds or the timeout elapses
 func dialConnection(ctx context.Context, c *Connector, p *msdsn.Config, logger ContextLogger) (conn net.Conn, err error) {
+	if c != nil && c.dialConn != nil {
+		return c.dialConn(ctx)
+	}
 	var instances msdsn.BrowserData
 	for _, protocol := range p.Protocols {
 		dialer := msdsn.ProtocolDialers[protocol]
@@ -1061,6 +1075,12 @@ func prepareLogin(ctx context.Context, c *Connector, p msdsn.Config, logger Cont
 		ChangePassword: p.ChangePassword,
 		ClientPID:      uint32(os.Getpid()),
 	}
+	if p.Database != "" {
+		// The login fails rather than falling back to the default
+		// database of the login when the database cannot be opened: a
+		// contained database user is only known to its database.
+		l.OptionFlags1 |= fDatabase
+	}
 	getClientId(&l.ClientID)
 	if p.ColumnEncryption {
 		_ = l.FeatureExt.Add(&featureExtColumnEncryption{})
@@ -1141,6 +1161,11 @@ func connect(ctx context.Context, c *Connector, logger ContextLogger, p msdsn.Co
 		// you should not provide instance name when you provide port
 		logger.Log(ctx, msdsn.LogDebug, "WARN: You specified both instance name and port in the connection string, port will be used and instance name will be ignored")
 	}
+	if mars, ok := p.Parameters[msdsn.MultipleActiveResultSets]; ok && uint64(p.LogFlags)&logDebug != 0 {
+		if enabled, _ := strconv.ParseBool(mars); enabled || strings.EqualFold(mars, "yes") {
+			logger.Log(ctx, msdsn.LogDebug, "WARN: MultipleActiveResultSets is not supported and will be ignored")
+		}
+	}
 
 	packetSize := p.PacketSize
 	if packetSize == 0 {
@@ -1171,9 +1196,24 @@ initiate_connection:
 	if err != nil {
 		return nil, err
 	}
+	if c != nil && c.dialConn != nil {
+		// the connections of NewConnectorWithConn are handed to the driver
+		defer func(conn net.Conn) {
+			if err != nil {
+				conn.Close()
+			}
+		}(conn)
+	}
 
-	toconn := newTimeoutConn(conn, p.ConnTimeout)
+	var capture *connCapture
+	if c != nil {
+		capture = c.PacketCapture.start()
+	}
+	toconn := newTimeoutConn(capture.wire(conn), p.ConnTimeout)
+	toconn.readTimeout = p.ReadTimeout
+	toconn.writeTimeout = p.WriteTimeout
 	outbuf := newTdsBuffer(packetSize, toconn)
+	outbuf.capture = capture
 
 	if p.Encryption == msdsn.EncryptionStrict {
 		tlsConn, err := getTLSConn(toconn, p, "tds/8.0")
@@ -1218,6 +1258,7 @@ initiate_connection:
 	if err != nil {
 		return nil, err
 	}
+	sess.prelogin = newPreloginInfo(fields)
 
 	//We need not perform TLS handshake if the communication channel is already encrypted (encrypt=strict)
 	if !isTransportEncrypted {
@@ -1249,7 +1290,7 @@ initiate_connection:
 			tlsConn := tls.Client(&passthrough, config)
 			err = tlsConn.Handshake()
 			if err != nil {
-				return nil, fmt.Errorf("TLS Handshake failed: %v", err)
+				return nil, fmt.Errorf("TLS Handshake failed: %w", err)
 			}
 			// Flush any pending packet from the handshake
 			// The driver's Finished message is still in the buffer
@@ -1362,6 +1403,7 @@ initiate_connection:
 				}
 			case loginAckStruct:
 				sess.loginAck = token
+				sess.spid = sess.buf.rSpid
 				loginAck = true
 			case featureExtAck:
 				for _, v := range token {
@@ -1380,15 +1422,19 @@ initiate_connection:
 					tokenErr := token.getError()
 					tokenErr.Message = "login error: " + tokenErr.Message
 					conn.Close()
+					tokenErr.login = &loginInfo{database: p.Database}
 					return nil, tokenErr
 				}
 			case error:
-				return nil, fmt.Errorf("login error: %s", token.Error())
+				return nil, fmt.Errorf("login error: %w", token)
 			}
 		}
 	}
 
 	if sess.routedServer != "" {
+		if c != nil && c.dialConn != nil {
+			return nil, fmt.Errorf("mssql: the server routed the connection to %s:%d, which NewConnectorWithConn cannot dial", sess.routedServer, sess.routedPort)
+		}
 		toconn.Close()
 		// Need to handle case when routedServer is in "host\instance" format.
 		routedParts := strings.SplitN(sess.routedServer, "\\", 2)
@@ -1397,15 +1443,28 @@ initiate_connection:
 			p.Instance = routedParts[1]
 		}
 		p.Port = uint64(sess.routedPort)
-		if !p.HostInCertificateProvided && p.TLSConfig != nil {
-			p.TLSConfig = p.TLSConfig.Clone()
-			p.TLSConfig.ServerName = p.Host
-		}
+		retargetTLS(&p)
 		goto initiate_connection
 	}
+	if fedAuth.FedAuthToken != "" {
+		sess.tokenExpiry = fedAuthTokenExpiry(fedAuth.FedAuthToken)
+	}
 	return sess, nil
 }
 
+// retargetTLS points the TLS server name of p, sent as SNI and verified
+// against the certificate of the server, at p.Host after the connection
+// moved to another server, a routed replica or a failover partner. The
+// name set by HostNameInCertificate is kept, as it names the certificate
+// of all the servers, such as the listener name a SAN certificate of the
+// replicas of an availability group holds.
+func retargetTLS(p *msdsn.Config) {
+	if !p.HostInCertificateProvided && p.TLSConfig != nil {
+		p.TLSConfig = p.TLSConfig.Clone()
+		p.TLSConfig.ServerName = p.Host
+	}
+}
+
 type featureExtColumnEncryption struct {
 }
 
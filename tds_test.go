@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type MockTransport struct {
@@ -101,6 +103,31 @@ func TestSendLogin(t *testing.T) {
 	}
 }
 
+func TestSendLoginSurrogatePairLengths(t *testing.T) {
+	memBuf := new(MockTransport)
+	buf := newTdsBuffer(1024, memBuf)
+	login := login{
+		TDSVersion: verTDS74,
+		PacketSize: 0x1000,
+		HostName:   "host",
+		AppName:    "app\U0001F680",
+		Database:   "db\U0001F600",
+	}
+	require.NoError(t, sendLogin(buf, &login))
+	out := memBuf.Bytes()[8:]
+	var hdr loginHeader
+	require.NoError(t, binary.Read(bytes.NewReader(out), binary.LittleEndian, &hdr))
+	// each emoji is a surrogate pair, two UTF-16 code units
+	assert.Equal(t, uint16(5), hdr.AppNameLength)
+	assert.Equal(t, uint16(4), hdr.DatabaseLength)
+	database, err := ucs22str(out[hdr.DatabaseOffset : hdr.DatabaseOffset+2*hdr.DatabaseLength])
+	require.NoError(t, err)
+	assert.Equal(t, "db\U0001F600", database)
+	appName, err := ucs22str(out[hdr.AppNameOffset : hdr.AppNameOffset+2*hdr.AppNameLength])
+	require.NoError(t, err)
+	assert.Equal(t, "app\U0001F680", appName)
+}
+
 func TestSendLoginWithFeatureExt(t *testing.T) {
 	memBuf := new(MockTransport)
 	buf := newTdsBuffer(1024, memBuf)
@@ -717,6 +744,56 @@ func TestBadCredentials(t *testing.T) {
 	_ = testConnectionBad(t, params.URL().String())
 }
 
+func TestContainedDatabaseUser(t *testing.T) {
+	params := testConnParams(t)
+	db, err := sql.Open("sqlserver", params.URL().String())
+	if err != nil {
+		t.Fatal("Open connection failed:", err.Error())
+	}
+	defer db.Close()
+	// Azure SQL databases always accept contained users, SQL Server only
+	// partially contained databases.
+	var database string
+	var contained bool
+	err = db.QueryRow(`SELECT DB_NAME(), CAST(CASE WHEN SERVERPROPERTY('EngineEdition') = 5 OR containment = 1 THEN 1 ELSE 0 END AS bit)
+		FROM sys.databases WHERE database_id = DB_ID()`).Scan(&database, &contained)
+	if err != nil {
+		t.Fatal("cannot read the containment of the database", err)
+	}
+	if !contained {
+		t.Skipf("database %s is not a contained database", database)
+	}
+	const user, password = "go_mssql_contained", "Contained-p4ssword"
+	if _, err = db.Exec("CREATE USER " + user + " WITH PASSWORD = '" + password + "'"); err != nil {
+		t.Skip("cannot create a contained database user:", err)
+	}
+	defer db.Exec("DROP USER " + user)
+
+	params.User, params.Password, params.Database = user, password, database
+	conn, err := sql.Open("sqlserver", params.URL().String())
+	if err != nil {
+		t.Fatal("Open connection failed:", err.Error())
+	}
+	defer conn.Close()
+	var authenticatedBy string
+	err = conn.QueryRow("SELECT DB_NAME(authenticating_database_id) FROM sys.dm_exec_sessions WHERE session_id = @@SPID").Scan(&authenticatedBy)
+	if err != nil {
+		t.Fatal("the contained user cannot log in:", err)
+	}
+	if authenticatedBy != database {
+		t.Errorf("authenticated by %s, expected %s", authenticatedBy, database)
+	}
+
+	params.Password = "wrong-password"
+	var loginErr LoginError
+	if err = testConnectionBad(t, params.URL().String()); !errors.As(err, &loginErr) {
+		t.Fatalf("expected a LoginError, got %T: %v", err, err)
+	}
+	if loginErr.Database != database {
+		t.Errorf("got database %q, expected %q", loginErr.Database, database)
+	}
+}
+
 func TestLeakedConnections(t *testing.T) {
 	goodParams := testConnParams(t)
 	badParams := testConnParams(t)
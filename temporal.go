@@ -0,0 +1,113 @@
+package mssql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-sql/civil"
+)
+
+// SystemTime is the FOR SYSTEM_TIME clause of a query of a system-versioned
+// temporal table, selecting the versions of its rows valid at a time or
+// during a period. Build it with SystemTimeAsOf, SystemTimeFromTo,
+// SystemTimeBetween, SystemTimeContainedIn or SystemTimeAll.
+type SystemTime struct {
+	// sub is the subclause, AS OF, FROM, BETWEEN, CONTAINED IN or ALL.
+	sub      string
+	from, to time.Time
+}
+
+// SystemTimeAsOf selects the rows as they were at t.
+func SystemTimeAsOf(t time.Time) SystemTime {
+	return SystemTime{sub: "AS OF", from: t}
+}
+
+// SystemTimeFromTo selects the versions valid at any time from from and
+// before to.
+func SystemTimeFromTo(from, to time.Time) SystemTime {
+	return SystemTime{sub: "FROM", from: from, to: to}
+}
+
+// SystemTimeBetween selects the versions valid at any time from from up to
+// and including to.
+func SystemTimeBetween(from, to time.Time) SystemTime {
+	return SystemTime{sub: "BETWEEN", from: from, to: to}
+}
+
+// SystemTimeContainedIn selects the versions that became valid and stopped
+// being valid from from up to and including to.
+func SystemTimeContainedIn(from, to time.Time) SystemTime {
+	return SystemTime{sub: "CONTAINED IN", from: from, to: to}
+}
+
+// SystemTimeAll selects all the versions of the rows.
+func SystemTimeAll() SystemTime {
+	return SystemTime{sub: "ALL"}
+}
+
+// Clause returns the text and arguments of the FOR SYSTEM_TIME clause, to
+// follow the name of the temporal table in a query:
+//
+//	clause, args, err := mssql.SystemTimeAsOf(t).Clause("asof")
+//	...
+//	rows, err := db.Query("select * from dbo.prices "+clause+" where id = @id",
+//		append(args, sql.Named("id", id))...)
+//
+// The times are named parameters @name, or @name_from and @name_to for a
+// period, so they are never embedded in the query text. They are sent as
+// datetime2 in UTC, the type and time zone of the period columns, and so
+// compare exactly with them.
+func (s SystemTime) Clause(name string) (string, []interface{}, error) {
+	if !validParamName(name) {
+		return "", nil, fmt.Errorf("mssql: invalid system time parameter name %q", name)
+	}
+	switch s.sub {
+	case "ALL":
+		return "FOR SYSTEM_TIME ALL", nil, nil
+	case "AS OF":
+		if s.from.IsZero() {
+			return "", nil, fmt.Errorf("mssql: system time AS OF requires a time")
+		}
+		return "FOR SYSTEM_TIME AS OF @" + name, []interface{}{sql.Named(name, systemTimeParam(s.from))}, nil
+	case "FROM", "BETWEEN", "CONTAINED IN":
+		if s.from.IsZero() || s.to.IsZero() {
+			return "", nil, fmt.Errorf("mssql: system time %s requires a start and an end time", s.sub)
+		}
+		if s.to.Before(s.from) {
+			return "", nil, fmt.Errorf("mssql: system time %s ends at %v, before its start at %v", s.sub, s.to, s.from)
+		}
+		sep := " AND "
+		if s.sub == "FROM" {
+			sep = " TO "
+		}
+		from, to := name+"_from", name+"_to"
+		var clause string
+		if s.sub == "CONTAINED IN" {
+			clause = "FOR SYSTEM_TIME CONTAINED IN (@" + from + ", @" + to + ")"
+		} else {
+			clause = "FOR SYSTEM_TIME " + s.sub + " @" + from + sep + "@" + to
+		}
+		return clause, []interface{}{
+			sql.Named(from, systemTimeParam(s.from)),
+			sql.Named(to, systemTimeParam(s.to)),
+		}, nil
+	}
+	return "", nil, fmt.Errorf("mssql: uninitialized SystemTime")
+}
+
+// systemTimeParam returns t as a datetime2 parameter in UTC.
+func systemTimeParam(t time.Time) civil.DateTime {
+	return civil.DateTimeOf(t.UTC())
+}
+
+// validParamName reports whether name can follow @ as a parameter name.
+func validParamName(name string) bool {
+	if name == "" || '0' <= name[0] && name[0] <= '9' {
+		return false
+	}
+	return strings.IndexFunc(name, func(r rune) bool {
+		return r != '_' && !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	}) < 0
+}
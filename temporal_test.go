@@ -0,0 +1,110 @@
+package mssql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang-sql/civil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemTimeClause(t *testing.T) {
+	from := time.Date(2024, 3, 1, 10, 30, 0, 123456700, time.FixedZone("CET", 3600))
+	to := from.Add(time.Hour)
+	utcFrom := civil.DateTime{Date: civil.Date{Year: 2024, Month: 3, Day: 1}, Time: civil.Time{Hour: 9, Minute: 30, Nanosecond: 123456700}}
+	utcTo := civil.DateTime{Date: utcFrom.Date, Time: civil.Time{Hour: 10, Minute: 30, Nanosecond: 123456700}}
+	period := []interface{}{sql.Named("v_from", utcFrom), sql.Named("v_to", utcTo)}
+
+	tests := []struct {
+		st     SystemTime
+		clause string
+		args   []interface{}
+	}{
+		{SystemTimeAsOf(from), "FOR SYSTEM_TIME AS OF @v", []interface{}{sql.Named("v", utcFrom)}},
+		{SystemTimeFromTo(from, to), "FOR SYSTEM_TIME FROM @v_from TO @v_to", period},
+		{SystemTimeBetween(from, to), "FOR SYSTEM_TIME BETWEEN @v_from AND @v_to", period},
+		{SystemTimeContainedIn(from, to), "FOR SYSTEM_TIME CONTAINED IN (@v_from, @v_to)", period},
+		{SystemTimeAll(), "FOR SYSTEM_TIME ALL", nil},
+	}
+	for _, tt := range tests {
+		clause, args, err := tt.st.Clause("v")
+		require.NoError(t, err, tt.clause)
+		assert.Equal(t, tt.clause, clause)
+		assert.Equal(t, tt.args, args, tt.clause)
+	}
+
+	_, _, err := SystemTimeBetween(from, from).Clause("v")
+	assert.NoError(t, err, "an empty period")
+
+	for name, st := range map[string]SystemTime{
+		"end before start": SystemTimeFromTo(to, from),
+		"no start":         SystemTimeBetween(time.Time{}, to),
+		"no end":           SystemTimeContainedIn(from, time.Time{}),
+		"no time":          SystemTimeAsOf(time.Time{}),
+		"zero value":       {},
+	} {
+		_, _, err := st.Clause("v")
+		assert.Error(t, err, name)
+	}
+	for _, name := range []string{"", "1v", "v; drop table t", "@v", "v-1"} {
+		_, _, err := SystemTimeAsOf(from).Clause(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSystemTimeQuery(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	table := "dbo.system_time_test"
+	drop := "IF OBJECT_ID('" + table + "') IS NOT NULL BEGIN ALTER TABLE " + table + " SET (SYSTEM_VERSIONING = OFF); DROP TABLE " + table + "; DROP TABLE " + table + "_history END"
+	_, err := conn.Exec(drop)
+	require.NoError(t, err)
+	_, err = conn.Exec(`CREATE TABLE ` + table + ` (
+	id int NOT NULL PRIMARY KEY CLUSTERED,
+	price int NOT NULL,
+	valid_from datetime2 GENERATED ALWAYS AS ROW START NOT NULL,
+	valid_to datetime2 GENERATED ALWAYS AS ROW END NOT NULL,
+	PERIOD FOR SYSTEM_TIME (valid_from, valid_to)
+) WITH (SYSTEM_VERSIONING = ON (HISTORY_TABLE = ` + table + `_history))`)
+	if err != nil {
+		t.Skip("temporal tables are not available on this server:", err)
+	}
+	defer conn.Exec(drop)
+
+	_, err = conn.Exec("INSERT INTO "+table+" (id, price) VALUES (1, @p1)", 10)
+	require.NoError(t, err)
+	var before time.Time
+	require.NoError(t, conn.QueryRow("SELECT SYSUTCDATETIME()").Scan(&before))
+	// the update must start after before
+	time.Sleep(10 * time.Millisecond)
+	_, err = conn.Exec("UPDATE "+table+" SET price = @p1 WHERE id = 1", 20)
+	require.NoError(t, err)
+	var after time.Time
+	require.NoError(t, conn.QueryRow("SELECT SYSUTCDATETIME()").Scan(&after))
+
+	price := func(st SystemTime) []int {
+		t.Helper()
+		clause, args, err := st.Clause("at")
+		require.NoError(t, err)
+		rows, err := conn.Query("SELECT price FROM "+table+" "+clause+" WHERE id = @id ORDER BY valid_from",
+			append(args, sql.Named("id", 1))...)
+		require.NoError(t, err)
+		defer rows.Close()
+		var prices []int
+		for rows.Next() {
+			var p int
+			require.NoError(t, rows.Scan(&p))
+			prices = append(prices, p)
+		}
+		require.NoError(t, rows.Err())
+		return prices
+	}
+	assert.Equal(t, []int{10}, price(SystemTimeAsOf(before)), "the prior version")
+	assert.Equal(t, []int{20}, price(SystemTimeAsOf(after)), "the current version")
+	assert.Equal(t, []int{10, 20}, price(SystemTimeBetween(before, after)))
+	assert.Equal(t, []int{10, 20}, price(SystemTimeAll()))
+}
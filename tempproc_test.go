@@ -0,0 +1,70 @@
+package mssql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareTempProc(t *testing.T) {
+	checkConnStr(t)
+	db, err := sql.Open("sqlserver", makeConnStr(t).String())
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE #tempproc (id int PRIMARY KEY, name nvarchar(20))")
+	require.NoError(t, err)
+	insert, err := PrepareTempProc(ctx, conn, "@id int, @name nvarchar(20)",
+		"SET NOCOUNT ON; INSERT INTO #tempproc (id, name) VALUES (@id, @name)")
+	require.NoError(t, err)
+	for i := 1; i <= 50; i++ {
+		_, err = insert.ExecContext(ctx, i, "row")
+		require.NoError(t, err)
+	}
+	_, err = insert.ExecContext(ctx, sql.Named("name", "named"), sql.Named("id", 51))
+	require.NoError(t, err)
+
+	sum, err := PrepareTempProc(ctx, conn, "@min int",
+		"SELECT COUNT(*), SUM(id), MAX(name) FROM #tempproc WHERE id >= @min")
+	require.NoError(t, err)
+	assert.NotEqual(t, insert.Name(), sum.Name())
+	rows, err := sum.QueryContext(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	var n, total int
+	var name string
+	require.NoError(t, rows.Scan(&n, &total, &name))
+	require.NoError(t, rows.Close())
+	assert.Equal(t, 51, n)
+	assert.Equal(t, 51*52/2, total)
+	assert.Equal(t, "row", name)
+
+	_, err = insert.ExecContext(ctx, 1, "duplicate")
+	var sqlErr Error
+	require.True(t, errors.As(err, &sqlErr), "%v", err)
+	assert.Equal(t, int32(2627), sqlErr.Number, "errors of the procedure are returned")
+
+	require.NoError(t, sum.Close(ctx))
+	_, err = sum.QueryContext(ctx, 1)
+	require.True(t, errors.As(err, &sqlErr), "%v", err)
+	assert.Equal(t, int32(errNoSuchProc), sqlErr.Number, "a closed procedure is not created again")
+
+	// the session reset of the pooled connection drops the procedure
+	proc := insert.Name()
+	require.NoError(t, conn.Close())
+	_, err = insert.ExecContext(ctx, 52, "closed")
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	conn, err = db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	var id sql.NullInt64
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT OBJECT_ID(N'tempdb.."+proc+"')").Scan(&id))
+	assert.False(t, id.Valid, "%s survived the session reset", proc)
+}
@@ -0,0 +1,80 @@
+package mssql
+
+import (
+	"context"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextNormalization is a Unicode normalization form text values are
+// returned in. See WithTextNormalization.
+type TextNormalization int
+
+const (
+	// TextAsStored returns text as the server stores it, the default.
+	TextAsStored TextNormalization = iota
+	// TextNFC returns text in the canonical composed form, in which é is
+	// one code point.
+	TextNFC
+	// TextNFD returns text in the canonical decomposed form, in which é is
+	// e followed by a combining acute accent.
+	TextNFD
+	// TextNFKC returns text in the compatibility composed form, which also
+	// replaces compatibility characters such as ﬁ by their equivalents.
+	TextNFKC
+	// TextNFKD returns text in the compatibility decomposed form.
+	TextNFKD
+)
+
+type textNormalizationKey struct{}
+
+// WithTextNormalization returns a copy of ctx whose queries return the
+// values of char, varchar, nchar, nvarchar, text, ntext and xml columns,
+// and sql_variant values holding them, converted to the Unicode
+// normalization form, so that strings comparing equal to the user compare
+// equal in Go too: the server stores text as it was written, and "é" may
+// arrive composed or decomposed. Connector.TextNormalization sets this for
+// all the queries of its connections; the form set on ctx takes
+// precedence.
+//
+// Checking a value reads all of its text, which costs little next to
+// decoding it for ASCII and already normalized text; a value that is not
+// in the form is copied. Values read with StringsAsBytes are returned
+// unchanged, and parameters are sent as given.
+func WithTextNormalization(ctx context.Context, form TextNormalization) context.Context {
+	return context.WithValue(ctx, textNormalizationKey{}, form)
+}
+
+// textNormalization returns the normalization form of the text returned by
+// queries run with ctx on c.
+func (c *Conn) textNormalization(ctx context.Context) TextNormalization {
+	if form, ok := ctx.Value(textNormalizationKey{}).(TextNormalization); ok {
+		return form
+	}
+	if c.connector != nil {
+		return c.connector.TextNormalization
+	}
+	return TextAsStored
+}
+
+// normalizeText converts the string values of row to form.
+func normalizeText(form TextNormalization, row []interface{}) {
+	var f norm.Form
+	switch form {
+	case TextNFC:
+		f = norm.NFC
+	case TextNFD:
+		f = norm.NFD
+	case TextNFKC:
+		f = norm.NFKC
+	case TextNFKD:
+		f = norm.NFKD
+	default:
+		return
+	}
+	for i, v := range row {
+		if s, ok := v.(string); ok {
+			row[i] = f.String(s)
+		}
+	}
+}
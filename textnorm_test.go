@@ -0,0 +1,73 @@
+package mssql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	composed   = "caf\u00e9"
+	decomposed = "cafe\u0301"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		form TextNormalization
+		want string
+	}{
+		{TextAsStored, decomposed},
+		{TextNFC, composed},
+		{TextNFD, decomposed},
+		{TextNFKC, composed},
+		{TextNFKD, decomposed},
+	}
+	for _, tt := range tests {
+		row := []interface{}{decomposed, int64(1), []byte(decomposed), nil}
+		normalizeText(tt.form, row)
+		assert.Equal(t, []interface{}{tt.want, int64(1), []byte(decomposed), nil}, row, "form %d", tt.form)
+	}
+
+	row := []interface{}{composed}
+	normalizeText(TextNFD, row)
+	assert.Equal(t, decomposed, row[0])
+	row = []interface{}{"\ufb01"}
+	normalizeText(TextNFKC, row)
+	assert.Equal(t, "fi", row[0], "compatibility characters are replaced")
+}
+
+func TestTextNormalizationSetting(t *testing.T) {
+	ctx := context.Background()
+	c := &Conn{}
+	assert.Equal(t, TextAsStored, c.textNormalization(ctx))
+	c.connector = &Connector{TextNormalization: TextNFC}
+	assert.Equal(t, TextNFC, c.textNormalization(ctx))
+	assert.Equal(t, TextNFD, c.textNormalization(WithTextNormalization(ctx, TextNFD)))
+	assert.Equal(t, TextAsStored, c.textNormalization(WithTextNormalization(ctx, TextAsStored)), "the context takes precedence")
+}
+
+func TestTextNormalizationOfResults(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	query := "select N'cafe' + nchar(0x301), cast(N'caf' + nchar(0xe9) as sql_variant)"
+	for _, form := range []TextNormalization{TextNFC, TextNFD} {
+		var text, variant string
+		err := conn.QueryRowContext(WithTextNormalization(ctx, form), query).Scan(&text, &variant)
+		require.NoError(t, err)
+		want := composed
+		if form == TextNFD {
+			want = decomposed
+		}
+		assert.Equal(t, want, text, "form %d", form)
+		assert.Equal(t, want, variant, "form %d", form)
+	}
+
+	var text string
+	require.NoError(t, conn.QueryRowContext(ctx, query).Scan(&text, new(string)))
+	assert.Equal(t, decomposed, text, "text is returned as stored by default")
+}
@@ -8,3 +8,27 @@ func getTimezone(c *Conn) *time.Location {
 	}
 	return time.UTC
 }
+
+// getZonelessLocation returns the location time.Time values are converted
+// to before they are written to the columns of types without a time zone,
+// or nil to write their wall clock as is. See msdsn.ZonelessTime.
+func getZonelessLocation(c *Conn) *time.Location {
+	if c != nil && c.sess != nil {
+		return c.sess.encoding.ZonelessLocation()
+	}
+	return nil
+}
+
+// zoneless returns t as written to the columns of types without a time
+// zone, date, time, smalldatetime, datetime and datetime2, which store its
+// wall clock: converted to loc, from getZonelessLocation, or as is when
+// loc is nil. With the default zoneless time, that is the timezone
+// connection parameter unless it is UTC, so reading the value back, which
+// is done in that location, returns the same instant, and with UTC the
+// wall clock of t, whatever its location, as the driver always did.
+func zoneless(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return t
+	}
+	return t.In(loc)
+}
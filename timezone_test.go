@@ -1,11 +1,13 @@
 package mssql
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetTimezone(t *testing.T) {
@@ -62,3 +64,172 @@ func TestGetTimezoneWithLocation(t *testing.T) {
 	result := getTimezone(conn)
 	assert.Equal(t, loc, result, "getTimezone() with location")
 }
+
+// dstInstants are the last minute before and the first minute after the
+// DST changes of 2024 in New York, where 02:00 EST became 03:00 EDT on
+// March 10 and 02:00 EDT became 01:00 EST on November 3.
+var dstInstants = []time.Time{
+	time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC),
+	time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+	time.Date(2024, 11, 3, 4, 59, 0, 0, time.UTC),
+	time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC),
+}
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("America/New_York timezone not available")
+	}
+	return loc
+}
+
+func TestZonelessTypesAcrossDST(t *testing.T) {
+	ny := newYork(t)
+	b := &Bulk{cn: &Conn{sess: &tdsSession{encoding: msdsn.EncodeParameters{Timezone: ny}}}}
+	types := []struct {
+		name   string
+		ti     typeInfo
+		decode func([]byte) time.Time
+		// layout compared, the part of the wall clock the type holds
+		layout string
+	}{
+		{"date", typeInfo{TypeId: typeDateN}, func(buf []byte) time.Time { return decodeDate(buf, ny) }, "2006-01-02"},
+		{"time", typeInfo{TypeId: typeTimeN, Scale: 7}, func(buf []byte) time.Time { return decodeTime(7, buf, ny) }, "15:04:05"},
+		{"smalldatetime", typeInfo{TypeId: typeDateTimeN, Size: 4}, func(buf []byte) time.Time { return decodeDateTim4(buf, ny) }, time.RFC3339},
+		{"datetime", typeInfo{TypeId: typeDateTimeN, Size: 8}, func(buf []byte) time.Time { return decodeDateTime(buf, ny) }, time.RFC3339},
+		{"datetime2", typeInfo{TypeId: typeDateTime2N, Scale: 7}, func(buf []byte) time.Time { return decodeDateTime2(7, buf, ny) }, time.RFC3339},
+	}
+	for _, tt := range types {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, instant := range dstInstants {
+				p, err := b.makeParam(instant, columnStruct{ti: tt.ti})
+				require.NoError(t, err)
+				got := tt.decode(p.buffer)
+				assert.Equal(t, instant.In(ny).Format(tt.layout), got.Format(tt.layout), "%v", instant)
+				if tt.layout == time.RFC3339 {
+					assert.True(t, instant.Equal(got), "%v read back as %v", instant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestZonelessKeepsWallClockInUTC(t *testing.T) {
+	ny := newYork(t)
+	local := time.Date(2024, 3, 10, 3, 30, 0, 0, ny)
+	utc := getZonelessLocation(&Conn{sess: &tdsSession{encoding: msdsn.EncodeParameters{Timezone: time.UTC}}})
+	assert.Nil(t, utc)
+	assert.Equal(t, local, zoneless(local, utc))
+	assert.Equal(t, local, zoneless(local, nil))
+	assert.Equal(t, "2024-03-10T03:30:00Z", decodeDateTime2(7, encodeDateTime2(zoneless(local, utc), 7), time.UTC).Format(time.RFC3339))
+	assert.Equal(t, "2024-03-10T07:30:00Z", decodeDateTime2(7, encodeDateTime2(zoneless(local.UTC(), ny), 7), ny).UTC().Format(time.RFC3339))
+}
+
+func TestZonelessTimeModes(t *testing.T) {
+	ny := newYork(t)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("Asia/Tokyo timezone not available")
+	}
+	// 10:00 in New York is 15:00 UTC and 00:00 the next day in Tokyo
+	instant := time.Date(2024, 1, 15, 10, 0, 0, 0, ny)
+	tests := []struct {
+		mode     msdsn.ZonelessTime
+		timezone *time.Location
+		want     string
+	}{
+		{msdsn.ZonelessDefault, time.UTC, "2024-01-15 10:00:00"},
+		{msdsn.ZonelessDefault, tokyo, "2024-01-16 00:00:00"},
+		{msdsn.ZonelessWallClock, time.UTC, "2024-01-15 10:00:00"},
+		{msdsn.ZonelessWallClock, tokyo, "2024-01-15 10:00:00"},
+		{msdsn.ZonelessUTC, time.UTC, "2024-01-15 15:00:00"},
+		{msdsn.ZonelessUTC, tokyo, "2024-01-15 15:00:00"},
+		{msdsn.ZonelessTimezone, time.UTC, "2024-01-15 15:00:00"},
+		{msdsn.ZonelessTimezone, tokyo, "2024-01-16 00:00:00"},
+	}
+	const layout = "2006-01-02 15:04:05"
+	for _, tt := range tests {
+		c := &Conn{sess: &tdsSession{encoding: msdsn.EncodeParameters{Timezone: tt.timezone, ZonelessTime: tt.mode}}}
+		c.sess.loginAck.TDSVersion = verTDS74
+
+		// parameters are sent as datetimeoffset, whose wall clock a column
+		// without a time zone stores
+		p, err := (&Stmt{c: c}).makeParam(instant)
+		require.NoError(t, err)
+		sent := decodeDateTimeOffset(p.ti.Scale, p.buffer)
+		assert.Equal(t, tt.want, sent.Format(layout), "mode %d in %v", tt.mode, tt.timezone)
+		assert.True(t, instant.Equal(sent), "mode %d in %v keeps the instant", tt.mode, tt.timezone)
+
+		p, err = (&Bulk{cn: c}).makeParam(instant, columnStruct{ti: typeInfo{TypeId: typeDateTime2N, Scale: 7}})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, decodeDateTime2(7, p.buffer, time.UTC).Format(layout), "bulk copy in mode %d in %v", tt.mode, tt.timezone)
+	}
+}
+
+func TestZonelessTimeStoredValue(t *testing.T) {
+	ny := newYork(t)
+	instant := time.Date(2024, 1, 15, 10, 0, 0, 0, ny)
+	for mode, want := range map[msdsn.ZonelessTime]string{
+		msdsn.ZonelessWallClock: "2024-01-15 10:00:00",
+		msdsn.ZonelessUTC:       "2024-01-15 15:00:00",
+	} {
+		config := testConnParams(t)
+		config.Encoding.ZonelessTime = mode
+		db, err := sql.Open("sqlserver", config.URL().String())
+		require.NoError(t, err)
+		defer db.Close()
+
+		var dt2, dt string
+		err = db.QueryRowContext(testContext(t), `declare @dt2 datetime2 = @p1, @dt datetime = @p1
+select convert(varchar(19), @dt2, 120), convert(varchar(19), @dt, 120)`, instant).Scan(&dt2, &dt)
+		require.NoError(t, err)
+		assert.Equal(t, want, dt2, "mode %d", mode)
+		assert.Equal(t, want, dt, "mode %d", mode)
+	}
+}
+
+func TestTimeParamUsesTimezoneOffset(t *testing.T) {
+	ny := newYork(t)
+	s := &Stmt{c: &Conn{sess: &tdsSession{encoding: msdsn.EncodeParameters{Timezone: ny}}}}
+	s.c.sess.loginAck.TDSVersion = verTDS74
+	for _, instant := range dstInstants {
+		p, err := s.makeParam(instant)
+		require.NoError(t, err)
+		require.Equal(t, uint8(typeDateTimeOffsetN), p.ti.TypeId)
+		got := decodeDateTimeOffset(p.ti.Scale, p.buffer)
+		assert.True(t, instant.Equal(got), "%v sent as %v", instant, got)
+		_, want := instant.In(ny).Zone()
+		_, offset := got.Zone()
+		assert.Equal(t, want, offset, "%v", instant)
+	}
+
+	// DateTimeOffset keeps the offset of the value
+	fixed := time.Date(2024, 3, 10, 12, 0, 0, 0, time.FixedZone("", 5*3600))
+	p, err := s.makeParam(DateTimeOffset(fixed))
+	require.NoError(t, err)
+	_, offset := decodeDateTimeOffset(p.ti.Scale, p.buffer).Zone()
+	assert.Equal(t, 5*3600, offset)
+}
+
+func TestTimezoneRoundTripAcrossDST(t *testing.T) {
+	ny := newYork(t)
+	config := testConnParams(t)
+	config.Encoding.Timezone = ny
+	db, err := sql.Open("sqlserver", config.URL().String())
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := testContext(t)
+
+	for _, instant := range dstInstants {
+		var dt2, dt, sdt, dto time.Time
+		var wall string
+		err := db.QueryRowContext(ctx, `declare @dt2 datetime2 = @p1, @dt datetime = @p1, @sdt smalldatetime = @p1, @dto datetimeoffset = @p1
+select @dt2, @dt, @sdt, @dto, convert(varchar(19), @dt2, 120)`, instant).Scan(&dt2, &dt, &sdt, &dto, &wall)
+		require.NoError(t, err)
+		assert.Equal(t, instant.In(ny).Format("2006-01-02 15:04:05"), wall, "wall clock stored")
+		for _, got := range []time.Time{dt2, dt, sdt, dto} {
+			assert.True(t, instant.Equal(got), "%v read back as %v", instant, got)
+		}
+	}
+}
@@ -108,6 +108,7 @@ const (
 // https://msdn.microsoft.com/en-us/library/dd357363.aspx
 const (
 	colFlagNullable  = 1
+	colFlagIdentity  = 0x10
 	colFlagEncrypted = 0x0800
 	// TODO implement more flags
 )
@@ -173,6 +174,19 @@ func (d doneStruct) getError() Error {
 
 type doneInProcStruct doneStruct
 
+// readTranDescriptor reads the 8 byte transaction descriptor sent as the
+// new value of a BEGIN TRANSACTION environment change.
+func readTranDescriptor(r io.Reader) uint64 {
+	tranid, err := readBVarByte(r)
+	if err != nil {
+		badStreamPanic(err)
+	}
+	if len(tranid) != 8 {
+		badStreamPanicf("invalid size of transaction identifier: %d", len(tranid))
+	}
+	return binary.LittleEndian.Uint64(tranid)
+}
+
 // ENVCHANGE stream
 // http://msdn.microsoft.com/en-us/library/dd303449.aspx
 func processEnvChg(ctx context.Context, sess *tdsSession) {
@@ -284,14 +298,7 @@ func processEnvChg(ctx context.Context, sess *tdsSession) {
 				badStreamPanic(err)
 			}
 		case envTypBeginTran:
-			tranid, err := readBVarByte(r)
-			if len(tranid) != 8 {
-				badStreamPanicf("invalid size of transaction identifier: %d", len(tranid))
-			}
-			sess.tranid = binary.LittleEndian.Uint64(tranid)
-			if err != nil {
-				badStreamPanic(err)
-			}
+			sess.tranid = readTranDescriptor(r)
 			sess.LogF(ctx, msdsn.LogTransaction, "BEGIN TRANSACTION %x", sess.tranid)
 			_, err = readBVarByte(r)
 			if err != nil {
@@ -313,25 +320,30 @@ func processEnvChg(ctx context.Context, sess *tdsSession) {
 			}
 			sess.tranid = 0
 		case envEnlistDTC:
-			// currently ignored
-			// new value, should be 0
-			if _, err = readBVarChar(r); err != nil {
+			// new value, the descriptor of the enlisted transaction
+			tranid, err := readBVarByte(r)
+			if err != nil {
 				badStreamPanic(err)
 			}
-			// old value
-			if _, err = readBVarChar(r); err != nil {
+			if len(tranid) == 8 {
+				sess.tranid = binary.LittleEndian.Uint64(tranid)
+				sess.LogF(ctx, msdsn.LogTransaction, "ENLIST DTC TRANSACTION %x", sess.tranid)
+			}
+			// old value, should be 0
+			if _, err = readBVarByte(r); err != nil {
 				badStreamPanic(err)
 			}
 		case envDefectTran:
-			// currently ignored
-			// new value
-			if _, err = readBVarChar(r); err != nil {
+			// new value, should be 0
+			if _, err = readBVarByte(r); err != nil {
 				badStreamPanic(err)
 			}
-			// old value, should be 0
-			if _, err = readBVarChar(r); err != nil {
+			// old value, the descriptor of the defected transaction
+			if _, err = readBVarByte(r); err != nil {
 				badStreamPanic(err)
 			}
+			sess.LogF(ctx, msdsn.LogTransaction, "DEFECT TRANSACTION %x", sess.tranid)
+			sess.tranid = 0
 		case envDatabaseMirrorPartner:
 			sess.partner, err = readBVarChar(r)
 			if err != nil {
@@ -363,15 +375,16 @@ func processEnvChg(ctx context.Context, sess *tdsSession) {
 				badStreamPanic(err)
 			}
 		case envTranEnded:
-			// currently ignored
-			// old value, B_VARBYTE
-			if _, err = readBVarChar(r); err != nil {
+			// new value, should be 0
+			if _, err = readBVarByte(r); err != nil {
 				badStreamPanic(err)
 			}
-			// should be 0
-			if _, err = readBVarChar(r); err != nil {
+			// old value, the descriptor of the ended transaction
+			if _, err = readBVarByte(r); err != nil {
 				badStreamPanic(err)
 			}
+			sess.LogF(ctx, msdsn.LogTransaction, "TRANSACTION ENDED %x", sess.tranid)
+			sess.tranid = 0
 		case envResetConnAck:
 			// currently ignored
 			// old value, should be 0
@@ -435,34 +448,88 @@ func parseReturnStatus(r *tdsBuffer) ReturnStatus {
 }
 
 func parseOrder(r *tdsBuffer) (res orderStruct) {
+	// The ORDER token lists the 1-based ordinals of the ORDER BY columns.
+	// It precedes the TABNAME and COLINFO tokens of browse-mode results.
 	len := int(r.uint16())
 	res.ColIds = make([]uint16, len/2)
 	for i := 0; i < len/2; i++ {
 		res.ColIds[i] = r.uint16()
 	}
+	if len%2 != 0 {
+		// skip the odd byte so the following token stays aligned
+		r.byte()
+	}
 	return res
 }
 
 // https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/9b5c1e40-b6ce-4e5f-9ce2-2284cc44a38d
-func parseTabName(r *tdsBuffer) {
+func parseTabName(r *tdsBuffer) (tables [][]string) {
 	// The TABNAME token describes the table names associated with a result set.
 	// It is sent in response to browse-mode queries and INSERT/UPDATE/DELETE on tables with triggers.
-	// We read and discard the data since it is informational only.
+	// Each table name has up to four parts: server.database.schema.table.
+	// The token is only informational, so a malformed payload yields the
+	// names decoded before it rather than failing the stream.
 	size := r.uint16()
-	if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
-		badStreamPanic(err)
+	buf := make([]byte, size)
+	r.ReadFull(buf)
+	rd := bytes.NewReader(buf)
+	for rd.Len() > 0 {
+		numParts, _ := rd.ReadByte()
+		parts := make([]string, numParts)
+		for i := range parts {
+			part, err := readUsVarChar(rd)
+			if err != nil {
+				return tables
+			}
+			parts[i] = part
+		}
+		tables = append(tables, parts)
 	}
+	return tables
+}
+
+// COLINFO status bits
+const (
+	colInfoExpression    = 0x04
+	colInfoKey           = 0x08
+	colInfoHidden        = 0x10
+	colInfoDifferentName = 0x20
+)
+
+type colInfoStruct struct {
+	ColNum   uint8
+	TableNum uint8
+	Status   uint8
+	// ColName is the base column name, sent only when it differs from
+	// the name of the result column.
+	ColName string
 }
 
 // https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/7ec86c73-d57e-4a0d-b945-d660ef8e5bf8
-func parseColInfo(r *tdsBuffer) {
+func parseColInfo(r *tdsBuffer) (res []colInfoStruct) {
 	// The COLINFO token describes the source of the column data in browse mode and
 	// for INSERT/UPDATE/DELETE on tables with triggers.
-	// We read and discard the data since it is informational only.
+	// As with TABNAME, a malformed payload yields the entries decoded before it.
 	size := r.uint16()
-	if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
-		badStreamPanic(err)
+	buf := make([]byte, size)
+	r.ReadFull(buf)
+	rd := bytes.NewReader(buf)
+	for rd.Len() > 0 {
+		var hdr [3]byte
+		if _, err := io.ReadFull(rd, hdr[:]); err != nil {
+			return res
+		}
+		info := colInfoStruct{ColNum: hdr[0], TableNum: hdr[1], Status: hdr[2]}
+		if info.Status&colInfoDifferentName != 0 {
+			name, err := readBVarChar(rd)
+			if err != nil {
+				return res
+			}
+			info.ColName = name
+		}
+		res = append(res, info)
 	}
+	return res
 }
 
 // https://msdn.microsoft.com/en-us/library/dd340421.aspx
@@ -1031,11 +1098,21 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 		badStreamPanic(fmt.Errorf("unexpected packet type in reply: got %v, expected %v", packet_type, packReply))
 	}
 	var columns []columnStruct
+	var tables [][]string
+	// arena holds the text values of a row read with StringsAsBytes
+	var arena rowArena
 	errs := make([]Error, 0, 5)
+	trace := tokenTraceFromContext(ctx)
 	for tokens := 0; ; tokens += 1 {
 		token := token(sess.buf.byte())
 		sess.LogF(ctx, msdsn.LogDebug, "got token %v", token)
 		switch token {
+		case tokenColMetadata, tokenRow, tokenNbcRow, tokenDone, tokenDoneProc, tokenDoneInProc, tokenError, tokenInfo:
+			// traced with their contents below
+		default:
+			trace.add(token, TokenEvent{})
+		}
+		switch token {
 		case tokenSSPI:
 			ch <- parseSSPIMsg(sess.buf)
 			return
@@ -1052,19 +1129,23 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			featureExtAck := parseFeatureExtAck(sess.buf)
 			ch <- featureExtAck
 		case tokenTabName:
-			parseTabName(sess.buf)
+			tables = parseTabName(sess.buf)
 		case tokenColInfo:
-			parseColInfo(sess.buf)
+			info := parseColInfo(sess.buf)
+			if outs.baseColumns != nil {
+				ch <- newBaseColumns(columns, tables, info)
+			}
 		case tokenOrder:
 			order := parseOrder(sess.buf)
 			ch <- order
 		case tokenDoneInProc:
 			done := parseDoneInProc(sess.buf)
+			trace.add(token, TokenEvent{Status: done.Status, CurCmd: done.CurCmd, RowCount: done.RowCount})
 
 			if done.Status&doneCount != 0 {
 				sess.LogF(ctx, msdsn.LogRows, "(%d rows affected)", done.RowCount)
 
-				if (colsReceived || done.CurCmd != cmdSelect) && outs.msgq != nil {
+				if countsRowsAffected(done.CurCmd, colsReceived) && outs.msgq != nil {
 					_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgRowsAffected{Count: int64(done.RowCount)})
 				}
 			}
@@ -1090,6 +1171,7 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			}
 		case tokenDone, tokenDoneProc:
 			done := parseDone(sess.buf)
+			trace.add(token, TokenEvent{Status: done.Status, CurCmd: done.CurCmd, RowCount: done.RowCount})
 			done.errors = errs
 			if outs.msgq != nil {
 				errs = make([]Error, 0, 5)
@@ -1106,7 +1188,7 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			if done.Status&doneCount != 0 {
 				sess.LogF(ctx, msdsn.LogRows, "(Rows affected: %d)", done.RowCount)
 
-				if (colsReceived || done.CurCmd != cmdSelect) && outs.msgq != nil {
+				if countsRowsAffected(done.CurCmd, colsReceived) && outs.msgq != nil {
 					_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgRowsAffected{Count: int64(done.RowCount)})
 				}
 
@@ -1130,7 +1212,16 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			}
 		case tokenColMetadata:
 			columns = parseColMetadata72(sess.buf, sess)
+			if trace != nil {
+				trace.add(token, TokenEvent{Columns: columnNames(columns)})
+			}
 			ch <- columns
+			if outs.xmlSchemas != nil {
+				ch <- newXMLSchemaCollections(columns)
+			}
+			if outs.stringsAsBytes {
+				columns = stringsAsBytesColumns(columns, &arena)
+			}
 			colsReceived = true
 			if outs.msgq != nil {
 				_ = sqlexp.ReturnMessageEnqueue(ctx, outs.msgq, sqlexp.MsgNext{})
@@ -1138,24 +1229,29 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 
 		case tokenRow:
 			row := make([]interface{}, len(columns))
+			arena.next()
 			err = parseRow(ctx, sess.buf, sess, columns, row)
 			if err != nil {
 				ch <- err
 				return
 			}
+			trace.add(token, TokenEvent{Values: len(row)})
 			ch <- row
 		case tokenNbcRow:
 			row := make([]interface{}, len(columns))
+			arena.next()
 			err = parseNbcRow(ctx, sess.buf, sess, columns, row)
 			if err != nil {
 				ch <- err
 				return
 			}
+			trace.add(token, TokenEvent{Values: len(row)})
 			ch <- row
 		case tokenEnvChange:
 			processEnvChg(ctx, sess)
 		case tokenError:
 			err := parseError72(sess.buf)
+			trace.add(token, TokenEvent{Number: err.Number, Message: err.Message})
 			sess.LogF(ctx, msdsn.LogDebug, "got ERROR %d %s", err.Number, err.Message)
 			errs = append(errs, err)
 			sess.LogS(ctx, msdsn.LogErrors, err.Message)
@@ -1164,6 +1260,7 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			}
 		case tokenInfo:
 			info := parseInfo(sess.buf)
+			trace.add(token, TokenEvent{Number: info.Number, Message: info.Message})
 			sess.LogF(ctx, msdsn.LogDebug, "got INFO %d %s", info.Number, info.Message)
 			sess.LogS(ctx, msdsn.LogMessages, info.Message)
 			if outs.msgq != nil {
@@ -1173,7 +1270,9 @@ func processSingleResponse(ctx context.Context, sess *tdsSession, ch chan tokenS
 			nv := parseReturnValue(sess.buf, sess)
 			if len(nv.Name) > 0 {
 				name := nv.Name[1:] // Remove the leading "@".
-				if ov, has := outs.params[name]; has {
+				if t, ok := nv.Value.(truncatedPLP); ok {
+					ch <- TruncatedValue{Column: nv.Name, Length: t.length, Received: t.received}
+				} else if ov, has := outs.outParam(name); has {
 					err = scanIntoOut(name, nv.Value, ov)
 					if err != nil {
 						fmt.Println("scan error", err)
@@ -1214,7 +1313,7 @@ func (sess *tdsSession) startResponseReader(ctx context.Context, tokChan chan to
 }
 
 func startReading(sess *tdsSession, ctx context.Context, outs outputs) *tokenProcessor {
-	tokChan := make(chan tokenStruct, 5)
+	tokChan := make(chan tokenStruct, prefetchRows(ctx))
 	sess.startResponseReader(ctx, tokChan, outs)
 	return &tokenProcessor{
 		tokChan: tokChan,
@@ -1224,26 +1323,44 @@ func startReading(sess *tdsSession, ctx context.Context, outs outputs) *tokenPro
 	}
 }
 
+// countsRowsAffected reports whether the row count of a DONE or DONEINPROC
+// token counts towards the rows affected. SELECT statements that did not
+// return a result set, such as variable assignments, report counts that
+// are not affected rows and are skipped. SELECT INTO, MERGE and the other
+// DML statements report their own command and are always counted.
+func countsRowsAffected(curCmd uint16, colsReceived bool) bool {
+	return colsReceived || curCmd != cmdSelect
+}
+
 func (t *tokenProcessor) iterateResponse() error {
+	colsReceived := false
 	for {
 		tok, err := t.nextToken()
 		if err == nil {
 			if tok == nil {
+				if sqlErr, ok := t.firstError.(Error); ok {
+					// counted over the whole response, also after the error
+					sqlErr.RowsAffected = t.rowCount
+					return sqlErr
+				}
 				return t.firstError
 			} else {
 				switch token := tok.(type) {
 				case []columnStruct:
 					t.sess.columns = token
+					colsReceived = true
 				case []interface{}:
 					t.lastRow = token
 				case doneInProcStruct:
-					if token.Status&doneCount != 0 {
+					if token.Status&doneCount != 0 && countsRowsAffected(token.CurCmd, colsReceived) {
 						t.rowCount += int64(token.RowCount)
 					}
+					colsReceived = false
 				case doneStruct:
-					if token.Status&doneCount != 0 {
+					if token.Status&doneCount != 0 && countsRowsAffected(token.CurCmd, colsReceived) {
 						t.rowCount += int64(token.RowCount)
 					}
+					colsReceived = false
 					if token.isError() && t.firstError == nil {
 						t.firstError = token.getError()
 					}
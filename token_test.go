@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDoneStructIsError(t *testing.T) {
@@ -167,8 +168,9 @@ func makeFinalBuf(data []byte) *tdsBuffer {
 
 func TestParseColInfo(t *testing.T) {
 	tests := []struct {
-		name string
-		data []byte
+		name     string
+		data     []byte
+		expected []colInfoStruct
 	}{
 		{
 			name: "empty",
@@ -177,13 +179,37 @@ func TestParseColInfo(t *testing.T) {
 		{
 			name: "with column info",
 			// length=3, ColNum=1, TableNum=1, Status=0
-			data: []byte{0x03, 0x00, 0x01, 0x01, 0x00},
+			data:     []byte{0x03, 0x00, 0x01, 0x01, 0x00},
+			expected: []colInfoStruct{{ColNum: 1, TableNum: 1}},
+		},
+		{
+			name: "with base column name",
+			// ColNum=1, TableNum=2, Status=key; ColNum=2, TableNum=2,
+			// Status=different name, B_VARCHAR "ab"; ColNum=3, TableNum=0,
+			// Status=expression
+			data: []byte{0x0e, 0x00,
+				0x01, 0x02, colInfoKey,
+				0x02, 0x02, colInfoDifferentName, 0x02, 'a', 0x00, 'b', 0x00,
+				0x03, 0x00, colInfoExpression},
+			expected: []colInfoStruct{
+				{ColNum: 1, TableNum: 2, Status: colInfoKey},
+				{ColNum: 2, TableNum: 2, Status: colInfoDifferentName, ColName: "ab"},
+				{ColNum: 3, Status: colInfoExpression},
+			},
+		},
+		{
+			name: "malformed payload keeps the decoded entries",
+			// the second entry announces a name longer than the payload
+			data:     []byte{0x08, 0x00, 0x01, 0x01, 0x00, 0x02, 0x01, colInfoDifferentName, 0x05, 'a'},
+			expected: []colInfoStruct{{ColNum: 1, TableNum: 1}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parseColInfo(makeFinalBuf(tt.data))
+			buf := makeFinalBuf(tt.data)
+			assert.Equal(t, tt.expected, parseColInfo(buf))
+			assert.Equal(t, len(tt.data), buf.rpos, "the whole token is consumed")
 		})
 	}
 
@@ -233,6 +259,71 @@ func TestParseTabName(t *testing.T) {
 	})
 }
 
+func TestParseTabNameParts(t *testing.T) {
+	usVarChar := func(s string) []byte {
+		return append([]byte{byte(len(s)), 0}, str2ucs2(s)...)
+	}
+	// two tables: db.dbo.t1 and t2
+	payload := []byte{3}
+	payload = append(payload, usVarChar("db")...)
+	payload = append(payload, usVarChar("dbo")...)
+	payload = append(payload, usVarChar("t1")...)
+	payload = append(payload, 1)
+	payload = append(payload, usVarChar("t2")...)
+	data := append([]byte{byte(len(payload)), 0}, payload...)
+
+	tables := parseTabName(makeFinalBuf(data))
+	assert.Equal(t, [][]string{{"db", "dbo", "t1"}, {"t2"}}, tables)
+}
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []uint16
+	}{
+		{"empty", []byte{0x00, 0x00}, []uint16{}},
+		{"two columns", []byte{0x04, 0x00, 0x02, 0x00, 0x01, 0x00}, []uint16{2, 1}},
+		{"odd length", []byte{0x03, 0x00, 0x03, 0x00, 0xff}, []uint16{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := makeFinalBuf(tt.data)
+			assert.Equal(t, tt.expected, parseOrder(buf).ColIds)
+			assert.Equal(t, len(tt.data), buf.rpos, "whole token consumed")
+		})
+	}
+}
+
+// TestProcessSingleResponseForBrowse verifies that the ORDER, TABNAME and
+// COLINFO tokens of a FOR BROWSE result set leave the rows around them intact.
+func TestProcessSingleResponseForBrowse(t *testing.T) {
+	stream := browseResultStream()
+	// insert ORDER BY column 2 after COLMETADATA, ahead of TABNAME
+	i := bytes.IndexByte(stream, byte(tokenTabName))
+	order := []byte{byte(tokenOrder), 0x02, 0x00, 0x02, 0x00}
+	stream = append(stream[:i:i], append(order, stream[i:]...)...)
+
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+		logger: optionalLogger{},
+	}
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(context.Background(), sess, ch, outputs{})
+	var got []tokenStruct
+	for tok := range ch {
+		if err, ok := tok.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+	}
+	require.Len(t, got, 4)
+	assert.Len(t, got[0], 4, "columns")
+	assert.Equal(t, orderStruct{ColIds: []uint16{2}}, got[1])
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(2), int64(9)}, got[2])
+	assert.Equal(t, uint64(1), got[3].(doneStruct).RowCount)
+}
+
 func TestTokenString(t *testing.T) {
 	assert.Equal(t, "tokenTabName", tokenTabName.String())
 	assert.Equal(t, "tokenColInfo", tokenColInfo.String())
@@ -467,3 +558,138 @@ func TestNextToken_CancelDrainClosedChannelStartsSecondResponse(t *testing.T) {
 		t.Fatal("expected attention packet to be written")
 	}
 }
+
+func TestIterateResponseRowsAffected(t *testing.T) {
+	const cmdInsert, cmdMerge = 0xc3, 0x117
+	tokChan := make(chan tokenStruct, 10)
+	// select @n = count(*) from t: a SELECT without a result set.
+	tokChan <- doneInProcStruct{Status: doneMore | doneCount, CurCmd: cmdSelect, RowCount: 1}
+	// select * from t: counted, like the rows affected message.
+	tokChan <- []columnStruct{{ColName: "a"}}
+	tokChan <- []interface{}{int64(1)}
+	tokChan <- doneInProcStruct{Status: doneMore | doneCount, CurCmd: cmdSelect, RowCount: 1}
+	tokChan <- doneInProcStruct{Status: doneMore | doneCount, CurCmd: cmdMerge, RowCount: 5}
+	tokChan <- doneStruct{Status: doneCount, CurCmd: cmdInsert, RowCount: 2}
+	close(tokChan)
+
+	reader := tokenProcessor{
+		tokChan: tokChan,
+		ctx:     context.Background(),
+		sess:    &tdsSession{},
+	}
+	if err := reader.iterateResponse(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.rowCount != 8 {
+		t.Errorf("expected 8 rows affected, got %d", reader.rowCount)
+	}
+}
+
+func makeReplyPacket(tokenStream []byte) []byte {
+	totalSize := 8 + len(tokenStream)
+	packet := make([]byte, totalSize)
+	packet[0] = byte(packReply)
+	packet[1] = 0x01
+	binary.BigEndian.PutUint16(packet[2:4], uint16(totalSize))
+	packet[6] = 0x01
+	copy(packet[8:], tokenStream)
+	return packet
+}
+
+// makeTranEnvChange returns an ENVCHANGE token with B_VARBYTE new and old values.
+func makeTranEnvChange(envtype uint8, newValue, oldValue []byte) []byte {
+	size := 1 + 1 + len(newValue) + 1 + len(oldValue)
+	tok := []byte{byte(tokenEnvChange), byte(size), byte(size >> 8), envtype, byte(len(newValue))}
+	tok = append(tok, newValue...)
+	tok = append(tok, byte(len(oldValue)))
+	return append(tok, oldValue...)
+}
+
+// sentTranDescriptor returns the transaction descriptor of the ALL_HEADERS
+// section of the request written to transport.
+func sentTranDescriptor(t *testing.T, transport *countingTransport) uint64 {
+	t.Helper()
+	b := transport.writes.Bytes()
+	// packet header, ALL_HEADERS total length, header length, header type
+	const offset = 8 + 4 + 4
+	if len(b) < offset+2+8 {
+		t.Fatalf("request too short: %d bytes", len(b))
+	}
+	assert.Equal(t, uint16(dataStmHdrTransDescr), binary.LittleEndian.Uint16(b[offset:]))
+	return binary.LittleEndian.Uint64(b[offset+2:])
+}
+
+func TestTransactionDescriptorFromBeginEnvChange(t *testing.T) {
+	descriptor := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	want := binary.LittleEndian.Uint64(descriptor)
+
+	done := make([]byte, 1+2+2+8)
+	done[0] = byte(tokenDone)
+	var replies []byte
+	// BEGIN TRANSACTION response
+	replies = append(replies, makeReplyPacket(append(makeTranEnvChange(envTypBeginTran, descriptor, nil), done...))...)
+	// query response
+	replies = append(replies, makeReplyPacket(done)...)
+	// COMMIT response
+	replies = append(replies, makeReplyPacket(append(makeTranEnvChange(envTypCommitTran, nil, descriptor), done...))...)
+
+	transport := &countingTransport{reader: bytes.NewReader(replies)}
+	conn := &Conn{
+		connector:      &Connector{},
+		sess:           &tdsSession{buf: newTdsBuffer(defaultPacketSize, transport), logger: optionalLogger{}},
+		connectionGood: true,
+	}
+	ctx := context.Background()
+
+	_, err := conn.begin(ctx, isolationUseCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(0), sentTranDescriptor(t, transport), "BEGIN is sent outside of a transaction")
+	assert.Equal(t, want, conn.sess.tranid)
+
+	transport.writes.Reset()
+	stmt, err := conn.prepareContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stmt.sendQuery(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, sentTranDescriptor(t, transport), "query in the transaction")
+	if err = startReading(conn.sess, ctx, outputs{}).iterateResponse(); err != nil {
+		t.Fatal(err)
+	}
+
+	transport.writes.Reset()
+	if err = conn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, sentTranDescriptor(t, transport), "COMMIT")
+	assert.Equal(t, uint64(0), conn.sess.tranid)
+}
+
+func TestProcessEnvChgDTCTransactions(t *testing.T) {
+	descriptor := []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}
+	parse := func(tok []byte, sess *tdsSession) {
+		t.Helper()
+		sess.buf = newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(tok))})
+		if _, err := sess.buf.BeginRead(); err != nil {
+			t.Fatal(err)
+		}
+		if b := sess.buf.byte(); b != byte(tokenEnvChange) {
+			t.Fatalf("unexpected token %x", b)
+		}
+		processEnvChg(context.Background(), sess)
+	}
+	sess := &tdsSession{logger: optionalLogger{}}
+
+	parse(makeTranEnvChange(envEnlistDTC, descriptor, nil), sess)
+	assert.Equal(t, binary.LittleEndian.Uint64(descriptor), sess.tranid, "enlist")
+	parse(makeTranEnvChange(envDefectTran, nil, descriptor), sess)
+	assert.Equal(t, uint64(0), sess.tranid, "defect")
+
+	sess.tranid = 42
+	parse(makeTranEnvChange(envTranEnded, nil, descriptor), sess)
+	assert.Equal(t, uint64(0), sess.tranid, "transaction ended")
+}
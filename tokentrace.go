@@ -0,0 +1,84 @@
+package mssql
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// TokenEvent describes a TDS token of a server response captured by a
+// TokenTrace. Fields that do not apply to the token are left zero.
+type TokenEvent struct {
+	// Token is the TDS token type, such as 0x81 for COLMETADATA.
+	Token byte
+	// Name is the protocol name of the token, such as "COLMETADATA".
+	Name string
+	// Columns holds the column names of a COLMETADATA token.
+	Columns []string
+	// Values is the number of values of a ROW or NBCROW token.
+	Values int
+	// Status, CurCmd and RowCount hold the fields of DONE, DONEPROC and
+	// DONEINPROC tokens.
+	Status   uint16
+	CurCmd   uint16
+	RowCount uint64
+	// Number and Message hold the fields of ERROR and INFO tokens.
+	Number  int32
+	Message string
+}
+
+// TokenTrace records the tokens the server sends in response to the
+// requests made with a context from WithTokenTrace. It is meant for
+// protocol debugging and is safe for concurrent use.
+type TokenTrace struct {
+	mu     sync.Mutex
+	events []TokenEvent
+}
+
+// Events returns the tokens recorded so far, in the order they were received.
+func (t *TokenTrace) Events() []TokenEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TokenEvent(nil), t.events...)
+}
+
+// Reset discards the recorded tokens.
+func (t *TokenTrace) Reset() {
+	t.mu.Lock()
+	t.events = nil
+	t.mu.Unlock()
+}
+
+// add records a token. It does nothing when t is nil.
+func (t *TokenTrace) add(tok token, ev TokenEvent) {
+	if t == nil {
+		return
+	}
+	ev.Token = byte(tok)
+	ev.Name = strings.ToUpper(strings.TrimPrefix(tok.String(), "token"))
+	t.mu.Lock()
+	t.events = append(t.events, ev)
+	t.mu.Unlock()
+}
+
+type tokenTraceKey struct{}
+
+// WithTokenTrace returns a copy of ctx that records in trace the tokens
+// of the responses to queries run with it. Tracing is off otherwise, and
+// adds no cost beyond a context lookup per response.
+func WithTokenTrace(ctx context.Context, trace *TokenTrace) context.Context {
+	return context.WithValue(ctx, tokenTraceKey{}, trace)
+}
+
+func tokenTraceFromContext(ctx context.Context) *TokenTrace {
+	trace, _ := ctx.Value(tokenTraceKey{}).(*TokenTrace)
+	return trace
+}
+
+func columnNames(columns []columnStruct) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.ColName
+	}
+	return names
+}
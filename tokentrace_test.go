@@ -0,0 +1,74 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenTraceCapturesResponse(t *testing.T) {
+	bVarChar := func(s string) []byte {
+		return append([]byte{byte(len(s))}, str2ucs2(s)...)
+	}
+	var stream []byte
+	// COLMETADATA: one int column named id
+	stream = append(stream, byte(tokenColMetadata), 0x01, 0x00, 0, 0, 0, 0, 0, 0, typeInt4)
+	stream = append(stream, bVarChar("id")...)
+	// ROW
+	stream = append(stream, byte(tokenRow), 0x07, 0x00, 0x00, 0x00)
+	// INFO 5701 "hi" from server s
+	info := []byte{0x45, 0x16, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00}
+	info = append(info, str2ucs2("hi")...)
+	info = append(info, bVarChar("s")...)
+	info = append(info, 0x00, 0x01, 0x00, 0x00, 0x00)
+	stream = append(stream, byte(tokenInfo), byte(len(info)), 0x00)
+	stream = append(stream, info...)
+	// ENVCHANGE: database changed from x to db
+	env := append([]byte{envTypDatabase}, bVarChar("db")...)
+	env = append(env, bVarChar("x")...)
+	stream = append(stream, byte(tokenEnvChange), byte(len(env)), 0x00)
+	stream = append(stream, env...)
+	// DONE with a row count
+	done := make([]byte, 1+2+2+8)
+	done[0] = byte(tokenDone)
+	binary.LittleEndian.PutUint16(done[1:], doneCount)
+	binary.LittleEndian.PutUint16(done[3:], cmdSelect)
+	binary.LittleEndian.PutUint64(done[5:], 1)
+	stream = append(stream, done...)
+
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+		logger: optionalLogger{},
+	}
+	trace := &TokenTrace{}
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(WithTokenTrace(context.Background(), trace), sess, ch, outputs{})
+	for tok := range ch {
+		if err, ok := tok.(error); ok {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []TokenEvent{
+		{Token: byte(tokenColMetadata), Name: "COLMETADATA", Columns: []string{"id"}},
+		{Token: byte(tokenRow), Name: "ROW", Values: 1},
+		{Token: byte(tokenInfo), Name: "INFO", Number: 5701, Message: "hi"},
+		{Token: byte(tokenEnvChange), Name: "ENVCHANGE"},
+		{Token: byte(tokenDone), Name: "DONE", Status: doneCount, CurCmd: cmdSelect, RowCount: 1},
+	}
+	assert.Equal(t, expected, trace.Events())
+
+	trace.Reset()
+	assert.Empty(t, trace.Events())
+}
+
+func TestTokenTraceDisabled(t *testing.T) {
+	assert.Nil(t, tokenTraceFromContext(context.Background()))
+	// A nil trace ignores tokens.
+	var trace *TokenTrace
+	require.NotPanics(t, func() { trace.add(tokenDone, TokenEvent{}) })
+}
@@ -0,0 +1,48 @@
+package mssql
+
+import "fmt"
+
+// TruncatedValue is returned by Rows.Next for a row holding a large value,
+// of a (max), xml or CLR type, the server sent less of, or more, than the
+// length it announced for it. The row is not returned rather than holding
+// a corrupt value; the rows after it can still be read. An output
+// parameter received so is not set, and the query fails with the error.
+//
+// Values truncated by the server to SET TEXTSIZE, which the login sets to
+// unlimited, are sent with their truncated length, which the driver cannot
+// tell from a whole value: compare the length of such a value with its
+// DATALENGTH to detect it.
+type TruncatedValue struct {
+	// Column is the name of the column, or of the output parameter.
+	Column string
+	// Length is the length in bytes the server announced, Received the
+	// bytes it sent.
+	Length   int64
+	Received int64
+}
+
+func (e TruncatedValue) Error() string {
+	return fmt.Sprintf("mssql: the value of column %s is truncated: %d of its %d bytes received", e.Column, e.Received, e.Length)
+}
+
+// truncatedPLP is the value read by readPLPType for a value whose chunks
+// do not add up to its announced length.
+type truncatedPLP struct {
+	length   int64
+	received int64
+}
+
+// checkTruncated returns a TruncatedValue error for the first value of row,
+// of the columns cols, that was received truncated.
+func checkTruncated(cols []columnStruct, row []interface{}) error {
+	for i, v := range row {
+		if t, ok := v.(truncatedPLP); ok {
+			name := ""
+			if i < len(cols) {
+				name = cols[i].ColName
+			}
+			return TruncatedValue{Column: name, Length: t.length, Received: t.received}
+		}
+	}
+	return nil
+}
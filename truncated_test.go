@@ -0,0 +1,130 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plpResultStream returns the tokens of a result set of an nvarchar(max)
+// column named doc, with a row per value. A value is sent announcing the
+// length of its entry in announced, when there is one, and in chunks of
+// at most chunk bytes.
+func plpResultStream(values []string, announced []int, chunk int) []byte {
+	stream := []byte{byte(tokenColMetadata), 0x01, 0x00}
+	// user type, flags, type, size (max), collation (Latin1_General_CI_AS)
+	stream = append(stream, 0, 0, 0, 0, 0x01, 0, typeNVarChar, 0xff, 0xff, 0x09, 0x04, 0xd0, 0x00, 0x34)
+	stream = append(stream, byte(len("doc")))
+	stream = append(stream, str2ucs2("doc")...)
+	for i, v := range values {
+		data := str2ucs2(v)
+		length := len(data)
+		if i < len(announced) {
+			length = announced[i]
+		}
+		stream = append(stream, byte(tokenRow))
+		stream = binary.LittleEndian.AppendUint64(stream, uint64(length))
+		for len(data) > 0 {
+			n := min(chunk, len(data))
+			stream = binary.LittleEndian.AppendUint32(stream, uint32(n))
+			stream = append(stream, data[:n]...)
+			data = data[n:]
+		}
+		stream = binary.LittleEndian.AppendUint32(stream, 0)
+	}
+	return append(stream, doneToken(doneCount, uint64(len(values)))...)
+}
+
+func TestTruncatedValueIsAnError(t *testing.T) {
+	// the first value announces 10 bytes but holds 4
+	stream := plpResultStream([]string{"ab", "cd"}, []int{10}, 2)
+	c := &Conn{
+		sess: &tdsSession{
+			buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+			logger: optionalLogger{},
+		},
+		connectionGood: true,
+	}
+	rows, err := (&Stmt{c: c}).processQueryResponse(context.Background())
+	require.NoError(t, err)
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	err = rows.Next(dest)
+	assert.Equal(t, TruncatedValue{Column: "doc", Length: 10, Received: 4}, err)
+	assert.EqualError(t, err, "mssql: the value of column doc is truncated: 4 of its 10 bytes received")
+	assert.True(t, c.connectionGood)
+
+	require.NoError(t, rows.Next(dest), "the next row is read")
+	assert.Equal(t, "cd", dest[0])
+	assert.Equal(t, io.EOF, rows.Next(dest))
+}
+
+func TestLargeValueUnderMaxResultBytes(t *testing.T) {
+	large := strings.Repeat("x", 100000)
+	server := newRowServer(t)
+	server.answer = func(int, []byte) ([]byte, bool) {
+		return plpResultStream([]string{"small", large}, nil, 8000), true
+	}
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the value is read in full, across packets, or not returned at all
+	var docs []string
+	rows, err := db.QueryContext(WithMaxResultBytes(ctx, 1000), "select doc")
+	require.NoError(t, err)
+	for rows.Next() {
+		var doc string
+		require.NoError(t, rows.Scan(&doc))
+		docs = append(docs, doc)
+	}
+	assert.Equal(t, ResultTooLarge{Limit: 1000}, rows.Err())
+	assert.Equal(t, []string{"small"}, docs)
+	rows.Close()
+
+	docs = nil
+	rows, err = db.QueryContext(ctx, "select doc")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var doc string
+		require.NoError(t, rows.Scan(&doc))
+		docs = append(docs, doc)
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, docs, 2)
+	assert.Equal(t, len(large), len(docs[1]))
+}
+
+func TestTextSizeTruncation(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	c, err := conn.Conn(ctx)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.ExecContext(ctx, "set textsize 10")
+	require.NoError(t, err)
+	defer c.ExecContext(ctx, "set textsize -1")
+
+	var value string
+	var length int64
+	err = c.QueryRowContext(ctx, "declare @v varchar(max) = replicate(cast('a' as varchar(max)), 100); select @v, datalength(@v)").Scan(&value, &length)
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, length)
+	assert.Len(t, value, 10, "the server truncates the value to the text size; DATALENGTH tells")
+}
@@ -10,6 +10,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -32,9 +34,23 @@ var (
 	ErrorSkip             = errors.New("all fields mustn't skip")
 	ErrorObjectName       = errors.New("wrong tvp name")
 	ErrorWrongTyping      = errors.New("the number of elements in columnStr and tvpFieldIndexes do not align")
+	ErrorNoMapKeys        = errors.New("TVP of maps must have at least one key")
 )
 
 // TVP is driver type, which allows supporting Table Valued Parameters (TVP) in SQL Server
+//
+// Value may also be a []map[string]interface{}, whose columns are the keys
+// of the maps sorted by name, so they are in the same order for every call;
+// declare the columns of the table type in that order. A key missing from a
+// row is NULL, and the type of a column is that of its first non-nil value.
+//
+// Value may also be a []Vector, such as the query vectors of a batched
+// similarity search, whose table type has an ordinal column, the index of
+// each vector in the slice, followed by a vector column:
+//
+//	CREATE TYPE dbo.QueryVectors AS TABLE (ordinal int, vector vector(3))
+//
+// The vectors must all have the same element type and dimensions.
 type TVP struct {
 	//TypeName mustn't be default value
 	TypeName string
@@ -59,6 +75,10 @@ func (tvp TVP) check() error {
 	if valueOf.IsNil() {
 		return ErrorTypeSliceIsEmpty
 	}
+	switch tvp.Value.(type) {
+	case []map[string]interface{}, []Vector:
+		return nil
+	}
 	if reflect.TypeOf(tvp.Value).Elem().Kind() != reflect.Struct {
 		return ErrorTypeSlice
 	}
@@ -69,29 +89,15 @@ func (tvp TVP) encode(schema, name string, columnStr []columnStruct, tvpFieldInd
 	if len(columnStr) != len(tvpFieldIndexes) {
 		return nil, ErrorWrongTyping
 	}
-	preparedBuffer := make([]byte, 0, 20+(10*len(columnStr)))
-	buf := bytes.NewBuffer(preparedBuffer)
-	err := writeBVarChar(buf, "")
+	buf, err := writeTVPMetadata(schema, name, columnStr, encoding)
 	if err != nil {
 		return nil, err
 	}
 
-	writeBVarChar(buf, schema)
-	writeBVarChar(buf, name)
-	binary.Write(buf, binary.LittleEndian, uint16(len(columnStr)))
-
-	for i, column := range columnStr {
-		binary.Write(buf, binary.LittleEndian, column.UserType)
-		binary.Write(buf, binary.LittleEndian, column.Flags)
-		writeTypeInfo(buf, &columnStr[i].ti, false, encoding)
-		writeBVarChar(buf, "")
-	}
-	// The returned error is always nil
-	buf.WriteByte(_TVP_END_TOKEN)
-
 	conn := new(Conn)
 	conn.sess = new(tdsSession)
 	conn.sess.loginAck = loginAckStruct{TDSVersion: verTDS73}
+	conn.sess.encoding = encoding
 	stmt := &Stmt{
 		c: conn,
 	}
@@ -142,6 +148,151 @@ func (tvp TVP) encode(schema, name string, columnStr []columnStruct, tvpFieldInd
 	return buf.Bytes(), nil
 }
 
+// writeTVPMetadata returns a buffer holding the type name and the columns
+// of a TVP, to which the rows are written.
+func writeTVPMetadata(schema, name string, columnStr []columnStruct, encoding msdsn.EncodeParameters) (*bytes.Buffer, error) {
+	preparedBuffer := make([]byte, 0, 20+(10*len(columnStr)))
+	buf := bytes.NewBuffer(preparedBuffer)
+	err := writeBVarChar(buf, "")
+	if err != nil {
+		return nil, err
+	}
+
+	writeBVarChar(buf, schema)
+	writeBVarChar(buf, name)
+	binary.Write(buf, binary.LittleEndian, uint16(len(columnStr)))
+
+	for i, column := range columnStr {
+		binary.Write(buf, binary.LittleEndian, column.UserType)
+		binary.Write(buf, binary.LittleEndian, column.Flags)
+		writeTypeInfo(buf, &columnStr[i].ti, false, encoding)
+		writeBVarChar(buf, "")
+	}
+	// The returned error is always nil
+	buf.WriteByte(_TVP_END_TOKEN)
+	return buf, nil
+}
+
+// encodeMaps encodes the rows of a TVP whose Value is a slice of maps.
+func (tvp TVP) encodeMaps(schema, name string, rows []map[string]interface{}, encoding msdsn.EncodeParameters) ([]byte, error) {
+	var keys []string
+	for _, row := range rows {
+		for k := range row {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = slices.Compact(keys)
+	if len(keys) == 0 {
+		return nil, ErrorNoMapKeys
+	}
+	return tvp.encodeMapRows(schema, name, keys, rows, encoding)
+}
+
+// Columns of a TVP whose Value is a slice of Vectors.
+const (
+	vectorTVPOrdinal = "ordinal"
+	vectorTVPVector  = "vector"
+)
+
+// encodeVectors encodes the rows of a TVP whose Value is a slice of
+// Vectors, which have an ordinal column, the index of the vector in the
+// slice, followed by a vector column. The vectors must have the same
+// element type and number of dimensions; NULL ones are kept.
+func (tvp TVP) encodeVectors(schema, name string, vectors []Vector, encoding msdsn.EncodeParameters) ([]byte, error) {
+	rows := make([]map[string]interface{}, len(vectors))
+	var first *Vector
+	for i, v := range vectors {
+		rows[i] = map[string]interface{}{vectorTVPOrdinal: int64(i)}
+		if v.Data == nil {
+			continue
+		}
+		if first == nil {
+			first = &vectors[i]
+		} else if v.ElementType != first.ElementType || len(v.Data) != len(first.Data) {
+			return nil, fmt.Errorf("mssql: vector %d of the TVP is a %s, the others are %s", i, v.SQLType(), first.SQLType())
+		}
+		rows[i][vectorTVPVector] = v
+	}
+	return tvp.encodeMapRows(schema, name, []string{vectorTVPOrdinal, vectorTVPVector}, rows, encoding)
+}
+
+// encodeMapRows encodes rows as the columns keys of a TVP.
+func (tvp TVP) encodeMapRows(schema, name string, keys []string, rows []map[string]interface{}, encoding msdsn.EncodeParameters) ([]byte, error) {
+	conn := new(Conn)
+	conn.sess = new(tdsSession)
+	conn.sess.loginAck = loginAckStruct{TDSVersion: verTDS73}
+	conn.sess.encoding = encoding
+	stmt := &Stmt{
+		c: conn,
+	}
+
+	// the type of a column is that of its first non-nil value
+	columnStr := make([]columnStruct, len(keys))
+	for i, k := range keys {
+		var sample interface{} = ""
+		for _, row := range rows {
+			if v := row[k]; v != nil {
+				sample = v
+				break
+			}
+		}
+		cval, err := convertInputParameter(sample)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert tvp column %s: %s", k, err)
+		}
+		param, err := stmt.makeParam(cval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make tvp column %s: %s", k, err)
+		}
+		columnStr[i].ti = param.ti
+		switch param.ti.TypeId {
+		case typeNVarChar, typeBigVarBin:
+			columnStr[i].ti.Size = 0
+		}
+	}
+
+	buf, err := writeTVPMetadata(schema, name, columnStr, encoding)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		buf.WriteByte(_TVP_ROW_TOKEN)
+		for i, k := range keys {
+			cval, err := convertInputParameter(row[k])
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert tvp parameter row col: %s", err)
+			}
+			if cval == nil {
+				writeTVPNull(buf, columnStr[i].ti)
+				continue
+			}
+			param, err := stmt.makeParam(cval)
+			if err != nil {
+				return nil, fmt.Errorf("failed to make tvp parameter row col: %s", err)
+			}
+			if param.ti.TypeId != columnStr[i].ti.TypeId {
+				return nil, fmt.Errorf("tvp column %s holds values of different types: %T", k, row[k])
+			}
+			columnStr[i].ti.Writer(buf, param.ti, param.buffer, encoding)
+		}
+	}
+	buf.WriteByte(_TVP_END_TOKEN)
+	return buf.Bytes(), nil
+}
+
+// writeTVPNull writes a NULL value of a column of type ti.
+func writeTVPNull(buf *bytes.Buffer, ti typeInfo) {
+	switch {
+	case ti.Size == 0 && (ti.TypeId == typeNVarChar || ti.TypeId == typeBigVarBin):
+		binary.Write(buf, binary.LittleEndian, uint64(_PLP_NULL))
+	case ti.TypeId == typeNVarChar || ti.TypeId == typeBigVarChar || ti.TypeId == typeBigVarBin:
+		binary.Write(buf, binary.LittleEndian, uint16(0xffff))
+	default:
+		binary.Write(buf, binary.LittleEndian, uint8(0))
+	}
+}
+
 func (tvp TVP) columnTypes() ([]columnStruct, []int, error) {
 	type fieldDetailStore struct {
 		defaultValue interface{}
@@ -6,6 +6,7 @@ package mssql
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"reflect"
 	"testing"
@@ -1713,3 +1714,116 @@ func TestTVPIdentity(t *testing.T) {
 		t.Fatal("TestTVPIdentity have to be same")
 	}
 }
+
+func TestTVPOfMapsAndMapParams(t *testing.T) {
+	const (
+		createTVP = `CREATE TYPE dbo.mapTVP AS TABLE (amount bigint, name nvarchar(50))`
+		dropTVP   = `DROP TYPE IF EXISTS dbo.mapTVP`
+	)
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	db.ExecContext(ctx, dropTVP)
+	if _, err := db.ExecContext(ctx, createTVP); err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, dropTVP)
+
+	rows := []map[string]interface{}{
+		{"name": "a", "amount": int64(5)},
+		{"name": "b"},
+		{"amount": int64(7)},
+	}
+	params := map[string]interface{}{"minAmount": 0, "suffix": "!"}
+	res, err := db.QueryContext(ctx,
+		"SELECT amount, name + @suffix FROM @rows WHERE ISNULL(amount, 0) >= @minAmount ORDER BY ISNULL(name, 'z')",
+		sql.Named("rows", TVP{TypeName: "dbo.mapTVP", Value: rows}), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+	var got []string
+	for res.Next() {
+		var amount sql.NullInt64
+		var name sql.NullString
+		if err = res.Scan(&amount, &name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%v %v", amount, name))
+	}
+	if err = res.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"{5 true} {a! true}", "{0 false} {b! true}", "{7 true} { false}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTVPOfVectors(t *testing.T) {
+	const (
+		createTVP   = `CREATE TYPE dbo.queryVectorsTVP AS TABLE (ordinal int, vector vector(2))`
+		dropTVP     = `DROP TYPE IF EXISTS dbo.queryVectorsTVP`
+		createTable = `CREATE TABLE dbo.tvpVectorItems (id int, embedding vector(2))`
+		dropTable   = `DROP TABLE IF EXISTS dbo.tvpVectorItems`
+	)
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	skipIfVectorUnsupported(t, db)
+	ctx := testContext(t)
+
+	db.ExecContext(ctx, dropTable)
+	db.ExecContext(ctx, dropTVP)
+	for _, stmt := range []string{createTVP, createTable,
+		`INSERT INTO dbo.tvpVectorItems VALUES (1, '[0,0]'), (2, '[10,0]'), (3, '[0,10]')`} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, dropTVP)
+	defer db.ExecContext(ctx, dropTable)
+
+	var queries []Vector
+	for _, data := range [][]float32{{9, 1}, {1, 1}, {1, 9}} {
+		v, err := NewVector(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queries = append(queries, v)
+	}
+	// the nearest item of each query vector, in one query
+	rows, err := db.QueryContext(ctx, `SELECT q.ordinal, n.id FROM @queries q
+CROSS APPLY (SELECT TOP (1) i.id FROM dbo.tvpVectorItems i
+	ORDER BY VECTOR_DISTANCE('euclidean', i.embedding, q.vector)) n
+ORDER BY q.ordinal`, sql.Named("queries", TVP{TypeName: "dbo.queryVectorsTVP", Value: queries}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []int
+	for rows.Next() {
+		var ordinal, id int
+		if err = rows.Scan(&ordinal, &id); err != nil {
+			t.Fatal(err)
+		}
+		if ordinal != len(got) {
+			t.Errorf("ordinal %d, want %d", ordinal, len(got))
+		}
+		got = append(got, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nearest items %v, want %v", got, want)
+	}
+
+	short, _ := NewVector([]float32{1})
+	_, err = db.ExecContext(ctx, "SELECT * FROM @queries", sql.Named("queries", TVP{TypeName: "dbo.queryVectorsTVP", Value: append(queries, short)}))
+	if err == nil {
+		t.Error("vectors of different dimensions should be rejected")
+	}
+}
@@ -587,3 +587,84 @@ func TestTVP_encode_WithGuidConversion(t *testing.T) {
 func TestTVP_encode(t *testing.T) {
 	testTVP_encode(t, false /*guidConversion*/)
 }
+
+func TestTVP_encodeMaps(t *testing.T) {
+	// the columns of a TVP of maps are its sorted keys, encoded as the
+	// fields of the same struct would be
+	type sorted struct {
+		Amount *int64
+		Name   string
+	}
+	amount := int64(5)
+	structs := TVP{TypeName: "dbo.T", Value: []sorted{{&amount, "a"}, {nil, "b"}}}
+	maps := TVP{TypeName: "dbo.T", Value: []map[string]interface{}{
+		{"Name": "a", "Amount": int64(5)},
+		{"Name": "b"},
+	}}
+	if err := maps.check(); err != nil {
+		t.Fatal("check:", err)
+	}
+
+	columnStr, indexes, err := structs.columnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := structs.encode("dbo", "T", columnStr, indexes, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := maps.Value.([]map[string]interface{})
+	got, err := maps.encodeMaps("dbo", "T", rows, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encodeMaps() = %x, want %x", got, want)
+	}
+
+	if _, err = maps.encodeMaps("dbo", "T", []map[string]interface{}{{}}, msdsn.EncodeParameters{}); err != ErrorNoMapKeys {
+		t.Errorf("encodeMaps() of maps without keys returned %v", err)
+	}
+	mixed := []map[string]interface{}{{"a": int64(1)}, {"a": "x"}}
+	if _, err = maps.encodeMaps("dbo", "T", mixed, msdsn.EncodeParameters{}); err == nil {
+		t.Error("encodeMaps() of a column of mixed types should fail")
+	}
+}
+
+func TestTVP_encodeVectors(t *testing.T) {
+	// a TVP of vectors is encoded as the maps of their ordinals and vectors
+	a, _ := NewVector([]float32{1, 2})
+	b, _ := NewVector([]float32{3, 4})
+	vectors := TVP{TypeName: "dbo.V", Value: []Vector{a, {}, b}}
+	if err := vectors.check(); err != nil {
+		t.Fatal("check:", err)
+	}
+	got, err := vectors.encodeVectors("dbo", "V", vectors.Value.([]Vector), msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := vectors.encodeMaps("dbo", "V", []map[string]interface{}{
+		{"ordinal": int64(0), "vector": "[1,2]"},
+		{"ordinal": int64(1)},
+		{"ordinal": int64(2), "vector": "[3,4]"},
+	}, msdsn.EncodeParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encodeVectors() = %x, want %x", got, want)
+	}
+
+	if _, err = vectors.encodeVectors("dbo", "V", nil, msdsn.EncodeParameters{}); err != nil {
+		t.Errorf("encodeVectors() of no vectors returned %v", err)
+	}
+	c, _ := NewVector([]float32{1, 2, 3})
+	_, err = vectors.encodeVectors("dbo", "V", []Vector{a, c}, msdsn.EncodeParameters{})
+	if err == nil || err.Error() != "mssql: vector 1 of the TVP is a VECTOR(3), the others are VECTOR(2)" {
+		t.Errorf("encodeVectors() of vectors of different dimensions returned %v", err)
+	}
+	h, _ := NewVectorWithType(VectorFloat16, []float32{1, 2})
+	if _, err = vectors.encodeVectors("dbo", "V", []Vector{a, h}, msdsn.EncodeParameters{}); err == nil {
+		t.Error("encodeVectors() of vectors of different element types should fail")
+	}
+}
@@ -276,12 +276,13 @@ func decodeDateTim4(buf []byte, loc *time.Location) time.Time {
 		0, int(mins), 0, 0, loc)
 }
 
-func encodeDateTim4(val time.Time, loc *time.Location) (buf []byte) {
+// encodeDateTim4 encodes the wall clock of val, as encodeDateTime does.
+func encodeDateTim4(val time.Time) (buf []byte) {
 	buf = make([]byte, 4)
 
-	ref := time.Date(1900, 1, 1, 0, 0, 0, 0, loc)
-	dur := val.Sub(ref)
-	days := dur / (24 * time.Hour)
+	// days since Jan 1st 1900 (same TZ as val), counted by date so days
+	// with a DST change, which are not 24 hours long, count as one
+	days := gregorianDays(val.Year(), val.YearDay()) - gregorianDays(1900, 1)
 	mins := val.Hour()*60 + val.Minute()
 	if days < 0 {
 		days = 0
@@ -642,6 +643,12 @@ func writeCollation(w io.Writer, col cp.Collation) (err error) {
 
 // reads variant value
 // http://msdn.microsoft.com/en-us/library/dd303302.aspx
+//
+// The base type sent ahead of the value selects the Go type it is returned
+// as, the one a column of that type returns: int64 for the integer types,
+// float64, bool, time.Time for the date and time types, string for the
+// character types, and []byte for binary, uniqueidentifier, and for
+// decimal and money, which hold their text as for a decimal column.
 func readVariantTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.EncodeParameters) interface{} {
 	size := r.int32()
 	loc := encoding.GetTimezone()
@@ -728,7 +735,7 @@ func readVariantTypeWithEncoding(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata,
 		r.ReadFull(buf)
 		return decodeNChar(buf)
 	default:
-		badStreamPanicf("Invalid variant typeid")
+		badStreamPanicf("Invalid variant typeid 0x%x", vartype)
 	}
 	panic("shoulnd't get here")
 }
@@ -759,6 +766,10 @@ func readPLPType(ti *typeInfo, r *tdsBuffer, c *cryptoMetadata, encoding msdsn.E
 				badStreamPanicf("Reading PLP type failed: %s", err.Error())
 			}
 		}
+		if size != _UNKNOWN_PLP_LEN && uint64(buf.Len()) != size {
+			// the stream is still in sync, past the terminator
+			return truncatedPLP{length: int64(size), received: int64(buf.Len())}
+		}
 		bytesToDecode = buf.Bytes()
 	} else {
 		bytesToDecode = r.rbuf
@@ -1,12 +1,14 @@
 package mssql
 
 import (
+	"bytes"
 	"encoding/binary"
 	"math"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/microsoft/go-mssqldb/internal/cp"
 	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/stretchr/testify/assert"
 )
@@ -311,3 +313,76 @@ func TestReadFixedType_Flt4_ReturnsFloat64(t *testing.T) {
 	}
 	assert.Equal(t, float64(want), gotF64)
 }
+
+// TestReadLongLenType verifies that TEXT, NTEXT and IMAGE values, which are
+// sent with a text pointer and timestamp ahead of the data, are decoded.
+func TestReadLongLenType(t *testing.T) {
+	value := func(data []byte) []byte {
+		b := []byte{16}
+		b = append(b, make([]byte, 16+8)...) // text pointer and timestamp
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+		return append(b, data...)
+	}
+	latin1 := cp.Collation{LcidAndFlags: 0x0409, SortId: 52} // SQL_Latin1_General_CP1_CI_AS
+	tests := []struct {
+		name     string
+		ti       typeInfo
+		data     []byte
+		expected interface{}
+	}{
+		{"text", typeInfo{TypeId: typeText, Collation: latin1}, value([]byte("caf\xe9")), "café"},
+		{"ntext", typeInfo{TypeId: typeNText}, value(str2ucs2("café")), "café"},
+		{"image", typeInfo{TypeId: typeImage}, value([]byte{0, 1, 0xff}), []byte{0, 1, 0xff}},
+		{"empty image", typeInfo{TypeId: typeImage}, value([]byte{}), []byte{}},
+		{"null", typeInfo{TypeId: typeText, Collation: latin1}, []byte{0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(tt.data))})
+			_, err := r.BeginRead()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readLongLenType(&tt.ti, r, nil, msdsn.EncodeParameters{})
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+// TestReadVariantType verifies that the base type of a sql_variant value
+// selects the Go type it is returned as, as for a column of that type.
+func TestReadVariantType(t *testing.T) {
+	variant := func(baseType byte, props, data []byte) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, uint32(2+len(props)+len(data)))
+		b = append(b, baseType, byte(len(props)))
+		b = append(b, props...)
+		return append(b, data...)
+	}
+	latin1 := []byte{0x09, 0x04, 0xd0, 0x00, 0x34} // SQL_Latin1_General_CP1_CI_AS
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{"int", variant(typeInt4, nil, []byte{0xec, 0xff, 0xff, 0xff}), int64(-20)},
+		{"tinyint", variant(typeInt1, nil, []byte{200}), int64(200)},
+		{"bigint", variant(typeInt8, nil, binary.LittleEndian.AppendUint64(nil, uint64(1)<<40)), int64(1) << 40},
+		{"decimal", variant(typeDecimalN, []byte{18, 2}, []byte{0, 0x39, 0x30, 0, 0}), []byte("-123.45")},
+		{"datetime", variant(typeDateTime, nil, []byte{0xac, 0x8e, 0, 0, 0xc0, 0x3b, 0xd6, 0}), time.Date(2000, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"nvarchar", variant(typeNVarChar, append(append([]byte{}, latin1...), 20, 0), str2ucs2("café")), "café"},
+		{"varchar", variant(typeBigVarChar, append(append([]byte{}, latin1...), 10, 0), []byte("caf\xe9")), "café"},
+		{"varbinary", variant(typeBigVarBin, []byte{10, 0}, []byte{0x12, 0x34}), []byte{0x12, 0x34}},
+		{"null", []byte{0, 0, 0, 0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(tt.data))})
+			_, err := r.BeginRead()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readVariantTypeWithEncoding(&typeInfo{TypeId: typeVariant}, r, nil, msdsn.EncodeParameters{Timezone: time.UTC})
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package mssql
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -116,7 +117,7 @@ func TestEncodeDateTim4(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := encodeDateTim4(tt.input, time.UTC)
+			result := encodeDateTim4(tt.input)
 
 			days := binary.LittleEndian.Uint16(result[0:2])
 			mins := binary.LittleEndian.Uint16(result[2:4])
@@ -138,7 +139,7 @@ func TestEncodeDateTim4_Roundtrip(t *testing.T) {
 
 	for _, tt := range testTimes {
 		t.Run(tt.Format("2006-01-02 15:04"), func(t *testing.T) {
-			encoded := encodeDateTim4(tt, time.UTC)
+			encoded := encodeDateTim4(tt)
 			decoded := decodeDateTim4(encoded, time.UTC)
 
 			// SmallDateTime only stores minutes, so we compare date and hour:minute
@@ -652,6 +653,52 @@ func TestEncodeDateTime2_Roundtrip(t *testing.T) {
 	}
 }
 
+func TestDateTime2ScaleFromColMetadata(t *testing.T) {
+	testCases := []struct {
+		scale uint8
+		val   time.Time
+	}{
+		{0, time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)},
+		{3, time.Date(2024, 2, 29, 23, 59, 59, 999000000, time.UTC)},
+		{7, time.Date(2024, 2, 29, 23, 59, 59, 999999900, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("datetime2(%d)", tc.scale), func(t *testing.T) {
+			value := encodeDateTime2(tc.val, int(tc.scale))
+			var stream []byte
+			// one nullable datetime2(n) column named d
+			stream = append(stream, byte(tokenColMetadata), 1, 0, 0, 0, 0, 0, 0x01, 0, typeDateTime2N, tc.scale, 1)
+			stream = append(stream, str2ucs2("d")...)
+			stream = append(stream, byte(tokenRow), byte(len(value)))
+			stream = append(stream, value...)
+			stream = append(stream, doneToken(doneCount, 1)...)
+
+			sess := &tdsSession{
+				buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(stream))}),
+				logger: optionalLogger{},
+			}
+			ch := make(chan tokenStruct, 5)
+			go processSingleResponse(context.Background(), sess, ch, outputs{})
+			var got []interface{}
+			for tok := range ch {
+				switch tok := tok.(type) {
+				case error:
+					t.Fatal(tok)
+				case []columnStruct:
+					assert.Equal(t, tc.scale, tok[0].ti.Scale, "scale of the column")
+					assert.Equal(t, calcTimeSize(int(tc.scale))+3, tok[0].ti.Size, "size of the values")
+				case []interface{}:
+					got = tok
+				}
+			}
+			if assert.Len(t, got, 1) {
+				assert.Equal(t, tc.val, got[0], "the value is exact, with no extra sub-second digits")
+			}
+		})
+	}
+}
+
 func TestEncodeDateTimeOffset_Roundtrip(t *testing.T) {
 	testCases := []struct {
 		name  string
@@ -8,8 +8,65 @@ import (
 	"strings"
 )
 
+// UniqueIdentifier is a uniqueidentifier value. Its bytes are in the order
+// of its string form, 00112233-4455-6677-8899-AABBCCDDEEFF, which is the
+// big-endian order of RFC 4122 used by Java's UUID and most Go UUID
+// packages; see Bytes and UniqueIdentifierFromBytes.
+//
+// SQL Server stores a uniqueidentifier, and sends it, in the mixed-endian
+// order of .NET's Guid.ToByteArray instead: the first three components,
+// of 4, 2 and 2 bytes, are little-endian, so that the value above is the
+// bytes 33 22 11 00 55 44 77 66 88 99 AA BB CC DD EE FF. Scan and Value
+// swap them, as do MixedEndianBytes and UniqueIdentifierFromMixedEndianBytes
+// for byte arrays exchanged with such systems, or read from a column cast
+// to binary(16).
 type UniqueIdentifier [16]byte
 
+// UniqueIdentifierFromBytes returns the UniqueIdentifier of the 16 bytes b
+// in the order of its string form.
+func UniqueIdentifierFromBytes(b []byte) (UniqueIdentifier, error) {
+	var u UniqueIdentifier
+	if len(b) != len(u) {
+		return u, errors.New("mssql: invalid UniqueIdentifier length")
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// UniqueIdentifierFromMixedEndianBytes returns the UniqueIdentifier of the
+// 16 bytes b in the order SQL Server stores it, that of .NET's
+// Guid.ToByteArray.
+func UniqueIdentifierFromMixedEndianBytes(b []byte) (UniqueIdentifier, error) {
+	u, err := UniqueIdentifierFromBytes(b)
+	if err == nil {
+		swapUniqueIdentifier(u[:])
+	}
+	return u, err
+}
+
+// Bytes returns the bytes of u in the order of its string form.
+func (u UniqueIdentifier) Bytes() []byte {
+	return append([]byte(nil), u[:]...)
+}
+
+// MixedEndianBytes returns the bytes of u in the order SQL Server stores
+// it, that of .NET's Guid.ToByteArray.
+func (u UniqueIdentifier) MixedEndianBytes() []byte {
+	b := u.Bytes()
+	swapUniqueIdentifier(b)
+	return b
+}
+
+// swapUniqueIdentifier swaps the bytes of the first three components of the
+// uniqueidentifier b between the two orders.
+func swapUniqueIdentifier(b []byte) {
+	for _, c := range [][]byte{b[0:4], b[4:6], b[6:8]} {
+		for i, j := 0, len(c)-1; i < j; i, j = i+1, j-1 {
+			c[i], c[j] = c[j], c[i]
+		}
+	}
+}
+
 func (u *UniqueIdentifier) Scan(v interface{}) error {
 	reverse := func(b []byte) {
 		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
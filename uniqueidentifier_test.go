@@ -138,3 +138,52 @@ func TestUniqueIdentifierScanInvalidStringLength(t *testing.T) {
 var _ fmt.Stringer = UniqueIdentifier{}
 var _ sql.Scanner = &UniqueIdentifier{}
 var _ driver.Valuer = UniqueIdentifier{}
+
+func TestUniqueIdentifierByteOrders(t *testing.T) {
+	t.Parallel()
+	const s = "00112233-4455-6677-8899-AABBCCDDEEFF"
+	big := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+	mixed := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+
+	u, err := UniqueIdentifierFromBytes(big)
+	assert.NoError(t, err)
+	assert.Equal(t, s, u.String())
+	assert.Equal(t, big, u.Bytes())
+	assert.Equal(t, mixed, u.MixedEndianBytes())
+
+	m, err := UniqueIdentifierFromMixedEndianBytes(mixed)
+	assert.NoError(t, err)
+	assert.Equal(t, u, m)
+	assert.Equal(t, s, m.String())
+
+	v, _ := u.Value()
+	assert.Equal(t, mixed, v, "sent to the server in mixed-endian order")
+	var scanned UniqueIdentifier
+	assert.NoError(t, scanned.Scan(mixed))
+	assert.Equal(t, u, scanned)
+
+	b := u.Bytes()
+	b[0] = 0xFF
+	assert.Equal(t, s, u.String(), "Bytes returns a copy")
+
+	_, err = UniqueIdentifierFromBytes(big[:15])
+	assert.Error(t, err)
+	_, err = UniqueIdentifierFromMixedEndianBytes(nil)
+	assert.Error(t, err)
+}
+
+func TestUniqueIdentifierBinaryCast(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	u, _ := UniqueIdentifierFromBytes([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF})
+	var raw []byte
+	var text string
+	err := conn.QueryRow("select cast(@p1 as binary(16)), cast(@p1 as char(36))", u).Scan(&raw, &text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, u.MixedEndianBytes(), raw, "binary(16) holds the stored order")
+	assert.Equal(t, u.String(), text)
+}
@@ -0,0 +1,63 @@
+package mssql
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+)
+
+// Unmarshal returns a sql.Scanner decoding a column into dest with the
+// methods it implements, for types that decode themselves through
+// encoding.TextUnmarshaler or encoding.BinaryUnmarshaler but do not
+// implement sql.Scanner, which is all database/sql calls:
+//
+//	var addr netip.Addr
+//	err := db.QueryRowContext(ctx, "SELECT address FROM hosts WHERE id = @p1", id).Scan(mssql.Unmarshal(&addr))
+//
+// The first method dest implements, in this order, decodes the value:
+//
+//  1. Scan of sql.Scanner, with any value, as database/sql would call it.
+//  2. UnmarshalText of encoding.TextUnmarshaler, with values of the char,
+//     varchar, nchar, nvarchar and other string columns.
+//  3. UnmarshalBinary of encoding.BinaryUnmarshaler, with values of the
+//     binary, varbinary and other []byte columns.
+//
+// A []byte value is decoded with UnmarshalText when dest implements only
+// encoding.TextUnmarshaler, which covers text read as []byte with
+// StringsAsBytes. NULL and values of other types, such as numbers, are
+// errors. The []byte passed to UnmarshalBinary and UnmarshalText is only
+// valid until they return.
+func Unmarshal(dest interface{}) *UnmarshalScanner {
+	return &UnmarshalScanner{dest: dest}
+}
+
+// UnmarshalScanner is the sql.Scanner returned by Unmarshal.
+type UnmarshalScanner struct {
+	dest interface{}
+}
+
+// Scan decodes src into the destination of s.
+func (s *UnmarshalScanner) Scan(src interface{}) error {
+	if scanner, ok := s.dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+	text, isText := s.dest.(encoding.TextUnmarshaler)
+	binary, isBinary := s.dest.(encoding.BinaryUnmarshaler)
+	if !isText && !isBinary {
+		return fmt.Errorf("mssql: Unmarshal requires a sql.Scanner, encoding.TextUnmarshaler or encoding.BinaryUnmarshaler, not %T", s.dest)
+	}
+	switch v := src.(type) {
+	case nil:
+		return fmt.Errorf("mssql: cannot scan NULL into %T", s.dest)
+	case string:
+		if isText {
+			return text.UnmarshalText([]byte(v))
+		}
+	case []byte:
+		if isBinary {
+			return binary.UnmarshalBinary(v)
+		}
+		return text.UnmarshalText(v)
+	}
+	return fmt.Errorf("mssql: cannot scan %T into %T", src, s.dest)
+}
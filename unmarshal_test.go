@@ -0,0 +1,97 @@
+package mssql
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperText decodes text in upper case.
+type upperText string
+
+func (u *upperText) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty text")
+	}
+	*u = upperText(strings.ToUpper(string(b)))
+	return nil
+}
+
+// checksum decodes binary values as their length and byte sum.
+type checksum struct {
+	n, sum int
+}
+
+func (c *checksum) UnmarshalBinary(b []byte) error {
+	c.n, c.sum = len(b), 0
+	for _, x := range b {
+		c.sum += int(x)
+	}
+	return nil
+}
+
+// both decodes text and binary values, and records which method ran.
+type both string
+
+func (b *both) UnmarshalText([]byte) error   { *b = "text"; return nil }
+func (b *both) UnmarshalBinary([]byte) error { *b = "binary"; return nil }
+
+// scanned decodes with Scan, although it also implements UnmarshalText.
+type scanned struct{ upperText }
+
+func (s *scanned) Scan(src interface{}) error {
+	s.upperText = "scanned"
+	return nil
+}
+
+func TestUnmarshalScan(t *testing.T) {
+	var u upperText
+	require.NoError(t, Unmarshal(&u).Scan("abc"))
+	assert.Equal(t, upperText("ABC"), u)
+	require.NoError(t, Unmarshal(&u).Scan([]byte("def")), "text read as bytes")
+	assert.Equal(t, upperText("DEF"), u)
+	assert.EqualError(t, Unmarshal(&u).Scan(""), "empty text", "errors of the method are returned")
+	assert.EqualError(t, Unmarshal(&u).Scan(nil), "mssql: cannot scan NULL into *mssql.upperText")
+	assert.EqualError(t, Unmarshal(&u).Scan(int64(1)), "mssql: cannot scan int64 into *mssql.upperText")
+
+	var c checksum
+	require.NoError(t, Unmarshal(&c).Scan([]byte{1, 2, 3}))
+	assert.Equal(t, checksum{3, 6}, c)
+	assert.EqualError(t, Unmarshal(&c).Scan("abc"), "mssql: cannot scan string into *mssql.checksum")
+
+	var b both
+	require.NoError(t, Unmarshal(&b).Scan("x"))
+	assert.Equal(t, both("text"), b)
+	require.NoError(t, Unmarshal(&b).Scan([]byte("x")))
+	assert.Equal(t, both("binary"), b)
+
+	var s scanned
+	require.NoError(t, Unmarshal(&s).Scan(nil), "sql.Scanner comes first, and handles NULL")
+	assert.Equal(t, upperText("scanned"), s.upperText)
+
+	var n int
+	assert.EqualError(t, Unmarshal(&n).Scan("1"),
+		"mssql: Unmarshal requires a sql.Scanner, encoding.TextUnmarshaler or encoding.BinaryUnmarshaler, not *int")
+}
+
+func TestUnmarshalIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	var addr netip.Addr
+	var c checksum
+	err := conn.QueryRow("SELECT CAST('192.0.2.1' AS varchar(15)), 0x010203").Scan(Unmarshal(&addr), Unmarshal(&c))
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), addr)
+	assert.Equal(t, checksum{3, 6}, c)
+
+	var u upperText
+	err = conn.QueryRow("SELECT N'mixed Case'").Scan(Unmarshal(&u))
+	require.NoError(t, err)
+	assert.Equal(t, upperText("MIXED CASE"), u)
+}
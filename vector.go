@@ -0,0 +1,357 @@
+package mssql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// VectorElementType is the element type of a SQL Server VECTOR value.
+type VectorElementType byte
+
+const (
+	// VectorFloat32 stores each element as a 4 byte float. It is the
+	// default element type of a VECTOR column.
+	VectorFloat32 VectorElementType = 0
+	// VectorFloat16 stores each element as a 2 byte float.
+	VectorFloat16 VectorElementType = 1
+)
+
+// Maximum number of dimensions of a VECTOR column for each element type.
+const (
+	maxVectorDimensionsFloat32 = 1998
+	maxVectorDimensionsFloat16 = 3996
+)
+
+func (t VectorElementType) String() string {
+	switch t {
+	case VectorFloat32:
+		return "float32"
+	case VectorFloat16:
+		return "float16"
+	}
+	return fmt.Sprintf("VectorElementType(%d)", byte(t))
+}
+
+func (t VectorElementType) maxDimensions() int {
+	switch t {
+	case VectorFloat16:
+		return maxVectorDimensionsFloat16
+	default:
+		return maxVectorDimensionsFloat32
+	}
+}
+
+// Vector holds the value of a SQL Server VECTOR column, such as an embedding.
+//
+// It is sent to the server as a JSON array string, which SQL Server
+// converts implicitly to the VECTOR type of the target column or variable.
+// The text is the same for both element types, so float16 vectors need no
+// parameter support of their own: on servers without float16 vectors it is
+// the float16 target, not the parameter, that fails.
+//
+// Scan accepts the JSON array text the server returns for VECTOR columns.
+// A Vector with nil Data is NULL.
+type Vector struct {
+	// ElementType is the element type of the VECTOR column. Elements are
+	// always held as float32 on the client.
+	ElementType VectorElementType
+	Data        []float32
+}
+
+// NewVector returns a float32 Vector holding data.
+func NewVector(data []float32) (Vector, error) {
+	return NewVectorWithType(VectorFloat32, data)
+}
+
+// NewVectorWithType returns a Vector with the given element type holding data.
+// It returns an error if the element type is unknown or the number of
+// elements is outside the range SQL Server supports for that type.
+func NewVectorWithType(elementType VectorElementType, data []float32) (Vector, error) {
+	if elementType != VectorFloat32 && elementType != VectorFloat16 {
+		return Vector{}, fmt.Errorf("mssql: unsupported vector element type %v", elementType)
+	}
+	if len(data) == 0 || len(data) > elementType.maxDimensions() {
+		return Vector{}, fmt.Errorf("mssql: a %v vector must have between 1 and %d dimensions, got %d", elementType, elementType.maxDimensions(), len(data))
+	}
+	return Vector{ElementType: elementType, Data: data}, nil
+}
+
+// NewVectorStrict is like NewVector but also rejects NaN and infinite
+// elements, which SQL Server does not accept.
+func NewVectorStrict(data []float32) (Vector, error) {
+	return NewVectorWithTypeStrict(VectorFloat32, data)
+}
+
+// NewVectorWithTypeStrict is like NewVectorWithType but also rejects
+// elements SQL Server does not accept: NaN, infinities and, for float16
+// vectors, values beyond the float16 range. NewVector and NewVectorWithType
+// leave these to be rejected by the server.
+func NewVectorWithTypeStrict(elementType VectorElementType, data []float32) (Vector, error) {
+	v, err := NewVectorWithType(elementType, data)
+	if err != nil {
+		return Vector{}, err
+	}
+	if err = v.validateElements(); err != nil {
+		return Vector{}, err
+	}
+	return v, nil
+}
+
+// maxFloat16 is the largest finite float16 value.
+const maxFloat16 = 65504
+
+func (v Vector) validateElements() error {
+	for i, f := range v.Data {
+		switch {
+		case math.IsNaN(float64(f)):
+			return fmt.Errorf("mssql: vector element %d is NaN", i)
+		case math.IsInf(float64(f), 0):
+			return fmt.Errorf("mssql: vector element %d is %v", i, f)
+		case v.ElementType == VectorFloat16 && math.Abs(float64(f)) > maxFloat16:
+			return fmt.Errorf("mssql: vector element %d (%v) is out of the float16 range", i, f)
+		}
+	}
+	return nil
+}
+
+// Dimensions returns the number of elements in v.
+func (v Vector) Dimensions() int {
+	return len(v.Data)
+}
+
+// SQLType returns the declaration of the VECTOR type matching v,
+// such as VECTOR(3) or VECTOR(3, float16).
+func (v Vector) SQLType() string {
+	if v.ElementType == VectorFloat16 {
+		return fmt.Sprintf("VECTOR(%d, float16)", len(v.Data))
+	}
+	return fmt.Sprintf("VECTOR(%d)", len(v.Data))
+}
+
+// String returns v as a JSON array, the text form SQL Server uses for vectors.
+func (v Vector) String() string {
+	b := make([]byte, 0, 2+len(v.Data)*12)
+	b = append(b, '[')
+	for i, f := range v.Data {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendFloat(b, float64(f), 'g', -1, 32)
+	}
+	b = append(b, ']')
+	return string(b)
+}
+
+// Value implements the driver.Valuer interface.
+func (v Vector) Value() (driver.Value, error) {
+	if v.Data == nil {
+		return nil, nil
+	}
+	return v.String(), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (v *Vector) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+		v.Data = nil
+		return nil
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to Vector", src)
+	}
+	data, err := parseVector(s)
+	if err != nil {
+		return err
+	}
+	v.Data = data
+	return nil
+}
+
+// Normalize returns a copy of v scaled to unit length. A zero vector is
+// returned unchanged.
+func (v Vector) Normalize() Vector {
+	out := Vector{ElementType: v.ElementType, Data: make([]float32, len(v.Data))}
+	norm := math.Sqrt(dot(v.Data, v.Data))
+	if norm == 0 {
+		copy(out.Data, v.Data)
+		return out
+	}
+	for i, f := range v.Data {
+		out.Data[i] = float32(float64(f) / norm)
+	}
+	return out
+}
+
+// Dot returns the dot product of v and other.
+func (v Vector) Dot(other Vector) (float32, error) {
+	if len(v.Data) != len(other.Data) {
+		return 0, vectorDimensionMismatch(v, other)
+	}
+	return float32(dot(v.Data, other.Data)), nil
+}
+
+// CosineSimilarity returns the cosine of the angle between v and other.
+// VECTOR_DISTANCE('cosine', ...) is one minus this value. It returns an
+// error if the dimensions differ or either vector has zero length.
+func (v Vector) CosineSimilarity(other Vector) (float32, error) {
+	if len(v.Data) != len(other.Data) {
+		return 0, vectorDimensionMismatch(v, other)
+	}
+	norms := math.Sqrt(dot(v.Data, v.Data) * dot(other.Data, other.Data))
+	if norms == 0 {
+		return 0, fmt.Errorf("mssql: cosine similarity of a zero vector is undefined")
+	}
+	return float32(dot(v.Data, other.Data) / norms), nil
+}
+
+// dot accumulates in float64 to limit rounding error on long vectors.
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func vectorDimensionMismatch(a, b Vector) error {
+	return fmt.Errorf("mssql: vector dimensions do not match: %d and %d", len(a.Data), len(b.Data))
+}
+
+// Equal reports whether v and other hold the same elements with the same
+// element type. Elements are compared exactly, by their bits, not within a
+// tolerance: 0 and -0 differ, and a NaN equals the same NaN. A vector with
+// nil Data, NULL, only equals another NULL vector of the same type.
+func (v Vector) Equal(other Vector) bool {
+	if v.ElementType != other.ElementType || len(v.Data) != len(other.Data) ||
+		(v.Data == nil) != (other.Data == nil) {
+		return false
+	}
+	for i, f := range v.Data {
+		if math.Float32bits(f) != math.Float32bits(other.Data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the FNV-1a hash of the element type and the bits of the
+// elements of v, so vectors that are Equal have the same hash. It lets
+// vectors key a map through a wrapper type, such as a cache of query
+// results by embedding. The hash is the same in every process.
+func (v Vector) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [4]byte
+	buf[0] = byte(v.ElementType)
+	if v.Data == nil {
+		buf[1] = 1
+	}
+	h.Write(buf[:2])
+	for _, f := range v.Data {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(f))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// AppendTo appends the elements of v to dst and returns the extended slice.
+func (v Vector) AppendTo(dst []float32) []float32 {
+	return append(dst, v.Data...)
+}
+
+// VectorElement is the constraint of the element types ScanVector decodes into.
+type VectorElement interface {
+	float32 | float64
+}
+
+// ScanVector returns a sql.Scanner decoding a VECTOR column into *dst.
+// The elements replace the contents of *dst, reusing its capacity, so a
+// slice scanned row after row is only reallocated when a vector is longer
+// than any before it. NULL sets *dst to nil.
+//
+//	var emb []float64
+//	for rows.Next() {
+//		err := rows.Scan(&id, mssql.ScanVector(&emb))
+//		...
+//	}
+func ScanVector[T VectorElement](dst *[]T) sql.Scanner {
+	return vectorScanner[T]{dst: dst}
+}
+
+type vectorScanner[T VectorElement] struct {
+	dst *[]T
+}
+
+func (s vectorScanner[T]) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*s.dst = nil
+		return nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return fmt.Errorf("mssql: cannot convert %T to a vector", src)
+	}
+	data, err := appendVector((*s.dst)[:0], text)
+	if err != nil {
+		return err
+	}
+	*s.dst = data
+	return nil
+}
+
+// parseVector parses the JSON array text form of a vector.
+func parseVector(s string) ([]float32, error) {
+	return appendVector[float32](nil, s)
+}
+
+// appendVector parses the JSON array text form of a vector and appends
+// its elements to dst.
+func appendVector[T VectorElement](dst []T, s string) ([]T, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("mssql: invalid vector %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if dst == nil {
+		dst = make([]T, 0, strings.Count(s, ",")+1)
+	}
+	if s == "" {
+		return dst, nil
+	}
+	bits := 64
+	var zero T
+	if _, ok := any(zero).(float32); ok {
+		bits = 32
+	}
+	for n := 1; len(s) > 0; n++ {
+		e := s
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			e, s = s[:i], s[i+1:]
+			if s == "" {
+				return nil, fmt.Errorf("mssql: invalid vector: trailing comma after element %d", n)
+			}
+		} else {
+			s = ""
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(e), bits)
+		if err != nil {
+			return nil, fmt.Errorf("mssql: invalid vector element %q: %w", e, err)
+		}
+		dst = append(dst, T(f))
+	}
+	return dst, nil
+}
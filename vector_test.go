@@ -0,0 +1,215 @@
+package mssql
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVectorWithType(t *testing.T) {
+	v, err := NewVector([]float32{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 3, v.Dimensions())
+	assert.Equal(t, "VECTOR(3)", v.SQLType())
+
+	v, err = NewVectorWithType(VectorFloat16, []float32{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "VECTOR(2, float16)", v.SQLType())
+
+	_, err = NewVector(nil)
+	assert.Error(t, err, "empty vector")
+	_, err = NewVector(make([]float32, maxVectorDimensionsFloat32+1))
+	assert.Error(t, err, "too many float32 dimensions")
+	_, err = NewVectorWithType(VectorFloat16, make([]float32, maxVectorDimensionsFloat32+1))
+	assert.NoError(t, err, "float16 vectors allow more dimensions")
+	_, err = NewVectorWithType(VectorElementType(9), []float32{1})
+	assert.Error(t, err, "unknown element type")
+}
+
+func TestNewVectorStrict(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+	for name, data := range map[string][]float32{
+		"NaN":  {1, nan},
+		"+Inf": {inf, 1},
+		"-Inf": {-inf},
+	} {
+		_, err := NewVectorStrict(data)
+		assert.Error(t, err, name)
+		_, err = NewVectorWithTypeStrict(VectorFloat16, data)
+		assert.Error(t, err, name)
+		_, err = NewVector(data)
+		assert.NoError(t, err, "%s is accepted by NewVector", name)
+	}
+
+	v, err := NewVectorStrict([]float32{1, -2, 1e30})
+	require.NoError(t, err)
+	assert.Equal(t, []float32{1, -2, 1e30}, v.Data)
+
+	_, err = NewVectorWithTypeStrict(VectorFloat16, []float32{1e30})
+	assert.Error(t, err, "out of float16 range")
+	_, err = NewVectorWithTypeStrict(VectorFloat16, []float32{-65504})
+	assert.NoError(t, err)
+	_, err = NewVectorStrict(nil)
+	assert.Error(t, err, "dimension checks still apply")
+}
+
+func TestVectorValueAndScan(t *testing.T) {
+	v, err := NewVector([]float32{1, -0.5, 1e-7})
+	require.NoError(t, err)
+	val, err := v.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "[1,-0.5,1e-07]", val)
+
+	var got Vector
+	require.NoError(t, got.Scan(val))
+	assert.Equal(t, v.Data, got.Data)
+
+	// Format returned by SQL Server for VECTOR columns.
+	require.NoError(t, got.Scan([]byte("[1.0000000e+000, 2.5000000e+000]")))
+	assert.Equal(t, []float32{1, 2.5}, got.Data)
+
+	require.NoError(t, got.Scan(nil))
+	assert.Nil(t, got.Data)
+	val, err = got.Value()
+	require.NoError(t, err)
+	assert.Nil(t, val, "nil Data is NULL")
+
+	assert.Error(t, got.Scan("1,2"))
+	assert.Error(t, got.Scan("[1,x]"))
+	assert.Error(t, got.Scan(42))
+}
+
+func TestVectorArithmetic(t *testing.T) {
+	a := Vector{Data: []float32{3, 4}}
+	b := Vector{Data: []float32{4, -3}}
+
+	n := a.Normalize()
+	assert.InDeltaSlice(t, []float32{0.6, 0.8}, n.Data, 1e-7)
+	assert.Equal(t, []float32{3, 4}, a.Data, "Normalize must not modify its receiver")
+	assert.Equal(t, []float32{0, 0}, Vector{Data: []float32{0, 0}}.Normalize().Data)
+	assert.Equal(t, VectorFloat16, Vector{ElementType: VectorFloat16, Data: []float32{1}}.Normalize().ElementType)
+
+	d, err := a.Dot(b)
+	require.NoError(t, err)
+	assert.Equal(t, float32(0), d)
+	d, err = a.Dot(a)
+	require.NoError(t, err)
+	assert.Equal(t, float32(25), d)
+
+	c, err := a.CosineSimilarity(b)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, c, 1e-7)
+	c, err = a.CosineSimilarity(Vector{Data: []float32{6, 8}})
+	require.NoError(t, err)
+	assert.InDelta(t, 1, c, 1e-7)
+	c, err = a.CosineSimilarity(Vector{Data: []float32{-3, -4}})
+	require.NoError(t, err)
+	assert.InDelta(t, -1, c, 1e-7)
+
+	_, err = a.CosineSimilarity(Vector{Data: []float32{0, 0}})
+	assert.Error(t, err, "zero vector")
+
+	short := Vector{Data: []float32{1}}
+	_, err = a.Dot(short)
+	assert.Error(t, err, "dimension mismatch")
+	_, err = a.CosineSimilarity(short)
+	assert.Error(t, err, "dimension mismatch")
+}
+
+func TestScanVector(t *testing.T) {
+	v := Vector{Data: []float32{1, 2}}
+	buf := make([]float32, 0, 4)
+	buf = v.AppendTo(buf)
+	buf = v.AppendTo(buf)
+	assert.Equal(t, []float32{1, 2, 1, 2}, buf)
+
+	f32 := make([]float32, 0, 8)
+	require.NoError(t, ScanVector(&f32).Scan("[0.1, 2.5e+000, -3]"))
+	assert.Equal(t, []float32{0.1, 2.5, -3}, f32)
+	assert.Equal(t, 8, cap(f32), "capacity of the destination is reused")
+	require.NoError(t, ScanVector(&f32).Scan([]byte("[4]")))
+	assert.Equal(t, []float32{4}, f32)
+
+	var f64 []float64
+	require.NoError(t, ScanVector(&f64).Scan("[0.1,-3]"))
+	assert.Equal(t, []float64{0.1, -3}, f64, "float64 targets parse at full precision")
+
+	require.NoError(t, ScanVector(&f64).Scan(nil))
+	assert.Nil(t, f64)
+	assert.EqualError(t, ScanVector(&f64).Scan("[1,]"), "mssql: invalid vector: trailing comma after element 1")
+	assert.EqualError(t, ScanVector(&f64).Scan("[1, 2 ,  ]"), "mssql: invalid vector: trailing comma after element 2")
+	assert.Error(t, ScanVector(&f64).Scan(1.5))
+}
+
+func BenchmarkScanVector(b *testing.B) {
+	v := Vector{Data: make([]float32, 1536)}
+	for i := range v.Data {
+		v.Data[i] = float32(i) / 1536
+	}
+	text := v.String()
+	b.Run("Vector", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var got Vector
+			if err := got.Scan(text); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("float32", func(b *testing.B) {
+		b.ReportAllocs()
+		var dst []float32
+		for i := 0; i < b.N; i++ {
+			if err := ScanVector(&dst).Scan(text); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("float64", func(b *testing.B) {
+		b.ReportAllocs()
+		var dst []float64
+		for i := 0; i < b.N; i++ {
+			if err := ScanVector(&dst).Scan(text); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestVectorEqualAndHash(t *testing.T) {
+	a := Vector{Data: []float32{1, 2.5, -3}}
+	b := Vector{Data: []float32{1, 2.5, -3}}
+	assert.True(t, a.Equal(b))
+	assert.Equal(t, a.Hash(), b.Hash(), "equal vectors have the same hash")
+
+	unequal := []Vector{
+		{Data: []float32{1, 2.5, -3.0000002}},
+		{Data: []float32{1, 2.5}},
+		{Data: []float32{1, 2.5, -3, 0}},
+		{ElementType: VectorFloat16, Data: []float32{1, 2.5, -3}},
+		{},
+	}
+	for _, u := range unequal {
+		assert.False(t, a.Equal(u), "%v %v", u.ElementType, u.Data)
+		assert.NotEqual(t, a.Hash(), u.Hash(), "%v %v", u.ElementType, u.Data)
+	}
+
+	assert.False(t, Vector{Data: []float32{0}}.Equal(Vector{Data: []float32{float32(math.Copysign(0, -1))}}), "0 and -0 differ")
+	nan := Vector{Data: []float32{float32(math.NaN())}}
+	assert.True(t, nan.Equal(nan), "a NaN equals itself")
+	assert.True(t, Vector{}.Equal(Vector{}))
+	assert.False(t, Vector{}.Equal(Vector{ElementType: VectorFloat16}))
+	assert.False(t, Vector{}.Equal(Vector{Data: []float32{}}), "NULL is not an empty vector")
+
+	type key struct{ hash uint64 }
+	cache := map[key][]Vector{}
+	cache[key{a.Hash()}] = append(cache[key{a.Hash()}], a)
+	var hit bool
+	for _, v := range cache[key{b.Hash()}] {
+		hit = hit || v.Equal(b)
+	}
+	assert.True(t, hit)
+}
@@ -0,0 +1,137 @@
+package mssql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// VectorMetric is a distance metric used by vector indexes, VECTOR_SEARCH
+// and VECTOR_DISTANCE.
+type VectorMetric string
+
+const (
+	VectorMetricCosine    VectorMetric = "cosine"
+	VectorMetricEuclidean VectorMetric = "euclidean"
+	VectorMetricDot       VectorMetric = "dot"
+)
+
+func (m VectorMetric) validate() error {
+	switch m {
+	case VectorMetricCosine, VectorMetricEuclidean, VectorMetricDot:
+		return nil
+	}
+	return fmt.Errorf("mssql: unsupported vector metric %q", string(m))
+}
+
+// VectorIndexDiskANN is the approximate nearest neighbor index type used by SQL Server.
+const VectorIndexDiskANN = "diskann"
+
+// VectorIndexOptions holds the optional settings of CreateVectorIndex.
+type VectorIndexOptions struct {
+	// Name is the name of the index. Defaults to <column>_vector_idx.
+	Name string
+	// Type is the index algorithm. Defaults to VectorIndexDiskANN, which
+	// is the only type SQL Server supports.
+	Type string
+	// MaxDOP limits the parallelism used to build the index. Zero leaves
+	// the server default.
+	MaxDOP int
+}
+
+// CreateVectorIndex returns a CREATE VECTOR INDEX statement for column of
+// table using the given distance metric. Queries only use the index when
+// they search with the same metric.
+//
+// table may be schema qualified, as in "dbo.docs". The parts of table and
+// column are quoted by CreateVectorIndex and must be passed unquoted.
+func CreateVectorIndex(table, column string, metric VectorMetric, opts *VectorIndexOptions) (string, error) {
+	if err := metric.validate(); err != nil {
+		return "", err
+	}
+	if table == "" || column == "" {
+		return "", fmt.Errorf("mssql: vector index requires a table and a column")
+	}
+	var o VectorIndexOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Name == "" {
+		o.Name = column + "_vector_idx"
+	}
+	if o.Type == "" {
+		o.Type = VectorIndexDiskANN
+	}
+	if !strings.EqualFold(o.Type, VectorIndexDiskANN) {
+		return "", fmt.Errorf("mssql: unsupported vector index type %q", o.Type)
+	}
+	if o.MaxDOP < 0 {
+		return "", fmt.Errorf("mssql: invalid vector index MaxDOP %d", o.MaxDOP)
+	}
+	q := TSQLQuoter{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE VECTOR INDEX %s ON %s (%s) WITH (METRIC = %s, TYPE = %s",
+		q.ID(o.Name), quoteMultipartName(table), q.ID(column), sqlString(string(metric)), sqlString(strings.ToLower(o.Type)))
+	if o.MaxDOP > 0 {
+		fmt.Fprintf(&b, ", MAXDOP = %d", o.MaxDOP)
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// VectorSearch describes an approximate nearest neighbor search of a table
+// with a vector index, run through the VECTOR_SEARCH table-valued function.
+type VectorSearch struct {
+	// Table is the searched table, optionally schema qualified, unquoted.
+	Table string
+	// Column is the indexed VECTOR column, unquoted.
+	Column string
+	// Metric must match the metric of the vector index on Column.
+	Metric VectorMetric
+	// Vector is the query vector. Its dimensions must match Column.
+	Vector Vector
+	// TopN is the number of nearest rows to return.
+	TopN int
+}
+
+// Query returns the text and arguments of a query returning the TopN rows
+// of Table nearest to Vector, ordered by distance. Each row holds the
+// columns of Table followed by a distance column.
+//
+// The query vector is sent as a parameter and assigned to a VECTOR
+// variable of the matching dimensions and element type, so it is never
+// embedded in the query text.
+func (s VectorSearch) Query() (string, []interface{}, error) {
+	if err := s.Metric.validate(); err != nil {
+		return "", nil, err
+	}
+	if s.Table == "" || s.Column == "" {
+		return "", nil, fmt.Errorf("mssql: vector search requires a table and a column")
+	}
+	if s.TopN <= 0 {
+		return "", nil, fmt.Errorf("mssql: vector search TopN must be positive, got %d", s.TopN)
+	}
+	v, err := NewVectorWithType(s.Vector.ElementType, s.Vector.Data)
+	if err != nil {
+		return "", nil, err
+	}
+	query := fmt.Sprintf(`DECLARE @query_vector %s = @vector;
+SELECT t.*, s.distance FROM VECTOR_SEARCH(
+	TABLE = %s AS t,
+	COLUMN = %s,
+	SIMILAR_TO = @query_vector,
+	METRIC = %s,
+	TOP_N = %d
+) AS s ORDER BY s.distance`,
+		v.SQLType(), quoteMultipartName(s.Table), TSQLQuoter{}.ID(s.Column), sqlString(string(s.Metric)), s.TopN)
+	return query, []interface{}{sql.Named("vector", v)}, nil
+}
+
+// quoteMultipartName quotes each dot separated part of name.
+func quoteMultipartName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = TSQLQuoter{}.ID(p)
+	}
+	return strings.Join(parts, ".")
+}
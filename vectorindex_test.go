@@ -0,0 +1,103 @@
+package mssql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateVectorIndex(t *testing.T) {
+	stmt, err := CreateVectorIndex("dbo.docs", "embedding", VectorMetricCosine, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE VECTOR INDEX [embedding_vector_idx] ON [dbo].[docs] ([embedding]) WITH (METRIC = 'cosine', TYPE = 'diskann')", stmt)
+
+	stmt, err = CreateVectorIndex("docs]", "emb", VectorMetricDot, &VectorIndexOptions{Name: "ix", Type: "DiskANN", MaxDOP: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE VECTOR INDEX [ix] ON [docs]]] ([emb]) WITH (METRIC = 'dot', TYPE = 'diskann', MAXDOP = 4)", stmt)
+
+	_, err = CreateVectorIndex("docs", "emb", VectorMetric("manhattan"), nil)
+	assert.Error(t, err, "unknown metric")
+	_, err = CreateVectorIndex("docs", "emb", VectorMetricCosine, &VectorIndexOptions{Type: "hnsw"})
+	assert.Error(t, err, "unknown index type")
+	_, err = CreateVectorIndex("docs", "emb", VectorMetricCosine, &VectorIndexOptions{MaxDOP: -1})
+	assert.Error(t, err, "negative MaxDOP")
+	_, err = CreateVectorIndex("", "emb", VectorMetricCosine, nil)
+	assert.Error(t, err, "missing table")
+}
+
+func TestVectorSearchQuery(t *testing.T) {
+	v, err := NewVectorWithType(VectorFloat16, []float32{1, 0})
+	require.NoError(t, err)
+	query, args, err := VectorSearch{Table: "dbo.docs", Column: "embedding", Metric: VectorMetricEuclidean, Vector: v, TopN: 5}.Query()
+	require.NoError(t, err)
+	assert.Contains(t, query, "DECLARE @query_vector VECTOR(2, float16) = @vector;")
+	assert.Contains(t, query, "TABLE = [dbo].[docs] AS t")
+	assert.Contains(t, query, "COLUMN = [embedding]")
+	assert.Contains(t, query, "METRIC = 'euclidean'")
+	assert.Contains(t, query, "TOP_N = 5")
+	assert.NotContains(t, query, "[1,0]", "query vector must be a parameter")
+	require.Len(t, args, 1)
+	assert.Equal(t, sql.Named("vector", v), args[0])
+
+	search := VectorSearch{Table: "docs", Column: "embedding", Metric: VectorMetricCosine, Vector: v, TopN: 5}
+	bad := search
+	bad.Metric = ""
+	_, _, err = bad.Query()
+	assert.Error(t, err, "missing metric")
+	bad = search
+	bad.TopN = 0
+	_, _, err = bad.Query()
+	assert.Error(t, err, "TopN must be positive")
+	bad = search
+	bad.Vector = Vector{}
+	_, _, err = bad.Query()
+	assert.Error(t, err, "empty query vector")
+}
+
+func TestVectorSearchIntegration(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	skipIfVectorUnsupported(t, conn)
+
+	table := "dbo.vector_search_test"
+	_, err := conn.Exec("DROP TABLE IF EXISTS " + table)
+	require.NoError(t, err)
+	_, err = conn.Exec("CREATE TABLE " + table + " (id int NOT NULL PRIMARY KEY CLUSTERED, embedding vector(2) NOT NULL)")
+	require.NoError(t, err)
+	defer conn.Exec("DROP TABLE IF EXISTS " + table)
+
+	for i, data := range [][]float32{{1, 0}, {0, 1}, {0.9, 0.1}} {
+		v, err := NewVector(data)
+		require.NoError(t, err)
+		_, err = conn.Exec("INSERT INTO "+table+" (id, embedding) VALUES (@p1, @p2)", i+1, v)
+		require.NoError(t, err)
+	}
+
+	stmt, err := CreateVectorIndex(table, "embedding", VectorMetricCosine, nil)
+	require.NoError(t, err)
+	if _, err = conn.Exec(stmt); err != nil {
+		t.Skip("vector indexes are not available on this server:", err)
+	}
+
+	v, err := NewVector([]float32{1, 0})
+	require.NoError(t, err)
+	query, args, err := VectorSearch{Table: table, Column: "embedding", Metric: VectorMetricCosine, Vector: v, TopN: 2}.Query()
+	require.NoError(t, err)
+	rows, err := conn.Query(query, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		var emb Vector
+		var distance float64
+		require.NoError(t, rows.Scan(&id, &emb, &distance))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1, 3}, ids)
+}
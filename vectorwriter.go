@@ -0,0 +1,134 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const defaultVectorWriterBatchSize = 1000
+
+// VectorWriterOptions configures a VectorWriter.
+type VectorWriterOptions struct {
+	// IDColumn and VectorColumn name the destination columns. They default
+	// to "id" and "embedding".
+	IDColumn     string
+	VectorColumn string
+	// BatchSize is the number of buffered rows that triggers a flush.
+	// Defaults to 1000.
+	BatchSize int
+	// Bulk holds the options of the bulk copy used to flush each batch.
+	Bulk BulkOptions
+}
+
+// VectorWriter buffers (id, vector) rows and writes them to a table with
+// bulk copy, one transaction per batch. It is not safe for concurrent use.
+type VectorWriter struct {
+	ctx     context.Context
+	db      *sql.DB
+	table   string
+	opts    VectorWriterOptions
+	ids     []interface{}
+	vectors []Vector
+	written int64
+	closed  bool
+}
+
+// NewVectorWriter returns a VectorWriter inserting rows into table of db.
+// ctx is used for every flush.
+func NewVectorWriter(ctx context.Context, db *sql.DB, table string, opts VectorWriterOptions) *VectorWriter {
+	if opts.IDColumn == "" {
+		opts.IDColumn = "id"
+	}
+	if opts.VectorColumn == "" {
+		opts.VectorColumn = "embedding"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultVectorWriterBatchSize
+	}
+	return &VectorWriter{ctx: ctx, db: db, table: table, opts: opts}
+}
+
+// Add buffers a row and flushes the buffer once it holds BatchSize rows.
+// All vectors added to a writer must have the same dimensions.
+func (w *VectorWriter) Add(id interface{}, v Vector) error {
+	if w.closed {
+		return errors.New("mssql: VectorWriter is closed")
+	}
+	if len(w.vectors) > 0 && v.Dimensions() != w.vectors[0].Dimensions() {
+		return fmt.Errorf("mssql: vector has %d dimensions, expected %d", v.Dimensions(), w.vectors[0].Dimensions())
+	}
+	w.ids = append(w.ids, id)
+	w.vectors = append(w.vectors, v)
+	if len(w.vectors) >= w.opts.BatchSize {
+		_, err := w.Flush()
+		return err
+	}
+	return nil
+}
+
+// Flush writes the buffered rows and returns the number of rows written.
+// If the bulk copy fails its transaction is rolled back and the rows stay
+// buffered, so Flush can be retried.
+func (w *VectorWriter) Flush() (int64, error) {
+	if len(w.vectors) == 0 {
+		return 0, nil
+	}
+	tx, err := w.db.BeginTx(w.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.copyIn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, fmt.Errorf("mssql: flushing %d vectors: %w", len(w.vectors), err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("mssql: flushing %d vectors: %w", len(w.vectors), err)
+	}
+	w.written += n
+	w.ids = w.ids[:0]
+	w.vectors = w.vectors[:0]
+	return n, nil
+}
+
+func (w *VectorWriter) copyIn(tx *sql.Tx) (int64, error) {
+	stmt, err := tx.PrepareContext(w.ctx, CopyIn(w.table, w.opts.Bulk, w.opts.IDColumn, w.opts.VectorColumn))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for i, v := range w.vectors {
+		if _, err = stmt.ExecContext(w.ctx, w.ids[i], v); err != nil {
+			return 0, err
+		}
+	}
+	res, err := stmt.ExecContext(w.ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Written returns the number of rows written by all successful flushes.
+func (w *VectorWriter) Written() int64 {
+	return w.written
+}
+
+// Buffered returns the number of rows waiting to be flushed.
+func (w *VectorWriter) Buffered() int {
+	return len(w.vectors)
+}
+
+// Close flushes the remaining rows. The writer cannot be used afterwards.
+func (w *VectorWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if _, err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
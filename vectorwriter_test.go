@@ -0,0 +1,109 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectorWriterBuffering(t *testing.T) {
+	w := NewVectorWriter(context.Background(), nil, "docs", VectorWriterOptions{})
+	assert.Equal(t, "id", w.opts.IDColumn)
+	assert.Equal(t, "embedding", w.opts.VectorColumn)
+	assert.Equal(t, defaultVectorWriterBatchSize, w.opts.BatchSize)
+
+	require.NoError(t, w.Add(1, Vector{Data: []float32{1, 2}}))
+	assert.Error(t, w.Add(2, Vector{Data: []float32{1, 2, 3}}), "dimensions must match")
+	assert.Equal(t, 1, w.Buffered())
+
+	empty := NewVectorWriter(context.Background(), nil, "docs", VectorWriterOptions{})
+	n, err := empty.Flush()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	require.NoError(t, empty.Close())
+	assert.Error(t, empty.Add(1, Vector{Data: []float32{1}}), "closed writer")
+}
+
+func createVectorWriterTable(t testing.TB, db *sql.DB, table string) {
+	_, err := db.Exec("DROP TABLE IF EXISTS " + table)
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE " + table + " (id int NOT NULL PRIMARY KEY, embedding vector(3) NOT NULL)")
+	require.NoError(t, err)
+}
+
+func TestVectorWriter(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+	skipIfVectorUnsupported(t, conn)
+
+	table := "dbo.vector_writer_test"
+	createVectorWriterTable(t, conn, table)
+	defer conn.Exec("DROP TABLE IF EXISTS " + table)
+
+	w := NewVectorWriter(testContext(t), conn, table, VectorWriterOptions{BatchSize: 10})
+	for i := 0; i < 25; i++ {
+		require.NoError(t, w.Add(i, Vector{Data: []float32{float32(i), 0.5, -1}}))
+	}
+	assert.Equal(t, int64(20), w.Written(), "two full batches flushed")
+	assert.Equal(t, 5, w.Buffered())
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(25), w.Written())
+
+	var count int
+	require.NoError(t, conn.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&count))
+	assert.Equal(t, 25, count)
+	var v Vector
+	require.NoError(t, conn.QueryRow("SELECT embedding FROM "+table+" WHERE id = 7").Scan(&v))
+	assert.Equal(t, []float32{7, 0.5, -1}, v.Data)
+
+	// A failed flush is rolled back and keeps its rows.
+	w = NewVectorWriter(testContext(t), conn, table, VectorWriterOptions{})
+	require.NoError(t, w.Add(7, Vector{Data: []float32{1, 1, 1}}))
+	_, err := w.Flush()
+	assert.Error(t, err, "duplicate key")
+	assert.Equal(t, 1, w.Buffered())
+	assert.Zero(t, w.Written())
+}
+
+func BenchmarkVectorInsert(b *testing.B) {
+	db := benchmarkDB(b)
+	var s string
+	if err := db.QueryRow("select cast(cast('[1]' as vector(1)) as nvarchar(max))").Scan(&s); err != nil {
+		b.Skip("VECTOR is not supported by this server:", err)
+	}
+	const rows = 500
+	table := "dbo.vector_writer_bench"
+	defer db.Exec("DROP TABLE IF EXISTS " + table)
+
+	b.Run("RowByRow", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			createVectorWriterTable(b, db, table)
+			for id := 0; id < rows; id++ {
+				if _, err := db.Exec("INSERT INTO "+table+" (id, embedding) VALUES (@p1, @p2)", id, Vector{Data: []float32{float32(id), 1, 2}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("VectorWriter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			createVectorWriterTable(b, db, table)
+			w := NewVectorWriter(context.Background(), db, table, VectorWriterOptions{BatchSize: 100})
+			for id := 0; id < rows; id++ {
+				if err := w.Add(id, Vector{Data: []float32{float32(id), 1, 2}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				b.Fatal(err)
+			}
+			if w.Written() != rows {
+				b.Fatalf("wrote %d rows, expected %d", w.Written(), rows)
+			}
+		}
+	})
+}
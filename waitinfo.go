@@ -0,0 +1,71 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SessionID returns the server process id (SPID) of the session, which is
+// the session_id of the dynamic management views. It is 0 before login.
+func (c *Conn) SessionID() int {
+	if c.sess == nil {
+		return 0
+	}
+	return int(c.sess.spid)
+}
+
+// SessionID returns the server process id of the session of conn, to be
+// passed to QueryWaitInfo from another connection.
+func SessionID(conn *sql.Conn) (int, error) {
+	var id int
+	err := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return errors.New("mssql: SessionID requires a connection of this driver")
+		}
+		id = c.SessionID()
+		return nil
+	})
+	return id, err
+}
+
+// WaitInfo describes what the request running in a session waits for.
+type WaitInfo struct {
+	SessionID int
+	// Status is the state of the request, such as running or suspended.
+	Status string
+	// Command is the kind of statement running, such as SELECT.
+	Command string
+	// WaitType, WaitTime and WaitResource describe the current wait of the
+	// request. WaitType is empty when it is not waiting.
+	WaitType     string
+	WaitTime     time.Duration
+	WaitResource string
+	// BlockingSessionID is the session holding what the request waits for,
+	// or 0 when it is not blocked.
+	BlockingSessionID int
+}
+
+const waitInfoQuery = `SELECT r.status, r.command, ISNULL(r.wait_type, N''), r.wait_time, r.wait_resource, ISNULL(r.blocking_session_id, 0)
+FROM sys.dm_exec_requests r WHERE r.session_id = @p1`
+
+// QueryWaitInfo returns the current wait of the request running in the
+// session sessionID, as returned by SessionID, and the session blocking
+// it. It returns sql.ErrNoRows when the session runs no request.
+//
+// db must not hold the session it looks at, whose connection is busy with
+// the request. Seeing the requests of other logins requires the VIEW SERVER
+// STATE permission, or VIEW DATABASE STATE on Azure SQL Database.
+func QueryWaitInfo(ctx context.Context, db *sql.DB, sessionID int) (WaitInfo, error) {
+	w := WaitInfo{SessionID: sessionID}
+	var waitMillis int64
+	err := db.QueryRowContext(ctx, waitInfoQuery, int16(sessionID)).Scan(
+		&w.Status, &w.Command, &w.WaitType, &waitMillis, &w.WaitResource, &w.BlockingSessionID)
+	if err != nil {
+		return WaitInfo{}, err
+	}
+	w.WaitTime = time.Duration(waitMillis) * time.Millisecond
+	return w, nil
+}
@@ -0,0 +1,74 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnSessionID(t *testing.T) {
+	assert.Zero(t, (&Conn{}).SessionID(), "before login")
+	assert.Equal(t, 53, (&Conn{sess: &tdsSession{spid: 53}}).SessionID())
+}
+
+func TestQueryWaitInfoOfBlockedQuery(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS dbo.wait_info_test; CREATE TABLE dbo.wait_info_test (id int NOT NULL PRIMARY KEY); INSERT INTO dbo.wait_info_test VALUES (1)")
+	require.NoError(t, err)
+	defer db.Exec("DROP TABLE IF EXISTS dbo.wait_info_test")
+
+	blocker, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer blocker.Close()
+	blocked, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer blocked.Close()
+
+	blockerID, err := SessionID(blocker)
+	require.NoError(t, err)
+	blockedID, err := SessionID(blocked)
+	require.NoError(t, err)
+	var spid int
+	require.NoError(t, blocked.QueryRowContext(ctx, "SELECT @@SPID").Scan(&spid))
+	assert.Equal(t, spid, blockedID, "the SPID of the login response")
+
+	_, err = QueryWaitInfo(ctx, db, blockedID)
+	assert.Equal(t, sql.ErrNoRows, err, "idle session")
+
+	tx, err := blocker.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "UPDATE dbo.wait_info_test SET id = 1 WHERE id = 1")
+	require.NoError(t, err)
+
+	queryCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		var id int
+		done <- blocked.QueryRowContext(queryCtx, "SELECT id FROM dbo.wait_info_test WHERE id = 1").Scan(&id)
+	}()
+
+	var w WaitInfo
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		w, err = QueryWaitInfo(ctx, db, blockedID)
+		if err == nil && w.BlockingSessionID != 0 {
+			break
+		}
+	}
+	cancel()
+	<-done
+	require.NoError(t, err)
+	assert.Equal(t, blockerID, w.BlockingSessionID)
+	assert.Equal(t, "LCK_M_S", w.WaitType)
+	assert.Equal(t, "suspended", w.Status)
+	assert.Equal(t, "SELECT", w.Command)
+	assert.NotEmpty(t, w.WaitResource)
+}
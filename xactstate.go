@@ -0,0 +1,65 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// XactState is the state of the transaction of a session, as returned by
+// XACT_STATE().
+type XactState int
+
+const (
+	// XactNone means the session has no open transaction: none was begun,
+	// or the server rolled it back after an error, as it does for most
+	// errors when XACT_ABORT is on. A *sql.Tx of the session can only be
+	// rolled back, which sends nothing, and its work must be done again.
+	XactNone XactState = 0
+	// XactCommittable means the transaction is open and may be committed.
+	XactCommittable XactState = 1
+	// XactUncommittable means an error doomed the transaction: it can only
+	// be rolled back. The server rolls back such a transaction at the end
+	// of the batch that doomed it, so it is only seen by the batch itself,
+	// such as in a CATCH block, and TransactionState reports XactNone.
+	XactUncommittable XactState = -1
+)
+
+func (s XactState) String() string {
+	switch s {
+	case XactNone:
+		return "none"
+	case XactCommittable:
+		return "committable"
+	case XactUncommittable:
+		return "uncommittable"
+	}
+	return "XactState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// TransactionState returns the state of the transaction of the session of
+// conn, to decide after an error whether the transaction may still be
+// committed or must be abandoned. Pass the *sql.Conn a *sql.Tx was begun
+// on with BeginTx. A transaction the server rolled back is reported as
+// XactNone without a request, as the driver rejects the requests of the
+// transaction once it knows of the rollback.
+func TransactionState(ctx context.Context, conn *sql.Conn) (XactState, error) {
+	var aborted bool
+	err := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return errors.New("mssql: TransactionState requires a connection of this driver")
+		}
+		aborted = c.sess != nil && c.inTransaction && c.sess.tranid == 0
+		return nil
+	})
+	if err != nil || aborted {
+		return XactNone, err
+	}
+	var state int
+	if err = conn.QueryRowContext(ctx, "SELECT XACT_STATE()").Scan(&state); err != nil {
+		return XactNone, err
+	}
+	return XactState(state), nil
+}
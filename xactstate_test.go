@@ -0,0 +1,100 @@
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXactStateString(t *testing.T) {
+	assert.Equal(t, "none", XactNone.String())
+	assert.Equal(t, "committable", XactCommittable.String())
+	assert.Equal(t, "uncommittable", XactUncommittable.String())
+	assert.Equal(t, "XactState(2)", XactState(2).String())
+}
+
+func TestTransactionStateAbortedByServer(t *testing.T) {
+	server := newRowServer(t)
+	connector, err := NewConnector(server.connString())
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the server answers SELECT XACT_STATE() with the connection number, 1
+	state, err := TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactCommittable, state)
+	assert.Equal(t, []int{1}, server.requestsPerConn())
+
+	// a transaction the server ended without a commit or rollback
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*Conn).inTransaction = true
+		return nil
+	}))
+	state, err = TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactNone, state)
+	assert.Equal(t, []int{1}, server.requestsPerConn(), "no request is sent")
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*Conn).inTransaction = false
+		return nil
+	}))
+}
+
+func TestTransactionState(t *testing.T) {
+	db, logger := open(t)
+	defer db.Close()
+	defer logger.StopLogging()
+	ctx := testContext(t)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "create table #xact (id int primary key)")
+	require.NoError(t, err)
+	state, err := TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactNone, state, "outside a transaction")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "insert into #xact values (1)")
+	require.NoError(t, err)
+	state, err = TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactCommittable, state)
+
+	// the batch sees the transaction doomed, and the server rolls it back
+	// at its end with error 3998
+	var inBatch int
+	_ = tx.QueryRowContext(ctx, `set xact_abort on;
+begin try
+	insert into #xact values (1);
+end try
+begin catch
+	select xact_state();
+end catch`).Scan(&inBatch)
+	assert.Equal(t, int(XactUncommittable), inBatch)
+	state, err = TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactNone, state, "after a doomed transaction")
+	assert.NoError(t, tx.Rollback())
+
+	tx, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "insert into #xact values (1)")
+	assert.Error(t, err, "XACT_ABORT is still on")
+	state, err = TransactionState(ctx, conn)
+	require.NoError(t, err)
+	assert.Equal(t, XactNone, state, "after an error with XACT_ABORT on")
+}
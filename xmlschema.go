@@ -0,0 +1,40 @@
+package mssql
+
+// XMLSchemaCollection names the XML schema collection validating a typed
+// xml column.
+type XMLSchemaCollection struct {
+	Database string
+	Schema   string
+	Name     string
+}
+
+// XMLSchemaCollections may be passed as a query argument to receive the XML
+// schema collection of each typed xml column of the result set. It holds
+// one entry per column, in the order of Rows.Columns, and the entries of
+// other columns, including untyped xml ones, are zero. It is filled in no
+// later than the first call to Rows.Next of each result set.
+//
+//	var schemas mssql.XMLSchemaCollections
+//	rows, err := db.Query("select id, doc from dbo.orders", &schemas)
+//	...
+//	for rows.Next() {
+//		...
+//	}
+//	log.Printf("doc is validated by %s.%s", schemas[1].Schema, schemas[1].Name)
+type XMLSchemaCollections []XMLSchemaCollection
+
+// newXMLSchemaCollections returns the XML schema collections of the xml
+// columns of a COLMETADATA token.
+func newXMLSchemaCollections(columns []columnStruct) XMLSchemaCollections {
+	res := make(XMLSchemaCollections, len(columns))
+	for i := range columns {
+		if info := columns[i].ti.XmlInfo; columns[i].ti.TypeId == typeXml && info.SchemaPresent != 0 {
+			res[i] = XMLSchemaCollection{
+				Database: info.DBName,
+				Schema:   info.OwningSchema,
+				Name:     info.XmlSchemaCollection,
+			}
+		}
+	}
+	return res
+}
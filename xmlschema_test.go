@@ -0,0 +1,111 @@
+package mssql
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// typedXMLResultStream returns the tokens of a result set with an int, a
+// typed xml and an untyped xml column, and one row.
+func typedXMLResultStream() []byte {
+	bVarChar := func(s string) []byte {
+		return append([]byte{byte(len(s))}, str2ucs2(s)...)
+	}
+	var stream []byte
+	stream = append(stream, byte(tokenColMetadata), 0x03, 0x00)
+	stream = append(stream, 0, 0, 0, 0, 0, 0, typeInt4)
+	stream = append(stream, bVarChar("id")...)
+	stream = append(stream, 0, 0, 0, 0, 0, 0, typeXml, 1)
+	stream = append(stream, bVarChar("db")...)
+	stream = append(stream, bVarChar("dbo")...)
+	stream = append(stream, byte(len("OrderSchema")), 0)
+	stream = append(stream, str2ucs2("OrderSchema")...)
+	stream = append(stream, bVarChar("doc")...)
+	stream = append(stream, 0, 0, 0, 0, 0, 0, typeXml, 0)
+	stream = append(stream, bVarChar("note")...)
+	// ROW: 1, <a/>, NULL
+	stream = append(stream, byte(tokenRow))
+	stream = binary.LittleEndian.AppendUint32(stream, 1)
+	doc := str2ucs2("<a/>")
+	stream = binary.LittleEndian.AppendUint64(stream, uint64(len(doc)))
+	stream = binary.LittleEndian.AppendUint32(stream, uint32(len(doc)))
+	stream = append(stream, doc...)
+	stream = append(stream, 0, 0, 0, 0)
+	stream = binary.LittleEndian.AppendUint64(stream, 0xffffffffffffffff)
+	return append(stream, doneToken(doneCount, 1)...)
+}
+
+func TestXMLSchemaCollectionsFromColMetadata(t *testing.T) {
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(typedXMLResultStream()))}),
+		logger: optionalLogger{},
+	}
+	var schemas XMLSchemaCollections
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(context.Background(), sess, ch, outputs{xmlSchemas: &schemas})
+	var got XMLSchemaCollections
+	rows := 0
+	for tok := range ch {
+		switch tok := tok.(type) {
+		case error:
+			t.Fatal(tok)
+		case XMLSchemaCollections:
+			assert.Zero(t, rows, "the schema collections are sent ahead of the rows")
+			got = tok
+		case []interface{}:
+			rows++
+			assert.Equal(t, []interface{}{int64(1), "<a/>", nil}, tok)
+		}
+	}
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, XMLSchemaCollections{{}, {Database: "db", Schema: "dbo", Name: "OrderSchema"}, {}}, got)
+}
+
+func TestXMLSchemaCollectionsNotRequested(t *testing.T) {
+	sess := &tdsSession{
+		buf:    newTdsBuffer(defaultPacketSize, closableBuffer{bytes.NewBuffer(makeReplyPacket(typedXMLResultStream()))}),
+		logger: optionalLogger{},
+	}
+	ch := make(chan tokenStruct, 10)
+	processSingleResponse(context.Background(), sess, ch, outputs{})
+	for tok := range ch {
+		_, ok := tok.(XMLSchemaCollections)
+		assert.False(t, ok, "XMLSchemaCollections sent without being requested")
+	}
+}
+
+func TestXMLSchemaCollectionsOfTypedColumn(t *testing.T) {
+	conn, logger := open(t)
+	defer conn.Close()
+	defer logger.StopLogging()
+
+	_, err := conn.Exec(`if object_id('dbo.go_mssql_typed_xml') is not null drop table dbo.go_mssql_typed_xml;
+if exists (select * from sys.xml_schema_collections where name = 'go_mssql_order_schema') drop xml schema collection dbo.go_mssql_order_schema`)
+	require.NoError(t, err)
+	_, err = conn.Exec(`create xml schema collection dbo.go_mssql_order_schema as N'<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="order" type="xs:int"/></xs:schema>'`)
+	require.NoError(t, err)
+	defer conn.Exec("drop xml schema collection dbo.go_mssql_order_schema")
+	_, err = conn.Exec("create table dbo.go_mssql_typed_xml (id int, doc xml(dbo.go_mssql_order_schema), note xml)")
+	require.NoError(t, err)
+	defer conn.Exec("drop table dbo.go_mssql_typed_xml")
+	_, err = conn.Exec("insert into dbo.go_mssql_typed_xml values (1, N'<order>5</order>', N'<a/>')")
+	require.NoError(t, err)
+
+	var dbName string
+	require.NoError(t, conn.QueryRow("select db_name()").Scan(&dbName))
+
+	var schemas XMLSchemaCollections
+	rows, err := conn.Query("select id, doc, note from dbo.go_mssql_typed_xml", &schemas)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	expected := XMLSchemaCollections{{}, {Database: dbName, Schema: "dbo", Name: "go_mssql_order_schema"}, {}}
+	assert.Equal(t, expected, schemas)
+}